@@ -0,0 +1,208 @@
+package lys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lyspgmon/stores/lyspgauditupdate"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+// AuditRevertInput is the request body of RevertFromAudit
+type AuditRevertInput struct {
+	AsOf  lystype.Datetime `json:"as_of"`
+	Field string           `json:"field"` // optional: db name of the only field to revert
+}
+
+// AuditOptions are optional settings of GetAuditHistory
+type AuditOptions struct {
+	PkColName string // db name of the table's primary key column, used with the "as_of" param. Default "id"
+}
+
+// iAuditHistory is a store that can be used by GetAuditHistory and GetAuditDiff, such as lyspgauditupdate.Store.
+type iAuditHistory interface {
+	SelectHistory(ctx context.Context, affectedSchema, affectedTable, affectedId string) (items []lyspgauditupdate.Model, err error)
+	SelectDiff(ctx context.Context, affectedSchema, affectedTable, affectedId string, fromId, toId int64) (diffs []lyspgauditupdate.FieldDiff, err error)
+	SelectStateAsOf(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time) (state map[string]any, exists bool, err error)
+}
+
+// iAuditRevert is a store that can be used by RevertFromAudit, such as lyspgauditupdate.Store.
+type iAuditRevert interface {
+	Revert(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time, field, userName string) (reverted map[string]any, err error)
+}
+
+// parseAsOf parses the as_of param, which may be in RFC3339 or lystype.DatetimeFormat
+func parseAsOf(asOfStr string) (asOf time.Time, err error) {
+
+	for _, layout := range []string{time.RFC3339, lystype.DatetimeFormat} {
+		asOf, err = time.Parse(layout, asOfStr)
+		if err == nil {
+			return asOf, nil
+		}
+	}
+
+	return time.Time{}, lyserr.User{Message: "invalid as_of param"}
+}
+
+// GetAuditHistory handles retrieval of the audit entries of a single item in schemaName.tableName, oldest first.
+// If the "as_of" param is sent, the item's state at that time is returned instead, reconstructed from the current row and the audit entries.
+// The table must have the t_audit_update trigger (see lyspgmon.CheckDb).
+func GetAuditHistory[idT lyspg.PrimaryKeyType](env Env, store iAuditHistory, schemaName, tableName string, options ...AuditOptions) http.HandlerFunc {
+
+	pkColName := "id"
+	if len(options) > 0 && options[0].PkColName != "" {
+		pkColName = options[0].PkColName
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// get the id param and parse it into an idT
+		id, err := getIdFromReq[idT](r)
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("GetAuditHistory: getIdFromReq failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// return state at a point in time
+		if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+
+			asOf, err := parseAsOf(asOfStr)
+			if err != nil {
+				HandleError(ctx, fmt.Errorf("GetAuditHistory: parseAsOf failed: %w", err), env.Logger, w)
+				return
+			}
+
+			state, exists, err := store.SelectStateAsOf(ctx, schemaName, tableName, pkColName, id, asOf)
+			if err != nil {
+				HandleError(ctx, fmt.Errorf("GetAuditHistory: store.SelectStateAsOf failed: %w", err), env.Logger, w)
+				return
+			}
+			if !exists {
+				HandleError(ctx, lyspgauditupdate.ErrNotExistAsOf, env.Logger, w)
+				return
+			}
+
+			resp := StdResponse{
+				Status: ReqSucceeded,
+				Data:   state,
+			}
+			JsonResponse(resp, http.StatusOK, w)
+			return
+		}
+
+		// select history from db
+		items, err := store.SelectHistory(ctx, schemaName, tableName, fmt.Sprint(id))
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("GetAuditHistory: store.SelectHistory failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// success
+		resp := StdResponse{
+			Status: ReqSucceeded,
+			Data:   items,
+		}
+		JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// GetAuditDiff handles retrieval of the field changes of a single item in schemaName.tableName between two versions.
+// Versions are identified by audit entry ids, sent in the "from" (optional: omit to diff from before the first entry) and "to" params.
+func GetAuditDiff[idT lyspg.PrimaryKeyType](env Env, store iAuditHistory, schemaName, tableName string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// get the id param and parse it into an idT
+		id, err := getIdFromReq[idT](r)
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("GetAuditDiff: getIdFromReq failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// get the version params
+		var fromId int64
+		if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+			fromId, err = strconv.ParseInt(fromStr, 10, 64)
+			if err != nil {
+				HandleError(ctx, lyserr.User{Message: "invalid from param"}, env.Logger, w)
+				return
+			}
+		}
+		toId, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		if err != nil {
+			HandleError(ctx, lyserr.User{Message: "missing or invalid to param"}, env.Logger, w)
+			return
+		}
+
+		// select diff from db
+		diffs, err := store.SelectDiff(ctx, schemaName, tableName, fmt.Sprint(id), fromId, toId)
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("GetAuditDiff: store.SelectDiff failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// success
+		resp := StdResponse{
+			Status: ReqSucceeded,
+			Data:   diffs,
+		}
+		JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// RevertFromAudit handles reverting a single item in schemaName.tableName, or one of its fields, to its state at a point in time, using the AuditRevertInput body.
+// The update sets last_user_update_by to the user name from ctx (see GetUserNameFromCtx). The reverted fields and values are returned.
+func RevertFromAudit[idT lyspg.PrimaryKeyType](env Env, store iAuditRevert, schemaName, tableName, pkColName string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// get the id param and parse it into an idT
+		id, err := getIdFromReq[idT](r)
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("RevertFromAudit: getIdFromReq failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// get req body
+		body, err := ExtractJsonBody(r, env.PostOptions.MaxBodySize)
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("RevertFromAudit: ExtractJsonBody failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// unmarshal the body
+		var input AuditRevertInput
+		err = json.Unmarshal(body, &input)
+		if err != nil {
+			HandleUserError(ErrInvalidJson, w)
+			return
+		}
+		if time.Time(input.AsOf).IsZero() {
+			HandleUserError(lyserr.User{Message: "as_of missing"}, w)
+			return
+		}
+
+		// revert the item in db
+		reverted, err := store.Revert(ctx, schemaName, tableName, pkColName, id, time.Time(input.AsOf), input.Field, GetUserNameFromCtx(ctx))
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("RevertFromAudit: store.Revert failed: %w", err), env.Logger, w)
+			return
+		}
+
+		// success
+		resp := StdResponse{
+			Status: ReqSucceeded,
+			Data:   reverted,
+		}
+		JsonResponse(resp, http.StatusOK, w)
+	}
+}
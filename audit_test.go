@@ -0,0 +1,192 @@
+package lys
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loveyourstack/lys/lyspgmon/stores/lyspgauditupdate"
+	"github.com/loveyourstack/lys/lystype"
+	"github.com/stretchr/testify/assert"
+)
+
+// memAuditStore serves the history of core.order record "1"
+type memAuditStore struct {
+	history   []lyspgauditupdate.Model
+	pkColName *string // if set, receives the pkColName passed to SelectStateAsOf or Revert
+}
+
+func (s memAuditStore) SelectHistory(ctx context.Context, affectedSchema, affectedTable, affectedId string) (items []lyspgauditupdate.Model, err error) {
+	if affectedSchema != "core" || affectedTable != "order" || affectedId != "1" {
+		return nil, nil
+	}
+	return s.history, nil
+}
+
+func (s memAuditStore) SelectDiff(ctx context.Context, affectedSchema, affectedTable, affectedId string, fromId, toId int64) (diffs []lyspgauditupdate.FieldDiff, err error) {
+	history, _ := s.SelectHistory(ctx, affectedSchema, affectedTable, affectedId)
+	return lyspgauditupdate.DiffVersions(history, fromId, toId)
+}
+
+func (s memAuditStore) SelectStateAsOf(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time) (state map[string]any, exists bool, err error) {
+	if s.pkColName != nil {
+		*s.pkColName = pkColName
+	}
+	history, _ := s.SelectHistory(ctx, affectedSchema, affectedTable, fmt.Sprint(pkVal))
+	current := map[string]any{"id": 1.0, "status": "closed", "amount": 5.0}
+	state, exists = lyspgauditupdate.Reconstruct(current, true, history, asOf)
+	return state, exists, nil
+}
+
+func (s memAuditStore) Revert(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time, field, userName string) (reverted map[string]any, err error) {
+	if s.pkColName != nil {
+		*s.pkColName = pkColName
+	}
+	state, exists, _ := s.SelectStateAsOf(ctx, affectedSchema, affectedTable, pkColName, pkVal, asOf)
+	if !exists {
+		return nil, lyspgauditupdate.ErrNotExistAsOf
+	}
+	current := map[string]any{"id": 1.0, "status": "closed", "amount": 5.0}
+	return lyspgauditupdate.RevertAssignments(current, state, pkColName), nil
+}
+
+func newMemAuditStore() memAuditStore {
+	return memAuditStore{history: []lyspgauditupdate.Model{
+		{Id: 10, AffectedAt: lystype.Datetime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)), AffectedOp: lyspgauditupdate.OpInsert, AffectedOldValues: map[string]any{}, AffectedNewValues: map[string]any{"status": "open", "amount": 5.0}},
+		{Id: 12, AffectedAt: lystype.Datetime(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)), AffectedOp: lyspgauditupdate.OpUpdate, AffectedOldValues: map[string]any{"status": "open"}, AffectedNewValues: map[string]any{"status": "closed"}},
+	}}
+}
+
+func TestGetAuditHistory(t *testing.T) {
+	env := Env{Logger: discardLog}
+	handler := GetAuditHistory[int64](env, newMemAuditStore(), "core", "order")
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/1/history", nil), map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp := decodeStdResponse(t, w)
+	assert.Equal(t, ReqSucceeded, resp.Status)
+	assert.Len(t, resp.Data, 2)
+
+	// id must parse as idT
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/a/history", nil), map[string]string{"id": "a"})
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetAuditHistoryAsOf(t *testing.T) {
+	env := Env{Logger: discardLog}
+	handler := GetAuditHistory[int64](env, newMemAuditStore(), "core", "order")
+
+	tests := []struct {
+		asOf            string
+		wantStatus      int
+		wantStatusField any
+	}{
+		{"2026-01-15T00:00:00Z", http.StatusOK, "open"},
+		{"2026-03-01 00:00:00+00", http.StatusOK, "closed"},
+		{"2025-12-01T00:00:00Z", http.StatusNotFound, nil}, // before insert
+		{"yesterday", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1/history?"+url.Values{"as_of": {tt.asOf}}.Encode(), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.asOf)
+		if tt.wantStatus == http.StatusOK {
+			state, _ := decodeStdResponse(t, w).Data.(map[string]any)
+			assert.Equal(t, tt.wantStatusField, state["status"], tt.asOf)
+		}
+	}
+}
+
+func TestGetAuditHistoryPkColName(t *testing.T) {
+	env := Env{Logger: discardLog}
+
+	tests := []struct {
+		options []AuditOptions
+		want    string
+	}{
+		{nil, "id"},
+		{[]AuditOptions{{}}, "id"},
+		{[]AuditOptions{{PkColName: "order_id"}}, "order_id"},
+	}
+	for _, tt := range tests {
+		store := newMemAuditStore()
+		var pkColName string
+		store.pkColName = &pkColName
+		handler := GetAuditHistory[int64](env, store, "core", "order", tt.options...)
+
+		req := httptest.NewRequest(http.MethodGet, "/orders/1/history?as_of=2026-01-15T00:00:00Z", nil)
+		w := httptest.NewRecorder()
+		handler(w, mux.SetURLVars(req, map[string]string{"id": "1"}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tt.want, pkColName)
+	}
+}
+
+func TestRevertFromAudit(t *testing.T) {
+	env := Env{Logger: discardLog, PostOptions: PostOptions{MaxBodySize: 1024}}
+	handler := RevertFromAudit[int64](env, newMemAuditStore(), "core", "order", "id")
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"as_of":"2026-01-15 00:00:00+00"}`, http.StatusOK},
+		{`{"as_of":"2025-12-01 00:00:00+00"}`, http.StatusNotFound}, // before insert
+		{`{}`, http.StatusBadRequest},
+		{`x`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/orders/1/revert", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, mux.SetURLVars(req, map[string]string{"id": "1"}))
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.body)
+		if tt.wantStatus == http.StatusOK {
+			assert.Equal(t, map[string]any{"status": "open"}, decodeStdResponse(t, w).Data, tt.body)
+		}
+	}
+}
+
+func TestGetAuditDiff(t *testing.T) {
+	env := Env{Logger: discardLog}
+	handler := GetAuditDiff[int64](env, newMemAuditStore(), "core", "order")
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantLen    int
+	}{
+		{"to=12", http.StatusOK, 2},         // from before insert: amount and status
+		{"from=10&to=12", http.StatusOK, 1}, // status only
+		{"from=12&to=10", http.StatusBadRequest, 0},
+		{"from=11&to=12", http.StatusNotFound, 0},
+		{"from=x&to=12", http.StatusBadRequest, 0},
+		{"", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/1/diff?"+tt.query, nil), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.query)
+		if tt.wantStatus == http.StatusOK {
+			assert.Len(t, decodeStdResponse(t, w).Data, tt.wantLen, tt.query)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lystrace"
 )
 
 // HandleInternalError returns a generic error message to the API user and logs the error
@@ -20,6 +21,7 @@ func HandleInternalError(ctx context.Context, err error, logger *slog.Logger, w
 		Status:         ReqFailed,
 		ErrDescription: "An internal error occurred",
 	}
+	setErrCategory(w, ErrCategoryInternal)
 	JsonResponse(resp, http.StatusInternalServerError, w)
 
 	logError(ctx, err, logger)
@@ -36,6 +38,7 @@ func HandleUserError(err lyserr.User, w http.ResponseWriter) {
 		Status:         ReqFailed,
 		ErrDescription: err.Message,
 	}
+	setErrCategory(w, ErrCategoryUser)
 	JsonResponse(resp, err.StatusCode, w)
 }
 
@@ -46,6 +49,7 @@ func HandleExtError(ctx context.Context, extMessage string, err error, logger *s
 		Status:         ReqFailed,
 		ErrDescription: extMessage,
 	}
+	setErrCategory(w, ErrCategoryExt)
 	JsonResponse(resp, http.StatusBadGateway, w) // BadGateway is used to indicate that the error was caused by a 3rd party API call
 
 	logError(ctx, err, logger)
@@ -108,6 +112,7 @@ func HandleDbError(ctx context.Context, line int, stmt string, err error, logger
 		Status:         ReqFailed,
 		ErrDescription: fmt.Sprintf("%sA database error occurred", lineTxt),
 	}
+	setErrCategory(w, ErrCategoryDb)
 	JsonResponse(resp, http.StatusInternalServerError, w)
 
 	extra := []slog.Attr{slog.String("stmt", stmt)}
@@ -164,11 +169,15 @@ func HandleError(ctx context.Context, err error, logger *slog.Logger, w http.Res
 	HandleInternalError(ctx, err, logger, w)
 }
 
-// logError is a helper function to log errors with user information and any extra attributes
+// logError is a helper function to log errors with user information, request ID (if any) and any extra attributes
 func logError(ctx context.Context, err error, logger *slog.Logger, extra ...slog.Attr) {
 	args := []any{slog.String("user", GetUserNameFromCtx(ctx))}
+	if id := GetRequestIdFromCtx(ctx); id != "" {
+		args = append(args, slog.String("request_id", id))
+	}
 	for _, a := range extra {
 		args = append(args, a)
 	}
-	logger.Error(err.Error(), args...)
+	logger.ErrorContext(ctx, err.Error(), args...)
+	lystrace.SpanFromCtx(ctx).SetError(err)
 }
@@ -0,0 +1,184 @@
+package lys
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+// filterDatetimeFormat is the format of datetime filter values sent to Postgres. Unlike lystype.DatetimeFormat, it keeps fractional seconds
+const filterDatetimeFormat string = "2006-01-02 15:04:05.999999-07"
+
+// filterValueParsers contains the funcs which parse a filter value of a lystype or netip field and return its normalized form for Postgres.
+// Filter values of other types are passed to Postgres unchanged
+var filterValueParsers = map[reflect.Type]func(s string, loc *time.Location) (string, error){
+	reflect.TypeFor[lystype.Date](): func(s string, loc *time.Location) (string, error) {
+		// only relative dates are converted: other date values are passed to Postgres unchanged
+		ti, ok, err := parseRelativeDate(s, loc)
+		if !ok || err != nil {
+			return s, err
+		}
+		return ti.Format(lystype.DateFormat), nil
+	},
+	reflect.TypeFor[lystype.Datetime](): func(s string, loc *time.Location) (string, error) {
+		ti, ok, err := parseRelativeDate(s, loc)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			// without a requested timezone, values are passed to Postgres unchanged, so values without offset are in the db session's timezone
+			if loc == nil {
+				return s, nil
+			}
+			dt, err := lystype.ParseDatetime(s, loc)
+			if err != nil {
+				return "", err
+			}
+			ti = dt.ToTime()
+		}
+		return ti.UTC().Format(filterDatetimeFormat), nil
+	},
+	reflect.TypeFor[lystype.Decimal](): func(s string, loc *time.Location) (string, error) {
+		d, err := lystype.ParseDecimal(s)
+		return d.String(), err
+	},
+	reflect.TypeFor[lystype.Money](): func(s string, loc *time.Location) (string, error) {
+		m, err := lystype.ParseMoney(s)
+		return m.String(), err
+	},
+	reflect.TypeFor[lystype.Interval](): func(s string, loc *time.Location) (string, error) {
+		iv, err := lystype.ParseInterval(s)
+		return iv.String(), err
+	},
+	reflect.TypeFor[netip.Prefix](): func(s string, loc *time.Location) (string, error) {
+		// inet values may be sent without prefix length
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			return addr.String(), err
+		}
+		p, err := netip.ParsePrefix(s)
+		return p.String(), err
+	},
+	reflect.TypeFor[lystype.DateRange](): func(s string, loc *time.Location) (string, error) {
+		r, err := lystype.ParseRange[lystype.Date](s, loc)
+		return r.String(), err
+	},
+	reflect.TypeFor[lystype.DatetimeRange](): func(s string, loc *time.Location) (string, error) {
+		r, err := lystype.ParseRange[lystype.Datetime](s, loc)
+		return r.String(), err
+	},
+}
+
+// rangeElementParsers contains the funcs which parse a single element of a range field, returning the range which contains only that element
+var rangeElementParsers = map[reflect.Type]func(s string, loc *time.Location) (string, error){
+	reflect.TypeFor[lystype.DateRange](): func(s string, loc *time.Location) (string, error) {
+		return rangeOfElement[lystype.Date](s, loc)
+	},
+	reflect.TypeFor[lystype.DatetimeRange](): func(s string, loc *time.Location) (string, error) {
+		return rangeOfElement[lystype.Datetime](s, loc)
+	},
+}
+
+// rangeOfElement returns the range literal containing only the element s, e.g. "[2024-01-01,2024-01-01]"
+func rangeOfElement[T lystype.RangeBound](s string, loc *time.Location) (string, error) {
+	bound, err := lystype.ParseRangeBound[T](s, loc)
+	if err != nil {
+		return "", err
+	}
+	if bound.IsZero() {
+		return "", fmt.Errorf("empty range element")
+	}
+	return lystype.Range[T]{Lower: bound, Upper: bound, LowerInc: true, UpperInc: true}.String(), nil
+}
+
+// parseRelativeDate returns the start of the day in loc (UTC if nil) of a relative date such as "{today}", "{today-7}" or "{today+1}", or the current time for "{now}".
+// ok is false if s is not a relative date
+func parseRelativeDate(s string, loc *time.Location) (ti time.Time, ok bool, err error) {
+
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return time.Time{}, false, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	now := time.Now().In(loc)
+	expr := s[1 : len(s)-1]
+
+	if expr == "now" {
+		return now, true, nil
+	}
+
+	days := 0
+	if offset, found := strings.CutPrefix(expr, "today"); found && offset != "" {
+		if offset[0] != '+' && offset[0] != '-' {
+			return time.Time{}, true, fmt.Errorf("invalid relative date: %s", s)
+		}
+		if days, err = strconv.Atoi(offset); err != nil {
+			return time.Time{}, true, fmt.Errorf("invalid relative date: %s", s)
+		}
+	} else if !found {
+		return time.Time{}, true, fmt.Errorf("invalid relative date: %s", s)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day()+days, 0, 0, 0, 0, loc), true, nil
+}
+
+// isRangeLiteral returns true if s is a Postgres range literal rather than a single element
+func isRangeLiteral(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.EqualFold(s, "empty") || (s != "" && strings.ContainsRune("[(", rune(s[0])))
+}
+
+// convertFilterValues validates and normalizes the values of cond according to the Go type of the filtered field (key)
+// Equals filters on range fields with a single element, e.g. "2024-01-01", become "contains element" filters
+// Datetime values are interpreted in loc and converted to UTC. Relative dates such as "{today-7}" are resolved in loc
+func convertFilterValues(cond lyspg.Condition, key string, reflType reflect.Type, loc *time.Location) (lyspg.Condition, error) {
+
+	if reflType == nil {
+		return cond, nil
+	}
+	if reflType.Kind() == reflect.Pointer {
+		reflType = reflType.Elem()
+	}
+
+	parse, ok := filterValueParsers[reflType]
+	if !ok {
+		return cond, nil
+	}
+
+	// only convert operators which compare values: text search, empty and null operators are left unchanged
+	switch cond.Operator {
+	case lyspg.OpEquals:
+		if parseElement, ok := rangeElementParsers[reflType]; ok && !isRangeLiteral(cond.Value) {
+			cond.Operator = lyspg.OpRangeContains
+			parse = parseElement
+		}
+	case lyspg.OpNotEquals, lyspg.OpLessThan, lyspg.OpLessThanEquals, lyspg.OpGreaterThan, lyspg.OpGreaterThanEquals, lyspg.OpIn, lyspg.OpNotIn:
+	default:
+		return cond, nil
+	}
+
+	var err error
+	if cond.Operator == lyspg.OpIn || cond.Operator == lyspg.OpNotIn {
+		for i, val := range cond.InValues {
+			if cond.InValues[i], err = parse(val, loc); err != nil {
+				return lyspg.Condition{}, lyserr.User{Message: "invalid value in filter field " + key + ": " + val}
+			}
+		}
+		return cond, nil
+	}
+
+	val := cond.Value
+	if cond.Value, err = parse(val, loc); err != nil {
+		return lyspg.Condition{}, lyserr.User{Message: "invalid value in filter field " + key + ": " + val}
+	}
+	return cond, nil
+}
@@ -0,0 +1,170 @@
+package lys
+
+import (
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var filterValuesJsonKeyDbNameMap = map[string]string{
+	"amount":    "amount_db",
+	"date":      "date_db",
+	"dt":        "dt_db",
+	"dec":       "dec_db",
+	"dr":        "dr_db",
+	"dtr":       "dtr_db",
+	"duration":  "duration_db",
+	"ip":        "ip_db",
+	"name":      "name_db",
+	"opt_price": "opt_price_db",
+}
+
+var filterValuesJsonKeyTypeMap = map[string]reflect.Type{
+	"amount":    reflect.TypeFor[lystype.Money](),
+	"date":      reflect.TypeFor[lystype.Date](),
+	"dt":        reflect.TypeFor[*lystype.Datetime](),
+	"dec":       reflect.TypeFor[lystype.Decimal](),
+	"dr":        reflect.TypeFor[lystype.DateRange](),
+	"dtr":       reflect.TypeFor[lystype.DatetimeRange](),
+	"duration":  reflect.TypeFor[lystype.Interval](),
+	"ip":        reflect.TypeFor[netip.Prefix](),
+	"name":      reflect.TypeFor[string](),
+	"opt_price": reflect.TypeFor[*lystype.Decimal](),
+}
+
+func mustExtractTypedFilter(t testing.TB, key, val string, loc *time.Location) lyspg.Condition {
+	t.Helper()
+
+	urlValues := url.Values{}
+	urlValues.Add(key, val)
+	conds, err := ExtractFiltersWithOptions(urlValues, filterValuesJsonKeyDbNameMap, nil, nil, mustFillGetOptions(t, GetOptions{}), FilterOptions{JsonKeyTypeMap: filterValuesJsonKeyTypeMap, Location: loc})
+	require.NoError(t, err)
+	require.Len(t, conds, 1)
+	return conds[0]
+}
+
+func TestExtractFiltersTypedValuesSuccess(t *testing.T) {
+
+	tests := []struct {
+		name string
+		key  string
+		val  string
+		cond lyspg.Condition
+	}{
+		{"decimal", "dec", ">1.5e3", lyspg.Condition{Field: "dec_db", Operator: lyspg.OpGreaterThan, Value: "1500"}},
+		{"decimal pointer", "opt_price", "!0.10", lyspg.Condition{Field: "opt_price_db", Operator: lyspg.OpNotEquals, Value: "0.10"}},
+		{"decimal in", "dec", "1|2.5", lyspg.Condition{Field: "dec_db", Operator: lyspg.OpIn, InValues: []string{"1", "2.5"}}},
+		{"money", "amount", "<eq1234.5", lyspg.Condition{Field: "amount_db", Operator: lyspg.OpLessThanEquals, Value: "1234.50"}},
+		{"interval", "duration", ">1h30m", lyspg.Condition{Field: "duration_db", Operator: lyspg.OpGreaterThan, Value: "PT1H30M"}},
+		{"inet address", "ip", "10.0.0.1", lyspg.Condition{Field: "ip_db", Operator: lyspg.OpEquals, Value: "10.0.0.1"}},
+		{"cidr", "ip", "!10.0.0.0/8", lyspg.Condition{Field: "ip_db", Operator: lyspg.OpNotEquals, Value: "10.0.0.0/8"}},
+		{"date range literal", "dr", "[2024-01-01,2024-02-01)", lyspg.Condition{Field: "dr_db", Operator: lyspg.OpEquals, Value: "[2024-01-01,2024-02-01)"}},
+		{"date range element", "dr", "2024-01-15", lyspg.Condition{Field: "dr_db", Operator: lyspg.OpRangeContains, Value: "[2024-01-15,2024-01-15]"}},
+		{"datetime range element", "dtr", "2024-01-15 10:00:00+00", lyspg.Condition{Field: "dtr_db", Operator: lyspg.OpRangeContains, Value: `["2024-01-15 10:00:00+00","2024-01-15 10:00:00+00"]`}},
+		{"text operator unchanged", "dec", "~1.5~", lyspg.Condition{Field: "dec_db", Operator: lyspg.OpContains, Value: "1.5"}},
+		{"null operator unchanged", "dr", "{null}", lyspg.Condition{Field: "dr_db", Operator: lyspg.OpNull, Value: ""}},
+		{"other type unchanged", "name", "1.5e3", lyspg.Condition{Field: "name_db", Operator: lyspg.OpEquals, Value: "1.5e3"}},
+	}
+
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.cond, mustExtractTypedFilter(t, tt.key, tt.val, nil), tt.name)
+	}
+}
+
+func TestExtractFiltersTypedValuesFailure(t *testing.T) {
+
+	tests := map[string]string{
+		"dec":      "abc",
+		"amount":   "12abc",
+		"duration": "1 day",
+		"ip":       "10.0.0.256",
+		"dr":       "[2024-01-01,x)",
+		"dtr":      "2024-01-15x",
+		"date":     "{tomorrow}",
+		"dt":       "{today*2}",
+	}
+
+	getOptions := mustFillGetOptions(t, GetOptions{})
+	for key, val := range tests {
+		urlValues := url.Values{}
+		urlValues.Add(key, val)
+		_, err := ExtractFiltersWithOptions(urlValues, filterValuesJsonKeyDbNameMap, nil, nil, getOptions, FilterOptions{JsonKeyTypeMap: filterValuesJsonKeyTypeMap})
+		assert.EqualValues(t, "invalid value in filter field "+key+": "+val, err.Error(), key)
+	}
+
+	// in value
+	urlValues := url.Values{}
+	urlValues.Add("dec", "!1|x")
+	_, err := ExtractFiltersWithOptions(urlValues, filterValuesJsonKeyDbNameMap, nil, nil, getOptions, FilterOptions{JsonKeyTypeMap: filterValuesJsonKeyTypeMap})
+	assert.EqualValues(t, "invalid value in filter field dec: x", err.Error())
+}
+
+func TestExtractFiltersDatetimeValuesSuccess(t *testing.T) {
+
+	loc := time.FixedZone("UTC-3", -3*3600)
+
+	tests := []struct {
+		name string
+		key  string
+		val  string
+		loc  *time.Location
+		cond lyspg.Condition
+	}{
+		{"datetime without tz unchanged", "dt", ">2024-01-15 10:00", nil, lyspg.Condition{Field: "dt_db", Operator: lyspg.OpGreaterThan, Value: "2024-01-15 10:00"}},
+		{"datetime in tz", "dt", ">2024-01-15 10:00", loc, lyspg.Condition{Field: "dt_db", Operator: lyspg.OpGreaterThan, Value: "2024-01-15 13:00:00+00"}},
+		{"datetime with offset", "dt", "<2024-01-15 10:00:00.5+01", loc, lyspg.Condition{Field: "dt_db", Operator: lyspg.OpLessThan, Value: "2024-01-15 09:00:00.5+00"}},
+		{"date in tz", "dt", "<eq2024-01-15", loc, lyspg.Condition{Field: "dt_db", Operator: lyspg.OpLessThanEquals, Value: "2024-01-15 03:00:00+00"}},
+		{"datetime range element in tz", "dtr", "2024-01-15 10:00", loc, lyspg.Condition{Field: "dtr_db", Operator: lyspg.OpRangeContains, Value: `["2024-01-15 10:00:00-03","2024-01-15 10:00:00-03"]`}},
+		{"date unchanged", "date", "2024-01-15", loc, lyspg.Condition{Field: "date_db", Operator: lyspg.OpEquals, Value: "2024-01-15"}},
+	}
+
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.cond, mustExtractTypedFilter(t, tt.key, tt.val, tt.loc), tt.name)
+	}
+}
+
+func TestExtractFiltersRelativeDates(t *testing.T) {
+
+	// use a timezone where the date differs from UTC for part of the day
+	loc := time.FixedZone("UTC+14", 14*3600)
+	today := time.Now().In(loc)
+	startOfToday := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+
+	cond := mustExtractTypedFilter(t, "date", "{today}", loc)
+	assert.EqualValues(t, lyspg.Condition{Field: "date_db", Operator: lyspg.OpEquals, Value: startOfToday.Format(lystype.DateFormat)}, cond, "date today")
+
+	cond = mustExtractTypedFilter(t, "date", ">eq{today-7}", loc)
+	assert.EqualValues(t, startOfToday.AddDate(0, 0, -7).Format(lystype.DateFormat), cond.Value, "date today-7")
+
+	cond = mustExtractTypedFilter(t, "date", "{today-1}|{today+1}", nil)
+	utcToday := time.Now().UTC()
+	assert.EqualValues(t, []string{
+		time.Date(utcToday.Year(), utcToday.Month(), utcToday.Day()-1, 0, 0, 0, 0, time.UTC).Format(lystype.DateFormat),
+		time.Date(utcToday.Year(), utcToday.Month(), utcToday.Day()+1, 0, 0, 0, 0, time.UTC).Format(lystype.DateFormat),
+	}, cond.InValues, "date in without tz uses UTC")
+
+	cond = mustExtractTypedFilter(t, "dt", ">eq{today}", loc)
+	assert.EqualValues(t, startOfToday.UTC().Format(filterDatetimeFormat), cond.Value, "datetime today")
+
+	cond = mustExtractTypedFilter(t, "dt", "<{now}", loc)
+	now, err := lystype.ParseDatetime(cond.Value, nil)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), now.ToTime(), time.Minute, "datetime now")
+}
+
+func TestExtractFiltersSkipsTzParam(t *testing.T) {
+
+	urlValues := url.Values{}
+	urlValues.Add("xtz", "Europe/Berlin")
+	urlValues.Add("name", "a")
+	conds, err := ExtractFiltersWithOptions(urlValues, filterValuesJsonKeyDbNameMap, nil, nil, mustFillGetOptions(t, GetOptions{}), FilterOptions{JsonKeyTypeMap: filterValuesJsonKeyTypeMap})
+	require.NoError(t, err)
+	assert.EqualValues(t, []lyspg.Condition{{Field: "name_db", Operator: lyspg.OpEquals, Value: "a"}}, conds)
+}
@@ -79,6 +79,7 @@ func Get[T any](env Env, store iGetable[T], opts *GetOpts[T]) http.HandlerFunc {
 				DbNames:                    lysset.FromSlice(plan.DbNames()),
 				GetOptions:                 env.GetOptions,
 				JsonKeyDbNameMap:           plan.JsonKeyDbNameMap(),
+				JsonKeyTypeMap:             plan.JsonKeyTypeMap(),
 				SetFuncUrlParamNames:       setFuncUrlParamNames,
 			})
 		if err != nil {
@@ -117,6 +118,14 @@ func Get[T any](env Env, store iGetable[T], opts *GetOpts[T]) http.HandlerFunc {
 			return
 		}
 
+		// if a timezone was requested, output datetimes in it
+		if getReqModifiers.Location != nil {
+			if err = lysmeta.SetLocation(items, getReqModifiers.Location); err != nil {
+				HandleInternalError(ctx, fmt.Errorf("Get: lysmeta.SetLocation failed: %w", err), env.Logger, w)
+				return
+			}
+		}
+
 		// if GetLastSyncAt func was passed, call it and add timestamp to resp headers
 		if getLastSyncAt != nil {
 			lastSyncAt, err := getLastSyncAt(ctx)
@@ -124,7 +133,11 @@ func Get[T any](env Env, store iGetable[T], opts *GetOpts[T]) http.HandlerFunc {
 				// log error but don't fail the request
 				env.Logger.Error("Get: getLastSyncAt failed", "error", err)
 			} else {
-				w.Header().Set("Last-Sync-At", lastSyncAt.Format(lystype.DatetimeFormat))
+				if getReqModifiers.Location != nil {
+					lastSyncAt = lastSyncAt.In(getReqModifiers.Location)
+				}
+				// String keeps offset minutes, e.g. "+05:30"
+				w.Header().Set("Last-Sync-At", lastSyncAt.String())
 			}
 		}
 
@@ -155,7 +168,7 @@ func Get[T any](env Env, store iGetable[T], opts *GetOpts[T]) http.HandlerFunc {
 			}))
 
 			// stream Excel to response writer
-			err = lysexcel.WriteItems(items, plan.JsonKeyTypeMap(), "", w)
+			err = lysexcel.WriteItems(items, plan.JsonKeyTypeMap(), "", w, lysexcel.WriteOptions{Location: getReqModifiers.Location})
 			if err != nil {
 				HandleInternalError(ctx, fmt.Errorf("Get: lysexcel.WriteItems failed: %w", err), env.Logger, w)
 				return
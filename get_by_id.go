@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/loveyourstack/lys/lysmeta"
 	"github.com/loveyourstack/lys/lyspg"
 )
 
@@ -26,6 +27,13 @@ func GetById[idT lyspg.PrimaryKeyType, outT any](env Env, store iGetableById[idT
 			return
 		}
 
+		// get the timezone in which to output datetimes, if requested
+		loc, err := ExtractTimezone(env.GetOptions.TzParamName, r.FormValue(env.GetOptions.TzParamName), r.Header.Get(TimezoneHeader))
+		if err != nil {
+			HandleError(ctx, fmt.Errorf("GetById: ExtractTimezone failed: %w", err), env.Logger, w)
+			return
+		}
+
 		// select item from Db
 		item, err := store.SelectById(ctx, id)
 		if err != nil {
@@ -33,6 +41,13 @@ func GetById[idT lyspg.PrimaryKeyType, outT any](env Env, store iGetableById[idT
 			return
 		}
 
+		if loc != nil {
+			if err = lysmeta.SetLocation(&item, loc); err != nil {
+				HandleInternalError(ctx, fmt.Errorf("GetById: lysmeta.SetLocation failed: %w", err), env.Logger, w)
+				return
+			}
+		}
+
 		// success
 		resp := StdResponse{
 			Status: ReqSucceeded,
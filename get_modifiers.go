@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/loveyourstack/lys/lyserr"
 	"github.com/loveyourstack/lys/lyspg"
@@ -24,11 +26,15 @@ var (
 	ValidFormats = lysset.New(FormatCsv, FormatExcel, FormatJson)
 )
 
+// TimezoneHeader is the request header which sets the timezone of datetimes in a GET request, e.g. "Europe/Berlin". It is overridden by GetOptions.TzParamName
+const TimezoneHeader string = "X-Timezone"
+
 type ExtractGetRequestModifierParams struct {
 	AdditionalFilterParamNames lysset.Set[string]
 	DbNames                    lysset.Set[string]
 	GetOptions                 GetOptions
 	JsonKeyDbNameMap           map[string]string
+	JsonKeyTypeMap             map[string]reflect.Type
 	SetFuncUrlParamNames       []string
 }
 
@@ -41,6 +47,7 @@ type GetReqModifiers struct {
 	PerPage            int
 	Sorts              []string
 	SetFuncParamValues []any
+	Location           *time.Location // the user's timezone, or nil if not requested
 }
 
 // ExtractGetRequestModifiers reads the Url params of the supplied GET request and converts them into a GetReqModifiers
@@ -52,10 +59,17 @@ func ExtractGetRequestModifiers(r *http.Request, params ExtractGetRequestModifie
 		return GetReqModifiers{}, fmt.Errorf("ExtractFormat failed: %w", err)
 	}
 
+	// timezone (used for datetime filters and output)
+	getReqModifiers.Location, err = ExtractTimezone(params.GetOptions.TzParamName, r.FormValue(params.GetOptions.TzParamName), r.Header.Get(TimezoneHeader))
+	if err != nil {
+		return GetReqModifiers{}, fmt.Errorf("ExtractTimezone failed: %w", err)
+	}
+
 	// filters (become WHERE clause conditions)
-	getReqModifiers.Conditions, err = ExtractFilters(r.URL.Query(), params.JsonKeyDbNameMap, params.AdditionalFilterParamNames, params.SetFuncUrlParamNames, params.GetOptions)
+	getReqModifiers.Conditions, err = ExtractFiltersWithOptions(r.URL.Query(), params.JsonKeyDbNameMap, params.AdditionalFilterParamNames, params.SetFuncUrlParamNames, params.GetOptions,
+		FilterOptions{JsonKeyTypeMap: params.JsonKeyTypeMap, Location: getReqModifiers.Location})
 	if err != nil {
-		return GetReqModifiers{}, fmt.Errorf("ExtractFilters failed: %w", err)
+		return GetReqModifiers{}, fmt.Errorf("ExtractFiltersWithOptions failed: %w", err)
 	}
 
 	// setFunc params (if setFunc is used, are passed as param values)
@@ -113,6 +127,34 @@ func ExtractFormat(formatParamName, formatVal string) (format string, err error)
 	return formatVal, nil
 }
 
+// ExtractTimezone returns the location of the timezone requested by the tz param or, if it is not sent, the TimezoneHeader. It returns nil if neither is sent
+func ExtractTimezone(tzParamName, tzVal, tzHeaderVal string) (loc *time.Location, err error) {
+
+	// tzParamName: e.g. "xtz"
+	// example: &xtz=Europe/Berlin
+
+	source := tzParamName + " param"
+	if tzVal == "" {
+		tzVal = tzHeaderVal
+		source = TimezoneHeader + " header"
+	}
+	if tzVal == "" {
+		return nil, nil
+	}
+
+	// "Local" would be the server's timezone, which the user can't know
+	if tzVal == "Local" {
+		return nil, lyserr.User{Message: source + " value is invalid: " + tzVal}
+	}
+
+	loc, err = time.LoadLocation(tzVal)
+	if err != nil {
+		return nil, lyserr.User{Message: source + " value is invalid: " + tzVal}
+	}
+
+	return loc, nil
+}
+
 // ExtractFields returns a slice of strings parsed from the request's fields param
 func ExtractFields(fieldsParamName, fieldsVal string, dbNames lysset.Set[string], jsonKeyDbNameMap map[string]string) (fields []string, err error) {
 
@@ -172,12 +214,24 @@ func ExtractFields(fieldsParamName, fieldsVal string, dbNames lysset.Set[string]
 	return fields, nil
 }
 
+// FilterOptions contains the optional inputs of ExtractFiltersWithOptions
+type FilterOptions struct {
+	JsonKeyTypeMap map[string]reflect.Type // if set, filter values of lystype and netip fields are validated and normalized
+	Location       *time.Location          // if set, datetime filter values without offset and relative dates such as "{today}" are interpreted in it, otherwise in UTC
+}
+
 // ExtractFilters returns a slice of conditions parsed from the request's params
 // to get urlValues from a request: r.Url.Query()
 func ExtractFilters(urlValues url.Values, jsonKeyDbNameMap map[string]string, additionalFilterParamNames lysset.Set[string], setFuncUrlParamNames []string, getOptions GetOptions) (conds []lyspg.Condition, err error) {
+	return ExtractFiltersWithOptions(urlValues, jsonKeyDbNameMap, additionalFilterParamNames, setFuncUrlParamNames, getOptions, FilterOptions{})
+}
+
+// ExtractFiltersWithOptions is ExtractFilters with optional type-aware value conversion and timezone
+func ExtractFiltersWithOptions(urlValues url.Values, jsonKeyDbNameMap map[string]string, additionalFilterParamNames lysset.Set[string], setFuncUrlParamNames []string, getOptions GetOptions,
+	filterOptions FilterOptions) (conds []lyspg.Condition, err error) {
 
 	// define special param names which have another purpose and may not be used as filter keys
-	specialParams := lysset.New(getOptions.FormatParamName, getOptions.FieldsParamName, getOptions.PageParamName, getOptions.PerPageParamName, getOptions.SortParamName)
+	specialParams := lysset.New(getOptions.FormatParamName, getOptions.FieldsParamName, getOptions.PageParamName, getOptions.PerPageParamName, getOptions.SortParamName, getOptions.TzParamName)
 	specialParams.AddAll(setFuncUrlParamNames...)
 
 	// for each Url value
@@ -213,6 +267,12 @@ func ExtractFilters(urlValues url.Values, jsonKeyDbNameMap map[string]string, ad
 
 			// create condition from this filter
 			cond := processFilterParam(dbName, val, getOptions)
+
+			// validate and normalize value according to the field's type
+			cond, err = convertFilterValues(cond, key, filterOptions.JsonKeyTypeMap[key], filterOptions.Location)
+			if err != nil {
+				return nil, err
+			}
 			conds = append(conds, cond)
 		}
 	}
@@ -344,6 +344,49 @@ func TestExtractFormatFailure(t *testing.T) {
 	assert.EqualValues(t, "xformat param value is invalid: a", err.Error())
 }
 
+func TestExtractTimezoneSuccess(t *testing.T) {
+
+	tzParamName := "xtz"
+
+	// param overrides header
+	loc, err := ExtractTimezone(tzParamName, "Europe/Berlin", "America/New_York")
+	if err != nil {
+		t.Errorf("ExtractTimezone failed: %v", err)
+	}
+	assert.EqualValues(t, "Europe/Berlin", loc.String())
+
+	// header only
+	loc, err = ExtractTimezone(tzParamName, "", "America/New_York")
+	if err != nil {
+		t.Errorf("ExtractTimezone failed: %v", err)
+	}
+	assert.EqualValues(t, "America/New_York", loc.String())
+
+	// neither
+	loc, err = ExtractTimezone(tzParamName, "", "")
+	if err != nil {
+		t.Errorf("ExtractTimezone failed: %v", err)
+	}
+	assert.Nil(t, loc)
+}
+
+func TestExtractTimezoneFailure(t *testing.T) {
+
+	tzParamName := "xtz"
+
+	// invalid param value
+	_, err := ExtractTimezone(tzParamName, "Europe/Nowhere", "")
+	assert.EqualValues(t, "xtz param value is invalid: Europe/Nowhere", err.Error())
+
+	// invalid header value
+	_, err = ExtractTimezone(tzParamName, "", "a")
+	assert.EqualValues(t, "X-Timezone header value is invalid: a", err.Error())
+
+	// server timezone
+	_, err = ExtractTimezone(tzParamName, "Local", "")
+	assert.EqualValues(t, "xtz param value is invalid: Local", err.Error())
+}
+
 func TestExtractPagingSuccess(t *testing.T) {
 
 	pageParamName := "xpage"
@@ -524,7 +524,14 @@ func TestGetLastSyncAtHeaderAllFormats(t *testing.T) {
 	_, excelHeaders := lysclient.MustGetFileWithHeaders(ctx, t, h, "/param-test?xformat=excel")
 	assert.Equal(t, expectedHeader, excelHeaders.Get("Last-Sync-At"), "excel LastSyncAt header")
 
-	assert.EqualValues(t, 3, callCount, "GetLastSyncAt should be called once per request")
+	// offset with minutes
+	rr = httptest.NewRecorder()
+	req = mustCreateGetReq(t, "/param-test?xformat=json&xtz=Asia/Kolkata")
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "json request status with tz")
+	assert.Equal(t, "2026-01-02 07:34:05+05:30", rr.Result().Header.Get("Last-Sync-At"), "LastSyncAt header with tz")
+
+	assert.EqualValues(t, 4, callCount, "GetLastSyncAt should be called once per request")
 }
 
 func TestGetLastSyncAtErrorNonFatal(t *testing.T) {
@@ -13,13 +13,12 @@ import (
 
 func CreateTestDbCmd(cliApp *cliapp.App) *cobra.Command {
 	return &cobra.Command{
-		Use:   "createTestDb",
-		Short: "Creates test database from embedded SQL files. Drops existing if present.",
-		Args:  cobra.NoArgs,
+		Use:         "createTestDb",
+		Short:       "Creates test database from embedded SQL files. Drops existing if present.",
+		Args:        cobra.NoArgs,
+		Annotations: noDbAnnotations,
 		Run: func(cmd *cobra.Command, args []string) {
 
-			defer cliApp.Db.Close()
-
 			// (re-)create test db
 			if err := lyspgdb.CreateLocalDb(cmd.Context(), ddl.SQLAssets, cliApp.Config.Db, cliApp.Config.DbSuperUser, cliApp.Config.DbOwnerUser, true, false,
 				nil, cliApp.Logger); err != nil {
@@ -0,0 +1,95 @@
+package rootcli
+
+import (
+	"fmt"
+
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/internal/sql/ddl"
+	"github.com/loveyourstack/lys/lysfixture"
+	"github.com/loveyourstack/lys/lyspgdb"
+	"github.com/spf13/cobra"
+)
+
+// expects db users to have been created first (create_users.sql)
+
+func DbCmd(cliApp *cliapp.App) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Creates, drops or populates the configured database",
+	}
+
+	var fixturesDir string
+	populateOptions := func() lyspgdb.PopulateOptions {
+		if fixturesDir == "" {
+			return lyspgdb.PopulateOptions{}
+		}
+		return lyspgdb.PopulateOptions{LoadFixtures: lysfixture.LoadFunc(fixturesDir)}
+	}
+
+	var dropExisting, securityPermissions bool
+	createCmd := &cobra.Command{
+		Use:         "create",
+		Short:       "Creates the database and populates it from the embedded SQL files",
+		Args:        cobra.NoArgs,
+		Annotations: noDbAnnotations,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf := cliApp.Config
+			if err := lyspgdb.CreateLocalDb(cmd.Context(), ddl.SQLAssets, conf.Db, conf.DbSuperUser, conf.DbOwnerUser, dropExisting, securityPermissions, nil,
+				cliApp.Logger, populateOptions()); err != nil {
+				return fmt.Errorf("lyspgdb.CreateLocalDb failed: %w", err)
+			}
+			return nil
+		},
+	}
+	createCmd.Flags().BoolVar(&dropExisting, "drop-existing", false, "drop the database first if it exists")
+	createCmd.Flags().BoolVar(&securityPermissions, "security-permissions", false, "run security_permissions.sql")
+	createCmd.Flags().StringVar(&fixturesDir, "fixtures", "", "also load the fixtures in this directory of the embedded SQL files")
+
+	var force bool
+	dropCmd := &cobra.Command{
+		Use:         "drop",
+		Short:       "Drops the database, disconnecting any sessions",
+		Args:        cobra.NoArgs,
+		Annotations: noDbAnnotations,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf := cliApp.Config
+			if !force {
+				return fmt.Errorf("use --force to confirm dropping database %s on %s", conf.Db.Database, conf.Db.Host)
+			}
+
+			pgDbConf := lyspgdb.Database{Host: conf.Db.Host, Port: conf.Db.Port, Database: "postgres"}
+			pgDb, err := lyspgdb.GetPool(cmd.Context(), pgDbConf, conf.DbSuperUser, conf.General.AppName+" Cli")
+			if err != nil {
+				return fmt.Errorf("lyspgdb.GetPool failed (postgres db with %v user): %w", conf.DbSuperUser.Name, err)
+			}
+			defer pgDb.Close()
+
+			if err = lyspgdb.DropDb(cmd.Context(), pgDb, conf.Db.Database); err != nil {
+				return fmt.Errorf("lyspgdb.DropDb failed: %w", err)
+			}
+			cliApp.Logger.Info("Dropped database " + conf.Db.Database)
+			return nil
+		},
+	}
+	dropCmd.Flags().BoolVar(&force, "force", false, "confirm dropping the database")
+
+	populateCmd := &cobra.Command{
+		Use:   "populate",
+		Short: "Populates the existing, empty database from the embedded SQL files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			if err := lyspgdb.PopulateDb(cmd.Context(), cliApp.Db, ddl.SQLAssets, cliApp.Config.Db.SchemaCreationOrder, nil, cliApp.Logger, populateOptions()); err != nil {
+				return fmt.Errorf("lyspgdb.PopulateDb failed: %w", err)
+			}
+			return nil
+		},
+	}
+	populateCmd.Flags().StringVar(&fixturesDir, "fixtures", "", "also load the fixtures in this directory of the embedded SQL files")
+
+	cmd.AddCommand(createCmd, dropCmd, populateCmd)
+
+	return cmd
+}
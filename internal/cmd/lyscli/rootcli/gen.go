@@ -0,0 +1,123 @@
+package rootcli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/lysgen"
+	"github.com/spf13/cobra"
+)
+
+type genOutput struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Code   string `json:"code"`
+}
+
+func GenCmd(cliApp *cliapp.App) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "gen",
+		Short: "Generates code from a db table using lysgen",
+	}
+
+	var outFile string
+	cmd.PersistentFlags().StringVar(&outFile, "out", "", "write the code to this file instead of stdout")
+
+	// genSubCmd returns a subcommand which calls genFunc with args schema and table, or the db object named by objName
+	genSubCmd := func(use, objName, short string, genFunc func(ctx context.Context, db *pgxpool.Pool, schema, table string, options ...lysgen.GenOptions) (string, error)) *cobra.Command {
+		return &cobra.Command{
+			Use:   use + " <schema> <" + objName + ">",
+			Short: short,
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				defer cliApp.Db.Close()
+
+				code, err := genFunc(cmd.Context(), cliApp.Db, args[0], args[1], lysgen.GenOptions{SkipClipboard: true})
+				if err != nil {
+					return fmt.Errorf("lysgen %s failed: %w", use, err)
+				}
+
+				if outFile != "" {
+					if err = os.WriteFile(outFile, []byte(code), 0644); err != nil {
+						return fmt.Errorf("os.WriteFile failed: %w", err)
+					}
+					cliApp.Logger.Info("written to " + outFile)
+					return nil
+				}
+
+				return writeOutput(cmd, genOutput{Schema: args[0], Table: args[1], Code: code}, func(w io.Writer) {
+					fmt.Fprint(w, code)
+				})
+			},
+		}
+	}
+
+	var withValidation bool
+	inputCmd := genSubCmd("input", "table", "Generates the Input struct of a store", func(ctx context.Context, db *pgxpool.Pool, schema, table string, options ...lysgen.GenOptions) (string, error) {
+		return lysgen.InputModel(ctx, db, schema, table, withValidation, options...)
+	})
+	inputCmd.Flags().BoolVar(&withValidation, "validation", true, "add validate tags")
+
+	var structsValidation bool
+	structsCmd := genSubCmd("structs", "table, view or set function", "Generates the enum types and the Input and Model structs of a store", func(ctx context.Context, db *pgxpool.Pool, schema, name string, options ...lysgen.GenOptions) (string, error) {
+		return lysgen.Structs(ctx, db, schema, name, structsValidation, options...)
+	})
+	structsCmd.Flags().BoolVar(&structsValidation, "validation", true, "add validate tags")
+
+	var storeDir string
+	var storeValidation bool
+	storeCmd := &cobra.Command{
+		Use:   "store <schema> <table, view or set function>",
+		Short: "Generates or regenerates a store package and its test skeleton in --dir, and prints the route registration",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			routes, err := lysgen.WriteStore(cmd.Context(), cliApp.Db, args[0], args[1], storeDir, storeValidation)
+			if err != nil {
+				return fmt.Errorf("lysgen.WriteStore failed: %w", err)
+			}
+
+			return writeOutput(cmd, genOutput{Schema: args[0], Table: args[1], Code: routes}, func(w io.Writer) {
+				fmt.Fprint(w, routes)
+			})
+		},
+	}
+	storeCmd.Flags().StringVar(&storeDir, "dir", ".", "parent dir of the store package dir")
+	storeCmd.Flags().BoolVar(&storeValidation, "validation", true, "add validate tags")
+
+	var tsClientDir string
+	tsClientCmd := &cobra.Command{
+		Use:   "ts-client <schema> <table, view or set function>",
+		Short: "Generates a typed TypeScript client of a store and the shared client runtime in --dir",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			if err := lysgen.WriteTsClient(cmd.Context(), cliApp.Db, args[0], args[1], tsClientDir); err != nil {
+				return fmt.Errorf("lysgen.WriteTsClient failed: %w", err)
+			}
+			cliApp.Logger.Info("written to " + tsClientDir)
+			return nil
+		},
+	}
+	tsClientCmd.Flags().StringVar(&tsClientDir, "dir", ".", "output dir")
+
+	cmd.AddCommand(
+		inputCmd,
+		structsCmd,
+		genSubCmd("enum", "enum", "Generates a Go type with constants and validation of an enum", lysgen.Enum),
+		storeCmd,
+		tsClientCmd,
+		genSubCmd("view", "table", "Generates the db view of a table", lysgen.View),
+		genSubCmd("ts", "table", "Generates TypeScript types of a table", lysgen.TsTypes),
+		genSubCmd("equal", "table", "Generates the store Equal func of a table", lysgen.Equal),
+	)
+
+	return cmd
+}
@@ -0,0 +1,132 @@
+package rootcli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/internal/sql/ddl"
+	"github.com/loveyourstack/lys/lyspgdb"
+	"github.com/spf13/cobra"
+)
+
+func MigrateCmd(cliApp *cliapp.App) *cobra.Command {
+
+	var dryRun bool
+
+	newMigrator := func() (*lyspgdb.Migrator, error) {
+		m, err := lyspgdb.NewMigrator(cliApp.Db, ddl.SQLAssets, cliApp.Logger, lyspgdb.MigrateOptions{DryRun: dryRun})
+		if err != nil {
+			return nil, fmt.Errorf("lyspgdb.NewMigrator failed: %w", err)
+		}
+		return m, nil
+	}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Applies or reverts versioned schema migrations from the embedded SQL files",
+	}
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "log the migrations which would run without running them")
+
+	var target int64
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Applies pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			m, err := newMigrator()
+			if err != nil {
+				return err
+			}
+			applied, err := m.Up(cmd.Context(), target)
+			if err != nil {
+				return fmt.Errorf("m.Up failed: %w", err)
+			}
+			cliApp.Logger.Info(fmt.Sprintf("%d migration(s) applied", len(applied)))
+			return nil
+		},
+	}
+	upCmd.Flags().Int64Var(&target, "target", 0, "apply up to and including this version (default: all)")
+
+	var steps int
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Reverts the last applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			m, err := newMigrator()
+			if err != nil {
+				return err
+			}
+			reverted, err := m.Down(cmd.Context(), steps)
+			if err != nil {
+				return fmt.Errorf("m.Down failed: %w", err)
+			}
+			cliApp.Logger.Info(fmt.Sprintf("%d migration(s) reverted", len(reverted)))
+			return nil
+		},
+	}
+	downCmd.Flags().IntVar(&steps, "steps", 1, "number of migrations to revert")
+
+	redoCmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Reverts and re-applies the last applied migration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			m, err := newMigrator()
+			if err != nil {
+				return err
+			}
+			redone, err := m.Redo(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("m.Redo failed: %w", err)
+			}
+			cliApp.Logger.Info(fmt.Sprintf("migration %d_%s redone", redone.Version, redone.Name))
+			return nil
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Lists migrations and whether they are applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			m, err := newMigrator()
+			if err != nil {
+				return err
+			}
+			statuses, err := m.Status(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("m.Status failed: %w", err)
+			}
+
+			return writeOutput(cmd, statuses, func(w io.Writer) {
+				for _, st := range statuses {
+					state := "pending"
+					if st.Applied {
+						state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+					if st.ChecksumMismatch {
+						state += " (changed since applied)"
+					}
+					if st.FileMissing {
+						state += " (file missing)"
+					}
+					fmt.Fprintf(w, "%d_%s\t%s\n", st.Version, st.Name, state)
+				}
+			})
+		},
+	}
+
+	cmd.AddCommand(upCmd, downCmd, redoCmd, statusCmd)
+
+	return cmd
+}
@@ -0,0 +1,80 @@
+package rootcli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/lyspgmon"
+	"github.com/spf13/cobra"
+)
+
+func MonCmd(cliApp *cliapp.App) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "mon",
+		Short: "Installs lyspgmon and checks the database with it",
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Creates the lyspgmon schema if needed and (re-)adds its functions and views",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			if err := lyspgmon.Install(cmd.Context(), cliApp.Db, cliApp.Config.DbOwnerUser.Name, cliApp.Logger); err != nil {
+				return fmt.Errorf("lyspgmon.Install failed: %w", err)
+			}
+			cliApp.Logger.Info("lyspgmon installed")
+			return nil
+		},
+	}
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Adds missing triggers and checks the integrity of the database. Run after schema updates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			if err := lyspgmon.CheckDb(cmd.Context(), cliApp.Db, cliApp.Logger); err != nil {
+				return fmt.Errorf("lyspgmon.CheckDb failed: %w", err)
+			}
+			return nil
+		},
+	}
+
+	triggerFuncs := map[string]func(ctx context.Context, ownerDb *pgxpool.Pool, logger *slog.Logger) error{
+		"audit":      lyspgmon.AddMissingAuditUpdateTriggers,
+		"live":       lyspgmon.AddMissingLiveNotifyTriggers,
+		"updated-at": lyspgmon.AddMissingUpdatedAtTriggers,
+	}
+	var kinds []string
+	addTriggersCmd := &cobra.Command{
+		Use:   "add-triggers",
+		Short: "Adds missing audit, live notify and updated_at triggers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			for _, kind := range kinds {
+				f, ok := triggerFuncs[kind]
+				if !ok {
+					return fmt.Errorf("invalid kind: %s", kind)
+				}
+				if err := f(cmd.Context(), cliApp.Db, cliApp.Logger); err != nil {
+					return fmt.Errorf("adding %s triggers failed: %w", kind, err)
+				}
+			}
+			return nil
+		},
+	}
+	addTriggersCmd.Flags().StringSliceVar(&kinds, "kind", []string{"audit", "live", "updated-at"}, "trigger kinds to add: audit, live and/or updated-at")
+
+	cmd.AddCommand(installCmd, checkCmd, addTriggersCmd)
+
+	return cmd
+}
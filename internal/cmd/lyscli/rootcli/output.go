@@ -0,0 +1,33 @@
+package rootcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// annotationNoDb marks subcommands which do not need the db connection, e.g. because the db may not exist yet
+const annotationNoDb string = "noDb"
+
+var noDbAnnotations = map[string]string{annotationNoDb: "true"}
+
+// jsonOutput is set by the persistent --json flag
+var jsonOutput bool
+
+// writeOutput writes v as indented JSON if --json is set, and otherwise calls writeText
+func writeOutput(cmd *cobra.Command, v any, writeText func(w io.Writer)) error {
+
+	if !jsonOutput {
+		writeText(cmd.OutOrStdout())
+		return nil
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("enc.Encode failed: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package rootcli
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -25,12 +26,34 @@ var rootCmd = &cobra.Command{
 	SilenceErrors: true, // subcommand errors are returned upwards via RunE and handled in Execute() below
 	SilenceUsage:  true,
 	// no Run function: a subcommand is always needed
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if cmd.Annotations[annotationNoDb] == "true" {
+			return nil
+		}
+
+		// connect to db and assign pool to cliApp
+		conf := cliApp.Config
+		cliApp.Db, err = lyspgdb.GetPool(cmd.Context(), conf.Db, conf.DbOwnerUser, conf.General.AppName+" Cli")
+		if err != nil {
+			return fmt.Errorf("failed to create db connection pool: %w", err)
+		}
+		return nil
+	},
 }
 
 var cliApp *cliapp.App
 
 func addSubCommands() {
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print output as JSON, for scripting")
+
 	rootCmd.AddCommand(CreateTestDbCmd(cliApp))
+	rootCmd.AddCommand(DbCmd(cliApp))
+	rootCmd.AddCommand(GenCmd(cliApp))
+	rootCmd.AddCommand(MigrateCmd(cliApp))
+	rootCmd.AddCommand(MonCmd(cliApp))
+	rootCmd.AddCommand(SchemaDiffCmd(cliApp))
+	rootCmd.AddCommand(SessionsCmd(cliApp))
 }
 
 func Execute() {
@@ -56,14 +79,13 @@ func Execute() {
 		Application: app,
 	}
 
-	// connect to db and assign pool to cliApp
-	cliApp.Db, err = lyspgdb.GetPool(ctx, conf.Db, conf.DbOwnerUser, conf.General.AppName+" Cli")
-	if err != nil {
-		log.Fatalf("initialization: failed to create db connection pool: %s", err.Error())
-	}
-	defer cliApp.Db.Close()
-
+	// the db connection is made in rootCmd.PersistentPreRunE, unless the subcommand is annotated with annotationNoDb
 	// note that defer db Close is also needed in subcommands or else context cancelation doesn't propagate to db
+	defer func() {
+		if cliApp.Db != nil {
+			cliApp.Db.Close()
+		}
+	}()
 
 	// subcommands
 	addSubCommands()
@@ -0,0 +1,53 @@
+package rootcli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/internal/sql/ddl"
+	"github.com/loveyourstack/lys/lyspgdb/schemadiff"
+	"github.com/spf13/cobra"
+)
+
+func SchemaDiffCmd(cliApp *cliapp.App) *cobra.Command {
+
+	var alter bool
+
+	cmd := &cobra.Command{
+		Use:   "schemaDiff",
+		Short: "Compares the db with a scratch db created from the embedded SQL files",
+		Long: `Creates a scratch db named "<db>_schemadiff" from the embedded SQL files, compares its schemas with the db and drops it.
+Prints the missing, extra and changed tables, columns, constraints, indexes, views, materialized views, functions and triggers. With --json, each difference includes its fix statements.
+Nothing is changed in the db.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cliApp.Db.Close()
+
+			items, err := schemadiff.DiffEmbeddedSchema(cmd.Context(), ddl.SQLAssets, cliApp.Config.Db, cliApp.Config.DbSuperUser, cliApp.Config.DbOwnerUser, nil, cliApp.Logger)
+			if err != nil {
+				return fmt.Errorf("schemadiff.DiffEmbeddedSchema failed: %w", err)
+			}
+
+			if alter && !jsonOutput {
+				fmt.Fprintln(cmd.OutOrStdout(), "-- review before running: statements for extra objects drop them")
+				for _, item := range items {
+					fmt.Fprintf(cmd.OutOrStdout(), "\n-- %s %s: %s\n", item.ObjectType, item.Name, item.Change)
+					fmt.Fprintln(cmd.OutOrStdout(), strings.Join(item.Fix, "\n"))
+				}
+				return nil
+			}
+
+			return writeOutput(cmd, items, func(w io.Writer) {
+				for _, item := range items {
+					fmt.Fprintf(w, "%-10s %-10s %s\n", item.Change, item.ObjectType, item.Name)
+				}
+				cliApp.Logger.Info(fmt.Sprintf("%d difference(s) found", len(items)))
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&alter, "alter", false, "print the statements which would make the db match the embedded SQL files")
+
+	return cmd
+}
@@ -0,0 +1,112 @@
+package rootcli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/loveyourstack/lys/internal/cmd/lyscli/cliapp"
+	"github.com/loveyourstack/lys/lysauth"
+	"github.com/loveyourstack/lys/lysclient"
+	"github.com/spf13/cobra"
+)
+
+// tokenEnvVar is the env var containing the bearer token for sessions subcommands, if --token is not set
+const tokenEnvVar string = "LYS_TOKEN"
+
+// bearerTransport adds a bearer token to each request
+type bearerTransport struct {
+	token string
+}
+
+func (bt bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+bt.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// SessionsCmd lists and revokes the sessions of a running server using its lysauth.AdminListSessions and lysauth.AdminRevokeSession routes
+func SessionsCmd(cliApp *cliapp.App) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Lists or revokes the sessions of a running server",
+	}
+
+	var baseUrl, token string
+	cmd.PersistentFlags().StringVar(&baseUrl, "url", "", "URL of the server's admin sessions route, e.g. http://localhost:8080/admin/sessions")
+	cmd.PersistentFlags().StringVar(&token, "token", "", "bearer token of an admin session (default $"+tokenEnvVar+")")
+	_ = cmd.MarkPersistentFlagRequired("url")
+
+	newClient := func() (http.Client, error) {
+		if token == "" {
+			token = os.Getenv(tokenEnvVar)
+		}
+		if token == "" {
+			return http.Client{}, fmt.Errorf("--token or $%s is required", tokenEnvVar)
+		}
+		return http.Client{Transport: bearerTransport{token: token}}, nil
+	}
+
+	var userId int64
+	listCmd := &cobra.Command{
+		Use:         "list",
+		Short:       "Lists sessions, most recently accessed first",
+		Args:        cobra.NoArgs,
+		Annotations: noDbAnnotations,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			targetUrl := baseUrl
+			if userId != 0 {
+				targetUrl += "?user_id=" + strconv.FormatInt(userId, 10)
+			}
+
+			sessions, err := lysclient.GetSlice[lysauth.SessionListItem](client, targetUrl)
+			if err != nil {
+				return fmt.Errorf("lysclient.GetSlice failed: %w", err)
+			}
+
+			return writeOutput(cmd, sessions, func(w io.Writer) {
+				for _, sess := range sessions {
+					fmt.Fprintf(w, "%s\tuser %d\t%s\tlast access %s\texpires %s\n", sess.Id, sess.UserId, sess.Ip,
+						sess.LastAccessAt.Format("2006-01-02 15:04:05"), sess.ExpiresAt.Format("2006-01-02 15:04:05"))
+				}
+			})
+		},
+	}
+	listCmd.Flags().Int64Var(&userId, "user-id", 0, "only list the sessions of this user")
+
+	revokeCmd := &cobra.Command{
+		Use:         "revoke <session id>",
+		Short:       "Revokes a session, logging its user out",
+		Args:        cobra.ExactArgs(1),
+		Annotations: noDbAnnotations,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+
+			targetUrl := strings.TrimSuffix(baseUrl, "/") + "/" + url.PathEscape(args[0])
+			if _, err = lysclient.DoToValue[string](cmd.Context(), client, http.MethodDelete, targetUrl); err != nil {
+				return fmt.Errorf("lysclient.DoToValue failed: %w", err)
+			}
+			cliApp.Logger.Info("revoked session " + args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(listCmd, revokeCmd)
+
+	return cmd
+}
@@ -0,0 +1,167 @@
+package lysauth
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysauth/lysauthddl"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lyspgdb"
+)
+
+const (
+	gSchemaName string = "lysauth"
+
+	apiKeyName           string = "API key"
+	apiKeyTableName      string = "api_key"
+	apiKeyViewName       string = "api_key"
+	apiKeyPkColName      string = "id"
+	apiKeyDefaultOrderBy string = "created_at DESC"
+)
+
+var (
+	apiKeyPlan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	apiKeyPlan, err = lysmeta.Analyze(ApiKey{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", gSchemaName, apiKeyTableName, err.Error())
+	}
+}
+
+// Install creates the lysauth schema in the database if it is not already present, and (re)-adds the tables in the lysauthddl folder.
+// note that local permissions need to be granted to lysauth schema and objects after installation
+func Install(ctx context.Context, ownerDb *pgxpool.Pool, dbOwner string, logger *slog.Logger) (err error) {
+
+	// create schema if needed
+	stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s AUTHORIZATION %s;",
+		pgx.Identifier{gSchemaName}.Sanitize(), pgx.Identifier{dbOwner}.Sanitize())
+	if _, err = ownerDb.Exec(ctx, stmt); err != nil {
+		return fmt.Errorf("ownerDb.Exec failed (create schema): %w", err)
+	}
+
+	// execute all embedded sql files into db
+	err = fs.WalkDir(lysauthddl.SQLAssets, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("unknown file err: %w", err)
+		}
+
+		// skip non-sql files
+		if !strings.HasSuffix(d.Name(), ".sql") {
+			return nil
+		}
+
+		// exec file into db
+		err = lyspgdb.ExecuteFile(ctx, ownerDb, path, lysauthddl.SQLAssets, nil, logger)
+		if err != nil {
+			return fmt.Errorf("lyspgdb.ExecuteFile failed for path '%s': %w", path, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("fs.WalkDir failed: %w", err)
+	}
+
+	return nil
+}
+
+// ApiKeyStore persists API keys in the lysauth.api_key table. Create the table using Install.
+type ApiKeyStore struct {
+	Db *pgxpool.Pool
+}
+
+// Create generates a new API key, stores its prefix and secret hash, and returns the full key. The full key cannot be retrieved again.
+func (s ApiKeyStore) Create(ctx context.Context, validate *validator.Validate, appPrefix string, input ApiKeyInput) (newId int64, key string, err error) {
+
+	if err = lysmeta.Validate(validate, input); err != nil {
+		return 0, "", fmt.Errorf("lysmeta.Validate failed: %w", err)
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.ToTime().Before(time.Now()) {
+		return 0, "", lyserr.User{Message: "expires_at must be in the future"}
+	}
+
+	key, keyPrefix, secretHash, err := GenerateApiKey(appPrefix)
+	if err != nil {
+		return 0, "", fmt.Errorf("GenerateApiKey failed: %w", err)
+	}
+
+	newId, err = lyspg.InsertWithExtras[ApiKeyInput, int64](ctx, s.Db, gSchemaName, apiKeyTableName, apiKeyPkColName, input,
+		[]string{"key_prefix", "secret_hash"}, []any{keyPrefix, secretHash})
+	if err != nil {
+		return 0, "", fmt.Errorf("lyspg.InsertWithExtras failed: %w", err)
+	}
+
+	return newId, key, nil
+}
+
+func (s ApiKeyStore) Delete(ctx context.Context, id int64) error {
+	return lyspg.DeleteUnique(ctx, s.Db, gSchemaName, apiKeyTableName, apiKeyPkColName, id)
+}
+
+func (s ApiKeyStore) GetName() string {
+	return apiKeyName
+}
+func (s ApiKeyStore) GetPlan() lysmeta.Plan {
+	return apiKeyPlan
+}
+
+// Revoke marks the key as revoked. Revoked keys are kept for auditing purposes.
+func (s ApiKeyStore) Revoke(ctx context.Context, id int64) error {
+
+	stmt := fmt.Sprintf("UPDATE %s.%s SET revoked_at = now() WHERE %s = $1 AND revoked_at IS NULL;", gSchemaName, apiKeyTableName, apiKeyPkColName)
+
+	cmdTag, err := s.Db.Exec(ctx, stmt, id)
+	if err != nil {
+		return lyserr.Db{Err: fmt.Errorf(lyspg.ErrDescUpdateExecFailed+": %w", err), Stmt: stmt}
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s ApiKeyStore) Select(ctx context.Context, params lyspg.SelectParams) (items []ApiKey, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[ApiKey](ctx, s.Db, gSchemaName, apiKeyTableName, apiKeyViewName, apiKeyDefaultOrderBy, apiKeyPlan.DbNames(), params)
+}
+
+func (s ApiKeyStore) SelectById(ctx context.Context, id int64) (item ApiKey, err error) {
+	return lyspg.SelectUnique[ApiKey](ctx, s.Db, gSchemaName, apiKeyViewName, apiKeyPkColName, id)
+}
+
+func (s ApiKeyStore) SelectByPrefix(ctx context.Context, keyPrefix string) (item ApiKey, err error) {
+	return lyspg.SelectUnique[ApiKey](ctx, s.Db, gSchemaName, apiKeyViewName, "key_prefix", keyPrefix)
+}
+
+func (s ApiKeyStore) SelectByUserId(ctx context.Context, userId int64) (items []ApiKey, err error) {
+	stmt := fmt.Sprintf("SELECT * FROM %s.%s WHERE user_id = $1 ORDER BY %s;", gSchemaName, apiKeyViewName, apiKeyDefaultOrderBy)
+	return lyspg.SelectT[ApiKey](ctx, s.Db, stmt, userId)
+}
+
+func (s ApiKeyStore) UpdateLastUsedAt(ctx context.Context, id int64, lastUsedAt time.Time) error {
+
+	stmt := fmt.Sprintf("UPDATE %s.%s SET last_used_at = $1 WHERE %s = $2;", gSchemaName, apiKeyTableName, apiKeyPkColName)
+
+	cmdTag, err := s.Db.Exec(ctx, stmt, lastUsedAt, id)
+	if err != nil {
+		return lyserr.Db{Err: fmt.Errorf(lyspg.ErrDescUpdateExecFailed+": %w", err), Stmt: stmt}
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package lysauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+const (
+	// ApiKeyHeader is the request header which may contain an API key as an alternative to the Authorization header.
+	ApiKeyHeader string = "X-Api-Key"
+
+	apiKeyIdBytes     int = 6  // random bytes in the visible key id, hex encoded to 12 chars
+	apiKeySecretBytes int = 24 // random bytes in the secret, hex encoded to 48 chars
+
+	defaultApiKeyLastUsedInterval = time.Minute
+)
+
+var (
+	ErrApiKeyExpired = lyserr.User{Message: "API key expired", StatusCode: http.StatusForbidden}
+	ErrApiKeyInvalid = lyserr.User{Message: "invalid API key", StatusCode: http.StatusForbidden}
+	ErrApiKeyMissing = lyserr.User{Message: "API key missing", StatusCode: http.StatusForbidden}
+	ErrApiKeyRevoked = lyserr.User{Message: "API key revoked", StatusCode: http.StatusForbidden}
+)
+
+// ApiKeyInput contains the fields needed to create an API key.
+type ApiKeyInput struct {
+	ExpiresAt *lystype.Datetime `db:"expires_at" json:"expires_at"` // nil: key does not expire
+	Name      string            `db:"name" json:"name" validate:"required"`
+	Scopes    []string          `db:"scopes" json:"scopes" validate:"required"`
+	UserId    int64             `db:"user_id" json:"user_id" validate:"required,gt=0"` // the user (usually a technical user) on whose behalf the key acts
+	UserName  string            `db:"user_name" json:"user_name" validate:"required"`
+}
+
+// ApiKey is an API key for machine clients. The secret itself is never stored, only its hash.
+type ApiKey struct {
+	Id         int64             `db:"id" json:"id"`
+	CreatedAt  lystype.Datetime  `db:"created_at" json:"created_at"`
+	KeyPrefix  string            `db:"key_prefix" json:"key_prefix"` // visible part of the key, used for lookup and identification
+	LastUsedAt *lystype.Datetime `db:"last_used_at" json:"last_used_at"`
+	RevokedAt  *lystype.Datetime `db:"revoked_at" json:"revoked_at"`
+	SecretHash string            `db:"secret_hash" json:"-"`
+	ApiKeyInput
+}
+
+// IsExpired returns true if the key has an expiry time which is before now.
+func (k ApiKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && now.After(k.ExpiresAt.ToTime())
+}
+
+// IsRevoked returns true if the key has been revoked.
+func (k ApiKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// GenerateApiKey returns a new API key in the format "<appPrefix>_<keyId>_<secret>", along with its visible prefix ("<appPrefix>_<keyId>") and the hash of the secret.
+// The full key must be shown to the user once and then discarded: only keyPrefix and secretHash should be stored.
+func GenerateApiKey(appPrefix string) (key, keyPrefix, secretHash string, err error) {
+
+	if appPrefix == "" || strings.Contains(appPrefix, "_") {
+		return "", "", "", fmt.Errorf("appPrefix must be non-empty and must not contain '_'")
+	}
+
+	keyId, err := randHex(apiKeyIdBytes)
+	if err != nil {
+		return "", "", "", fmt.Errorf("randHex failed (keyId): %w", err)
+	}
+	secret, err := randHex(apiKeySecretBytes)
+	if err != nil {
+		return "", "", "", fmt.Errorf("randHex failed (secret): %w", err)
+	}
+
+	keyPrefix = appPrefix + "_" + keyId
+	key = keyPrefix + "_" + secret
+
+	return key, keyPrefix, HashApiKeySecret(secret), nil
+}
+
+// HashApiKeySecret returns the hex-encoded SHA-256 hash of the supplied secret.
+// A fast hash is sufficient since the secret is long and random, unlike a user password.
+func HashApiKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseApiKey splits the supplied key into its visible prefix and its secret.
+func ParseApiKey(key string) (keyPrefix, secret string, err error) {
+
+	// secret is after the last underscore
+	idx := strings.LastIndex(key, "_")
+	if idx < 1 || idx == len(key)-1 {
+		return "", "", ErrApiKeyInvalid
+	}
+
+	keyPrefix, secret = key[:idx], key[idx+1:]
+
+	// prefix must itself consist of app prefix and key id
+	if !strings.Contains(keyPrefix, "_") {
+		return "", "", ErrApiKeyInvalid
+	}
+
+	return keyPrefix, secret, nil
+}
+
+// VerifyApiKeySecret returns true if the supplied secret matches the stored hash.
+func VerifyApiKeySecret(secret, secretHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashApiKeySecret(secret)), []byte(secretHash)) == 1
+}
+
+// GetApiKeyFromHeader returns the API key from the X-Api-Key header, or from the Authorization bearer token if that header is not set.
+func GetApiKeyFromHeader(header http.Header) (key string, err error) {
+
+	key = header.Get(ApiKeyHeader)
+	if key != "" {
+		return key, nil
+	}
+
+	if header.Get("Authorization") == "" {
+		return "", ErrApiKeyMissing
+	}
+
+	key, err = GetBearerToken(header)
+	if err != nil {
+		return "", fmt.Errorf("GetBearerToken failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// ScopeRoles maps an API key scope to the application roles it grants.
+type ScopeRoles map[string][]string
+
+// Roles returns the sorted, de-duplicated roles granted by the supplied scopes. Unknown scopes grant no roles.
+func (sr ScopeRoles) Roles(scopes []string) (roles []string) {
+
+	roles = []string{}
+	for _, scope := range scopes {
+		for _, role := range sr[scope] {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	slices.Sort(roles)
+
+	return roles
+}
+
+// ApiKeyUserInfo is bound to the request context by ApiKeyAuth using lys.UserInfoCtxKey.
+type ApiKeyUserInfo struct {
+	ApiKeyId  int64    `json:"api_key_id"`
+	KeyPrefix string   `json:"key_prefix"`
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes"`
+	UserId    int64    `json:"user_id"`
+	UserName  string   `json:"user_name"`
+}
+
+func (u ApiKeyUserInfo) GetUserId() int64 {
+	return u.UserId
+}
+
+func (u ApiKeyUserInfo) GetUserName() string {
+	return u.UserName
+}
+
+// HasRole returns true if the user info contains the supplied role.
+func (u ApiKeyUserInfo) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// iApiKeyStore is a store that can be used by ApiKeyAuth.
+type iApiKeyStore interface {
+	SelectByPrefix(ctx context.Context, keyPrefix string) (item ApiKey, err error)
+	UpdateLastUsedAt(ctx context.Context, id int64, lastUsedAt time.Time) error
+}
+
+// ApiKeyAuthOptions contains optional settings for ApiKeyAuth.
+type ApiKeyAuthOptions struct {
+	LastUsedInterval time.Duration // min time between last_used_at updates of a key, to avoid a db write per request. 0 means default (1 minute)
+}
+
+// ApiKeyAuth returns middleware which authenticates the request using an API key, and binds an ApiKeyUserInfo to the request context using lys.UserInfoCtxKey.
+// Unlike AppSessions.FromRequest, the key is not tied to the client's IP or User-Agent.
+func ApiKeyAuth(store iApiKeyStore, scopeRoles ScopeRoles, logger *slog.Logger, options ...ApiKeyAuthOptions) func(http.Handler) http.Handler {
+
+	opts := ApiKeyAuthOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.LastUsedInterval <= 0 {
+		opts.LastUsedInterval = defaultApiKeyLastUsedInterval
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			apiKey, err := AuthenticateApiKey(ctx, store, r.Header, time.Now())
+			if err != nil {
+				lys.HandleError(ctx, fmt.Errorf("ApiKeyAuth: AuthenticateApiKey failed: %w", err), logger, w)
+				return
+			}
+
+			// record usage, at most once per LastUsedInterval
+			now := time.Now()
+			if apiKey.LastUsedAt == nil || now.Sub(apiKey.LastUsedAt.ToTime()) >= opts.LastUsedInterval {
+				if err = store.UpdateLastUsedAt(ctx, apiKey.Id, now); err != nil {
+					// not fatal for the request
+					logger.Error("ApiKeyAuth: store.UpdateLastUsedAt failed: "+err.Error(), slog.String("key_prefix", apiKey.KeyPrefix))
+				}
+			}
+
+			userInfo := ApiKeyUserInfo{
+				ApiKeyId:  apiKey.Id,
+				KeyPrefix: apiKey.KeyPrefix,
+				Roles:     scopeRoles.Roles(apiKey.Scopes),
+				Scopes:    apiKey.Scopes,
+				UserId:    apiKey.UserId,
+				UserName:  apiKey.UserName,
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, lys.UserInfoCtxKey, userInfo)))
+		})
+	}
+}
+
+// AuthenticateApiKey extracts the API key from the supplied header, looks it up in store and verifies it.
+func AuthenticateApiKey(ctx context.Context, store iApiKeyStore, header http.Header, now time.Time) (apiKey ApiKey, err error) {
+
+	key, err := GetApiKeyFromHeader(header)
+	if err != nil {
+		return ApiKey{}, fmt.Errorf("GetApiKeyFromHeader failed: %w", err)
+	}
+
+	keyPrefix, secret, err := ParseApiKey(key)
+	if err != nil {
+		return ApiKey{}, fmt.Errorf("ParseApiKey failed: %w", err)
+	}
+
+	apiKey, err = store.SelectByPrefix(ctx, keyPrefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ApiKey{}, ErrApiKeyInvalid
+		}
+		return ApiKey{}, fmt.Errorf("store.SelectByPrefix failed: %w", err)
+	}
+
+	if !VerifyApiKeySecret(secret, apiKey.SecretHash) {
+		return ApiKey{}, ErrApiKeyInvalid
+	}
+	if apiKey.IsRevoked() {
+		return ApiKey{}, ErrApiKeyRevoked
+	}
+	if apiKey.IsExpired(now) {
+		return ApiKey{}, ErrApiKeyExpired
+	}
+
+	return apiKey, nil
+}
+
+// randHex returns n random bytes, hex encoded.
+func randHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
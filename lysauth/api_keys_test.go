@@ -0,0 +1,200 @@
+package lysauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lystype"
+	"github.com/stretchr/testify/assert"
+)
+
+// memApiKeyStore is an in-memory iApiKeyStore used for testing.
+type memApiKeyStore struct {
+	mu          sync.Mutex
+	keys        map[string]ApiKey // keyPrefix → key
+	lastUsedSet int
+}
+
+func (s *memApiKeyStore) SelectByPrefix(ctx context.Context, keyPrefix string) (ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[keyPrefix]
+	if !ok {
+		return ApiKey{}, pgx.ErrNoRows
+	}
+	return k, nil
+}
+
+func (s *memApiKeyStore) UpdateLastUsedAt(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for prefix, k := range s.keys {
+		if k.Id == id {
+			t := lystype.Datetime(lastUsedAt)
+			k.LastUsedAt = &t
+			s.keys[prefix] = k
+			s.lastUsedSet++
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+func newMemApiKeyStore(t *testing.T, input ApiKeyInput) (store *memApiKeyStore, key string) {
+	t.Helper()
+
+	key, keyPrefix, secretHash, err := GenerateApiKey("lys")
+	if err != nil {
+		t.Fatalf("GenerateApiKey failed: %v", err)
+	}
+
+	store = &memApiKeyStore{keys: map[string]ApiKey{
+		keyPrefix: {Id: 1, KeyPrefix: keyPrefix, SecretHash: secretHash, ApiKeyInput: input},
+	}}
+	return store, key
+}
+
+func TestGenerateApiKey(t *testing.T) {
+
+	key, keyPrefix, secretHash, err := GenerateApiKey("lys")
+	if err != nil {
+		t.Fatalf("GenerateApiKey failed: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(key, keyPrefix+"_"))
+	assert.True(t, strings.HasPrefix(keyPrefix, "lys_"))
+	assert.Len(t, secretHash, 64)
+	assert.NotContains(t, key, secretHash)
+
+	parsedPrefix, secret, err := ParseApiKey(key)
+	if err != nil {
+		t.Fatalf("ParseApiKey failed: %v", err)
+	}
+	assert.Equal(t, keyPrefix, parsedPrefix)
+	assert.True(t, VerifyApiKeySecret(secret, secretHash))
+	assert.False(t, VerifyApiKeySecret(secret+"x", secretHash))
+
+	// keys are unique
+	key2, _, _, _ := GenerateApiKey("lys")
+	assert.NotEqual(t, key, key2)
+
+	// invalid app prefixes
+	_, _, _, err = GenerateApiKey("")
+	assert.Error(t, err)
+	_, _, _, err = GenerateApiKey("a_b")
+	assert.Error(t, err)
+}
+
+func TestParseApiKey_Invalid(t *testing.T) {
+	for _, key := range []string{"", "abc", "_abc", "abc_", "lys_abc"} {
+		_, _, err := ParseApiKey(key)
+		assert.ErrorIs(t, err, ErrApiKeyInvalid, key)
+	}
+}
+
+func TestScopeRoles_Roles(t *testing.T) {
+	sr := ScopeRoles{
+		"orders:read":  {"OrderReader"},
+		"orders:write": {"OrderReader", "OrderWriter"},
+	}
+	assert.Equal(t, []string{"OrderReader", "OrderWriter"}, sr.Roles([]string{"orders:write", "orders:read", "unknown"}))
+	assert.Equal(t, []string{}, sr.Roles(nil))
+}
+
+func TestApiKeyAuth(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, key := newMemApiKeyStore(t, ApiKeyInput{Name: "etl", Scopes: []string{"orders:read"}, UserId: 7, UserName: "etl-job"})
+
+	// handler echoes user info from ctx
+	var gotUserInfo ApiKeyUserInfo
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserInfo, _ = r.Context().Value(lys.UserInfoCtxKey).(ApiKeyUserInfo)
+		assert.EqualValues(t, 7, lys.GetUserIdFromCtx(r.Context()))
+		assert.Equal(t, "etl-job", lys.GetUserNameFromCtx(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	})
+	h := ApiKeyAuth(store, ScopeRoles{"orders:read": {"OrderReader"}}, logger)(next)
+
+	// bearer header
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{"OrderReader"}, gotUserInfo.Roles)
+	assert.EqualValues(t, 1, gotUserInfo.ApiKeyId)
+	assert.Equal(t, 1, store.lastUsedSet)
+
+	// X-Api-Key header: last used is not updated again within interval
+	req = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(ApiKeyHeader, key)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, store.lastUsedSet)
+}
+
+func TestApiKeyAuth_Failures(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	past := lystype.Datetime(time.Now().Add(-time.Hour))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not be called")
+	})
+
+	tests := []struct {
+		name    string
+		input   ApiKeyInput
+		revoked bool
+		mutate  func(key string) string
+		wantErr string
+	}{
+		{name: "missing", mutate: func(key string) string { return "" }, wantErr: ErrApiKeyMissing.Message},
+		{name: "wrong secret", mutate: func(key string) string { return key + "x" }, wantErr: ErrApiKeyInvalid.Message},
+		{name: "unknown prefix", mutate: func(key string) string { return "lys_000000000000_abc" }, wantErr: ErrApiKeyInvalid.Message},
+		{name: "expired", input: ApiKeyInput{ExpiresAt: &past}, wantErr: ErrApiKeyExpired.Message},
+		{name: "revoked", revoked: true, wantErr: ErrApiKeyRevoked.Message},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, key := newMemApiKeyStore(t, tt.input)
+			if tt.revoked {
+				for prefix, k := range store.keys {
+					k.RevokedAt = &past
+					store.keys[prefix] = k
+				}
+			}
+			if tt.mutate != nil {
+				key = tt.mutate(key)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if key != "" {
+				req.Header.Set(ApiKeyHeader, key)
+			}
+			rr := httptest.NewRecorder()
+			ApiKeyAuth(store, nil, logger)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			resp := lys.StdResponse{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("json.Unmarshal failed: %v", err)
+			}
+			assert.Equal(t, tt.wantErr, resp.ErrDescription)
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package lysauthddl
+
+import "embed"
+
+//go:embed *
+var SQLAssets embed.FS
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS lysauth.api_key
+(
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  created_at tracking_at,
+  expires_at timestamptz,
+  key_prefix text NOT NULL UNIQUE,
+  last_used_at timestamptz,
+  name text NOT NULL CHECK (name != ''),
+  revoked_at timestamptz,
+  scopes text[] NOT NULL,
+  secret_hash text NOT NULL,
+  user_id bigint NOT NULL,
+  user_name text NOT NULL
+);
+COMMENT ON TABLE lysauth.api_key IS 'shortname: auth_ak';
+
+CREATE INDEX IF NOT EXISTS api_key_user_id_idx ON lysauth.api_key (user_id);
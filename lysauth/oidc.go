@@ -0,0 +1,484 @@
+package lysauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lyserr"
+)
+
+var (
+	ErrOidcInvalidState  = lyserr.User{Message: "invalid or expired login state", StatusCode: http.StatusForbidden}
+	ErrOidcInvalidToken  = lyserr.User{Message: "invalid ID token", StatusCode: http.StatusForbidden}
+	ErrOidcLoginFailed   = lyserr.User{Message: "login failed at identity provider", StatusCode: http.StatusForbidden}
+	ErrOidcTooManyLogins = lyserr.User{Message: "too many pending logins, please try again later", StatusCode: http.StatusServiceUnavailable}
+)
+
+const (
+	defaultOidcClockSkew             = time.Minute
+	defaultOidcJwksMinRefresh        = time.Minute
+	defaultOidcMaxPending            = 10000
+	defaultOidcRolesClaim            = "roles"
+	defaultOidcStateTtl              = 10 * time.Minute
+	oidcDiscoveryPath         string = "/.well-known/openid-configuration"
+
+	// OidcStateCookieName is the cookie which ties the login state to the browser which started the login, preventing login CSRF
+	OidcStateCookieName string = "lys_oidc_state"
+)
+
+// OidcConfig contains the relying party settings for an OIDC identity provider.
+type OidcConfig struct {
+	ClientId     string
+	ClientSecret string // optional: public clients rely on PKCE only
+	IssuerUrl    string // e.g. "https://login.example.com/realms/main". Discovery document is fetched from IssuerUrl + "/.well-known/openid-configuration"
+	RedirectUrl  string // the app's callback URL, as registered with the IdP
+
+	ClockSkew  time.Duration // leeway when checking exp and iat. 0 means default (1 minute)
+	HttpClient *http.Client  // nil means http.DefaultClient
+	MaxPending int           // max number of started logins awaiting their callback. 0 means default (10000)
+	RolesClaim string        // name of the ID token claim containing roles. "" means default ("roles")
+	Scopes     []string      // nil means default ("openid", "email", "profile")
+	StateTtl   time.Duration // max time between redirect to IdP and callback. 0 means default (10 minutes)
+}
+
+// OidcDiscovery contains the fields of the IdP's discovery document which are used by OidcProvider.
+type OidcDiscovery struct {
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	Issuer                        string   `json:"issuer"`
+	JwksUri                       string   `json:"jwks_uri"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint"`
+}
+
+// OidcTokens is the token endpoint response.
+type OidcTokens struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IdToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+}
+
+// OidcClaims contains the verified claims of an ID token.
+type OidcClaims struct {
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Expiry            int64    `json:"exp"`
+	FamilyName        string   `json:"family_name"`
+	GivenName         string   `json:"given_name"`
+	IssuedAt          int64    `json:"iat"`
+	Issuer            string   `json:"iss"`
+	Name              string   `json:"name"`
+	Nonce             string   `json:"nonce"`
+	Picture           string   `json:"picture"`
+	PreferredUsername string   `json:"preferred_username"`
+	Subject           string   `json:"sub"`
+
+	Roles []string       `json:"-"` // from the configured RolesClaim
+	Raw   map[string]any `json:"-"` // all claims, for access to IdP-specific claims
+}
+
+// ApplyTo copies the identity fields of the claims into sessionInput and returns it.
+// Fields which are empty in the claims are left unchanged, so that the app can pre-fill defaults.
+func (c OidcClaims) ApplyTo(sessionInput SessionInput) SessionInput {
+
+	if c.Email != "" {
+		sessionInput.Email = c.Email
+	}
+	if c.FamilyName != "" {
+		sessionInput.FamilyName = c.FamilyName
+	}
+	if c.GivenName != "" {
+		sessionInput.GivenName = c.GivenName
+	}
+	if c.Picture != "" {
+		sessionInput.ProfilePic = c.Picture
+	}
+	if c.Roles != nil {
+		sessionInput.Roles = c.Roles
+	}
+
+	switch {
+	case c.PreferredUsername != "":
+		sessionInput.UserName = c.PreferredUsername
+	case c.Email != "":
+		sessionInput.UserName = c.Email
+	}
+
+	return sessionInput
+}
+
+// OidcAuthRequest contains the details of a started authorization code flow.
+type OidcAuthRequest struct {
+	CodeVerifier string
+	CreatedAt    time.Time
+	Nonce        string
+	State        string
+	Url          string // the IdP URL to redirect the user to
+}
+
+// OidcProvider is an OIDC relying party for a single identity provider.
+type OidcProvider struct {
+	Discovery OidcDiscovery
+
+	config              OidcConfig
+	jwksMinRefreshIntvl time.Duration
+	keys                map[string]crypto.PublicKey // kid → key
+	keysFetchedAt       time.Time
+	logger              *slog.Logger
+	mu                  sync.RWMutex // protects keys, keysFetchedAt and pending
+	now                 func() time.Time
+	pending             map[string]OidcAuthRequest // state → auth request
+}
+
+// NewOidcProvider fetches the IdP's discovery document and signing keys and returns a new OidcProvider.
+func NewOidcProvider(ctx context.Context, config OidcConfig, logger *slog.Logger) (p *OidcProvider, err error) {
+
+	if config.IssuerUrl == "" {
+		return nil, fmt.Errorf("IssuerUrl is required")
+	}
+	if config.ClientId == "" {
+		return nil, fmt.Errorf("ClientId is required")
+	}
+	if config.RedirectUrl == "" {
+		return nil, fmt.Errorf("RedirectUrl is required")
+	}
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	if config.ClockSkew == 0 {
+		config.ClockSkew = defaultOidcClockSkew
+	}
+	if config.HttpClient == nil {
+		config.HttpClient = http.DefaultClient
+	}
+	if config.MaxPending == 0 {
+		config.MaxPending = defaultOidcMaxPending
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = defaultOidcRolesClaim
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if config.StateTtl == 0 {
+		config.StateTtl = defaultOidcStateTtl
+	}
+
+	p = &OidcProvider{
+		config:              config,
+		jwksMinRefreshIntvl: defaultOidcJwksMinRefresh,
+		logger:              logger.With("component", "oidc provider"),
+		now:                 time.Now,
+		pending:             make(map[string]OidcAuthRequest),
+	}
+
+	// discovery
+	discoveryUrl := strings.TrimSuffix(config.IssuerUrl, "/") + oidcDiscoveryPath
+	if err = p.getJson(ctx, discoveryUrl, &p.Discovery); err != nil {
+		return nil, fmt.Errorf("p.getJson failed (discovery): %w", err)
+	}
+	if p.Discovery.Issuer != strings.TrimSuffix(config.IssuerUrl, "/") && p.Discovery.Issuer != config.IssuerUrl {
+		return nil, fmt.Errorf("discovery issuer %s does not match IssuerUrl %s", p.Discovery.Issuer, config.IssuerUrl)
+	}
+	if p.Discovery.AuthorizationEndpoint == "" || p.Discovery.TokenEndpoint == "" || p.Discovery.JwksUri == "" {
+		return nil, fmt.Errorf("discovery document is missing required endpoints")
+	}
+
+	// signing keys
+	if err = p.fetchKeys(ctx); err != nil {
+		return nil, fmt.Errorf("p.fetchKeys failed: %w", err)
+	}
+
+	return p, nil
+}
+
+// AuthCodeUrl starts an authorization code flow with PKCE. The returned request is remembered until it is consumed by Exchange or expires.
+// Expired requests are removed when the number of pending requests reaches MaxPending. If it is still reached, ErrOidcTooManyLogins is returned.
+func (p *OidcProvider) AuthCodeUrl() (authReq OidcAuthRequest, err error) {
+
+	state, err := randBase64Url(24)
+	if err != nil {
+		return OidcAuthRequest{}, fmt.Errorf("randBase64Url failed (state): %w", err)
+	}
+	nonce, err := randBase64Url(24)
+	if err != nil {
+		return OidcAuthRequest{}, fmt.Errorf("randBase64Url failed (nonce): %w", err)
+	}
+	codeVerifier, err := randBase64Url(32) // RFC 7636: 43-128 chars
+	if err != nil {
+		return OidcAuthRequest{}, fmt.Errorf("randBase64Url failed (codeVerifier): %w", err)
+	}
+
+	params := url.Values{
+		"client_id":             {p.config.ClientId},
+		"code_challenge":        {PkceChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+		"nonce":                 {nonce},
+		"redirect_uri":          {p.config.RedirectUrl},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+	}
+
+	sep := "?"
+	if strings.Contains(p.Discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	authReq = OidcAuthRequest{
+		CodeVerifier: codeVerifier,
+		CreatedAt:    p.now(),
+		Nonce:        nonce,
+		State:        state,
+		Url:          p.Discovery.AuthorizationEndpoint + sep + params.Encode(),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) >= p.config.MaxPending {
+		p.cleanupExpiredLocked()
+		if len(p.pending) >= p.config.MaxPending {
+			return OidcAuthRequest{}, ErrOidcTooManyLogins
+		}
+	}
+	p.pending[state] = authReq
+
+	return authReq, nil
+}
+
+// CleanupExpired removes pending auth requests which are older than StateTtl.
+func (p *OidcProvider) CleanupExpired() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cleanupExpiredLocked()
+}
+
+// cleanupExpiredLocked removes expired pending auth requests. p.mu must be held
+func (p *OidcProvider) cleanupExpiredLocked() {
+	cutoff := p.now().Add(-p.config.StateTtl)
+
+	for state, authReq := range p.pending {
+		if authReq.CreatedAt.Before(cutoff) {
+			delete(p.pending, state)
+		}
+	}
+}
+
+// Exchange consumes the pending auth request matching state, redeems code at the token endpoint and verifies the returned ID token.
+func (p *OidcProvider) Exchange(ctx context.Context, state, code string) (claims OidcClaims, tokens OidcTokens, err error) {
+
+	// each state may only be used once
+	p.mu.Lock()
+	authReq, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+
+	if !ok || p.now().After(authReq.CreatedAt.Add(p.config.StateTtl)) {
+		return OidcClaims{}, OidcTokens{}, ErrOidcInvalidState
+	}
+	if code == "" {
+		return OidcClaims{}, OidcTokens{}, lyserr.User{Message: "code missing", StatusCode: http.StatusForbidden}
+	}
+
+	tokens, err = p.redeemCode(ctx, code, authReq.CodeVerifier)
+	if err != nil {
+		return OidcClaims{}, OidcTokens{}, fmt.Errorf("p.redeemCode failed: %w", err)
+	}
+
+	claims, err = p.VerifyIdToken(ctx, tokens.IdToken, authReq.Nonce)
+	if err != nil {
+		return OidcClaims{}, OidcTokens{}, fmt.Errorf("p.VerifyIdToken failed: %w", err)
+	}
+
+	return claims, tokens, nil
+}
+
+// redeemCode posts the authorization code and PKCE verifier to the token endpoint.
+func (p *OidcProvider) redeemCode(ctx context.Context, code, codeVerifier string) (tokens OidcTokens, err error) {
+
+	form := url.Values{
+		"code":          {code},
+		"code_verifier": {codeVerifier},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {p.config.RedirectUrl},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientId)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OidcTokens{}, fmt.Errorf("http.NewRequestWithContext failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.config.HttpClient.Do(req)
+	if err != nil {
+		return OidcTokens{}, lyserr.Ext{Err: fmt.Errorf("HttpClient.Do failed: %w", err), Message: "identity provider unavailable"}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return OidcTokens{}, fmt.Errorf("io.ReadAll failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// e.g. invalid_grant: code expired or PKCE verifier mismatch
+		var errResp struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &errResp)
+		return OidcTokens{}, fmt.Errorf("%w: token endpoint returned %d: %s %s", ErrOidcLoginFailed, resp.StatusCode, errResp.Error, errResp.ErrorDescription)
+	}
+
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return OidcTokens{}, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	if tokens.IdToken == "" {
+		return OidcTokens{}, fmt.Errorf("token response has no id_token")
+	}
+
+	return tokens, nil
+}
+
+// PkceChallenge returns the S256 code challenge for the supplied code verifier.
+func PkceChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OidcSessionInputFunc returns the session input for a user who has logged in via OIDC, typically by looking up or provisioning the app user matching the claims.
+// Ip and UserAgent are filled from the request if not set. Return a lyserr.User to reject the login.
+type OidcSessionInputFunc func(ctx context.Context, r *http.Request, claims OidcClaims) (SessionInput, error)
+
+// OidcLogin returns a handler which redirects the user to the IdP to log in. The login state is also set in a short-lived HttpOnly cookie, which OidcCallback checks.
+func OidcLogin(p *OidcProvider, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		authReq, err := p.AuthCodeUrl()
+		if err != nil {
+			lys.HandleError(r.Context(), fmt.Errorf("OidcLogin: p.AuthCodeUrl failed: %w", err), logger, w)
+			return
+		}
+
+		http.SetCookie(w, p.stateCookie(authReq.State, int(p.config.StateTtl.Seconds())))
+		http.Redirect(w, r, authReq.Url, http.StatusFound)
+	}
+}
+
+// stateCookie returns the cookie containing the login state. maxAge < 0 deletes the cookie.
+// SameSite is Lax, since the callback is a top-level navigation from the IdP.
+func (p *OidcProvider) stateCookie(state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     OidcStateCookieName,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(p.config.RedirectUrl, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// OidcCallback returns a handler for the redirect from the IdP. It exchanges the code, verifies the ID token, and adds a session to appS.
+// The session token is returned in the Data field.
+func OidcCallback(p *OidcProvider, appS *AppSessions, sessionInputFunc OidcSessionInputFunc, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		// the state cookie is single use
+		http.SetCookie(w, p.stateCookie("", -1))
+
+		// the state must have been started by this browser: prevents an attacker from logging the user into the attacker's account
+		stateCookie, err := r.Cookie(OidcStateCookieName)
+		if err != nil || subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(query.Get("state"))) != 1 {
+			lys.HandleError(ctx, fmt.Errorf("OidcCallback: state cookie missing or mismatched: %w", ErrOidcInvalidState), logger, w)
+			return
+		}
+
+		// IdP reported an error, e.g. user denied consent
+		if idpErr := query.Get("error"); idpErr != "" {
+			p.mu.Lock()
+			delete(p.pending, query.Get("state"))
+			p.mu.Unlock()
+			lys.HandleError(ctx, fmt.Errorf("OidcCallback: %w: %s", ErrOidcLoginFailed, idpErr), logger, w)
+			return
+		}
+
+		claims, _, err := p.Exchange(ctx, query.Get("state"), query.Get("code"))
+		if err != nil {
+			lys.HandleError(ctx, fmt.Errorf("OidcCallback: p.Exchange failed: %w", err), logger, w)
+			return
+		}
+
+		sessionInput, err := sessionInputFunc(ctx, r, claims)
+		if err != nil {
+			lys.HandleError(ctx, fmt.Errorf("OidcCallback: sessionInputFunc failed: %w", err), logger, w)
+			return
+		}
+
+		// fill request details
+		if !sessionInput.Ip.IsValid() {
+			sessionInput.Ip, err = GetRemoteHostIP(r, appS.useXForwardedFor, appS.xForwardedForIdx)
+			if err != nil {
+				lys.HandleError(ctx, fmt.Errorf("OidcCallback: GetRemoteHostIP failed: %w", err), logger, w)
+				return
+			}
+		}
+		if sessionInput.UserAgent == "" {
+			sessionInput.UserAgent = r.UserAgent()
+		}
+
+		token, err := appS.Add(sessionInput)
+		if err != nil {
+			lys.HandleError(ctx, fmt.Errorf("OidcCallback: appS.Add failed: %w", err), logger, w)
+			return
+		}
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   token,
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// randBase64Url returns n random bytes, base64url encoded without padding.
+func randBase64Url(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
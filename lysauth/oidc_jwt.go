@@ -0,0 +1,352 @@
+package lysauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jsonWebKey is a single key from a JWKS document. Only the fields needed for signature verification are decoded.
+type jsonWebKey struct {
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	E   string `json:"e"`
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	Use string `json:"use"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the crypto public key represented by jwk.
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+
+	switch jwk.Kty {
+
+	case "RSA":
+		nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		e := new(big.Int).SetBytes(eBytes)
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid e: too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported crv: %s", jwk.Crv)
+		}
+		xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		yBytes, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xBytes), Y: new(big.Int).SetBytes(yBytes)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return pub, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported kty: %s", jwk.Kty)
+	}
+}
+
+// jwtHeader is the decoded JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// supportedJwtAlgs are the signature algorithms accepted by verifyJwtSignature. "none" and HMAC algs are deliberately excluded.
+var supportedJwtAlgs = []string{"RS256", "RS384", "RS512", "ES256", "ES384"}
+
+// parseJwt splits a compact JWT into its decoded header, payload and signature, and returns the signing input.
+func parseJwt(rawToken string) (header jwtHeader, payload, signature []byte, signingInput string, err error) {
+
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return jwtHeader{}, nil, nil, "", fmt.Errorf("token must have 3 parts, has %d", len(parts))
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwtHeader{}, nil, nil, "", fmt.Errorf("header decode failed: %w", err)
+	}
+	if err = json.Unmarshal(headerBytes, &header); err != nil {
+		return jwtHeader{}, nil, nil, "", fmt.Errorf("header json.Unmarshal failed: %w", err)
+	}
+
+	payload, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwtHeader{}, nil, nil, "", fmt.Errorf("payload decode failed: %w", err)
+	}
+
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtHeader{}, nil, nil, "", fmt.Errorf("signature decode failed: %w", err)
+	}
+
+	return header, payload, signature, parts[0] + "." + parts[1], nil
+}
+
+// verifyJwtSignature checks signature against signingInput using the supplied alg and public key.
+func verifyJwtSignature(alg string, pub crypto.PublicKey, signingInput string, signature []byte) error {
+
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg: %s", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+
+	case "RS":
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("alg %s requires an RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaPub, hash, digest, signature); err != nil {
+			return fmt.Errorf("rsa.VerifyPKCS1v15 failed: %w", err)
+		}
+		return nil
+
+	case "ES":
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("alg %s requires an EC key", alg)
+		}
+		// JWS ECDSA signatures are the concatenation of fixed-length r and s
+		size := (ecPub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid ECDSA signature length: %d", len(signature))
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecPub, digest, r, s) {
+			return fmt.Errorf("ecdsa.Verify failed")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported alg: %s", alg)
+	}
+}
+
+// getSigningKey returns the JWKS key matching kid. If it is not cached, the JWKS is refetched, at most once per jwksMinRefreshIntvl.
+func (p *OidcProvider) getSigningKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	lastFetch := p.keysFetchedAt
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	// unknown kid: the IdP may have rotated its keys
+	if !lastFetch.IsZero() && time.Since(lastFetch) < p.jwksMinRefreshIntvl {
+		return nil, fmt.Errorf("key not found: %s", kid)
+	}
+	if err := p.fetchKeys(ctx); err != nil {
+		return nil, fmt.Errorf("p.fetchKeys failed: %w", err)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key not found: %s", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys fetches the JWKS document and replaces the cached signing keys.
+func (p *OidcProvider) fetchKeys(ctx context.Context) error {
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJson(ctx, p.Discovery.JwksUri, &jwks); err != nil {
+		return fmt.Errorf("p.getJson failed: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			// skip keys of unsupported types rather than failing the whole set
+			p.logger.Debug("skipping JWKS key", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = pub
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// audience is the JWT "aud" claim, which may be a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return fmt.Errorf("aud must be a string or array of strings: %w", err)
+	}
+	*a = ss
+	return nil
+}
+
+// VerifyIdToken verifies the signature and standard claims of the supplied ID token, and returns its claims.
+// nonce must be the value sent in the authorization request.
+func (p *OidcProvider) VerifyIdToken(ctx context.Context, rawIdToken, nonce string) (claims OidcClaims, err error) {
+
+	header, payload, signature, signingInput, err := parseJwt(rawIdToken)
+	if err != nil {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("parseJwt failed: %w", err))
+	}
+	if !slices.Contains(supportedJwtAlgs, header.Alg) {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("unsupported alg: %s", header.Alg))
+	}
+
+	pub, err := p.getSigningKey(ctx, header.Kid)
+	if err != nil {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("p.getSigningKey failed: %w", err))
+	}
+	if err = verifyJwtSignature(header.Alg, pub, signingInput, signature); err != nil {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("verifyJwtSignature failed: %w", err))
+	}
+
+	// signature is valid: decode claims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("claims json.Unmarshal failed: %w", err))
+	}
+	claims.Raw = map[string]any{}
+	if err = json.Unmarshal(payload, &claims.Raw); err != nil {
+		return OidcClaims{}, invalidTokenErr(fmt.Errorf("raw claims json.Unmarshal failed: %w", err))
+	}
+	claims.Roles = claimStrings(claims.Raw[p.config.RolesClaim])
+
+	// validate standard claims
+	now := p.now()
+	switch {
+	case claims.Issuer != p.Discovery.Issuer:
+		err = fmt.Errorf("iss mismatch: got %s, want %s", claims.Issuer, p.Discovery.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientId):
+		err = fmt.Errorf("aud %v does not contain client id", claims.Audience)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientId:
+		err = fmt.Errorf("azp mismatch: got %s", claims.AuthorizedParty)
+	case claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(p.config.ClockSkew)):
+		err = fmt.Errorf("token expired")
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(p.config.ClockSkew)):
+		err = fmt.Errorf("token issued in the future")
+	case claims.Nonce != nonce:
+		err = fmt.Errorf("nonce mismatch")
+	case claims.Subject == "":
+		err = fmt.Errorf("sub is missing")
+	}
+	if err != nil {
+		return OidcClaims{}, invalidTokenErr(err)
+	}
+
+	return claims, nil
+}
+
+// claimStrings converts a claim value which may be a string or an array of strings into a string slice.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		ss := []string{}
+		for _, el := range v {
+			if s, ok := el.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	default:
+		return nil
+	}
+}
+
+// getJson GETs url and decodes the JSON response into dest.
+func (p *OidcProvider) getJson(ctx context.Context, url string, dest any) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.config.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HttpClient.Do failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %d", url, resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("json decode failed: %w", err)
+	}
+
+	return nil
+}
+
+// invalidTokenErr wraps err with ErrOidcInvalidToken, so that the user only sees the generic message.
+func invalidTokenErr(err error) error {
+	return fmt.Errorf("%w: %w", ErrOidcInvalidToken, err)
+}
@@ -0,0 +1,366 @@
+package lysauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/loveyourstack/lys"
+	"github.com/stretchr/testify/assert"
+)
+
+// testIdp is a minimal stand-in OIDC identity provider.
+type testIdp struct {
+	*httptest.Server
+	clientId string
+	key      *rsa.PrivateKey
+	kid      string
+
+	mu    sync.Mutex
+	codes map[string]testIdpCode // code → pending authorization
+}
+
+type testIdpCode struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newTestIdp(t *testing.T, clientId string) *testIdp {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey failed: %v", err)
+	}
+
+	idp := &testIdp{clientId: clientId, key: key, kid: "k1", codes: make(map[string]testIdpCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": idp.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		idp.mu.Lock()
+		pending, ok := idp.codes[r.Form.Get("code")]
+		delete(idp.codes, r.Form.Get("code"))
+		idp.mu.Unlock()
+
+		if !ok || PkceChallenge(r.Form.Get("code_verifier")) != pending.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, pending.claims),
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// authorize simulates the user logging in at the IdP: it returns the code which the IdP would send to the redirect URL.
+func (idp *testIdp) authorize(t *testing.T, authUrl string, extraClaims map[string]any) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatalf("url.Parse failed: %v", err)
+	}
+	q := u.Query()
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, idp.clientId, q.Get("client_id"))
+
+	claims := map[string]any{
+		"iss":   idp.URL,
+		"aud":   idp.clientId,
+		"sub":   "user-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range extraClaims {
+		claims[k] = v
+	}
+
+	code = "code-" + q.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = testIdpCode{challenge: q.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func (idp *testIdp) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": idp.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("rsa.SignPKCS1v15 failed: %v", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestOidcProvider(t *testing.T, idp *testIdp) *OidcProvider {
+	t.Helper()
+
+	p, err := NewOidcProvider(context.Background(), OidcConfig{
+		ClientId:    idp.clientId,
+		IssuerUrl:   idp.URL,
+		RedirectUrl: "https://app.example.com/auth/callback",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewOidcProvider failed: %v", err)
+	}
+	return p
+}
+
+func TestOidcCallback(t *testing.T) {
+
+	idp := newTestIdp(t, "lys-app")
+	p := newTestOidcProvider(t, idp)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+
+	// login redirects to IdP
+	rr := httptest.NewRecorder()
+	OidcLogin(p, logger).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	assert.Equal(t, http.StatusFound, rr.Code)
+	authUrl := rr.Header().Get("Location")
+	stateCookies := rr.Result().Cookies()
+	if len(stateCookies) != 1 {
+		t.Fatalf("state cookie len mismatch: got %d, want 1", len(stateCookies))
+	}
+	assert.Equal(t, OidcStateCookieName, stateCookies[0].Name)
+	assert.True(t, stateCookies[0].HttpOnly)
+	assert.True(t, stateCookies[0].Secure)
+
+	code, state := idp.authorize(t, authUrl, map[string]any{
+		"email":              "jane.doe@example.com",
+		"given_name":         "Jane",
+		"family_name":        "Doe",
+		"preferred_username": "jane.doe",
+		"roles":              []string{"Tech", "Admin"},
+	})
+
+	sessionInputFunc := func(ctx context.Context, r *http.Request, claims OidcClaims) (SessionInput, error) {
+		assert.Equal(t, "user-1", claims.Subject)
+		return claims.ApplyTo(SessionInput{
+			GeoIpCountryIsoCode: "US",
+			GeoIpLocation:       "New York, NY",
+			UserId:              42,
+		}), nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code="+url.QueryEscape(code)+"&state="+url.QueryEscape(state), nil)
+	req.RemoteAddr = "198.51.100.100:12345"
+	req.Header.Set("User-Agent", "test-agent")
+	req.AddCookie(stateCookies[0])
+	rr = httptest.NewRecorder()
+	OidcCallback(p, appS, sessionInputFunc, logger).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	resp := lys.StdResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	assert.NotEmpty(t, resp.Data)
+
+	sessions := appS.GetByUserId(42)
+	if len(sessions) != 1 {
+		t.Fatalf("GetByUserId len mismatch: got %d, want 1", len(sessions))
+	}
+	assert.Equal(t, resp.Data, sessions[0].Token)
+	assert.Equal(t, "jane.doe", sessions[0].UserName)
+	assert.Equal(t, "jane.doe@example.com", sessions[0].Email)
+	assert.Equal(t, []string{"Tech", "Admin"}, sessions[0].Roles)
+	assert.Equal(t, netip.MustParseAddr("198.51.100.100"), sessions[0].Ip)
+	assert.Equal(t, "test-agent", sessions[0].UserAgent)
+
+	// state cannot be replayed
+	rr = httptest.NewRecorder()
+	OidcCallback(p, appS, sessionInputFunc, logger).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestOidcCallback_StateCookie(t *testing.T) {
+
+	idp := newTestIdp(t, "lys-app")
+	p := newTestOidcProvider(t, idp)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+
+	sessionInputFunc := func(ctx context.Context, r *http.Request, claims OidcClaims) (SessionInput, error) {
+		return claims.ApplyTo(SessionInput{UserId: 42}), nil
+	}
+
+	tests := []struct {
+		name        string
+		cookieValue string // "" means no cookie
+	}{
+		{name: "missing cookie", cookieValue: ""},
+		{name: "mismatched cookie", cookieValue: "attacker-state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authReq, err := p.AuthCodeUrl()
+			if err != nil {
+				t.Fatalf("AuthCodeUrl failed: %v", err)
+			}
+			code, state := idp.authorize(t, authReq.Url, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/callback?code="+url.QueryEscape(code)+"&state="+url.QueryEscape(state), nil)
+			if tt.cookieValue != "" {
+				req.AddCookie(&http.Cookie{Name: OidcStateCookieName, Value: tt.cookieValue})
+			}
+			rr := httptest.NewRecorder()
+			OidcCallback(p, appS, sessionInputFunc, logger).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			assert.Empty(t, appS.GetByUserId(42))
+		})
+	}
+}
+
+func TestOidcProvider_AuthCodeUrl_MaxPending(t *testing.T) {
+
+	idp := newTestIdp(t, "lys-app")
+	p, err := NewOidcProvider(context.Background(), OidcConfig{
+		ClientId:    idp.clientId,
+		IssuerUrl:   idp.URL,
+		MaxPending:  2,
+		RedirectUrl: "https://app.example.com/auth/callback",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewOidcProvider failed: %v", err)
+	}
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for range 2 {
+		if _, err := p.AuthCodeUrl(); err != nil {
+			t.Fatalf("AuthCodeUrl failed: %v", err)
+		}
+	}
+
+	// cap reached
+	_, err = p.AuthCodeUrl()
+	assert.ErrorIs(t, err, ErrOidcTooManyLogins)
+
+	// expired requests are removed to make room
+	now = now.Add(defaultOidcStateTtl + time.Second)
+	_, err = p.AuthCodeUrl()
+	assert.NoError(t, err)
+	assert.Len(t, p.pending, 1)
+}
+
+func TestOidcProvider_Exchange_Failures(t *testing.T) {
+
+	idp := newTestIdp(t, "lys-app")
+	p := newTestOidcProvider(t, idp)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		extraClaims map[string]any
+		mutate      func(code, state string) (string, string)
+		wantErr     error
+	}{
+		{name: "unknown state", mutate: func(code, state string) (string, string) { return code, "x" }, wantErr: ErrOidcInvalidState},
+		{name: "unknown code", mutate: func(code, state string) (string, string) { return "x", state }, wantErr: ErrOidcLoginFailed},
+		{name: "wrong audience", extraClaims: map[string]any{"aud": "other-app"}, wantErr: ErrOidcInvalidToken},
+		{name: "wrong issuer", extraClaims: map[string]any{"iss": "https://evil.example.com"}, wantErr: ErrOidcInvalidToken},
+		{name: "expired", extraClaims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, wantErr: ErrOidcInvalidToken},
+		{name: "wrong nonce", extraClaims: map[string]any{"nonce": "x"}, wantErr: ErrOidcInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authReq, err := p.AuthCodeUrl()
+			if err != nil {
+				t.Fatalf("AuthCodeUrl failed: %v", err)
+			}
+			code, state := idp.authorize(t, authReq.Url, tt.extraClaims)
+			if tt.mutate != nil {
+				code, state = tt.mutate(code, state)
+			}
+
+			_, _, err = p.Exchange(ctx, state, code)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOidcProvider_VerifyIdToken_Tampered(t *testing.T) {
+
+	idp := newTestIdp(t, "lys-app")
+	p := newTestOidcProvider(t, idp)
+
+	token := idp.sign(t, map[string]any{"iss": idp.URL, "aud": "lys-app", "sub": "1", "exp": time.Now().Add(time.Hour).Unix(), "nonce": "n"})
+	_, err := p.VerifyIdToken(context.Background(), token, "n")
+	assert.NoError(t, err)
+
+	// alter payload but keep signature
+	otherPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + idp.URL + `","aud":"lys-app","sub":"2","exp":9999999999,"nonce":"n"}`))
+	parts := strings.Split(token, ".")
+	_, err = p.VerifyIdToken(context.Background(), parts[0]+"."+otherPayload+"."+parts[2], "n")
+	assert.ErrorIs(t, err, ErrOidcInvalidToken)
+
+	// alg none is rejected
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`))
+	_, err = p.VerifyIdToken(context.Background(), noneHeader+"."+parts[1]+".", "n")
+	assert.ErrorIs(t, err, ErrOidcInvalidToken)
+}
+
+func TestPkceChallenge(t *testing.T) {
+	// base64url(sha256(verifier)) without padding
+	assert.Equal(t, "3yoxVMovk58aKiMy6d9BqHQK9qkoqiJ-3I2Dhll0myI", PkceChallenge("dBjftJeZ4CVP-mB92K9uhvDBpKbUI4uECz3Qoxtl3zQ"))
+}
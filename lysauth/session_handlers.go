@@ -0,0 +1,175 @@
+package lysauth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lyserr"
+)
+
+var (
+	ErrSessionIdMissing = lyserr.User{Message: "session id missing", StatusCode: http.StatusBadRequest}
+	ErrSessionNotFound  = lyserr.User{Message: "session not found", StatusCode: http.StatusNotFound}
+)
+
+// SessionListItem is a session as returned by the session handlers.
+type SessionListItem struct {
+	IsCurrent bool `json:"is_current"` // whether this is the session making the request
+	Session
+}
+
+// toSessionListItems converts sessions to list items, flagging the one with currentToken.
+func toSessionListItems(sessions []Session, currentToken string) (items []SessionListItem) {
+	items = make([]SessionListItem, 0, len(sessions)) // for JSON encoding to return [] instead of null
+	for _, sess := range sessions {
+		items = append(items, SessionListItem{IsCurrent: sess.Token == currentToken, Session: sess})
+	}
+	return items
+}
+
+// MySessions returns a handler listing the sessions of the requesting user, most recently accessed first.
+func MySessions(appS *AppSessions, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		current, err := appS.FromRequest(r, logger)
+		if err != nil {
+			lys.HandleError(r.Context(), fmt.Errorf("MySessions: appS.FromRequest failed: %w", err), logger, w)
+			return
+		}
+
+		var sessions []Session
+		for _, sess := range appS.ListByLastAccessAt(false) {
+			if sess.UserId == current.UserId {
+				sessions = append(sessions, sess)
+			}
+		}
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   toSessionListItems(sessions, current.Token),
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// RevokeMySession returns a handler which deletes the session with the "id" path param, provided it belongs to the requesting user.
+// Revoking the current session logs the user out.
+func RevokeMySession(appS *AppSessions, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		current, err := appS.FromRequest(r, logger)
+		if err != nil {
+			lys.HandleError(r.Context(), fmt.Errorf("RevokeMySession: appS.FromRequest failed: %w", err), logger, w)
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		if id == "" {
+			lys.HandleError(r.Context(), ErrSessionIdMissing, logger, w)
+			return
+		}
+
+		// other users' sessions are reported as not found
+		sess, found := appS.GetById(id)
+		if !found || sess.UserId != current.UserId || !appS.DeleteById(id) {
+			lys.HandleError(r.Context(), ErrSessionNotFound, logger, w)
+			return
+		}
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   lys.DataDeleted,
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// RevokeMyOtherSessions returns a handler which deletes all sessions of the requesting user except the current one.
+// The number of revoked sessions is returned in Data.
+func RevokeMyOtherSessions(appS *AppSessions, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		current, err := appS.FromRequest(r, logger)
+		if err != nil {
+			lys.HandleError(r.Context(), fmt.Errorf("RevokeMyOtherSessions: appS.FromRequest failed: %w", err), logger, w)
+			return
+		}
+
+		count := appS.DeleteByUserIdExcept(current.UserId, current.Token)
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   count,
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// AdminListSessions returns a handler listing all sessions, most recently accessed first. The optional "user_id" query param filters by user.
+// The handler does not check permissions: restrict the route to admins using the app's own middleware.
+func AdminListSessions(appS *AppSessions, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		sessions := appS.ListByLastAccessAt(false)
+
+		if userIdStr := r.URL.Query().Get("user_id"); userIdStr != "" {
+			userId, err := strconv.ParseInt(userIdStr, 10, 64)
+			if err != nil {
+				lys.HandleError(r.Context(), lyserr.User{Message: "invalid user_id"}, logger, w)
+				return
+			}
+
+			var filtered []Session
+			for _, sess := range sessions {
+				if sess.UserId == userId {
+					filtered = append(filtered, sess)
+				}
+			}
+			sessions = filtered
+		}
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   toSessionListItems(sessions, ""),
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
+
+// AdminRevokeSession returns a handler which deletes the session with the "id" path param, regardless of which user it belongs to.
+// The handler does not check permissions: restrict the route to admins using the app's own middleware.
+func AdminRevokeSession(appS *AppSessions, logger *slog.Logger) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := mux.Vars(r)["id"]
+		if id == "" {
+			lys.HandleError(r.Context(), ErrSessionIdMissing, logger, w)
+			return
+		}
+
+		if !appS.DeleteById(id) {
+			lys.HandleError(r.Context(), ErrSessionNotFound, logger, w)
+			return
+		}
+
+		// success
+		resp := lys.StdResponse{
+			Status: lys.ReqSucceeded,
+			Data:   lys.DataDeleted,
+		}
+		lys.JsonResponse(resp, http.StatusOK, w)
+	}
+}
@@ -0,0 +1,173 @@
+package lysauth
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/loveyourstack/lys"
+	"github.com/stretchr/testify/assert"
+)
+
+// addTestSessions adds two sessions for user 1 and one for user 2, returning their tokens.
+func addTestSessions(t *testing.T, appS *AppSessions) (tokens []string) {
+	t.Helper()
+
+	for i, userId := range []int64{1, 1, 2} {
+		input := newDefaultSessionInput()
+		input.AllowMultipleSessions = true
+		input.Ip = netip.AddrFrom4([4]byte{198, 51, 100, byte(10 + i)})
+		input.UserId = userId
+
+		token, err := appS.Add(input)
+		if err != nil {
+			t.Fatalf("appS.Add failed: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func decodeSessionList(t *testing.T, rr *httptest.ResponseRecorder) (items []SessionListItem) {
+	t.Helper()
+
+	resp := struct {
+		Data []SessionListItem `json:"data"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	return resp.Data
+}
+
+func TestMySessions(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+	tokens := addTestSessions(t, appS)
+
+	rr := httptest.NewRecorder()
+	MySessions(appS, logger).ServeHTTP(rr, newAuthRequest(t, "198.51.100.10", tokens[0], "test-agent"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	items := decodeSessionList(t, rr)
+	if len(items) != 2 {
+		t.Fatalf("len mismatch: got %d, want 2", len(items))
+	}
+	current := 0
+	for _, item := range items {
+		assert.EqualValues(t, 1, item.UserId)
+		assert.NotEmpty(t, item.Id)
+		assert.Empty(t, item.Token) // token is never sent
+		if item.IsCurrent {
+			current++
+		}
+	}
+	assert.Equal(t, 1, current)
+	assert.NotContains(t, rr.Body.String(), tokens[0])
+}
+
+func TestRevokeMySession(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+	tokens := addTestSessions(t, appS)
+
+	ownOther := appS.GetByUserId(1)
+	otherUser := appS.GetByUserId(2)[0]
+
+	var ownOtherId string
+	for _, sess := range ownOther {
+		if sess.Token == tokens[1] {
+			ownOtherId = sess.Id
+		}
+	}
+
+	revoke := func(id string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(newAuthRequest(t, "198.51.100.10", tokens[0], "test-agent"), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		RevokeMySession(appS, logger).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// another user's session cannot be revoked
+	rr := revoke(otherUser.Id)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, 3, appS.Count())
+
+	// own session
+	rr = revoke(ownOtherId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, appS.Count())
+
+	// already revoked
+	rr = revoke(ownOtherId)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRevokeMyOtherSessions(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+	tokens := addTestSessions(t, appS)
+
+	rr := httptest.NewRecorder()
+	RevokeMyOtherSessions(appS, logger).ServeHTTP(rr, newAuthRequest(t, "198.51.100.10", tokens[0], "test-agent"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	resp := lys.StdResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	assert.EqualValues(t, 1, resp.Data)
+
+	// current session and other user's session remain
+	remaining := appS.GetByUserId(1)
+	if len(remaining) != 1 {
+		t.Fatalf("len mismatch: got %d, want 1", len(remaining))
+	}
+	assert.Equal(t, tokens[0], remaining[0].Token)
+	assert.Len(t, appS.GetByUserId(2), 1)
+}
+
+func TestAdminSessions(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appS := NewAppSessions(validator.New(), time.Hour, false, 0)
+	_ = addTestSessions(t, appS)
+
+	// list all
+	rr := httptest.NewRecorder()
+	AdminListSessions(appS, logger).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sessions", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, decodeSessionList(t, rr), 3)
+
+	// filter by user
+	rr = httptest.NewRecorder()
+	AdminListSessions(appS, logger).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sessions?user_id=2", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, decodeSessionList(t, rr), 1)
+
+	rr = httptest.NewRecorder()
+	AdminListSessions(appS, logger).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sessions?user_id=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// revoke
+	id := appS.GetByUserId(2)[0].Id
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/sessions/"+id, nil), map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	AdminRevokeSession(appS, logger).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, appS.Count())
+
+	rr = httptest.NewRecorder()
+	AdminRevokeSession(appS, logger).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -37,14 +37,24 @@ type SessionInput struct {
 type Session struct {
 	CreatedAt    lystype.Datetime `json:"created_at"`
 	ExpiresAt    lystype.Datetime `json:"expires_at"`
+	Id           string           `json:"id"` // public identifier, safe to expose to clients unlike Token
 	LastAccessAt lystype.Datetime `json:"last_access_at"`
 	Token        string           `json:"-"`
 	SessionInput
 }
 
+// SessionHooks are optional callbacks fired when sessions change, e.g. to write an audit log or to push a logout notification to the user's websocket.
+// Hooks are called synchronously after the change has been made and the lock released, so they may call AppSessions methods.
+type SessionHooks struct {
+	OnCreate func(sess Session) // session was added
+	OnExpire func(sess Session) // expired session was removed by DeleteExpired or FromRequest
+	OnRevoke func(sess Session) // session was deleted before expiry, e.g. by logout, admin action or single session policy
+}
+
 // AppSessions contains sessions and methods to manage them.
 type AppSessions struct {
 	all              map[string]Session // map of token to session
+	hooks            SessionHooks
 	mu               sync.RWMutex
 	sessionDuration  time.Duration // duration of a session before it expires
 	useXForwardedFor bool          // whether to use X-Forwarded-For header to determine IP
@@ -93,13 +103,19 @@ func (appS *AppSessions) Add(sessionInput SessionInput) (token string, err error
 
 		CreatedAt:    lystype.Datetime(time.Now()),
 		ExpiresAt:    lystype.Datetime(time.Now().Add(appS.sessionDuration)),
+		Id:           lysstring.Rand(16),
 		LastAccessAt: lystype.Datetime(time.Now()),
 		Token:        token,
 	}
 	appS.mu.Lock()
 	appS.all[token] = sess
+	onCreate := appS.hooks.OnCreate
 	appS.mu.Unlock()
 
+	if onCreate != nil {
+		onCreate(sess)
+	}
+
 	return token, nil
 }
 
@@ -132,42 +148,63 @@ func (appS *AppSessions) DeleteByIp(ip netip.Addr) error {
 		ip = ip.Unmap()
 	}
 
-	appS.mu.Lock()
-	defer appS.mu.Unlock()
-
-	for token, session := range appS.all {
-		if session.Ip == ip {
-			delete(appS.all, token)
-		}
-	}
+	appS.deleteWhere(func(session Session) bool { return session.Ip == ip }, false)
 
 	return nil
 }
 
+// DeleteById deletes the session with the specified public ID. It returns false if the session was not found.
+func (appS *AppSessions) DeleteById(id string) (found bool) {
+	if id == "" {
+		return false
+	}
+	return len(appS.deleteWhere(func(session Session) bool { return session.Id == id }, false)) > 0
+}
+
 // DeleteByTokens deletes the sessions for the specified tokens.
 func (appS *AppSessions) DeleteByTokens(tokens []string) {
-	appS.mu.Lock()
-	defer appS.mu.Unlock()
-
-	for _, token := range tokens {
-		_, exists := appS.all[token]
-		if !exists {
-			continue
-		}
-		delete(appS.all, token)
-	}
+	appS.deleteWhere(func(session Session) bool { return slices.Contains(tokens, session.Token) }, false)
 }
 
 // DeleteByUserId deletes all sessions for the specified user ID.
 func (appS *AppSessions) DeleteByUserId(userId int64) {
-	appS.mu.Lock()
-	defer appS.mu.Unlock()
+	appS.deleteWhere(func(session Session) bool { return session.UserId == userId }, false)
+}
+
+// DeleteByUserIdExcept deletes all sessions for the specified user ID except the one with keepToken, and returns the number deleted.
+func (appS *AppSessions) DeleteByUserIdExcept(userId int64, keepToken string) (count int) {
+	return len(appS.deleteWhere(func(session Session) bool { return session.UserId == userId && session.Token != keepToken }, false))
+}
 
+// DeleteExpired deletes all expired sessions and returns the number deleted. Call it periodically to fire the OnExpire hook and free memory.
+func (appS *AppSessions) DeleteExpired() (count int) {
+	now := time.Now()
+	return len(appS.deleteWhere(func(session Session) bool { return now.After(time.Time(session.ExpiresAt)) }, true))
+}
+
+// deleteWhere deletes all sessions matching the supplied func and fires the OnExpire or OnRevoke hook for each of them.
+func (appS *AppSessions) deleteWhere(match func(session Session) bool, expired bool) (deleted []Session) {
+
+	appS.mu.Lock()
 	for token, session := range appS.all {
-		if session.UserId == userId {
+		if match(session) {
+			deleted = append(deleted, session)
 			delete(appS.all, token)
 		}
 	}
+	hook := appS.hooks.OnRevoke
+	if expired {
+		hook = appS.hooks.OnExpire
+	}
+	appS.mu.Unlock()
+
+	if hook != nil {
+		for _, session := range deleted {
+			hook(session)
+		}
+	}
+
+	return deleted
 }
 
 // FromRequest returns the session associated with the request, or an error if the session is invalid.
@@ -216,16 +253,24 @@ func (appS *AppSessions) FromRequest(r *http.Request, logger *slog.Logger) (sess
 		return Session{}, lyserr.User{Message: "invalid token", StatusCode: http.StatusForbidden}
 	}
 
-	// check if session has expired
-	if time.Now().After(time.Time(session.ExpiresAt)) {
+	// check if session has expired: if so, remove it, unless a concurrent request already has
+	now := time.Now()
+	if now.After(time.Time(session.ExpiresAt)) {
+		appS.deleteWhere(func(s Session) bool { return s.Token == token && now.After(time.Time(s.ExpiresAt)) }, true)
 		return Session{}, lyserr.User{Message: "session expired", StatusCode: http.StatusForbidden}
 	}
 
 	// session verified, http only: update LastAccessAt and ExpiresAt
+	// the session is re-read under the write lock, so that a session revoked or changed since the read above is not overwritten
 	if !isWebSocket {
-		session.LastAccessAt = lystype.Datetime(time.Now())
-		session.ExpiresAt = lystype.Datetime(time.Now().Add(appS.sessionDuration))
 		appS.mu.Lock()
+		session, exists = appS.all[token]
+		if !exists {
+			appS.mu.Unlock()
+			return Session{}, lyserr.User{Message: "token not found", StatusCode: http.StatusForbidden}
+		}
+		session.LastAccessAt = lystype.Datetime(now)
+		session.ExpiresAt = lystype.Datetime(now.Add(appS.sessionDuration))
 		appS.all[token] = session
 		appS.mu.Unlock()
 	}
@@ -233,6 +278,19 @@ func (appS *AppSessions) FromRequest(r *http.Request, logger *slog.Logger) (sess
 	return session, nil
 }
 
+// GetById returns the session with the specified public ID.
+func (appS *AppSessions) GetById(id string) (sess Session, found bool) {
+	appS.mu.RLock()
+	defer appS.mu.RUnlock()
+
+	for _, session := range appS.all {
+		if id != "" && session.Id == id {
+			return session, true
+		}
+	}
+	return Session{}, false
+}
+
 // GetByUserId returns all sessions for the specified user ID.
 func (appS *AppSessions) GetByUserId(userId int64) (sessions []Session) {
 	appS.mu.RLock()
@@ -308,12 +366,23 @@ func (appS *AppSessions) Load(sessions []Session) (err error) {
 	}
 
 	for _, session := range sessions {
+		// sessions saved by earlier versions have no ID
+		if session.Id == "" {
+			session.Id = lysstring.Rand(16)
+		}
 		appS.all[session.Token] = session
 	}
 
 	return nil
 }
 
+// SetHooks sets the callbacks fired when sessions are created, expired or revoked.
+func (appS *AppSessions) SetHooks(hooks SessionHooks) {
+	appS.mu.Lock()
+	defer appS.mu.Unlock()
+	appS.hooks = hooks
+}
+
 // UpdateProfilePicByUserId updates the ProfilePic for all sessions belonging to the specified user ID.
 func (appS *AppSessions) UpdateProfilePicByUserId(userId int64, profilePic string) {
 	appS.mu.Lock()
@@ -934,3 +934,78 @@ func TestAppSessions_ConcurrentAddAndFromRequest(t *testing.T) {
 		t.Fatalf("Count mismatch after concurrent Add/FromRequest: got %d, want %d", got, totalAdds)
 	}
 }
+
+func TestAppSessions_Hooks(t *testing.T) {
+	validate := validator.New()
+	appS := NewAppSessions(validate, 10*time.Hour, false, 0)
+
+	var created, expired, revoked []Session
+	appS.SetHooks(SessionHooks{
+		OnCreate: func(sess Session) { created = append(created, sess) },
+		OnExpire: func(sess Session) { expired = append(expired, sess) },
+		OnRevoke: func(sess Session) {
+			revoked = append(revoked, sess)
+			_ = appS.Count() // hooks may call AppSessions methods without deadlocking
+		},
+	})
+
+	token1, err := appS.Add(newDefaultSessionInput())
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(created) != 1 || created[0].Token != token1 || created[0].Id == "" {
+		t.Fatalf("OnCreate mismatch: got %d calls", len(created))
+	}
+
+	// single session policy revokes the prior session
+	token2, err := appS.Add(newDefaultSessionInput())
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0].Token != token1 {
+		t.Fatalf("OnRevoke mismatch after Add: got %d calls", len(revoked))
+	}
+
+	// expire the remaining session
+	appS.mu.Lock()
+	sess := appS.all[token2]
+	sess.ExpiresAt = lystype.Datetime(time.Now().Add(-time.Minute))
+	appS.all[token2] = sess
+	appS.mu.Unlock()
+
+	if got := appS.DeleteExpired(); got != 1 {
+		t.Fatalf("DeleteExpired count mismatch: got %d, want 1", got)
+	}
+	if len(expired) != 1 || expired[0].Token != token2 {
+		t.Fatalf("OnExpire mismatch: got %d calls", len(expired))
+	}
+	if len(revoked) != 1 {
+		t.Fatalf("OnRevoke should not fire on expiry: got %d calls", len(revoked))
+	}
+
+	// an expired session found by FromRequest is removed
+	sessionInput := newDefaultSessionInput()
+	token3, err := appS.Add(sessionInput)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	appS.mu.Lock()
+	sess = appS.all[token3]
+	sess.ExpiresAt = lystype.Datetime(time.Now().Add(-time.Minute))
+	appS.all[token3] = sess
+	appS.mu.Unlock()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = sessionInput.Ip.String() + ":12345"
+	req.Header.Set("Authorization", "Bearer "+token3)
+	req.Header.Set("User-Agent", sessionInput.UserAgent)
+	if _, err := appS.FromRequest(req, slog.Default()); err == nil || !strings.Contains(err.Error(), "session expired") {
+		t.Fatalf("FromRequest(expired) error mismatch: got %v", err)
+	}
+	if len(expired) != 2 || expired[1].Token != token3 {
+		t.Fatalf("OnExpire mismatch after FromRequest: got %d calls", len(expired))
+	}
+	if got := appS.Count(); got != 0 {
+		t.Fatalf("Count mismatch after FromRequest: got %d, want 0", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
 	"reflect"
 	"slices"
@@ -152,7 +153,7 @@ func getStrData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type
 				if !ok {
 					continue
 				}
-				row[j] = timeVal.Format(lystype.DatetimeFormat)
+				row[j] = timeVal.String() // rendered in the value's location, which may have been set to the user's timezone
 
 			case reflect.TypeFor[lystype.Time]():
 				timeVal, ok := val.(lystype.Time)
@@ -161,13 +162,24 @@ func getStrData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type
 				}
 				row[j] = timeVal.Format(lystype.TimeFormat)
 
-			default:
-				strVal, ok := val.(string)
-				if ok {
-					row[j] = strVal
+			case reflect.TypeFor[netip.Prefix](), reflect.TypeFor[*netip.Prefix]():
+				prefixVal, ok := val.(netip.Prefix)
+				if ok && prefixVal.IsValid() {
+					row[j] = prefixVal.String()
 				} else {
 					row[j] = ""
 				}
+
+			default:
+				// other types such as lystype.Decimal, lystype.Interval or lystype ranges are written using their String method
+				switch v := val.(type) {
+				case string:
+					row[j] = v
+				case fmt.Stringer:
+					row[j] = v.String()
+				default:
+					row[j] = ""
+				}
 			}
 
 		} // next key
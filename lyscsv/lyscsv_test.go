@@ -3,6 +3,7 @@ package lyscsv
 import (
 	"bytes"
 	"errors"
+	"net/netip"
 	"reflect"
 	"testing"
 	"time"
@@ -24,6 +25,40 @@ type writeItemsRec struct {
 	NoJSONTag string
 }
 
+type writeItemsFurtherTypesRec struct {
+	CDecimal   *lystype.Decimal    `json:"c_decimal"`
+	CInterval  lystype.Interval    `json:"c_interval"`
+	CJSON      lystype.JSON[[]int] `json:"c_json"`
+	CMoney     lystype.Money       `json:"c_money"`
+	CNetPrefix netip.Prefix        `json:"c_net_prefix"`
+	CRange     lystype.DateRange   `json:"c_range"`
+}
+
+func newWriteItemsFurtherTypesRec(t *testing.T) writeItemsFurtherTypesRec {
+	dec, err := lystype.ParseDecimal("12345678901234567890.10")
+	require.NoError(t, err)
+	dr, err := lystype.ParseRange[lystype.Date]("[2026-01-01,2026-02-01)", nil)
+	require.NoError(t, err)
+
+	return writeItemsFurtherTypesRec{
+		CDecimal:   &dec,
+		CInterval:  lystype.Interval{Days: 1, Microseconds: 3600000000},
+		CJSON:      lystype.JSON[[]int]{V: []int{1, 2}},
+		CMoney:     lystype.MoneyFromCents(-123450),
+		CNetPrefix: netip.MustParsePrefix("10.0.0.0/8"),
+		CRange:     dr,
+	}
+}
+
+var writeItemsFurtherTypesMap = map[string]reflect.Type{
+	"c_decimal":    reflect.TypeFor[*lystype.Decimal](),
+	"c_interval":   reflect.TypeFor[lystype.Interval](),
+	"c_json":       reflect.TypeFor[lystype.JSON[[]int]](),
+	"c_money":      reflect.TypeFor[lystype.Money](),
+	"c_net_prefix": reflect.TypeFor[netip.Prefix](),
+	"c_range":      reflect.TypeFor[lystype.DateRange](),
+}
+
 func TestWriteItemsSuccess(t *testing.T) {
 
 	t.Run("with items", func(t *testing.T) {
@@ -62,6 +97,19 @@ func TestWriteItemsSuccess(t *testing.T) {
 		assert.Equal(t, expected, b.String())
 	})
 
+	t.Run("with further lystypes", func(t *testing.T) {
+		items := []writeItemsFurtherTypesRec{newWriteItemsFurtherTypesRec(t), {}}
+
+		var b bytes.Buffer
+		err := WriteItems(items, writeItemsFurtherTypesMap, ';', &b)
+		require.NoError(t, err)
+
+		expected := "c_decimal;c_interval;c_json;c_money;c_net_prefix;c_range\n" +
+			"12345678901234567890.10;P1DT1H;[1,2];-1234.50;10.0.0.0/8;[2026-01-01,2026-02-01)\n" +
+			";PT0S;null;0.00;;(,)\n"
+		assert.Equal(t, expected, b.String())
+	})
+
 	t.Run("empty items", func(t *testing.T) {
 		var b bytes.Buffer
 		err := WriteItems([]writeItemsRec{}, map[string]reflect.Type{"name": reflect.TypeFor[string]()}, ',', &b)
@@ -3,6 +3,7 @@ package lysexcel
 import (
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
 	"reflect"
 	"slices"
@@ -14,11 +15,21 @@ import (
 	"golang.org/x/exp/maps"
 )
 
+// WriteOptions configures WriteItems
+type WriteOptions struct {
+	Location *time.Location // if set, datetimes are written as shown in it, e.g. the user's timezone. Otherwise in UTC
+}
+
 // WriteItems writes an Excel workbook to a writer from items.
 // T must have json tags set. Only the fields with a json tag get written.
 // jsonTagTypeMap is a map of [json tag]type.
 // sheetName is optional and defaults to "data".
-func WriteItems[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetName string, w io.Writer) (err error) {
+func WriteItems[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetName string, w io.Writer, options ...WriteOptions) (err error) {
+
+	var opts WriteOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
 
 	if len(jsonTagTypeMap) == 0 {
 		return fmt.Errorf("jsonTagTypeMap is empty")
@@ -34,7 +45,7 @@ func WriteItems[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetN
 	}
 
 	// write to Excel file in memory, return workbook
-	wb, sh, err := writeData(recsMap, jsonTagTypeMap, sheetName)
+	wb, sh, err := writeData(recsMap, jsonTagTypeMap, sheetName, opts.Location)
 	if err != nil {
 		return fmt.Errorf("writeData failed: %w", err)
 	}
@@ -53,7 +64,7 @@ func WriteItems[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetN
 // T must have json tags set. Only the fields with a json tag get written.
 // jsonTagTypeMap is a map of [json tag]type.
 // sheetName is optional and defaults to "data".
-func WriteItemsToFile[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetName, filePath string) (err error) {
+func WriteItemsToFile[T any](items []T, jsonTagTypeMap map[string]reflect.Type, sheetName, filePath string, options ...WriteOptions) (err error) {
 
 	if filePath == "" {
 		return fmt.Errorf("filePath is mandatory")
@@ -70,18 +81,21 @@ func WriteItemsToFile[T any](items []T, jsonTagTypeMap map[string]reflect.Type,
 		}
 	}()
 
-	if err := WriteItems(items, jsonTagTypeMap, sheetName, f); err != nil {
+	if err := WriteItems(items, jsonTagTypeMap, sheetName, f, options...); err != nil {
 		return fmt.Errorf("WriteItems failed: %w", err)
 	}
 
 	return nil
 }
 
-func writeData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type, sheetName string) (wb *xlsx.File, sh *xlsx.Sheet, err error) {
+func writeData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type, sheetName string, loc *time.Location) (wb *xlsx.File, sh *xlsx.Sheet, err error) {
 
 	if sheetName == "" {
 		sheetName = "data"
 	}
+	if loc == nil {
+		loc = time.UTC
+	}
 
 	// get sorted keys
 	keys := maps.Keys(jsonTagTypeMap)
@@ -155,7 +169,7 @@ func writeData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type,
 			case reflect.TypeFor[lystype.Datetime]():
 				timeVal, ok := val.(lystype.Datetime)
 				if ok {
-					cell.SetDateTime(time.Time(timeVal))
+					cell.SetDateTime(wallClock(time.Time(timeVal).In(loc)))
 				}
 
 			case reflect.TypeFor[lystype.Time]():
@@ -164,10 +178,31 @@ func writeData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type,
 					cell.SetString(timeVal.Format(lystype.TimeFormat))
 				}
 
-			default:
-				strVal, ok := val.(string)
+			case reflect.TypeFor[lystype.Decimal](), reflect.TypeFor[*lystype.Decimal]():
+				decVal, ok := val.(lystype.Decimal)
+				if ok {
+					cell.SetNumeric(decVal.String())
+				}
+
+			case reflect.TypeFor[lystype.Money](), reflect.TypeFor[*lystype.Money]():
+				moneyVal, ok := val.(lystype.Money)
 				if ok {
-					cell.SetString(strVal)
+					cell.SetFloatWithFormat(moneyVal.Float64(), "#,##0.00")
+				}
+
+			case reflect.TypeFor[netip.Prefix](), reflect.TypeFor[*netip.Prefix]():
+				prefixVal, ok := val.(netip.Prefix)
+				if ok && prefixVal.IsValid() {
+					cell.SetString(prefixVal.String())
+				}
+
+			default:
+				// other types such as lystype.Interval or lystype ranges are written using their String method
+				switch v := val.(type) {
+				case string:
+					cell.SetString(v)
+				case fmt.Stringer:
+					cell.SetString(v.String())
 				}
 			}
 
@@ -177,3 +212,9 @@ func writeData(recsMap []map[string]any, jsonTagTypeMap map[string]reflect.Type,
 
 	return wb, sh, nil
 }
+
+// wallClock returns the date and time of t as shown in its location, but in UTC. Excel datetimes have no timezone and xlsx converts them to UTC,
+// so this preserves the location which t was converted to
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -3,6 +3,7 @@ package lysexcel
 import (
 	"bytes"
 	"errors"
+	"net/netip"
 	"reflect"
 	"testing"
 	"time"
@@ -25,6 +26,40 @@ type writeItemsRec struct {
 	NoJSONTag string
 }
 
+type writeItemsFurtherTypesRec struct {
+	CDecimal   *lystype.Decimal    `json:"c_decimal"`
+	CInterval  lystype.Interval    `json:"c_interval"`
+	CJSON      lystype.JSON[[]int] `json:"c_json"`
+	CMoney     lystype.Money       `json:"c_money"`
+	CNetPrefix netip.Prefix        `json:"c_net_prefix"`
+	CRange     lystype.DateRange   `json:"c_range"`
+}
+
+func newWriteItemsFurtherTypesRec(t *testing.T) writeItemsFurtherTypesRec {
+	dec, err := lystype.ParseDecimal("12345678901234567890.10")
+	require.NoError(t, err)
+	dr, err := lystype.ParseRange[lystype.Date]("[2026-01-01,2026-02-01)", nil)
+	require.NoError(t, err)
+
+	return writeItemsFurtherTypesRec{
+		CDecimal:   &dec,
+		CInterval:  lystype.Interval{Days: 1, Microseconds: 3600000000},
+		CJSON:      lystype.JSON[[]int]{V: []int{1, 2}},
+		CMoney:     lystype.MoneyFromCents(-123450),
+		CNetPrefix: netip.MustParsePrefix("10.0.0.0/8"),
+		CRange:     dr,
+	}
+}
+
+var writeItemsFurtherTypesMap = map[string]reflect.Type{
+	"c_decimal":    reflect.TypeFor[*lystype.Decimal](),
+	"c_interval":   reflect.TypeFor[lystype.Interval](),
+	"c_json":       reflect.TypeFor[lystype.JSON[[]int]](),
+	"c_money":      reflect.TypeFor[lystype.Money](),
+	"c_net_prefix": reflect.TypeFor[netip.Prefix](),
+	"c_range":      reflect.TypeFor[lystype.DateRange](),
+}
+
 func TestWriteItemsSuccess(t *testing.T) {
 
 	t.Run("with items", func(t *testing.T) {
@@ -89,6 +124,46 @@ func TestWriteItemsSuccess(t *testing.T) {
 		assert.Equal(t, "09:15", dataRow.GetCell(6).String())
 	})
 
+	t.Run("with location", func(t *testing.T) {
+		timeDT := time.Date(2026, 4, 23, 12, 34, 56, 0, time.FixedZone("UTC+2", 2*3600))
+		items := []writeItemsRec{{CDatetime: lystype.Datetime(timeDT)}}
+		jsonTagTypeMap := map[string]reflect.Type{"c_datetime": reflect.TypeFor[lystype.Datetime]()}
+
+		var b bytes.Buffer
+		err := WriteItems(items, jsonTagTypeMap, "", &b, WriteOptions{Location: time.FixedZone("UTC+5:30", 5*3600+1800)})
+		require.NoError(t, err)
+
+		wb, err := xlsx.OpenBinary(b.Bytes())
+		require.NoError(t, err)
+		dataRow, err := wb.Sheets[0].Row(1)
+		require.NoError(t, err)
+		assert.Equal(t, "4/23/26 16:04", dataRow.GetCell(0).String()) // wall clock in the location
+	})
+
+	t.Run("with further lystypes", func(t *testing.T) {
+		items := []writeItemsFurtherTypesRec{newWriteItemsFurtherTypesRec(t)}
+
+		var b bytes.Buffer
+		err := WriteItems(items, writeItemsFurtherTypesMap, "", &b)
+		require.NoError(t, err)
+
+		wb, err := xlsx.OpenBinary(b.Bytes())
+		require.NoError(t, err)
+		dataRow, err := wb.Sheets[0].Row(1)
+		require.NoError(t, err)
+
+		decVal, err := dataRow.GetCell(0).Float()
+		require.NoError(t, err)
+		assert.Equal(t, 12345678901234567890.10, decVal)
+		assert.Equal(t, "P1DT1H", dataRow.GetCell(1).String())
+		assert.Equal(t, "[1,2]", dataRow.GetCell(2).String())
+		moneyVal, err := dataRow.GetCell(3).Float()
+		require.NoError(t, err)
+		assert.Equal(t, -1234.5, moneyVal)
+		assert.Equal(t, "10.0.0.0/8", dataRow.GetCell(4).String())
+		assert.Equal(t, "[2026-01-01,2026-02-01)", dataRow.GetCell(5).String())
+	})
+
 	t.Run("empty items", func(t *testing.T) {
 		var b bytes.Buffer
 		err := WriteItems([]writeItemsRec{}, map[string]reflect.Type{"name": reflect.TypeFor[string]()}, "", &b)
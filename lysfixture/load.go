@@ -0,0 +1,353 @@
+package lysfixture
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+// refPrefix starts a reference to another row in a foreign key column, e.g. "@category.seafood". In any column, "@@" escapes a literal "@"
+const refPrefix string = "@"
+
+// column is a db column of a fixture table
+type column struct {
+	Name     string `db:"name"`
+	TypeOid  uint32 `db:"type_oid"`  // base type for domains
+	TypeName string `db:"type_name"` // as used in a cast, e.g. "numeric(12,2)" or "core.weekday[]"
+	SeqName  string `db:"seq_name"`  // identity or serial sequence, if any
+}
+
+// tableInfo contains the db metadata of a fixture table
+type tableInfo struct {
+	columns map[string]column
+	fks     map[string]lyspg.ForeignKey // by child column
+}
+
+// Load inserts fx into db, with tables in foreign key dependency order. Run it in a tx so that it is atomic.
+// Values are sent as text and cast to their column's type by Postgres, so any value which Postgres accepts as input can be used, e.g. "1 hour" for an interval.
+//
+// A value starting with "@" in a foreign key column references the row with that label in the parent table, e.g. "@category.seafood" or "@core.category.seafood".
+// In any column, a leading "@@" is replaced by "@", e.g. "@@handle" is loaded as "@handle". Other values starting with "@" are only references in foreign key columns.
+// If the referenced row has no value for the parent column, one is assigned: the next value of its sequence for identity and serial columns, or a uuid derived from
+// the table name and label for uuid columns. Sequences of columns given explicit values are advanced past them.
+func Load(ctx context.Context, db lyspg.PoolOrTx, fx Fixtures) (err error) {
+
+	// refs are resolved in place
+	fx = fx.clone()
+
+	// get db metadata
+	infos := make(map[string]tableInfo, len(fx.Tables))
+	for _, t := range fx.Tables {
+		infos[t.Name], err = getTableInfo(ctx, db, t.Name)
+		if err != nil {
+			return fmt.Errorf("getTableInfo failed for %s: %w", t.Name, err)
+		}
+	}
+
+	tables, err := insertOrder(fx, infos)
+	if err != nil {
+		return fmt.Errorf("insertOrder failed: %w", err)
+	}
+
+	nextKey := func(tableName, colName, label string) (string, error) {
+		col := infos[tableName].columns[colName]
+		if col.SeqName != "" {
+			var key int64
+			if err := db.QueryRow(ctx, "SELECT nextval($1);", col.SeqName).Scan(&key); err != nil {
+				return "", fmt.Errorf("db.QueryRow failed (nextval): %w", err)
+			}
+			return strconv.FormatInt(key, 10), nil
+		}
+		if col.TypeOid == pgtype.UUIDOID {
+			return uuid.NewSHA1(uuid.NameSpaceOID, []byte(tableName+"."+label)).String(), nil
+		}
+		return "", fmt.Errorf("no value for referenced column %s.%s of row %s, and no value can be assigned", tableName, colName, label)
+	}
+
+	if err = resolveRefs(tables, infos, nextKey); err != nil {
+		return fmt.Errorf("resolveRefs failed: %w", err)
+	}
+
+	for _, t := range tables {
+		if err = insertTable(ctx, db, t, infos[t.Name]); err != nil {
+			return fmt.Errorf("insertTable failed for %s: %w", t.Name, err)
+		}
+	}
+
+	// advance sequences past explicit values
+	for _, t := range tables {
+		for _, colName := range explicitSeqCols(t, infos[t.Name]) {
+			stmt := fmt.Sprintf("SELECT setval($1, GREATEST((SELECT max(%s) FROM %s), nextval($1)));", pgx.Identifier{colName}.Sanitize(),
+				pgx.Identifier(strings.Split(t.Name, ".")).Sanitize())
+			if _, err = db.Exec(ctx, stmt, infos[t.Name].columns[colName].SeqName); err != nil {
+				return lyserr.Db{Err: fmt.Errorf("db.Exec failed (setval): %w", err), Stmt: stmt}
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadFunc returns a func which reads the fixtures in dir of sqlAssets and loads them in a tx. Use it as lyspgdb.PopulateOptions.LoadFixtures
+func LoadFunc(dir string) func(ctx context.Context, db *pgxpool.Pool, sqlAssets embed.FS) error {
+	return func(ctx context.Context, db *pgxpool.Pool, sqlAssets embed.FS) error {
+
+		fx, err := ReadFS(sqlAssets, dir)
+		if err != nil {
+			return fmt.Errorf("ReadFS failed: %w", err)
+		}
+
+		return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			return Load(ctx, tx, fx)
+		})
+	}
+}
+
+func getTableInfo(ctx context.Context, db lyspg.PoolOrTx, tableName string) (info tableInfo, err error) {
+
+	schemaName, relName, _ := strings.Cut(tableName, ".")
+
+	stmt := `SELECT a.attname AS name, CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE a.atttypid END AS type_oid,
+			format_type(a.atttypid, a.atttypmod) AS type_name,
+			COALESCE(pg_get_serial_sequence(quote_ident(n.nspname) || '.' || quote_ident(c.relname), a.attname), '') AS seq_name
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped;`
+	rows, _ := db.Query(ctx, stmt, schemaName, relName)
+	cols, err := pgx.CollectRows(rows, pgx.RowToStructByName[column])
+	if err != nil {
+		return tableInfo{}, lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+	if len(cols) == 0 {
+		return tableInfo{}, fmt.Errorf("table does not exist")
+	}
+
+	info.columns = make(map[string]column, len(cols))
+	for _, col := range cols {
+		info.columns[col.Name] = col
+	}
+
+	fks, err := lyspg.GetForeignKeys(ctx, db, schemaName, relName)
+	if err != nil {
+		return tableInfo{}, fmt.Errorf("lyspg.GetForeignKeys failed: %w", err)
+	}
+	info.fks = make(map[string]lyspg.ForeignKey, len(fks))
+	for _, fk := range fks {
+		info.fks[fk.ChildColumn] = fk
+	}
+
+	return info, nil
+}
+
+// insertOrder returns the tables of fx sorted so that parents come before their children. Otherwise, fixture order is kept.
+// Self references are allowed, since FKs are checked at the end of each statement. Other cycles are not
+func insertOrder(fx Fixtures, infos map[string]tableInfo) (tables []Table, err error) {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(fx.Tables))
+
+	var visit func(t Table, chain []string) error
+	visit = func(t Table, chain []string) error {
+		switch state[t.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("foreign key cycle: %s", strings.Join(append(chain, t.Name), " -> "))
+		}
+		state[t.Name] = visiting
+
+		// visit parents in a stable order
+		var parentNames []string
+		for _, fk := range infos[t.Name].fks {
+			parentName := fk.ParentSchema + "." + fk.ParentTable
+			if parentName != t.Name && !slices.Contains(parentNames, parentName) {
+				parentNames = append(parentNames, parentName)
+			}
+		}
+		slices.Sort(parentNames)
+
+		for _, parentName := range parentNames {
+			i := slices.IndexFunc(fx.Tables, func(pt Table) bool { return pt.Name == parentName })
+			if i == -1 {
+				continue // parent has no fixtures
+			}
+			if err := visit(fx.Tables[i], append(chain, t.Name)); err != nil {
+				return err
+			}
+		}
+
+		state[t.Name] = visited
+		tables = append(tables, t)
+		return nil
+	}
+
+	for _, t := range fx.Tables {
+		if err = visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return tables, nil
+}
+
+// resolveRefs replaces references in foreign key columns with the referenced values, assigning keys to referenced rows using nextKey where needed.
+// tables are modified in place. It also checks that all columns exist
+func resolveRefs(tables []Table, infos map[string]tableInfo, nextKey func(tableName, colName, label string) (string, error)) (err error) {
+
+	tableIdx := make(map[string]int, len(tables))
+	for i, t := range tables {
+		tableIdx[t.Name] = i
+	}
+
+	for _, t := range tables {
+		info := infos[t.Name]
+
+		for _, row := range t.Rows {
+			for _, colName := range row.Cols {
+				if _, ok := info.columns[colName]; !ok {
+					return fmt.Errorf("table %s: column does not exist: %s", t.Name, colName)
+				}
+
+				val := row.Values[colName]
+				if val == nil || !strings.HasPrefix(*val, refPrefix) {
+					continue
+				}
+
+				// "@@" escapes "@" in any column, so that values are unescaped the same way whether or not the column is a foreign key
+				if strings.HasPrefix(*val, refPrefix+refPrefix) {
+					unescaped := (*val)[len(refPrefix):]
+					row.Values[colName] = &unescaped
+					continue
+				}
+
+				fk, isFk := info.fks[colName]
+				if !isFk {
+					continue
+				}
+
+				// parse ref
+				ref := (*val)[len(refPrefix):]
+				refTable, label, ok := cutLast(ref, ".")
+				parentName := fk.ParentSchema + "." + fk.ParentTable
+				if !ok || (refTable != parentName && refTable != fk.ParentTable) {
+					return fmt.Errorf("table %s, column %s: reference %s must be to a row of %s", t.Name, colName, *val, parentName)
+				}
+
+				pi, ok := tableIdx[parentName]
+				if !ok {
+					return fmt.Errorf("table %s, column %s: reference %s: %s has no fixtures", t.Name, colName, *val, parentName)
+				}
+				ri := slices.IndexFunc(tables[pi].Rows, func(r Row) bool { return r.Label == label })
+				if ri == -1 {
+					return fmt.Errorf("table %s, column %s: reference %s: label not found", t.Name, colName, *val)
+				}
+				parentRow := tables[pi].Rows[ri]
+
+				// assign parent key if needed
+				parentVal, ok := parentRow.Values[fk.ParentColumn]
+				if !ok {
+					key, err := nextKey(parentName, fk.ParentColumn, label)
+					if err != nil {
+						return fmt.Errorf("nextKey failed: %w", err)
+					}
+					parentVal = &key
+					parentRow.Cols = append(parentRow.Cols, fk.ParentColumn)
+					parentRow.Values[fk.ParentColumn] = parentVal
+					tables[pi].Rows[ri] = parentRow
+				}
+				if parentVal == nil {
+					return fmt.Errorf("table %s, column %s: reference %s: referenced column %s is NULL", t.Name, colName, *val, fk.ParentColumn)
+				}
+
+				row.Values[colName] = parentVal
+			}
+		}
+	}
+
+	return nil
+}
+
+// clone returns a deep copy of fx
+func (fx Fixtures) clone() Fixtures {
+	res := Fixtures{Tables: make([]Table, len(fx.Tables))}
+	for i, t := range fx.Tables {
+		res.Tables[i] = Table{Name: t.Name, Rows: make([]Row, len(t.Rows))}
+		for j, row := range t.Rows {
+			res.Tables[i].Rows[j] = Row{Label: row.Label, Cols: slices.Clone(row.Cols), Values: maps.Clone(row.Values)}
+		}
+	}
+	return res
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// explicitSeqCols returns the columns of t which have a sequence and are given a value in at least one row
+func explicitSeqCols(t Table, info tableInfo) (colNames []string) {
+	for _, row := range t.Rows {
+		for _, colName := range row.Cols {
+			if info.columns[colName].SeqName != "" && !slices.Contains(colNames, colName) {
+				colNames = append(colNames, colName)
+			}
+		}
+	}
+	return colNames
+}
+
+// insertTable inserts the rows of t in one batch, with one INSERT per row so that omitted columns get their default values
+func insertTable(ctx context.Context, db lyspg.PoolOrTx, t Table, info tableInfo) (err error) {
+
+	batch := &pgx.Batch{}
+	for _, row := range t.Rows {
+		args := make([]any, len(row.Cols))
+		for i, colName := range row.Cols {
+			args[i] = row.Values[colName]
+		}
+		batch.Queue(insertStmt(t.Name, row.Cols, info), args...)
+	}
+
+	if err = db.SendBatch(ctx, batch).Close(); err != nil {
+		return lyserr.Db{Err: fmt.Errorf("db.SendBatch.Close failed: %w", err)}
+	}
+
+	return nil
+}
+
+// insertStmt returns an INSERT of one row into tableName. Each value is a text param which is cast to its column's type, e.g. "$1::text::interval".
+// Values of GENERATED ALWAYS identity columns are allowed, as they were with COPY
+func insertStmt(tableName string, colNames []string, info tableInfo) string {
+
+	cols := make([]string, len(colNames))
+	vals := make([]string, len(colNames))
+	for i, colName := range colNames {
+		cols[i] = pgx.Identifier{colName}.Sanitize()
+		vals[i] = fmt.Sprintf("$%d::text::%s", i+1, info.columns[colName].TypeName)
+	}
+
+	if len(colNames) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES;", pgx.Identifier(strings.Split(tableName, ".")).Sanitize())
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE VALUES (%s);", pgx.Identifier(strings.Split(tableName, ".")).Sanitize(), strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
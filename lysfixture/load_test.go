@@ -0,0 +1,50 @@
+package lysfixture
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loveyourstack/lys/internal/myapp"
+	"github.com/loveyourstack/lys/lyspgdb"
+	"github.com/loveyourstack/lys/lyspgdb/lyspgdbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPostgresInput(t *testing.T) {
+
+	ctx := context.Background()
+	conf := myapp.MustGetConfig(t)
+
+	db, err := lyspgdb.GetPool(ctx, conf.Db, conf.DbOwnerUser, "test")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := lyspgdbtest.MustBeginRollback(ctx, t, db)
+	_, err = tx.Exec(ctx, `SET LOCAL TIME ZONE 'UTC';
+		CREATE TABLE core.fixture_load_test (id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY, c_tstz timestamptz, c_tstz_nooffset timestamptz, c_ts timestamp,
+			c_interval interval, c_money money);`)
+	require.NoError(t, err)
+
+	// values which the pgx text codecs reject, but Postgres accepts
+	fx := Fixtures{Tables: []Table{{Name: "core.fixture_load_test", Rows: []Row{{
+		Cols: []string{"c_tstz", "c_tstz_nooffset", "c_ts", "c_interval", "c_money"},
+		Values: map[string]*string{"c_tstz": strPtr("2024-01-01T10:00:00Z"), "c_tstz_nooffset": strPtr("2024-01-01 10:00:00"), "c_ts": strPtr("2024-01-01T10:00:00"),
+			"c_interval": strPtr("1 hour"), "c_money": strPtr("12.34")},
+	}}}}}
+	require.NoError(t, Load(ctx, tx, fx))
+
+	var tstz, tstzNoOffset, ts time.Time
+	var interval, amount string
+	err = tx.QueryRow(ctx, `SELECT c_tstz, c_tstz_nooffset, c_ts, c_interval::text, c_money::numeric::text FROM core.fixture_load_test;`).
+		Scan(&tstz, &tstzNoOffset, &ts, &interval, &amount)
+	require.NoError(t, err)
+
+	expected := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	assert.True(t, expected.Equal(tstz), tstz)
+	assert.True(t, expected.Equal(tstzNoOffset), tstzNoOffset)
+	assert.Equal(t, expected, ts)
+	assert.Equal(t, "01:00:00", interval)
+	assert.Equal(t, "12.34", amount)
+}
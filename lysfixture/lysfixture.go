@@ -0,0 +1,247 @@
+// Package lysfixture loads declarative seed data and test fixtures from YAML, JSON and CSV files into db tables.
+// Rows may reference other rows by label in foreign key columns, e.g. "@category.seafood", and tables are inserted in foreign key dependency order.
+package lysfixture
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// csvLabelCol is the optional CSV column containing row labels
+const csvLabelCol string = "_label"
+
+// Row is a fixture row
+type Row struct {
+	Label  string             // optional, unique per table. Used to reference the row from other rows
+	Cols   []string           // in file order
+	Values map[string]*string // text representation of each col value. Nil is NULL
+}
+
+// Table contains the fixture rows of a db table
+type Table struct {
+	Name string // qualified, e.g. "core.category"
+	Rows []Row
+}
+
+// Fixtures contains the fixture tables in the order they were read
+type Fixtures struct {
+	Tables []Table
+}
+
+// Add appends rows to the named table, adding the table if needed. It returns an error if a label is duplicated
+func (fx *Fixtures) Add(tableName string, rows ...Row) (err error) {
+
+	if strings.Count(tableName, ".") != 1 {
+		return fmt.Errorf("table name must be qualified as schema.table: %s", tableName)
+	}
+
+	i := slices.IndexFunc(fx.Tables, func(t Table) bool { return t.Name == tableName })
+	if i == -1 {
+		fx.Tables = append(fx.Tables, Table{Name: tableName})
+		i = len(fx.Tables) - 1
+	}
+
+	for _, row := range rows {
+		if row.Label != "" && slices.ContainsFunc(fx.Tables[i].Rows, func(r Row) bool { return r.Label == row.Label }) {
+			return fmt.Errorf("table %s: duplicate label: %s", tableName, row.Label)
+		}
+		fx.Tables[i].Rows = append(fx.Tables[i].Rows, row)
+	}
+
+	return nil
+}
+
+// ReadFS reads all .yaml, .yml, .json and .csv files in dir (not recursively), in file name order
+func ReadFS(fsys fs.FS, dir string) (fx Fixtures, err error) {
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("fs.ReadDir failed: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json", ".csv":
+		default:
+			continue
+		}
+
+		filePath := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return Fixtures{}, fmt.Errorf("fs.ReadFile failed for %s: %w", filePath, err)
+		}
+		if err = Parse(&fx, entry.Name(), data); err != nil {
+			return Fixtures{}, fmt.Errorf("Parse failed for %s: %w", filePath, err)
+		}
+	}
+
+	return fx, nil
+}
+
+// Parse adds the rows in data to fx. The format is determined by the extension of fileName.
+//
+// YAML and JSON files contain a map keyed by qualified table name. Each table contains either a map of rows keyed by label, or a list of unlabeled rows.
+// Each row is a map of column name to value. Nested maps and lists are stored as JSON.
+//
+// CSV files are named after the qualified table, e.g. "core.category.csv", and have a header row. The optional "_label" column contains row labels. Empty values are NULL.
+func Parse(fx *Fixtures, fileName string, data []byte) (err error) {
+
+	switch path.Ext(fileName) {
+	case ".yaml", ".yml", ".json":
+		return parseYaml(fx, data)
+	case ".csv":
+		return parseCsv(fx, strings.TrimSuffix(path.Base(fileName), ".csv"), data)
+	default:
+		return fmt.Errorf("unsupported file extension: %s", path.Ext(fileName))
+	}
+}
+
+// parseYaml parses YAML or JSON (which is valid YAML), preserving the order of tables, rows and columns
+func parseYaml(fx *Fixtures, data []byte) (err error) {
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("yaml.Unmarshal failed: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty file
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected a map of tables at line %d", root.Line)
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		tableName := root.Content[i].Value
+		tableNode := root.Content[i+1]
+
+		var rows []Row
+		switch tableNode.Kind {
+		case yaml.MappingNode:
+			for j := 0; j < len(tableNode.Content); j += 2 {
+				row, err := parseYamlRow(tableNode.Content[j+1])
+				if err != nil {
+					return fmt.Errorf("table %s, row %s: %w", tableName, tableNode.Content[j].Value, err)
+				}
+				row.Label = tableNode.Content[j].Value
+				rows = append(rows, row)
+			}
+		case yaml.SequenceNode:
+			for j, rowNode := range tableNode.Content {
+				row, err := parseYamlRow(rowNode)
+				if err != nil {
+					return fmt.Errorf("table %s, row %d: %w", tableName, j, err)
+				}
+				rows = append(rows, row)
+			}
+		default:
+			return fmt.Errorf("table %s: expected a map or list of rows at line %d", tableName, tableNode.Line)
+		}
+
+		if err = fx.Add(tableName, rows...); err != nil {
+			return fmt.Errorf("fx.Add failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func parseYamlRow(rowNode *yaml.Node) (row Row, err error) {
+
+	if rowNode.Kind != yaml.MappingNode {
+		return Row{}, fmt.Errorf("expected a map of columns at line %d", rowNode.Line)
+	}
+
+	row.Values = make(map[string]*string, len(rowNode.Content)/2)
+	for i := 0; i < len(rowNode.Content); i += 2 {
+		colName := rowNode.Content[i].Value
+		valNode := rowNode.Content[i+1]
+
+		if _, ok := row.Values[colName]; ok {
+			return Row{}, fmt.Errorf("duplicate column: %s", colName)
+		}
+		row.Cols = append(row.Cols, colName)
+
+		switch {
+		case valNode.Kind == yaml.ScalarNode && valNode.Tag == "!!null":
+			row.Values[colName] = nil
+		case valNode.Kind == yaml.ScalarNode:
+			val := valNode.Value
+			row.Values[colName] = &val
+		default:
+			// store maps and lists as JSON
+			var v any
+			if err = valNode.Decode(&v); err != nil {
+				return Row{}, fmt.Errorf("valNode.Decode failed for column %s: %w", colName, err)
+			}
+			jsonB, err := json.Marshal(v)
+			if err != nil {
+				return Row{}, fmt.Errorf("json.Marshal failed for column %s: %w", colName, err)
+			}
+			val := string(jsonB)
+			row.Values[colName] = &val
+		}
+	}
+
+	return row, nil
+}
+
+func parseCsv(fx *Fixtures, tableName string, data []byte) (err error) {
+
+	r := csv.NewReader(bytes.NewReader(data))
+
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil // empty file
+		}
+		return fmt.Errorf("r.Read failed (header): %w", err)
+	}
+
+	var rows []Row
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("r.Read failed: %w", err)
+		}
+
+		row := Row{Values: make(map[string]*string, len(header))}
+		for i, colName := range header {
+			if colName == csvLabelCol {
+				row.Label = rec[i]
+				continue
+			}
+			row.Cols = append(row.Cols, colName)
+			if rec[i] == "" {
+				row.Values[colName] = nil
+				continue
+			}
+			val := rec[i]
+			row.Values[colName] = &val
+		}
+		rows = append(rows, row)
+	}
+
+	if err = fx.Add(tableName, rows...); err != nil {
+		return fmt.Errorf("fx.Add failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package lysfixture
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestReadFS(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"fixtures/01_category.yaml": {Data: []byte(`
+core.category:
+  seafood:
+    name: Seafood
+    tags: [fish, shellfish]
+  empty:
+    name: ~
+`)},
+		"fixtures/02_product.json":   {Data: []byte(`{"core.product": [{"name": "Shrimp", "category_id": "@category.seafood", "price": 9.5}]}`)},
+		"fixtures/core.supplier.csv": {Data: []byte("_label,name,city\nacme,Acme,\n")},
+		"fixtures/README.md":         {Data: []byte("ignored")},
+	}
+
+	fx, err := ReadFS(fsys, "fixtures")
+	require.NoError(t, err)
+	require.Len(t, fx.Tables, 3)
+
+	cat := fx.Tables[0]
+	assert.Equal(t, "core.category", cat.Name)
+	require.Len(t, cat.Rows, 2)
+	assert.Equal(t, "seafood", cat.Rows[0].Label)
+	assert.Equal(t, []string{"name", "tags"}, cat.Rows[0].Cols)
+	assert.Equal(t, strPtr("Seafood"), cat.Rows[0].Values["name"])
+	assert.Equal(t, strPtr(`["fish","shellfish"]`), cat.Rows[0].Values["tags"])
+	assert.Nil(t, cat.Rows[1].Values["name"])
+
+	prod := fx.Tables[1]
+	assert.Equal(t, "core.product", prod.Name)
+	require.Len(t, prod.Rows, 1)
+	assert.Empty(t, prod.Rows[0].Label)
+	assert.Equal(t, []string{"name", "category_id", "price"}, prod.Rows[0].Cols)
+	assert.Equal(t, strPtr("@category.seafood"), prod.Rows[0].Values["category_id"])
+	assert.Equal(t, strPtr("9.5"), prod.Rows[0].Values["price"])
+
+	sup := fx.Tables[2]
+	assert.Equal(t, "core.supplier", sup.Name)
+	require.Len(t, sup.Rows, 1)
+	assert.Equal(t, "acme", sup.Rows[0].Label)
+	assert.Equal(t, []string{"name", "city"}, sup.Rows[0].Cols)
+	assert.Nil(t, sup.Rows[0].Values["city"])
+
+	// duplicate label across files
+	fsys["fixtures/03_more.yaml"] = &fstest.MapFile{Data: []byte("core.category:\n  seafood:\n    name: Again\n")}
+	_, err = ReadFS(fsys, "fixtures")
+	assert.Error(t, err)
+
+	// unqualified table name
+	var fx2 Fixtures
+	assert.Error(t, Parse(&fx2, "a.yaml", []byte("category:\n  a:\n    name: A\n")))
+}
+
+func testInfos() map[string]tableInfo {
+	return map[string]tableInfo{
+		"core.category": {
+			columns: map[string]column{
+				"id":        {Name: "id", TypeOid: pgtype.Int8OID, TypeName: "bigint", SeqName: "core.category_id_seq"},
+				"name":      {Name: "name", TypeOid: pgtype.TextOID, TypeName: "text"},
+				"parent_id": {Name: "parent_id", TypeOid: pgtype.Int8OID, TypeName: "bigint"},
+			},
+			fks: map[string]lyspg.ForeignKey{
+				"parent_id": {ChildSchema: "core", ChildTable: "category", ChildColumn: "parent_id", ParentSchema: "core", ParentTable: "category", ParentColumn: "id"},
+			},
+		},
+		"core.product": {
+			columns: map[string]column{
+				"id":          {Name: "id", TypeOid: pgtype.Int8OID, TypeName: "bigint", SeqName: "core.product_id_seq"},
+				"name":        {Name: "name", TypeOid: pgtype.TextOID, TypeName: "text"},
+				"category_id": {Name: "category_id", TypeOid: pgtype.Int8OID, TypeName: "bigint"},
+				"launched_on": {Name: "launched_on", TypeOid: pgtype.DateOID, TypeName: "date"},
+				"status":      {Name: "status", TypeOid: 99999, TypeName: "core.status"}, // e.g. an enum
+			},
+			fks: map[string]lyspg.ForeignKey{
+				"category_id": {ChildSchema: "core", ChildTable: "product", ChildColumn: "category_id", ParentSchema: "core", ParentTable: "category", ParentColumn: "id"},
+			},
+		},
+	}
+}
+
+func TestInsertOrder(t *testing.T) {
+
+	infos := testInfos()
+
+	fx := Fixtures{Tables: []Table{{Name: "core.product"}, {Name: "core.category"}}}
+	tables, err := insertOrder(fx, infos)
+	require.NoError(t, err)
+	require.Len(t, tables, 2)
+	assert.Equal(t, "core.category", tables[0].Name)
+	assert.Equal(t, "core.product", tables[1].Name)
+
+	// cycle
+	info := infos["core.category"]
+	info.fks["product_id"] = lyspg.ForeignKey{ParentSchema: "core", ParentTable: "product", ParentColumn: "id"}
+	_, err = insertOrder(fx, infos)
+	assert.ErrorContains(t, err, "cycle")
+}
+
+func TestResolveRefs(t *testing.T) {
+
+	infos := testInfos()
+
+	tables := []Table{
+		{Name: "core.category", Rows: []Row{
+			{Label: "food", Cols: []string{"name"}, Values: map[string]*string{"name": strPtr("Food")}},
+			{Label: "seafood", Cols: []string{"id", "name", "parent_id"}, Values: map[string]*string{"id": strPtr("7"), "name": strPtr("Seafood"), "parent_id": strPtr("@category.food")}},
+		}},
+		{Name: "core.product", Rows: []Row{
+			{Cols: []string{"name", "category_id"}, Values: map[string]*string{"name": strPtr("@shrimp"), "category_id": strPtr("@core.category.seafood")}},
+			{Cols: []string{"name", "category_id"}, Values: map[string]*string{"name": strPtr("@@other"), "category_id": strPtr("@@literal")}},
+		}},
+	}
+
+	var keyCalls []string
+	nextKey := func(tableName, colName, label string) (string, error) {
+		keyCalls = append(keyCalls, tableName+"."+colName+"."+label)
+		return "100", nil
+	}
+
+	require.NoError(t, resolveRefs(tables, infos, nextKey))
+
+	// key assigned to food, which had no id
+	assert.Equal(t, []string{"core.category.id.food"}, keyCalls)
+	assert.Equal(t, []string{"name", "id"}, tables[0].Rows[0].Cols)
+	assert.Equal(t, strPtr("100"), tables[0].Rows[0].Values["id"])
+	assert.Equal(t, strPtr("100"), tables[0].Rows[1].Values["parent_id"])
+
+	// refs only apply to FK columns
+	assert.Equal(t, strPtr("@shrimp"), tables[1].Rows[0].Values["name"])
+	assert.Equal(t, strPtr("7"), tables[1].Rows[0].Values["category_id"])
+	assert.Equal(t, strPtr("@literal"), tables[1].Rows[1].Values["category_id"])
+
+	// "@@" is unescaped in any column
+	assert.Equal(t, strPtr("@other"), tables[1].Rows[1].Values["name"])
+
+	// errors
+	for _, val := range []string{"@category.missing", "@product.x", "@nodot"} {
+		tables := []Table{
+			{Name: "core.category"},
+			{Name: "core.product", Rows: []Row{{Cols: []string{"category_id"}, Values: map[string]*string{"category_id": strPtr(val)}}}},
+		}
+		assert.Error(t, resolveRefs(tables, infos, nextKey), val)
+	}
+
+	tables = []Table{{Name: "core.product", Rows: []Row{{Cols: []string{"nope"}, Values: map[string]*string{"nope": strPtr("a")}}}}}
+	assert.ErrorContains(t, resolveRefs(tables, infos, nextKey), "column does not exist")
+}
+
+func TestInsertStmt(t *testing.T) {
+
+	info := testInfos()["core.product"]
+	assert.Equal(t, `INSERT INTO "core"."product" ("name", "launched_on", "status") OVERRIDING SYSTEM VALUE VALUES ($1::text::text, $2::text::date, $3::text::core.status);`,
+		insertStmt("core.product", []string{"name", "launched_on", "status"}, info))
+	assert.Equal(t, `INSERT INTO "core"."product" DEFAULT VALUES;`, insertStmt("core.product", nil, info))
+}
@@ -0,0 +1,171 @@
+package lysgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lysstring"
+)
+
+// EnumSpec describes a db enum and the Go type generated from it
+type EnumSpec struct {
+	SchemaName string
+	Name       string
+	GoName     string   // e.g. "OrderStatus" for enum "order_status"
+	Values     []string // in enum order
+}
+
+// ConstName returns the name of the Go constant of val, e.g. "OrderStatusInProgress" for "in progress"
+func (e EnumSpec) ConstName(val string) string {
+
+	parts := strings.FieldsFunc(val, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i := range parts {
+		parts[i] = lysstring.Title(parts[i])
+	}
+	return e.GoName + strings.Join(parts, "")
+}
+
+// ConstNames returns the constant names of Values, suffixing duplicates with their position, e.g. for "a-b" and "a_b"
+func (e EnumSpec) ConstNames() []string {
+
+	names := make([]string, len(e.Values))
+	seen := make(map[string]bool)
+	for i, val := range e.Values {
+		name := e.ConstName(val)
+		if seen[name] || name == e.GoName+"Values" {
+			name += strconv.Itoa(i + 1)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// OneOf returns the validate tag value allowing only Values, e.g. "oneof=a b", or "" if a value cannot be expressed in the tag
+func (e EnumSpec) OneOf() string {
+
+	if len(e.Values) == 0 {
+		return ""
+	}
+	for _, val := range e.Values {
+		if val == "" || strings.ContainsAny(val, " ,|'") {
+			return ""
+		}
+	}
+	return "oneof=" + strings.Join(e.Values, " ")
+}
+
+// GetEnumSpec returns the EnumSpec of the supplied db enum
+func GetEnumSpec(ctx context.Context, db *pgxpool.Pool, schema, name string) (spec EnumSpec, err error) {
+
+	vals, err := lyspg.SelectEnum(ctx, db, pgx.Identifier{schema, name}.Sanitize(), nil, nil, "")
+	if err != nil {
+		return EnumSpec{}, fmt.Errorf("lyspg.SelectEnum failed: %w", err)
+	}
+
+	return EnumSpec{
+		SchemaName: schema,
+		Name:       name,
+		GoName:     lysstring.Convert(name, "_", "", lysstring.Title),
+		Values:     vals,
+	}, nil
+}
+
+// getEnumSpecs returns the EnumSpecs of the enum and enum array cols, keyed by "schema.name"
+func getEnumSpecs(ctx context.Context, db *pgxpool.Pool, cols []lyspg.Column) (enums map[string]EnumSpec, err error) {
+
+	enums = make(map[string]EnumSpec)
+
+	udtNames := []string{}
+	for _, col := range cols {
+		if col.DataType == "USER-DEFINED" || col.DataType == "ARRAY" {
+			udtNames = append(udtNames, col.UdtSchema+"."+strings.TrimPrefix(col.UdtName, "_"))
+		}
+	}
+	if len(udtNames) == 0 {
+		return enums, nil
+	}
+
+	stmt := `SELECT n.nspname::text, t.typname::text FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace WHERE t.typtype = 'e' AND n.nspname || '.' || t.typname = ANY($1);`
+
+	type enumName struct {
+		Schema string
+		Name   string
+	}
+
+	rows, _ := db.Query(ctx, stmt, udtNames)
+	enumNames, err := pgx.CollectRows(rows, pgx.RowToStructByPos[enumName])
+	if err != nil {
+		return nil, lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+
+	for _, en := range enumNames {
+		enums[en.Schema+"."+en.Name], err = GetEnumSpec(ctx, db, en.Schema, en.Name)
+		if err != nil {
+			return nil, fmt.Errorf("GetEnumSpec failed for %s.%s: %w", en.Schema, en.Name, err)
+		}
+	}
+
+	return enums, nil
+}
+
+// Enum generates a Go string type with constants, a values slice and a Valid method from the supplied db enum. The code needs the "slices" import
+func Enum(ctx context.Context, db *pgxpool.Pool, schema, name string, options ...GenOptions) (res string, err error) {
+
+	spec, err := GetEnumSpec(ctx, db, schema, name)
+	if err != nil {
+		return "", fmt.Errorf("GetEnumSpec failed: %w", err)
+	}
+
+	res, err = renderEnum(spec)
+	if err != nil {
+		return "", fmt.Errorf("renderEnum failed: %w", err)
+	}
+
+	// write to clipboard for convenience
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
+	}
+
+	return "\n" + res + "\n", nil
+}
+
+// renderEnum returns the formatted Go code of spec
+func renderEnum(spec EnumSpec) (res string, err error) {
+
+	if spec.GoName == "" || len(spec.Values) == 0 {
+		return "", fmt.Errorf("enum spec must have GoName and Values")
+	}
+
+	var buf bytes.Buffer
+	if err = storeTmpls.ExecuteTemplate(&buf, "enum", spec); err != nil {
+		return "", fmt.Errorf("storeTmpls.ExecuteTemplate failed: %w", err)
+	}
+
+	return formatSnippet(buf.String())
+}
+
+// formatSnippet formats Go declarations which are not a complete file
+func formatSnippet(src string) (res string, err error) {
+
+	const pkgClause = "package snippet\n\n"
+
+	formatted, err := format.Source([]byte(pkgClause + src))
+	if err != nil {
+		return "", fmt.Errorf("format.Source failed: %w", err)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(string(formatted), pkgClause)), nil
+}
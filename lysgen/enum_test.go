@@ -0,0 +1,38 @@
+package lysgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnumSpecConstNames(t *testing.T) {
+
+	spec := EnumSpec{GoName: "Status", Values: []string{"in progress", "done", "a-b", "a_b", "Values"}}
+	assert.Equal(t, []string{"StatusInProgress", "StatusDone", "StatusAB", "StatusAB4", "StatusValues5"}, spec.ConstNames())
+}
+
+func TestEnumSpecOneOf(t *testing.T) {
+
+	assert.Equal(t, "oneof=active inactive", EnumSpec{Values: []string{"active", "inactive"}}.OneOf())
+	assert.Equal(t, "", EnumSpec{Values: []string{"in progress", "done"}}.OneOf())
+	assert.Equal(t, "", EnumSpec{}.OneOf())
+}
+
+func TestRenderEnumSuccess(t *testing.T) {
+
+	res, err := renderEnum(EnumSpec{SchemaName: "core", Name: "order_status", GoName: "OrderStatus", Values: []string{"open", "in progress"}})
+	require.NoError(t, err)
+	assert.Contains(t, res, "// OrderStatus is the db enum core.order_status\ntype OrderStatus string\n")
+	assert.Contains(t, res, "\tOrderStatusOpen       OrderStatus = \"open\"\n\tOrderStatusInProgress OrderStatus = \"in progress\"\n")
+	assert.Contains(t, res, "var OrderStatusValues = []OrderStatus{OrderStatusOpen, OrderStatusInProgress}")
+	assert.Contains(t, res, "func (e OrderStatus) Valid() bool {\n\treturn slices.Contains(OrderStatusValues, e)\n}")
+	assert.NotContains(t, res, "package")
+}
+
+func TestRenderEnumFailure(t *testing.T) {
+
+	_, err := renderEnum(EnumSpec{GoName: "OrderStatus"})
+	assert.Error(t, err)
+}
@@ -11,7 +11,7 @@ import (
 )
 
 // Equal generates the Go store Equal function from the supplied db table
-func Equal(ctx context.Context, db *pgxpool.Pool, schema, table string) (res string, err error) {
+func Equal(ctx context.Context, db *pgxpool.Pool, schema, table string, options ...GenOptions) (res string, err error) {
 
 	// get table columns
 	cols, err := lyspg.GetTableColumns(ctx, db, schema, table)
@@ -30,9 +30,11 @@ func Equal(ctx context.Context, db *pgxpool.Pool, schema, table string) (res str
 	res = strings.Join(resA, "\n")
 
 	// write to clipboard for convenience
-	err = WriteToClipboard(res)
-	if err != nil {
-		return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
 	}
 
 	return "\n" + res + "\n", nil
@@ -12,7 +12,7 @@ import (
 
 // InputModel generates the store Input and Model structs from the supplied db table
 // only handles 1 level of join, and does not coalesce nulls
-func InputModel(ctx context.Context, db *pgxpool.Pool, schema, table string, withValidation bool) (res string, err error) {
+func InputModel(ctx context.Context, db *pgxpool.Pool, schema, table string, withValidation bool, options ...GenOptions) (res string, err error) {
 
 	// get table columns
 	cols, err := lyspg.GetTableColumns(ctx, db, schema, table)
@@ -67,9 +67,11 @@ func InputModel(ctx context.Context, db *pgxpool.Pool, schema, table string, wit
 	res = strings.Join(resA, "\n")
 
 	// write to clipboard for convenience
-	err = WriteToClipboard(res)
-	if err != nil {
-		return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
 	}
 
 	return "\n" + res + "\n", nil
@@ -93,7 +95,7 @@ func getInput(cols []lyspg.Column, withValidation bool) (resA []string, err erro
 		goName := lysstring.Convert(col.Name, "_", "", lysstring.Title)
 
 		// get Go data type
-		goDataType, omitStr, err := GetGoDataTypeFromPg(col.DataType)
+		goDataType, omitStr, err := GetGoDataTypeFromPg(getColDataType(col))
 		if err != nil {
 			return nil, fmt.Errorf("GetGoDataTypeFromPg failed: %w", err)
 		}
@@ -132,7 +134,7 @@ func getModel(cols []lyspg.Column, parentCols []lyspg.Column, childFks []lyspg.F
 		goName := lysstring.Convert(col.Name, "_", "", lysstring.Title)
 
 		// get Go data type
-		goDataType, omitStr, err := GetGoDataTypeFromPg(col.DataType)
+		goDataType, omitStr, err := GetGoDataTypeFromPg(getColDataType(col))
 		if err != nil {
 			return nil, fmt.Errorf("GetGoDataTypeFromPg failed: %w", err)
 		}
@@ -157,7 +159,7 @@ func getModel(cols []lyspg.Column, parentCols []lyspg.Column, childFks []lyspg.F
 		goName := lysstring.Convert(prefixedColName, "_", "", lysstring.Title)
 
 		// get Go data type
-		goDataType, omitStr, err := GetGoDataTypeFromPg(parCol.DataType)
+		goDataType, omitStr, err := GetGoDataTypeFromPg(getColDataType(parCol))
 		if err != nil {
 			return nil, fmt.Errorf("GetGoDataTypeFromPg failed: %w", err)
 		}
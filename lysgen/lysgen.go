@@ -6,11 +6,56 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/loveyourstack/lys/lyspg"
 )
 
-// GetGoDataTypeFromPg returns a Go data type from a PostgreSQL data type
+// GenOptions configures the generator funcs
+type GenOptions struct {
+	SkipClipboard bool // if true, the result is only returned, e.g. to write it to a file
+}
+
+// pgTypeAliases maps internal Postgres type names, e.g. from udt_name, to the names used in information_schema
+var pgTypeAliases = map[string]string{
+	"bool":        "boolean",
+	"bpchar":      "character",
+	"float4":      "real",
+	"float8":      "double precision",
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"timestamptz": "timestamp with time zone",
+	"timetz":      "time with time zone",
+	"varchar":     "character varying",
+}
+
+// getColDataType returns the data type of col, using its udt_name to resolve the element type of arrays, e.g. "int8[]".
+// Arrays of unmapped element types, such as composite types, remain "ARRAY"
+func getColDataType(col lyspg.Column) string {
+	if elemType, ok := strings.CutPrefix(col.UdtName, "_"); ok && col.DataType == "ARRAY" {
+		if _, _, err := GetGoDataTypeFromPg(elemType); err == nil {
+			return elemType + "[]"
+		}
+	}
+	return col.DataType
+}
+
+// GetGoDataTypeFromPg returns a Go data type from a PostgreSQL data type.
+// Arrays are either "ARRAY", which defaults to []string, or have their element type, e.g. "int8[]" or "bigint[]"
 func GetGoDataTypeFromPg(pgType string) (goType, omitStr string, err error) {
 
+	if elemType, ok := strings.CutSuffix(pgType, "[]"); ok {
+		elemGoType, _, err := GetGoDataTypeFromPg(elemType)
+		if err != nil {
+			return "", "", err
+		}
+		return "[]" + elemGoType, "omitempty", nil
+	}
+
+	if alias, ok := pgTypeAliases[pgType]; ok {
+		pgType = alias
+	}
+
 	switch pgType {
 	case "ARRAY":
 		return "[]string", "omitempty", nil // defaulting to string, change type manually as needed
@@ -18,7 +63,7 @@ func GetGoDataTypeFromPg(pgType string) (goType, omitStr string, err error) {
 		return "int64", "omitempty", nil
 	case "bit", "boolean":
 		return "bool", "omitempty", nil
-	case "character", "character varying", "text", "USER-DEFINED": // "USER-DEFINED" is enum or domain. Change as needed
+	case "character", "character varying", "text", "USER-DEFINED": // "USER-DEFINED" is an enum or composite type. Enums are resolved by GetStoreSpec
 		return "string", "omitempty", nil
 	case "date":
 		return "lystype.Date", "omitzero", nil
@@ -28,16 +73,30 @@ func GetGoDataTypeFromPg(pgType string) (goType, omitStr string, err error) {
 		return "int", "omitempty", nil
 	case "time", "time without time zone":
 		return "lystype.Time", "omitzero", nil
-	case "timestamp", "timestamp with time zone":
+	case "timestamp", "timestamp with time zone", "timestamp without time zone":
 		return "lystype.Datetime", "omitzero", nil
+	case "uuid":
+		return "uuid.UUID", "omitzero", nil
 	default:
 		return "", "", fmt.Errorf("no go type found for pgType: %s", pgType)
 	}
 }
 
-// GetTsDataTypeFromPg returns a Typescript data type from a PostgreSQL data type
+// GetTsDataTypeFromPg returns a Typescript data type from a PostgreSQL data type. Arrays are handled as in GetGoDataTypeFromPg
 func GetTsDataTypeFromPg(pgType string) (tsType string, err error) {
 
+	if elemType, ok := strings.CutSuffix(pgType, "[]"); ok {
+		elemTsType, err := GetTsDataTypeFromPg(elemType)
+		if err != nil {
+			return "", err
+		}
+		return elemTsType + "[]", nil
+	}
+
+	if alias, ok := pgTypeAliases[pgType]; ok {
+		pgType = alias
+	}
+
 	switch pgType {
 	case "ARRAY":
 		return "string[]", nil // defaulting to string, change type manually as needed
@@ -45,9 +104,9 @@ func GetTsDataTypeFromPg(pgType string) (tsType string, err error) {
 		return "number", nil
 	case "bit", "boolean":
 		return "boolean", nil
-	case "character", "character varying", "date", "text", "time", "time without time zone", "USER-DEFINED": // "USER-DEFINED" is enum or domain
+	case "character", "character varying", "date", "text", "time", "time without time zone", "USER-DEFINED", "uuid": // "USER-DEFINED" is enum or composite type
 		return "string", nil
-	case "timestamp", "timestamp with time zone":
+	case "timestamp", "timestamp with time zone", "timestamp without time zone":
 		return "Date", nil
 	default:
 		return "", fmt.Errorf("no Typescript type found for pgType: %s", pgType)
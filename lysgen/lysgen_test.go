@@ -0,0 +1,54 @@
+package lysgen
+
+import (
+	"testing"
+
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGoDataTypeFromPgSuccess(t *testing.T) {
+
+	tests := map[string]string{
+		"ARRAY":                       "[]string",
+		"bigint":                      "int64",
+		"int8":                        "int64",
+		"int8[]":                      "[]int64",
+		"int4[]":                      "[]int",
+		"text[]":                      "[]string",
+		"bool":                        "bool",
+		"timestamptz":                 "lystype.Datetime",
+		"timestamp without time zone": "lystype.Datetime",
+		"date[]":                      "[]lystype.Date",
+		"uuid":                        "uuid.UUID",
+	}
+	for pgType, expected := range tests {
+		goType, _, err := GetGoDataTypeFromPg(pgType)
+		require.NoError(t, err, pgType)
+		assert.Equal(t, expected, goType, pgType)
+	}
+}
+
+func TestGetGoDataTypeFromPgFailure(t *testing.T) {
+
+	_, _, err := GetGoDataTypeFromPg("point")
+	assert.EqualError(t, err, "no go type found for pgType: point")
+
+	_, _, err = GetGoDataTypeFromPg("point[]")
+	assert.Error(t, err)
+}
+
+func TestGetTsDataTypeFromPg(t *testing.T) {
+
+	tsType, err := GetTsDataTypeFromPg("float8[]")
+	require.NoError(t, err)
+	assert.Equal(t, "number[]", tsType)
+}
+
+func TestGetColDataType(t *testing.T) {
+
+	assert.Equal(t, "text", getColDataType(lyspg.Column{DataType: "text", UdtName: "text"}))
+	assert.Equal(t, "int8[]", getColDataType(lyspg.Column{DataType: "ARRAY", UdtName: "_int8"}))
+	assert.Equal(t, "ARRAY", getColDataType(lyspg.Column{DataType: "ARRAY", UdtName: "_my_composite"}))
+}
@@ -0,0 +1,665 @@
+package lysgen
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lysstring"
+)
+
+// custom region markers. Lines between a begin and end marker with the same name are preserved when regenerating
+const (
+	regionBegin string = "// lysgen:begin "
+	regionEnd   string = "// lysgen:end "
+)
+
+// StoreField is a field of a generated Input or Model struct
+type StoreField struct {
+	GoName   string
+	GoType   string
+	DbName   string
+	DataType string // Postgres data type as in information_schema, e.g. "text". Used for TypeScript types
+	OmitStr  string // json omit option, e.g. "omitempty". Empty for nullable (pointer) fields
+	Validate string // validate tag, e.g. "required". Empty for none
+}
+
+// StoreSpec describes a store package. Get it from the db with GetStoreSpec and render it with RenderStore
+type StoreSpec struct {
+	PackageName    string   // e.g. "corecategory"
+	Name           string   // e.g. "Core category"
+	SchemaName     string   // e.g. "core"
+	TableName      string   // empty for views and set functions
+	ViewName       string   // "v_<table>" if that view exists, otherwise the table name. If TableName and SetFuncName are empty, a read-only store selecting from this view is generated
+	SetFuncName    string   // if set, a read-only store selecting from this set-returning function is generated
+	SetFuncParams  []string // url param names of the set function's input params, in order
+	PkColName      string
+	PkGoType       string // "int64", "uuid.UUID" or "string"
+	DefaultOrderBy string
+	Archived       bool   // if true, "<table>_archived" exists, and Archive and Restore are generated instead of Delete
+	Endpoint       string // used in the route snippet, e.g. "/category"
+	InputFields    []StoreField
+	ModelFields    []StoreField // fields not in Input. Model embeds Input, except for views and set functions
+	Enums          []EnumSpec   // enum types used by the fields, generated in the store package
+}
+
+// StoreFiles contains the generated code of a store package
+type StoreFiles struct {
+	Store  string // <package>.go
+	Test   string // <package>_test.go
+	Routes string // route registration snippet for the app's router
+}
+
+// GetStoreSpec returns the StoreSpec of the supplied db table, view or set-returning function.
+// Views and set-returning functions get read-only stores. Enum columns get Go enum types
+func GetStoreSpec(ctx context.Context, db *pgxpool.Pool, schema, name string, withValidation bool) (spec StoreSpec, err error) {
+
+	spec = StoreSpec{
+		PackageName: schema + strings.ReplaceAll(name, "_", ""),
+		Name:        lysstring.Title(schema) + " " + strings.ReplaceAll(name, "_", " "),
+		SchemaName:  schema,
+		Endpoint:    "/" + strings.ReplaceAll(name, "_", "-"),
+	}
+
+	relKind, err := getRelKind(ctx, db, schema, name)
+	if err != nil {
+		return StoreSpec{}, fmt.Errorf("getRelKind failed: %w", err)
+	}
+
+	var cols, viewCols []lyspg.Column
+
+	switch relKind {
+	case "":
+		// not a relation: try set function
+		cols, err = getSetFuncSpec(ctx, db, &spec, name)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("getSetFuncSpec failed: %w", err)
+		}
+
+	case "r", "p":
+		spec.TableName = name
+		spec.ViewName = name
+
+		cols, err = lyspg.GetTableColumns(ctx, db, schema, name)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("lyspg.GetTableColumns failed: %w", err)
+		}
+
+		spec.PkColName, spec.PkGoType, err = getPrimaryKey(ctx, db, schema, name)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("getPrimaryKey failed: %w", err)
+		}
+		spec.DefaultOrderBy = spec.PkColName
+
+		// use the view of the table if it exists
+		viewKind, err := getRelKind(ctx, db, schema, "v_"+name)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("getRelKind failed for view: %w", err)
+		}
+		if viewKind == "v" {
+			spec.ViewName = "v_" + name
+			viewCols, err = lyspg.GetTableColumns(ctx, db, schema, spec.ViewName)
+			if err != nil {
+				return StoreSpec{}, fmt.Errorf("lyspg.GetTableColumns failed for view: %w", err)
+			}
+		}
+
+		archivedColNames, err := lyspg.GetTableColumnNames(ctx, db, schema, name+"_archived")
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("lyspg.GetTableColumnNames failed for archived table: %w", err)
+		}
+		spec.Archived = len(archivedColNames) > 0
+
+	case "v":
+		spec.ViewName = name
+
+		// view columns are always reported as nullable, so are not made pointers
+		viewCols, err = lyspg.GetTableColumns(ctx, db, schema, name)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("lyspg.GetTableColumns failed: %w", err)
+		}
+		spec.DefaultOrderBy = viewCols[0].Name
+		if slices.ContainsFunc(viewCols, func(col lyspg.Column) bool { return col.Name == "id" }) {
+			spec.DefaultOrderBy = "id"
+		}
+
+	default:
+		return StoreSpec{}, fmt.Errorf("%s.%s has unsupported relkind: %s", schema, name, relKind)
+	}
+
+	enums, err := getEnumSpecs(ctx, db, slices.Concat(cols, viewCols))
+	if err != nil {
+		return StoreSpec{}, fmt.Errorf("getEnumSpecs failed: %w", err)
+	}
+
+	if spec.SetFuncName != "" {
+		spec.ModelFields, err = getSetFuncFields(cols, enums)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("getSetFuncFields failed: %w", err)
+		}
+	} else {
+		spec.InputFields, spec.ModelFields, err = getStoreFields(cols, viewCols, spec.PkColName, withValidation, enums)
+		if err != nil {
+			return StoreSpec{}, fmt.Errorf("getStoreFields failed: %w", err)
+		}
+	}
+
+	// only include the enums that are used
+	for _, enum := range enums {
+		if slices.ContainsFunc(slices.Concat(spec.InputFields, spec.ModelFields), func(f StoreField) bool { return strings.TrimLeft(f.GoType, "*[]") == enum.GoName }) {
+			spec.Enums = append(spec.Enums, enum)
+		}
+	}
+	slices.SortFunc(spec.Enums, func(a, b EnumSpec) int { return strings.Compare(a.GoName, b.GoName) })
+
+	return spec, nil
+}
+
+// getRelKind returns the pg_class relkind of a relation, e.g. "r" for a table or "v" for a view, or "" if it does not exist
+func getRelKind(ctx context.Context, db *pgxpool.Pool, schema, name string) (relKind string, err error) {
+
+	stmt := `SELECT COALESCE((SELECT c.relkind::text FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2), '');`
+
+	rows, _ := db.Query(ctx, stmt, schema, name)
+	relKind, err = pgx.CollectExactlyOneRow(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", lyserr.Db{Err: fmt.Errorf("pgx.CollectExactlyOneRow failed: %w", err), Stmt: stmt}
+	}
+
+	return relKind, nil
+}
+
+// getPrimaryKey returns the single primary key column of a table and its Go type
+func getPrimaryKey(ctx context.Context, db *pgxpool.Pool, schema, table string) (colName, goType string, err error) {
+
+	stmt := `SELECT a.attname, format_type(a.atttypid, NULL)
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = to_regclass($1) AND i.indisprimary;`
+
+	type pkCol struct {
+		Name     string
+		DataType string
+	}
+
+	rows, _ := db.Query(ctx, stmt, pgx.Identifier{schema, table}.Sanitize())
+	pkCols, err := pgx.CollectRows(rows, pgx.RowToStructByPos[pkCol])
+	if err != nil {
+		return "", "", lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+	if len(pkCols) != 1 {
+		return "", "", fmt.Errorf("table %s.%s must have a single column primary key", schema, table)
+	}
+
+	switch pkCols[0].DataType {
+	case "bigint", "integer", "smallint":
+		goType = "int64"
+	case "uuid":
+		goType = "uuid.UUID"
+	case "character varying", "text":
+		goType = "string"
+	default:
+		return "", "", fmt.Errorf("unsupported primary key type: %s", pkCols[0].DataType)
+	}
+
+	return pkCols[0].Name, goType, nil
+}
+
+// getFieldGoType returns the Go type of col, which is an enum type if col is an enum or enum array
+func getFieldGoType(col lyspg.Column, enums map[string]EnumSpec) (goType, omitStr string, err error) {
+
+	if col.DataType == "USER-DEFINED" || col.DataType == "ARRAY" {
+		if enum, ok := enums[col.UdtSchema+"."+strings.TrimPrefix(col.UdtName, "_")]; ok {
+			if col.DataType == "ARRAY" {
+				return "[]" + enum.GoName, "omitempty", nil
+			}
+			return enum.GoName, "omitempty", nil
+		}
+	}
+
+	return GetGoDataTypeFromPg(getColDataType(col))
+}
+
+// getStoreField returns the StoreField of col
+func getStoreField(col lyspg.Column, enums map[string]EnumSpec) (field StoreField, err error) {
+
+	goType, omitStr, err := getFieldGoType(col, enums)
+	if err != nil {
+		return StoreField{}, fmt.Errorf("getFieldGoType failed for column %s: %w", col.Name, err)
+	}
+
+	return StoreField{
+		GoName:   lysstring.Convert(col.Name, "_", "", lysstring.Title),
+		GoType:   goType,
+		DbName:   col.Name,
+		DataType: getColDataType(col),
+		OmitStr:  omitStr,
+	}, nil
+}
+
+// getStoreFields returns the Input and Model fields from the table cols, and the view cols if the table has a view. If there are only view cols, they are all Model fields
+func getStoreFields(cols, viewCols []lyspg.Column, pkColName string, withValidation bool, enums map[string]EnumSpec) (inputFields, modelFields []StoreField, err error) {
+
+	inputNames := []string{}
+
+	for _, col := range cols {
+
+		field, err := getStoreField(col, enums)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getStoreField failed: %w", err)
+		}
+
+		// db-assigned cols go into Model
+		if col.Name == pkColName || col.IsIdentity || col.IsGenerated || col.IsTracking {
+			modelFields = append(modelFields, field)
+			continue
+		}
+
+		if col.IsNullable {
+			field.GoType = "*" + field.GoType
+			field.OmitStr = ""
+		} else if withValidation && field.GoType != "bool" {
+			field.Validate = "required"
+			if enum, ok := enums[col.UdtSchema+"."+col.UdtName]; ok && col.DataType == "USER-DEFINED" && enum.OneOf() != "" {
+				field.Validate += "," + enum.OneOf()
+			}
+		}
+		inputFields = append(inputFields, field)
+		inputNames = append(inputNames, col.Name)
+	}
+
+	if len(viewCols) == 0 {
+		return inputFields, modelFields, nil
+	}
+
+	// the view determines the Model fields
+	modelFields = nil
+	for _, col := range viewCols {
+		if slices.Contains(inputNames, col.Name) {
+			continue
+		}
+		field, err := getStoreField(col, enums)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getStoreField failed for view: %w", err)
+		}
+		modelFields = append(modelFields, field)
+	}
+
+	return inputFields, modelFields, nil
+}
+
+// getSetFuncSpec fills spec from the set-returning function with the supplied name, which must return TABLE or have OUT params.
+// It returns the output cols. Domains are resolved to their base types, as in information_schema
+func getSetFuncSpec(ctx context.Context, db *pgxpool.Pool, spec *StoreSpec, funcName string) (cols []lyspg.Column, err error) {
+
+	stmt := `SELECT COALESCE(a.name, 'p' || a.ord), a.mode,
+			CASE WHEN bt.typcategory = 'A' THEN 'ARRAY' WHEN bt.typtype IN ('c','e') THEN 'USER-DEFINED' ELSE format_type(bt.oid, NULL) END,
+			bn.nspname::text, bt.typname::text
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		CROSS JOIN LATERAL unnest(p.proargnames, p.proargmodes::text[], p.proallargtypes) WITH ORDINALITY AS a(name, mode, type_oid, ord)
+		JOIN pg_type t ON t.oid = a.type_oid
+		JOIN pg_type bt ON bt.oid = CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE t.oid END
+		JOIN pg_namespace bn ON bn.oid = bt.typnamespace
+		WHERE n.nspname = $1 AND p.proname = $2 AND p.proretset
+		ORDER BY a.ord;`
+
+	type funcArg struct {
+		Name      string
+		Mode      string
+		DataType  string
+		UdtSchema string
+		UdtName   string
+	}
+
+	rows, _ := db.Query(ctx, stmt, spec.SchemaName, funcName)
+	args, err := pgx.CollectRows(rows, pgx.RowToStructByPos[funcArg])
+	if err != nil {
+		return nil, lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s.%s is neither a table, a view nor a set-returning function with TABLE or OUT columns", spec.SchemaName, funcName)
+	}
+
+	spec.SetFuncName = funcName
+	for _, arg := range args {
+
+		// input params: url param names omit the leading underscore
+		if arg.Mode == "i" || arg.Mode == "b" || arg.Mode == "v" {
+			spec.SetFuncParams = append(spec.SetFuncParams, strings.TrimPrefix(arg.Name, "_"))
+		}
+
+		// output cols
+		if arg.Mode == "o" || arg.Mode == "b" || arg.Mode == "t" {
+			cols = append(cols, lyspg.Column{SchemaName: spec.SchemaName, TableName: funcName, Name: arg.Name, DataType: arg.DataType, UdtSchema: arg.UdtSchema, UdtName: arg.UdtName})
+		}
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("set function %s.%s has no TABLE or OUT columns", spec.SchemaName, funcName)
+	}
+	spec.DefaultOrderBy = cols[0].Name
+
+	return cols, nil
+}
+
+// getSetFuncFields returns the Model fields of a set function's output cols
+func getSetFuncFields(cols []lyspg.Column, enums map[string]EnumSpec) (fields []StoreField, err error) {
+
+	for _, col := range cols {
+		field, err := getStoreField(col, enums)
+		if err != nil {
+			return nil, fmt.Errorf("getStoreField failed: %w", err)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// RenderStore renders the store package, test skeleton and route snippet of spec. Code inside the custom regions of existingStore and existingTest,
+// which may be empty, is copied into the corresponding regions of the result, so that regenerating with an unchanged spec returns the existing code
+func RenderStore(spec StoreSpec, existingStore, existingTest string) (files StoreFiles, err error) {
+
+	if err = validateStoreSpec(spec); err != nil {
+		return StoreFiles{}, err
+	}
+	if spec.PackageName == "" {
+		return StoreFiles{}, fmt.Errorf("spec must have PackageName")
+	}
+
+	data := storeTemplateData{StoreSpec: spec, Source: getStoreSource(spec), Regions: true}
+	data.StoreImports, data.TestImports = getStoreImports(spec)
+
+	files.Store, err = renderGo("store", data, existingStore)
+	if err != nil {
+		return StoreFiles{}, fmt.Errorf("renderGo failed for store: %w", err)
+	}
+	files.Test, err = renderGo("test", data, existingTest)
+	if err != nil {
+		return StoreFiles{}, fmt.Errorf("renderGo failed for test: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err = storeTmpls.ExecuteTemplate(&buf, "routes", data); err != nil {
+		return StoreFiles{}, fmt.Errorf("storeTmpls.ExecuteTemplate failed for routes: %w", err)
+	}
+	files.Routes = buf.String()
+
+	return files, nil
+}
+
+// validateStoreSpec checks that spec is either for a table, a set function or a view
+func validateStoreSpec(spec StoreSpec) error {
+
+	if spec.SchemaName == "" {
+		return fmt.Errorf("spec must have SchemaName")
+	}
+
+	switch {
+	case spec.TableName != "" && spec.SetFuncName == "":
+		if spec.PkColName == "" || spec.PkGoType == "" {
+			return fmt.Errorf("table spec must have PkColName and PkGoType")
+		}
+	case spec.SetFuncName != "" && spec.TableName == "":
+	case spec.ViewName != "" && spec.TableName == "" && spec.SetFuncName == "":
+	default:
+		return fmt.Errorf("spec must have one of TableName, SetFuncName or ViewName")
+	}
+
+	return nil
+}
+
+// getStoreSource returns the db table, set function or view of spec, e.g. "core.category"
+func getStoreSource(spec StoreSpec) string {
+	return spec.SchemaName + "." + cmp.Or(spec.TableName, spec.SetFuncName, spec.ViewName)
+}
+
+// Structs generates the enum types and the store Input and Model structs from the supplied db table, view or set-returning function.
+// Unlike InputModel, Model contains the columns of the table's view "v_<table>", if it exists, instead of joining parent tables
+func Structs(ctx context.Context, db *pgxpool.Pool, schema, name string, withValidation bool, options ...GenOptions) (res string, err error) {
+
+	spec, err := GetStoreSpec(ctx, db, schema, name, withValidation)
+	if err != nil {
+		return "", fmt.Errorf("GetStoreSpec failed: %w", err)
+	}
+
+	res, err = renderStructs(spec)
+	if err != nil {
+		return "", fmt.Errorf("renderStructs failed: %w", err)
+	}
+
+	// write to clipboard for convenience
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
+	}
+
+	return "\n" + res + "\n", nil
+}
+
+// renderStructs returns the formatted enum types and Input and Model structs of spec
+func renderStructs(spec StoreSpec) (res string, err error) {
+
+	if err = validateStoreSpec(spec); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = storeTmpls.ExecuteTemplate(&buf, "structs", storeTemplateData{StoreSpec: spec}); err != nil {
+		return "", fmt.Errorf("storeTmpls.ExecuteTemplate failed: %w", err)
+	}
+
+	return formatSnippet(buf.String())
+}
+
+// WriteStore generates the store package of the supplied db table, view or set function into dir/<package>, preserving the custom regions of existing files.
+// It returns the route registration snippet
+func WriteStore(ctx context.Context, db *pgxpool.Pool, schema, name, dir string, withValidation bool) (routes string, err error) {
+
+	spec, err := GetStoreSpec(ctx, db, schema, name, withValidation)
+	if err != nil {
+		return "", fmt.Errorf("GetStoreSpec failed: %w", err)
+	}
+
+	pkgDir := filepath.Join(dir, spec.PackageName)
+	storePath := filepath.Join(pkgDir, spec.PackageName+".go")
+	testPath := filepath.Join(pkgDir, spec.PackageName+"_test.go")
+
+	existingStore, err := readIfExists(storePath)
+	if err != nil {
+		return "", fmt.Errorf("readIfExists failed: %w", err)
+	}
+	existingTest, err := readIfExists(testPath)
+	if err != nil {
+		return "", fmt.Errorf("readIfExists failed: %w", err)
+	}
+
+	files, err := RenderStore(spec, existingStore, existingTest)
+	if err != nil {
+		return "", fmt.Errorf("RenderStore failed: %w", err)
+	}
+
+	if err = os.MkdirAll(pkgDir, 0755); err != nil {
+		return "", fmt.Errorf("os.MkdirAll failed: %w", err)
+	}
+	if err = os.WriteFile(storePath, []byte(files.Store), 0644); err != nil {
+		return "", fmt.Errorf("os.WriteFile failed for store: %w", err)
+	}
+	if err = os.WriteFile(testPath, []byte(files.Test), 0644); err != nil {
+		return "", fmt.Errorf("os.WriteFile failed for test: %w", err)
+	}
+
+	return files.Routes, nil
+}
+
+func readIfExists(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(b), nil
+}
+
+// getStoreImports returns the imports of the store and test files, sorted into stdlib and other groups separated by ""
+func getStoreImports(spec StoreSpec) (storeImports, testImports []string) {
+
+	usesType := func(prefix string) bool {
+		for _, f := range slices.Concat(spec.InputFields, spec.ModelFields) {
+			if strings.HasPrefix(strings.TrimPrefix(f.GoType, "*"), prefix) || strings.HasPrefix(f.GoType, "[]"+prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	other := []string{"github.com/jackc/pgx/v5/pgxpool", "github.com/loveyourstack/lys/lysmeta", "github.com/loveyourstack/lys/lyspg"}
+	if spec.TableName != "" {
+		other = append(other, "github.com/go-playground/validator/v10")
+	}
+	if spec.Archived {
+		other = append(other, "github.com/jackc/pgx/v5")
+	}
+	if spec.PkGoType == "uuid.UUID" || usesType("uuid.") {
+		other = append(other, "github.com/google/uuid")
+	}
+	if usesType("lystype.") {
+		other = append(other, "github.com/loveyourstack/lys/lystype")
+	}
+	slices.Sort(other)
+	std := []string{"context", "log"}
+	if len(spec.Enums) > 0 {
+		std = append(std, "slices")
+	}
+	storeImports = append(append(std, ""), other...)
+
+	testOther := []string{"github.com/jackc/pgx/v5/pgxpool", "github.com/loveyourstack/lys/lyspg", "github.com/stretchr/testify/require"}
+	if spec.TableName != "" {
+		testOther = append(testOther, "github.com/go-playground/validator/v10", "github.com/stretchr/testify/assert")
+	}
+	if spec.Archived {
+		testOther = append(testOther, "github.com/jackc/pgx/v5")
+	}
+	slices.Sort(testOther)
+	testImports = append([]string{"context", "testing", ""}, testOther...)
+
+	return storeImports, testImports
+}
+
+// renderGo executes the named template of storeTmpls, copies the custom regions of existing into the result, and formats it
+func renderGo(tmplName string, data storeTemplateData, existing string) (res string, err error) {
+
+	var buf bytes.Buffer
+	if err = storeTmpls.ExecuteTemplate(&buf, tmplName, data); err != nil {
+		return "", fmt.Errorf("storeTmpls.ExecuteTemplate failed: %w", err)
+	}
+
+	merged, err := mergeRegions(buf.String(), existing)
+	if err != nil {
+		return "", fmt.Errorf("mergeRegions failed: %w", err)
+	}
+
+	formatted, err := format.Source([]byte(merged))
+	if err != nil {
+		return "", fmt.Errorf("format.Source failed: %w", err)
+	}
+
+	return string(formatted), nil
+}
+
+// getRegions returns the lines inside each custom region of src, keyed by region name
+func getRegions(src string) (regions map[string][]string, err error) {
+
+	regions = make(map[string][]string)
+	current := ""
+
+	for i, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, regionBegin):
+			if current != "" {
+				return nil, fmt.Errorf("line %d: region %s begins inside region %s", i+1, strings.TrimPrefix(trimmed, regionBegin), current)
+			}
+			current = strings.TrimPrefix(trimmed, regionBegin)
+			if _, ok := regions[current]; ok {
+				return nil, fmt.Errorf("line %d: duplicate region: %s", i+1, current)
+			}
+			regions[current] = []string{}
+
+		case strings.HasPrefix(trimmed, regionEnd):
+			if name := strings.TrimPrefix(trimmed, regionEnd); name != current {
+				return nil, fmt.Errorf("line %d: unexpected end of region: %s", i+1, name)
+			}
+			current = ""
+
+		case current != "":
+			regions[current] = append(regions[current], line)
+		}
+	}
+
+	if current != "" {
+		return nil, fmt.Errorf("region %s is not ended", current)
+	}
+
+	return regions, nil
+}
+
+// mergeRegions replaces the custom regions of generated with those of existing. It returns an error if existing has a non-empty region that generated lacks
+func mergeRegions(generated, existing string) (res string, err error) {
+
+	existingRegions, err := getRegions(existing)
+	if err != nil {
+		return "", fmt.Errorf("getRegions failed for existing code: %w", err)
+	}
+	generatedRegions, err := getRegions(generated)
+	if err != nil {
+		return "", fmt.Errorf("getRegions failed for generated code: %w", err)
+	}
+	for name, lines := range existingRegions {
+		if _, ok := generatedRegions[name]; !ok && strings.TrimSpace(strings.Join(lines, "")) != "" {
+			return "", fmt.Errorf("custom region %s is no longer generated: move its code before regenerating", name)
+		}
+	}
+
+	var resA []string
+	skipping := false
+
+	for _, line := range strings.Split(generated, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, regionBegin):
+			resA = append(resA, line)
+			name := strings.TrimPrefix(trimmed, regionBegin)
+			if existingLines, ok := existingRegions[name]; ok {
+				resA = append(resA, existingLines...)
+				skipping = true
+			}
+
+		case strings.HasPrefix(trimmed, regionEnd):
+			resA = append(resA, line)
+			skipping = false
+
+		case !skipping:
+			resA = append(resA, line)
+		}
+	}
+
+	return strings.Join(resA, "\n"), nil
+}
@@ -0,0 +1,210 @@
+package lysgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestStoreSpecs() map[string]StoreSpec {
+	return map[string]StoreSpec{
+		"table": {
+			PackageName: "corecategory", Name: "Core category", SchemaName: "core", TableName: "category", ViewName: "v_category",
+			PkColName: "id", PkGoType: "int64", DefaultOrderBy: "id", Endpoint: "/category",
+			InputFields: []StoreField{
+				{GoName: "Name", GoType: "string", DbName: "name", DataType: "text", OmitStr: "omitempty", Validate: "required"},
+				{GoName: "ValidFrom", GoType: "*lystype.Date", DbName: "valid_from", DataType: "date"},
+			},
+			ModelFields: []StoreField{
+				{GoName: "Id", GoType: "int64", DbName: "id", DataType: "bigint", OmitStr: "omitempty"},
+				{GoName: "CreatedAt", GoType: "lystype.Datetime", DbName: "created_at", DataType: "timestamp with time zone", OmitStr: "omitzero"},
+			},
+		},
+		"archived uuid": {
+			PackageName: "coreitem", Name: "Core item", SchemaName: "core", TableName: "item", ViewName: "item",
+			PkColName: "id", PkGoType: "uuid.UUID", DefaultOrderBy: "id", Archived: true, Endpoint: "/item",
+			InputFields: []StoreField{{GoName: "Name", GoType: "string", DbName: "name", DataType: "text", OmitStr: "omitempty"}},
+			ModelFields: []StoreField{{GoName: "Id", GoType: "uuid.UUID", DbName: "id", DataType: "uuid", OmitStr: "omitzero"}},
+		},
+		"set func": {
+			PackageName: "coresetfunc", Name: "Core setfunc", SchemaName: "core", SetFuncName: "setfunc", SetFuncParams: []string{"p_text", "p_int"},
+			DefaultOrderBy: "text_val", Endpoint: "/setfunc",
+			ModelFields: []StoreField{
+				{GoName: "TextVal", GoType: "string", DbName: "text_val", DataType: "text", OmitStr: "omitempty"},
+				{GoName: "IntVal", GoType: "int", DbName: "int_val", DataType: "integer", OmitStr: "omitempty"},
+			},
+		},
+		"view with enum": {
+			PackageName: "coreordersummary", Name: "Core order summary", SchemaName: "core", ViewName: "order_summary",
+			DefaultOrderBy: "id", Endpoint: "/order-summary",
+			ModelFields: []StoreField{
+				{GoName: "Id", GoType: "int64", DbName: "id", DataType: "bigint", OmitStr: "omitempty"},
+				{GoName: "Status", GoType: "OrderStatus", DbName: "status", DataType: "USER-DEFINED", OmitStr: "omitempty"},
+				{GoName: "Amounts", GoType: "[]float64", DbName: "amounts", DataType: "numeric[]", OmitStr: "omitempty"},
+			},
+			Enums: []EnumSpec{{SchemaName: "core", Name: "order_status", GoName: "OrderStatus", Values: []string{"open", "closed"}}},
+		},
+	}
+}
+
+func TestRenderStoreSuccess(t *testing.T) {
+
+	specs := getTestStoreSpecs()
+
+	files, err := RenderStore(specs["table"], "", "")
+	require.NoError(t, err)
+	assert.Contains(t, files.Store, "package corecategory\n")
+	assert.Contains(t, files.Store, "\"github.com/loveyourstack/lys/lystype\"")
+	assert.NotContains(t, files.Store, "\"github.com/google/uuid\"")
+	assert.Contains(t, files.Store, "viewName       string = \"v_category\"")
+	assert.Contains(t, files.Store, "Name      string        `db:\"name\" json:\"name,omitempty\" validate:\"required\"`")
+	assert.Contains(t, files.Store, "ValidFrom *lystype.Date `db:\"valid_from\" json:\"valid_from\"`")
+	assert.Contains(t, files.Store, "func (s Store) Delete(ctx context.Context, id int64) error {")
+	assert.Contains(t, files.Store, "lyspg.Insert[Input, int64](ctx, s.Db, schemaName, tableName, pkColName, input)")
+	assert.NotContains(t, files.Store, "Archive")
+	assert.Contains(t, files.Test, "require.NoError(t, store.Delete(ctx, newId))")
+	assert.Contains(t, files.Routes, "categoryStore := corecategory.Store{Db: srvApp.Db}")
+	assert.Contains(t, files.Routes, "r.HandleFunc(endpoint+\"/{id}\", lys.Delete(apiEnv, categoryStore)).Methods(\"DELETE\")")
+
+	files, err = RenderStore(specs["archived uuid"], "", "")
+	require.NoError(t, err)
+	assert.Contains(t, files.Store, "\"github.com/google/uuid\"")
+	assert.Contains(t, files.Store, "\"github.com/jackc/pgx/v5\"")
+	assert.Contains(t, files.Store, "func (s Store) Archive(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {")
+	assert.Contains(t, files.Store, "func (s Store) Restore(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {")
+	assert.Contains(t, files.Store, "func (s Store) SelectById(ctx context.Context, id uuid.UUID) (item Model, err error) {")
+	assert.NotContains(t, files.Store, "Delete")
+	assert.Contains(t, files.Test, "return store.Archive(ctx, tx, newId)")
+	assert.Contains(t, files.Routes, "lys.Archive(apiEnv, srvApp.Db, itemStore)")
+
+	files, err = RenderStore(specs["set func"], "", "")
+	require.NoError(t, err)
+	assert.Contains(t, files.Store, "setFuncName    string = \"setfunc\"")
+	assert.Contains(t, files.Store, "return []string{\"p_text\", \"p_int\"}")
+	assert.Contains(t, files.Store, "lyspg.Select[Model](ctx, s.Db, schemaName, \"\", setFuncName, defaultOrderBy, plan.DbNames(), params)")
+	assert.NotContains(t, files.Store, "Input")
+	assert.NotContains(t, files.Store, "validator")
+	assert.Contains(t, files.Test, "func TestSelect(t *testing.T) {")
+	assert.Contains(t, files.Routes, "SetFuncUrlParamNames: setfuncStore.GetSetFuncUrlParamNames(),")
+
+	files, err = RenderStore(specs["view with enum"], "", "")
+	require.NoError(t, err)
+	assert.Contains(t, files.Store, "// Generated by lysgen from core.order_summary.")
+	assert.Contains(t, files.Store, "\t\"log\"\n\t\"slices\"\n")
+	assert.Contains(t, files.Store, "viewName       string = \"order_summary\"")
+	assert.Contains(t, files.Store, "type OrderStatus string")
+	assert.Contains(t, files.Store, "Status  OrderStatus `db:\"status\" json:\"status,omitempty\"`")
+	assert.Contains(t, files.Store, "lyspg.Select[Model](ctx, s.Db, schemaName, \"\", viewName, defaultOrderBy, plan.DbNames(), params)")
+	assert.NotContains(t, files.Store, "Input")
+	assert.NotContains(t, files.Store, "SelectById")
+	assert.Contains(t, files.Test, "func TestSelect(t *testing.T) {")
+	assert.NotContains(t, files.Test, "SetFuncParamValues")
+	assert.Contains(t, files.Routes, "orderSummaryStore := coreordersummary.Store{Db: srvApp.Db}")
+	assert.Contains(t, files.Routes, "r.HandleFunc(endpoint, lys.Get(apiEnv, orderSummaryStore, nil)).Methods(\"GET\")")
+	assert.NotContains(t, files.Routes, "POST")
+}
+
+func TestRenderStructsSuccess(t *testing.T) {
+
+	specs := getTestStoreSpecs()
+
+	res, err := renderStructs(specs["table"])
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(res, "type Input struct {"))
+	assert.Contains(t, res, "type Model struct {")
+	assert.NotContains(t, res, "lysgen:")
+
+	res, err = renderStructs(specs["view with enum"])
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(res, "// OrderStatus is the db enum core.order_status"))
+	assert.NotContains(t, res, "type Input struct {")
+}
+
+func TestGetStoreFieldsEnums(t *testing.T) {
+
+	enums := map[string]EnumSpec{
+		"core.status": {SchemaName: "core", Name: "status", GoName: "Status", Values: []string{"a", "b"}},
+	}
+	cols := []lyspg.Column{
+		{Name: "id", DataType: "bigint", UdtName: "int8", IsIdentity: true},
+		{Name: "status", DataType: "USER-DEFINED", UdtSchema: "core", UdtName: "status"},
+		{Name: "statuses", DataType: "ARRAY", UdtSchema: "core", UdtName: "_status"},
+		{Name: "other", DataType: "USER-DEFINED", UdtSchema: "core", UdtName: "other", IsNullable: true},
+		{Name: "ids", DataType: "ARRAY", UdtSchema: "pg_catalog", UdtName: "_int8", IsNullable: true},
+	}
+
+	inputFields, modelFields, err := getStoreFields(cols, nil, "id", true, enums)
+	require.NoError(t, err)
+	require.Len(t, inputFields, 4)
+	assert.Equal(t, StoreField{GoName: "Status", GoType: "Status", DbName: "status", DataType: "USER-DEFINED", OmitStr: "omitempty", Validate: "required,oneof=a b"}, inputFields[0])
+	assert.Equal(t, "[]Status", inputFields[1].GoType)
+	assert.Equal(t, "required", inputFields[1].Validate)
+	assert.Equal(t, "*string", inputFields[2].GoType)
+	assert.Equal(t, "*[]int64", inputFields[3].GoType)
+	assert.Equal(t, "int8[]", inputFields[3].DataType)
+	require.Len(t, modelFields, 1)
+	assert.Equal(t, "int64", modelFields[0].GoType)
+}
+
+func TestRenderStoreRegions(t *testing.T) {
+
+	spec := getTestStoreSpecs()["table"]
+
+	files, err := RenderStore(spec, "", "")
+	require.NoError(t, err)
+
+	// add custom code
+	existingStore := strings.Replace(files.Store, "\t// lysgen:begin model\n", "\t// lysgen:begin model\n\tExtra string `db:\"-\" json:\"extra\"`\n", 1)
+	existingStore = strings.Replace(existingStore, "// lysgen:begin methods\n", "// lysgen:begin methods\nfunc (s Store) Custom() string {\n\treturn \"custom\"\n}\n", 1)
+	existingTest := strings.Replace(files.Test, "\treturn Input{}\n", "\treturn Input{Name: \"a\"}\n", 1)
+
+	// regenerate with a changed spec
+	spec.InputFields = append(spec.InputFields, StoreField{GoName: "Code", GoType: "string", DbName: "code", OmitStr: "omitempty"})
+	files2, err := RenderStore(spec, existingStore, existingTest)
+	require.NoError(t, err)
+	assert.Contains(t, files2.Store, "Extra string `db:\"-\" json:\"extra\"`")
+	assert.Contains(t, files2.Store, "func (s Store) Custom() string {")
+	assert.Contains(t, files2.Store, "Code      string        `db:\"code\" json:\"code,omitempty\"`")
+	assert.Contains(t, files2.Test, "return Input{Name: \"a\"}")
+	assert.NotContains(t, files2.Test, "return Input{}")
+
+	// idempotent
+	files3, err := RenderStore(spec, files2.Store, files2.Test)
+	require.NoError(t, err)
+	assert.Equal(t, files2, files3)
+}
+
+func TestRenderStoreFailure(t *testing.T) {
+
+	specs := getTestStoreSpecs()
+
+	_, err := RenderStore(StoreSpec{PackageName: "a", SchemaName: "core"}, "", "")
+	assert.Error(t, err)
+
+	spec := specs["table"]
+	spec.PkGoType = ""
+	_, err = RenderStore(spec, "", "")
+	assert.Error(t, err)
+
+	spec = specs["set func"]
+	spec.ViewName = "v"
+	spec.TableName = "t"
+	_, err = RenderStore(spec, "", "")
+	assert.ErrorContains(t, err, "spec must have one of TableName, SetFuncName or ViewName")
+
+	// custom region that is not generated for set funcs
+	files, err := RenderStore(specs["table"], "", "")
+	require.NoError(t, err)
+	existing := strings.Replace(files.Store, "\t// lysgen:begin input\n", "\t// lysgen:begin input\n\tExtra string\n", 1)
+	_, err = RenderStore(specs["set func"], existing, "")
+	assert.ErrorContains(t, err, "custom region input is no longer generated")
+
+	// unbalanced regions
+	_, err = RenderStore(specs["table"], "// lysgen:begin model\n", "")
+	assert.ErrorContains(t, err, "region model is not ended")
+	_, err = RenderStore(specs["table"], "// lysgen:begin model\n// lysgen:end methods\n", "")
+	assert.ErrorContains(t, err, "unexpected end of region: methods")
+}
@@ -0,0 +1,301 @@
+package lysgen
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/loveyourstack/lys/lysstring"
+)
+
+type storeTemplateData struct {
+	StoreSpec
+	Source       string // the db table, view or set function, e.g. "core.category"
+	Regions      bool   // if true, custom regions are added
+	StoreImports []string
+	TestImports  []string
+}
+
+var storeFuncs = template.FuncMap{
+	"tag": func(f StoreField) string {
+		jsonVal := f.DbName
+		if f.OmitStr != "" {
+			jsonVal += "," + f.OmitStr
+		}
+		tag := fmt.Sprintf("db:%q json:%q", f.DbName, jsonVal)
+		if f.Validate != "" {
+			tag += fmt.Sprintf(" validate:%q", f.Validate)
+		}
+		return "`" + tag + "`"
+	},
+	"quoteJoin": func(a []string) string {
+		quoted := make([]string, len(a))
+		for i, s := range a {
+			quoted[i] = fmt.Sprintf("%q", s)
+		}
+		return strings.Join(quoted, ", ")
+	},
+	"storeVar": func(spec StoreSpec) string {
+		name := cmp.Or(spec.TableName, spec.SetFuncName, spec.ViewName)
+		return lysstring.FirstLower(lysstring.Convert(name, "_", "", lysstring.Title)) + "Store"
+	},
+}
+
+// storeTmpls contains the templates "enum", "structs", "store", "test" and "routes", which are executed with storeTemplateData, except for "enum" (EnumSpec)
+var storeTmpls = template.Must(template.New("").Funcs(storeFuncs).Parse(`
+{{- define "enum"}}
+// {{.GoName}} is the db enum {{.SchemaName}}.{{.Name}}
+type {{.GoName}} string
+
+const (
+{{- range $i, $name := .ConstNames}}
+	{{$name}} {{$.GoName}} = "{{index $.Values $i}}"
+{{- end}}
+)
+
+// {{.GoName}}Values are the values of {{.GoName}} in enum order
+var {{.GoName}}Values = []{{.GoName}}{ {{- range $i, $name := .ConstNames}}{{if $i}}, {{end}}{{$name}}{{end -}} }
+
+// Valid returns true if e is one of {{.GoName}}Values
+func (e {{.GoName}}) Valid() bool {
+	return slices.Contains({{.GoName}}Values, e)
+}
+{{end}}
+
+{{- define "structs"}}
+{{- range .Enums}}{{template "enum" .}}{{end}}
+{{- if .TableName}}
+type Input struct {
+{{- range .InputFields}}
+	{{.GoName}} {{.GoType}} {{tag .}}
+{{- end}}
+{{- if .Regions}}
+	// lysgen:begin input
+	// lysgen:end input
+{{- end}}
+}
+{{end}}
+type Model struct {
+{{- range .ModelFields}}
+	{{.GoName}} {{.GoType}} {{tag .}}
+{{- end}}
+{{- if .Regions}}
+	// lysgen:begin model
+	// lysgen:end model
+{{- end}}
+{{- if .TableName}}
+	Input
+{{- end}}
+}
+{{end}}
+
+{{- define "header"}}// Generated by lysgen from {{.Source}}.
+// Regenerating keeps the code between lysgen:begin and lysgen:end comments. Other changes are overwritten.
+
+package {{.PackageName}}
+{{end}}
+
+{{- define "store"}}{{template "header" .}}
+import (
+{{- range .StoreImports}}
+	{{if .}}"{{.}}"{{end}}
+{{- end}}
+	// lysgen:begin imports
+	// lysgen:end imports
+)
+
+const (
+	name           string = "{{.Name}}"
+	schemaName     string = "{{.SchemaName}}"
+{{- if .TableName}}
+	tableName      string = "{{.TableName}}"
+	viewName       string = "{{.ViewName}}"
+	pkColName      string = "{{.PkColName}}"
+{{- else if .SetFuncName}}
+	setFuncName    string = "{{.SetFuncName}}"
+{{- else}}
+	viewName       string = "{{.ViewName}}"
+{{- end}}
+	defaultOrderBy string = "{{.DefaultOrderBy}}"
+)
+{{template "structs" .}}
+var (
+	plan{{if .TableName}}, inputPlan{{end}} lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, {{if .TableName}}tableName{{else if .SetFuncName}}setFuncName{{else}}viewName{{end}}, err.Error())
+	}
+{{- if .TableName}}
+	inputPlan, _ = lysmeta.Analyze(Input{})
+{{- end}}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+{{if .Archived}}
+func (s Store) Archive(ctx context.Context, tx pgx.Tx, id {{.PkGoType}}) error {
+	return lyspg.Archive(ctx, tx, schemaName, tableName, pkColName, id, false)
+}
+{{else if .TableName}}
+func (s Store) Delete(ctx context.Context, id {{.PkGoType}}) error {
+	return lyspg.DeleteUnique(ctx, s.Db, schemaName, tableName, pkColName, id)
+}
+{{end}}
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+{{- if .SetFuncName}}
+func (s Store) GetSetFuncUrlParamNames() []string {
+	return []string{ {{- quoteJoin .SetFuncParams -}} }
+}
+{{- end}}
+{{if .TableName}}
+func (s Store) Insert(ctx context.Context, input Input) (newId {{.PkGoType}}, err error) {
+	return lyspg.Insert[Input, {{.PkGoType}}](ctx, s.Db, schemaName, tableName, pkColName, input)
+}
+{{end}}
+{{- if .Archived}}
+func (s Store) Restore(ctx context.Context, tx pgx.Tx, id {{.PkGoType}}) error {
+	return lyspg.Restore(ctx, tx, schemaName, tableName, pkColName, id, false)
+}
+{{end}}
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+{{- if .TableName}}
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+{{- else if .SetFuncName}}
+	return lyspg.Select[Model](ctx, s.Db, schemaName, "", setFuncName, defaultOrderBy, plan.DbNames(), params)
+{{- else}}
+	return lyspg.Select[Model](ctx, s.Db, schemaName, "", viewName, defaultOrderBy, plan.DbNames(), params)
+{{- end}}
+}
+{{if .TableName}}
+func (s Store) SelectById(ctx context.Context, id {{.PkGoType}}) (item Model, err error) {
+	return lyspg.SelectUnique[Model](ctx, s.Db, schemaName, viewName, pkColName, id)
+}
+
+func (s Store) Update(ctx context.Context, input Input, id {{.PkGoType}}) error {
+	return lyspg.Update(ctx, s.Db, schemaName, tableName, pkColName, input, id)
+}
+
+func (s Store) UpdatePartial(ctx context.Context, assignmentsMap map[string]any, id {{.PkGoType}}) error {
+	return lyspg.UpdatePartial(ctx, s.Db, schemaName, tableName, pkColName, inputPlan.JsonKeyDbNameMap(), assignmentsMap, id)
+}
+
+func (s Store) Validate(validate *validator.Validate, input Input) error {
+	return lysmeta.Validate(validate, input)
+}
+{{end}}
+// lysgen:begin methods
+// lysgen:end methods
+{{end}}
+
+{{- define "test"}}{{template "header" .}}
+import (
+{{- range .TestImports}}
+	{{if .}}"{{.}}"{{end}}
+{{- end}}
+	// lysgen:begin imports
+	// lysgen:end imports
+)
+
+// getTestDb returns a pool connected to a db containing {{.Source}}
+func getTestDb(t *testing.T) *pgxpool.Pool {
+	// lysgen:begin testdb
+	t.Skip("getTestDb: connect to a test db, e.g. using lyspgdbtest.TestDbTemplate.MustClone")
+	return nil
+	// lysgen:end testdb
+}
+{{if .TableName}}
+// getTestInput returns a valid Input
+func getTestInput() Input {
+	// lysgen:begin testinput
+	return Input{}
+	// lysgen:end testinput
+}
+
+func TestStore(t *testing.T) {
+
+	ctx := context.Background()
+	store := Store{Db: getTestDb(t)}
+
+	input := getTestInput()
+	require.NoError(t, store.Validate(validator.New(), input))
+
+	newId, err := store.Insert(ctx, input)
+	require.NoError(t, err)
+
+	item, err := store.SelectById(ctx, newId)
+	require.NoError(t, err)
+	assert.Equal(t, input, item.Input)
+
+	require.NoError(t, store.Update(ctx, input, newId))
+
+	_, _, err = store.Select(ctx, lyspg.SelectParams{})
+	require.NoError(t, err)
+{{- if .Archived}}
+
+	require.NoError(t, pgx.BeginFunc(ctx, store.Db, func(tx pgx.Tx) error {
+		return store.Archive(ctx, tx, newId)
+	}))
+	require.NoError(t, pgx.BeginFunc(ctx, store.Db, func(tx pgx.Tx) error {
+		return store.Restore(ctx, tx, newId)
+	}))
+{{- else}}
+
+	require.NoError(t, store.Delete(ctx, newId))
+{{- end}}
+}
+{{else}}
+func TestSelect(t *testing.T) {
+
+	ctx := context.Background()
+	store := Store{Db: getTestDb(t)}
+
+	params := lyspg.SelectParams{
+		// lysgen:begin params
+{{- if .SetFuncName}}
+		SetFuncParamValues: []any{},
+{{- end}}
+		// lysgen:end params
+	}
+	_, _, err := store.Select(ctx, params)
+	require.NoError(t, err)
+}
+{{end}}
+// lysgen:begin tests
+// lysgen:end tests
+{{end}}
+
+{{- define "routes"}}	endpoint = "{{.Endpoint}}"
+
+	{{storeVar .StoreSpec}} := {{.PackageName}}.Store{Db: srvApp.Db}
+{{- if .SetFuncName}}
+	r.HandleFunc(endpoint, lys.Get(apiEnv, {{storeVar .StoreSpec}}, &lys.GetOpts[{{.PackageName}}.Model]{
+		SetFuncUrlParamNames: {{storeVar .StoreSpec}}.GetSetFuncUrlParamNames(),
+	})).Methods("GET")
+{{- else if not .TableName}}
+	r.HandleFunc(endpoint, lys.Get(apiEnv, {{storeVar .StoreSpec}}, nil)).Methods("GET")
+{{- else}}
+	r.HandleFunc(endpoint, lys.Get(apiEnv, {{storeVar .StoreSpec}}, nil)).Methods("GET")
+	r.HandleFunc(endpoint+"/{id}", lys.GetById(apiEnv, {{storeVar .StoreSpec}})).Methods("GET")
+	r.HandleFunc(endpoint, lys.Post(apiEnv, {{storeVar .StoreSpec}})).Methods("POST")
+	r.HandleFunc(endpoint+"/{id}", lys.Put(apiEnv, {{storeVar .StoreSpec}})).Methods("PUT")
+	r.HandleFunc(endpoint+"/{id}", lys.Patch(apiEnv, {{storeVar .StoreSpec}})).Methods("PATCH")
+{{- if .Archived}}
+	r.HandleFunc(endpoint+"/{id}/archive", lys.Archive(apiEnv, srvApp.Db, {{storeVar .StoreSpec}})).Methods("DELETE")
+	r.HandleFunc(endpoint+"/{id}/restore", lys.Restore(apiEnv, srvApp.Db, {{storeVar .StoreSpec}})).Methods("POST")
+{{- else}}
+	r.HandleFunc(endpoint+"/{id}", lys.Delete(apiEnv, {{storeVar .StoreSpec}})).Methods("DELETE")
+{{- end}}
+{{- end}}
+{{end}}
+`))
@@ -0,0 +1,498 @@
+package lysgen
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysstring"
+)
+
+// TsClientRuntimeFileName is the file name of the shared TypeScript client runtime, which is imported by each store client
+const TsClientRuntimeFileName string = "lys.ts"
+
+type tsClientField struct {
+	Name       string
+	Type       string
+	Optional   bool
+	FilterType string // scalar type used in filters
+}
+
+type tsClientEnum struct {
+	Name  string
+	Union string // e.g. "'a' | 'b'"
+}
+
+type tsClientData struct {
+	Source        string // e.g. "core.category"
+	TypeName      string // e.g. "Category"
+	Enums         []tsClientEnum
+	Endpoint      string
+	PkType        string
+	Archived      bool
+	InputFields   []tsClientField
+	ModelFields   []tsClientField // all fields of the response, including Input fields
+	SetFuncParams []string
+}
+
+// getTsClientType returns the TypeScript type of f as exchanged via json.
+// Dates and times are strings, since lystype marshals them in its own formats, e.g. lystype.DatetimeFormat. Enum fields use the type of their enum
+func getTsClientType(f StoreField, enums []EnumSpec) (tsType, filterType string, err error) {
+
+	baseGoType := strings.TrimLeft(f.GoType, "*")
+	elemGoType, isSlice := strings.CutPrefix(baseGoType, "[]")
+
+	if i := slices.IndexFunc(enums, func(e EnumSpec) bool { return e.GoName == elemGoType }); i != -1 {
+		tsType = enums[i].GoName
+		if isSlice {
+			tsType += "[]"
+		}
+	} else {
+		tsType, err = GetTsDataTypeFromPg(f.DataType)
+		if err != nil {
+			return "", "", fmt.Errorf("GetTsDataTypeFromPg failed for field %s: %w", f.DbName, err)
+		}
+		tsType = strings.ReplaceAll(tsType, "Date", "string")
+	}
+	filterType = strings.TrimSuffix(tsType, "[]")
+
+	if strings.HasPrefix(f.GoType, "*") {
+		tsType += " | null"
+	}
+
+	return tsType, filterType, nil
+}
+
+// TsClient generates a typed TypeScript client for the store described by spec. It imports the runtime returned by TsClientRuntime from "./lys".
+// The output only depends on spec, so that it can be committed and regenerated
+func TsClient(spec StoreSpec) (res string, err error) {
+
+	if err = validateStoreSpec(spec); err != nil {
+		return "", err
+	}
+
+	data := tsClientData{
+		Archived:      spec.Archived,
+		Endpoint:      spec.Endpoint,
+		SetFuncParams: spec.SetFuncParams,
+	}
+
+	name := cmp.Or(spec.TableName, spec.SetFuncName, spec.ViewName)
+	data.Source = getStoreSource(spec)
+	data.TypeName = lysstring.Convert(name, "_", "", lysstring.Title)
+	if data.Endpoint == "" {
+		data.Endpoint = "/" + strings.ReplaceAll(name, "_", "-")
+	}
+
+	switch spec.PkGoType {
+	case "":
+	case "int64":
+		data.PkType = "number"
+	case "uuid.UUID", "string":
+		data.PkType = "string"
+	default:
+		return "", fmt.Errorf("unsupported PkGoType: %s", spec.PkGoType)
+	}
+
+	for _, enum := range spec.Enums {
+		vals := make([]string, len(enum.Values))
+		for i, val := range enum.Values {
+			vals[i] = "'" + strings.ReplaceAll(val, "'", "\\'") + "'"
+		}
+		data.Enums = append(data.Enums, tsClientEnum{Name: enum.GoName, Union: strings.Join(vals, " | ")})
+	}
+
+	// response fields may be missing due to json omit options
+	for _, f := range spec.ModelFields {
+		tsType, filterType, err := getTsClientType(f, spec.Enums)
+		if err != nil {
+			return "", fmt.Errorf("getTsClientType failed: %w", err)
+		}
+		data.ModelFields = append(data.ModelFields, tsClientField{Name: f.DbName, Type: tsType, Optional: f.OmitStr != "", FilterType: filterType})
+	}
+	for _, f := range spec.InputFields {
+		tsType, filterType, err := getTsClientType(f, spec.Enums)
+		if err != nil {
+			return "", fmt.Errorf("getTsClientType failed: %w", err)
+		}
+		data.InputFields = append(data.InputFields, tsClientField{Name: f.DbName, Type: tsType, Optional: f.Validate != "required", FilterType: filterType})
+		data.ModelFields = append(data.ModelFields, tsClientField{Name: f.DbName, Type: tsType, Optional: f.OmitStr != "", FilterType: filterType})
+	}
+
+	var buf bytes.Buffer
+	if err = tsClientTmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("tsClientTmpl.Execute failed: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// TsClientRuntime returns the shared TypeScript client runtime: the StdResponse and GetMetadata envelope types, LysApiError, filter builders and LysClient
+func TsClientRuntime() string {
+	return tsClientRuntime
+}
+
+// WriteTsClient generates the TypeScript client of the supplied db table, view or set function into dir/<package>.ts, and (re-)writes the runtime to dir/lys.ts
+func WriteTsClient(ctx context.Context, db *pgxpool.Pool, schema, name, dir string) (err error) {
+
+	spec, err := GetStoreSpec(ctx, db, schema, name, true)
+	if err != nil {
+		return fmt.Errorf("GetStoreSpec failed: %w", err)
+	}
+
+	client, err := TsClient(spec)
+	if err != nil {
+		return fmt.Errorf("TsClient failed: %w", err)
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll failed: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, TsClientRuntimeFileName), []byte(tsClientRuntime), 0644); err != nil {
+		return fmt.Errorf("os.WriteFile failed for runtime: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, spec.PackageName+".ts"), []byte(client), 0644); err != nil {
+		return fmt.Errorf("os.WriteFile failed for client: %w", err)
+	}
+
+	return nil
+}
+
+var tsClientTmpl = template.Must(template.New("tsClient").Parse(`// Generated by lysgen from {{.Source}}. Do not edit: regenerate instead.
+
+import type { FieldFilter, ListParams, ListResult, LysClient{{if .SetFuncParams}}, Scalar{{end}} } from './lys'
+{{range .Enums}}
+export type {{.Name}} = {{.Union}}
+{{end}}
+{{- if .PkType}}
+export interface {{.TypeName}}Input {
+{{- range .InputFields}}
+  {{.Name}}{{if .Optional}}?{{end}}: {{.Type}}
+{{- end}}
+}
+{{end}}
+export interface {{.TypeName}} {
+{{- range .ModelFields}}
+  {{.Name}}{{if .Optional}}?{{end}}: {{.Type}}
+{{- end}}
+}
+
+export type {{.TypeName}}Field = keyof {{.TypeName}}
+
+export type {{.TypeName}}Filters = {
+{{- range .ModelFields}}
+  {{.Name}}?: FieldFilter<{{.FilterType}}>
+{{- end}}
+}
+{{if .SetFuncParams}}
+export type {{.TypeName}}FuncParams = {
+{{- range .SetFuncParams}}
+  {{.}}: Scalar
+{{- end}}
+}
+{{end}}
+export class {{.TypeName}}Client {
+  readonly endpoint: string
+  private readonly client: LysClient
+
+  constructor(client: LysClient, endpoint = '{{.Endpoint}}') {
+    this.client = client
+    this.endpoint = endpoint
+  }
+{{if .SetFuncParams}}
+  list(funcParams: {{.TypeName}}FuncParams, params: ListParams<{{.TypeName}}Filters, {{.TypeName}}Field> = {}): Promise<ListResult<{{.TypeName}}>> {
+    return this.client.list<{{.TypeName}}, {{.TypeName}}Filters, {{.TypeName}}Field>(this.endpoint, params, funcParams)
+  }
+{{- else}}
+  list(params: ListParams<{{.TypeName}}Filters, {{.TypeName}}Field> = {}): Promise<ListResult<{{.TypeName}}>> {
+    return this.client.list<{{.TypeName}}, {{.TypeName}}Filters, {{.TypeName}}Field>(this.endpoint, params)
+  }
+{{- end}}
+{{- if .PkType}}
+
+  get(id: {{.PkType}}): Promise<{{.TypeName}}> {
+    return this.client.getById<{{.TypeName}}>(this.endpoint, id)
+  }
+
+  create(input: {{.TypeName}}Input): Promise<{{.PkType}}> {
+    return this.client.post<{{.TypeName}}Input, {{.PkType}}>(this.endpoint, input)
+  }
+
+  update(id: {{.PkType}}, input: {{.TypeName}}Input): Promise<void> {
+    return this.client.put(this.endpoint, id, input)
+  }
+
+  patch(id: {{.PkType}}, input: Partial<{{.TypeName}}Input>): Promise<void> {
+    return this.client.patch(this.endpoint, id, input)
+  }
+{{- if .Archived}}
+
+  archive(id: {{.PkType}}): Promise<void> {
+    return this.client.archive(this.endpoint, id)
+  }
+
+  restore(id: {{.PkType}}): Promise<void> {
+    return this.client.restore(this.endpoint, id)
+  }
+{{- else}}
+
+  delete(id: {{.PkType}}): Promise<void> {
+    return this.client.delete(this.endpoint, id)
+  }
+{{- end}}
+{{- end}}
+}
+`))
+
+const tsClientRuntime string = `// Generated by lysgen. Do not edit: regenerate instead.
+
+/** GetMetadata is returned with the items of a list request */
+export interface GetMetadata {
+  count: number
+  total_count: number
+  total_count_is_estimated: boolean
+}
+
+/** StdResponse is the envelope of all lys API responses */
+export interface StdResponse<T> {
+  status: 'succeeded' | 'failed'
+  data?: T
+  metadata?: GetMetadata
+  err_description?: string
+}
+
+/** LysApiError is thrown when a request fails. status is the HTTP status, or 0 if no response was received */
+export class LysApiError extends Error {
+  readonly status: number
+
+  constructor(status: number, message: string) {
+    super(message)
+    this.name = 'LysApiError'
+    this.status = status
+  }
+}
+
+export type Scalar = string | number | boolean
+
+/** Filter is a condition on a field. It is sent as a url param using the operator syntax of the lys API */
+export type Filter<T extends Scalar> =
+  | { op: 'eq' | 'ne' | 'gt' | 'gte' | 'lt' | 'lte'; value: T }
+  | { op: 'in' | 'notIn' | 'containsAny'; values: T[] }
+  | { op: 'contains' | 'notContains' | 'startsWith' | 'endsWith'; value: string }
+  | { op: 'empty' | 'notEmpty' | 'null' | 'notNull' }
+
+/** FieldFilter is one or more filters on a field, which must all match */
+export type FieldFilter<T extends Scalar> = Filter<T> | Filter<T>[]
+
+/** filter contains a builder for each Filter operator */
+export const filter = {
+  eq: <T extends Scalar>(value: T): Filter<T> => ({ op: 'eq', value }),
+  ne: <T extends Scalar>(value: T): Filter<T> => ({ op: 'ne', value }),
+  gt: <T extends Scalar>(value: T): Filter<T> => ({ op: 'gt', value }),
+  gte: <T extends Scalar>(value: T): Filter<T> => ({ op: 'gte', value }),
+  lt: <T extends Scalar>(value: T): Filter<T> => ({ op: 'lt', value }),
+  lte: <T extends Scalar>(value: T): Filter<T> => ({ op: 'lte', value }),
+  in: <T extends Scalar>(...values: T[]): Filter<T> => ({ op: 'in', values }),
+  notIn: <T extends Scalar>(...values: T[]): Filter<T> => ({ op: 'notIn', values }),
+  containsAny: <T extends Scalar>(...values: T[]): Filter<T> => ({ op: 'containsAny', values }),
+  contains: (value: string): Filter<string> => ({ op: 'contains', value }),
+  notContains: (value: string): Filter<string> => ({ op: 'notContains', value }),
+  startsWith: (value: string): Filter<string> => ({ op: 'startsWith', value }),
+  endsWith: (value: string): Filter<string> => ({ op: 'endsWith', value }),
+  empty: <T extends Scalar>(): Filter<T> => ({ op: 'empty' }),
+  notEmpty: <T extends Scalar>(): Filter<T> => ({ op: 'notEmpty' }),
+  null: <T extends Scalar>(): Filter<T> => ({ op: 'null' }),
+  notNull: <T extends Scalar>(): Filter<T> => ({ op: 'notNull' }),
+}
+
+/** filterParamValue returns the url param value of f. sep is the API's GetOptions.MultipleValueSeparator */
+export function filterParamValue<T extends Scalar>(f: Filter<T>, sep: string): string {
+  switch (f.op) {
+    case 'eq':
+      return String(f.value)
+    case 'ne':
+      return '!' + f.value
+    case 'gt':
+      return '>' + f.value
+    case 'gte':
+      return '>eq' + f.value
+    case 'lt':
+      return '<' + f.value
+    case 'lte':
+      return '<eq' + f.value
+    case 'in':
+      return f.values.join(sep)
+    case 'notIn':
+      return '!' + f.values.join(sep)
+    case 'containsAny':
+      return '~[' + f.values.join(sep) + ']~'
+    case 'contains':
+      return '~' + f.value + '~'
+    case 'notContains':
+      return '!~' + f.value + '~'
+    case 'startsWith':
+      return f.value + '~'
+    case 'endsWith':
+      return '~' + f.value
+    case 'empty':
+      return '{empty}'
+    case 'notEmpty':
+      return '{!empty}'
+    case 'null':
+      return '{null}'
+    case 'notNull':
+      return '{!null}'
+  }
+}
+
+/** ListParams are the params of a list request. Sort fields prefixed with "-" are sorted descending */
+export interface ListParams<F, K extends string | number | symbol> {
+  filters?: F
+  fields?: K[]
+  sort?: (K | ` + "`-${K & string}`" + `)[]
+  page?: number
+  perPage?: number
+}
+
+export interface ListResult<T> {
+  items: T[]
+  metadata: GetMetadata
+}
+
+/** ClientOptions configure a LysClient. The param names and separator must match the API's GetOptions */
+export interface ClientOptions {
+  baseUrl: string
+  fetch?: typeof fetch
+  headers?: () => Record<string, string> | Promise<Record<string, string>>
+  fieldsParamName?: string
+  pageParamName?: string
+  perPageParamName?: string
+  sortParamName?: string
+  multipleValueSeparator?: string
+}
+
+/** LysClient sends requests to a lys API and unwraps the StdResponse envelope */
+export class LysClient {
+  private readonly opts: Required<Omit<ClientOptions, 'headers'>> & Pick<ClientOptions, 'headers'>
+
+  constructor(options: ClientOptions) {
+    this.opts = {
+      fetch: (input, init) => globalThis.fetch(input, init),
+      fieldsParamName: 'xfields',
+      pageParamName: 'xpage',
+      perPageParamName: 'xper_page',
+      sortParamName: 'xsort',
+      multipleValueSeparator: '|',
+      ...options,
+      baseUrl: options.baseUrl.replace(/\/+$/, ''),
+    }
+  }
+
+  /** request sends a request and returns the response envelope. It throws a LysApiError if the request fails */
+  async request<T>(method: string, path: string, query?: URLSearchParams, body?: unknown): Promise<StdResponse<T>> {
+    const headers: Record<string, string> = { Accept: 'application/json', ...(await this.opts.headers?.()) }
+    if (body !== undefined) {
+      headers['Content-Type'] = 'application/json'
+    }
+    const qs = query?.toString() ? '?' + query.toString() : ''
+
+    let res: Response
+    try {
+      res = await this.opts.fetch(this.opts.baseUrl + path + qs, {
+        method,
+        headers,
+        body: body === undefined ? undefined : JSON.stringify(body),
+      })
+    } catch (e) {
+      throw new LysApiError(0, e instanceof Error ? e.message : String(e))
+    }
+
+    let resp: StdResponse<T>
+    try {
+      resp = (await res.json()) as StdResponse<T>
+    } catch {
+      throw new LysApiError(res.status, res.statusText || 'invalid JSON response')
+    }
+    if (!res.ok || resp.status !== 'succeeded') {
+      throw new LysApiError(res.status, resp.err_description || res.statusText || 'request failed')
+    }
+
+    return resp
+  }
+
+  async list<T, F extends object, K extends string | number | symbol>(path: string, params: ListParams<F, K> = {}, funcParams: Record<string, Scalar> = {}): Promise<ListResult<T>> {
+    const query = new URLSearchParams()
+
+    for (const [key, val] of Object.entries(funcParams)) {
+      query.append(key, String(val))
+    }
+    for (const [key, fieldFilter] of Object.entries(params.filters ?? {}) as [string, FieldFilter<Scalar> | undefined][]) {
+      if (fieldFilter === undefined) {
+        continue
+      }
+      for (const f of Array.isArray(fieldFilter) ? fieldFilter : [fieldFilter]) {
+        query.append(key, filterParamValue(f, this.opts.multipleValueSeparator))
+      }
+    }
+    if (params.fields?.length) {
+      query.set(this.opts.fieldsParamName, params.fields.join(','))
+    }
+    if (params.sort?.length) {
+      query.set(this.opts.sortParamName, params.sort.join(','))
+    }
+    if (params.page !== undefined) {
+      query.set(this.opts.pageParamName, String(params.page))
+    }
+    if (params.perPage !== undefined) {
+      query.set(this.opts.perPageParamName, String(params.perPage))
+    }
+
+    const resp = await this.request<T[]>('GET', path, query)
+    return {
+      items: resp.data ?? [],
+      metadata: resp.metadata ?? { count: 0, total_count: 0, total_count_is_estimated: false },
+    }
+  }
+
+  async getById<T>(path: string, id: string | number): Promise<T> {
+    const resp = await this.request<T>('GET', idPath(path, id))
+    return resp.data as T
+  }
+
+  async post<I, O>(path: string, input: I): Promise<O> {
+    const resp = await this.request<O>('POST', path, undefined, input)
+    return resp.data as O
+  }
+
+  async put<I>(path: string, id: string | number, input: I): Promise<void> {
+    await this.request('PUT', idPath(path, id), undefined, input)
+  }
+
+  async patch<I>(path: string, id: string | number, input: I): Promise<void> {
+    await this.request('PATCH', idPath(path, id), undefined, input)
+  }
+
+  async delete(path: string, id: string | number): Promise<void> {
+    await this.request('DELETE', idPath(path, id))
+  }
+
+  async archive(path: string, id: string | number): Promise<void> {
+    await this.request('DELETE', idPath(path, id) + '/archive')
+  }
+
+  async restore(path: string, id: string | number): Promise<void> {
+    await this.request('POST', idPath(path, id) + '/restore')
+  }
+}
+
+function idPath(path: string, id: string | number): string {
+  return path + '/' + encodeURIComponent(String(id))
+}
+`
@@ -0,0 +1,66 @@
+package lysgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTsClientSuccess(t *testing.T) {
+
+	specs := getTestStoreSpecs()
+
+	res, err := TsClient(specs["table"])
+	require.NoError(t, err)
+	assert.Contains(t, res, "// Generated by lysgen from core.category.")
+	assert.Contains(t, res, "export interface CategoryInput {\n  name: string\n  valid_from?: string | null\n}")
+	assert.Contains(t, res, "export interface Category {\n  id?: number\n  created_at?: string\n  name?: string\n  valid_from: string | null\n}")
+	assert.Contains(t, res, "  valid_from?: FieldFilter<string>\n")
+	assert.Contains(t, res, "constructor(client: LysClient, endpoint = '/category') {")
+	assert.Contains(t, res, "get(id: number): Promise<Category> {")
+	assert.Contains(t, res, "create(input: CategoryInput): Promise<number> {")
+	assert.Contains(t, res, "delete(id: number): Promise<void> {")
+	assert.NotContains(t, res, "archive")
+
+	// deterministic
+	res2, err := TsClient(specs["table"])
+	require.NoError(t, err)
+	assert.Equal(t, res, res2)
+
+	res, err = TsClient(specs["archived uuid"])
+	require.NoError(t, err)
+	assert.Contains(t, res, "get(id: string): Promise<Item> {")
+	assert.Contains(t, res, "archive(id: string): Promise<void> {")
+	assert.Contains(t, res, "restore(id: string): Promise<void> {")
+	assert.NotContains(t, res, "delete(")
+
+	res, err = TsClient(specs["set func"])
+	require.NoError(t, err)
+	assert.Contains(t, res, "import type { FieldFilter, ListParams, ListResult, LysClient, Scalar } from './lys'")
+	assert.Contains(t, res, "export type SetfuncFuncParams = {\n  p_text: Scalar\n  p_int: Scalar\n}")
+	assert.Contains(t, res, "list(funcParams: SetfuncFuncParams, params: ListParams<SetfuncFilters, SetfuncField> = {}): Promise<ListResult<Setfunc>> {")
+	assert.NotContains(t, res, "SetfuncInput")
+	assert.NotContains(t, res, "get(")
+
+	res, err = TsClient(specs["view with enum"])
+	require.NoError(t, err)
+	assert.Contains(t, res, "// Generated by lysgen from core.order_summary.")
+	assert.Contains(t, res, "export type OrderStatus = 'open' | 'closed'\n")
+	assert.Contains(t, res, "export interface OrderSummary {\n  id?: number\n  status?: OrderStatus\n  amounts?: number[]\n}")
+	assert.Contains(t, res, "  status?: FieldFilter<OrderStatus>\n")
+	assert.Contains(t, res, "constructor(client: LysClient, endpoint = '/order-summary') {")
+	assert.NotContains(t, res, "OrderSummaryInput")
+	assert.NotContains(t, res, "get(")
+}
+
+func TestTsClientFailure(t *testing.T) {
+
+	_, err := TsClient(StoreSpec{SchemaName: "core"})
+	assert.Error(t, err)
+
+	spec := getTestStoreSpecs()["table"]
+	spec.InputFields = append(spec.InputFields, StoreField{DbName: "unknown", DataType: "unknown"})
+	_, err = TsClient(spec)
+	assert.EqualError(t, err, "getTsClientType failed: GetTsDataTypeFromPg failed for field unknown: no Typescript type found for pgType: unknown")
+}
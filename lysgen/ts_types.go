@@ -12,7 +12,7 @@ import (
 
 // TsTypes generates the TS type definitions from the supplied db table
 // only handles 1 level of join
-func TsTypes(ctx context.Context, db *pgxpool.Pool, schema, table string) (res string, err error) {
+func TsTypes(ctx context.Context, db *pgxpool.Pool, schema, table string, options ...GenOptions) (res string, err error) {
 
 	// get table columns
 	cols, err := lyspg.GetTableColumns(ctx, db, schema, table)
@@ -82,9 +82,11 @@ func TsTypes(ctx context.Context, db *pgxpool.Pool, schema, table string) (res s
 	res = strings.Join(resA, "\n")
 
 	// write to clipboard for convenience
-	err = WriteToClipboard(res)
-	if err != nil {
-		return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
 	}
 
 	return "\n" + res + "\n", nil
@@ -105,7 +107,7 @@ func getTsInputIFace(tsTableName string, cols []lyspg.Column) (resA []string, er
 		}
 
 		// get Ts data type
-		tsDataType, err := GetTsDataTypeFromPg(col.DataType)
+		tsDataType, err := GetTsDataTypeFromPg(getColDataType(col))
 		if err != nil {
 			return nil, fmt.Errorf("GetTsDataTypeFromPg failed: %w", err)
 		}
@@ -143,7 +145,7 @@ func getTsIFace(tsTableName string, cols []lyspg.Column, parentCols []lyspg.Colu
 		}
 
 		// get Ts data type
-		tsDataType, err := GetTsDataTypeFromPg(col.DataType)
+		tsDataType, err := GetTsDataTypeFromPg(getColDataType(col))
 		if err != nil {
 			return nil, fmt.Errorf("GetTsDataTypeFromPg failed: %w", err)
 		}
@@ -165,7 +167,7 @@ func getTsIFace(tsTableName string, cols []lyspg.Column, parentCols []lyspg.Colu
 		prefixedColName := parCol.TableName + "_" + parCol.Name
 
 		// get Ts data type
-		tsDataType, err := GetTsDataTypeFromPg(parCol.DataType)
+		tsDataType, err := GetTsDataTypeFromPg(getColDataType(parCol))
 		if err != nil {
 			return nil, fmt.Errorf("GetTsDataTypeFromPg failed: %w", err)
 		}
@@ -226,7 +228,7 @@ func getTsConstructor(tsTableName string, cols []lyspg.Column, parentCols []lysp
 	for _, col := range generatedCols {
 
 		// get Ts data type
-		tsDataType, err := GetTsDataTypeFromPg(col.DataType)
+		tsDataType, err := GetTsDataTypeFromPg(getColDataType(col))
 		if err != nil {
 			return nil, fmt.Errorf("GetTsDataTypeFromPg failed: %w", err)
 		}
@@ -248,7 +250,7 @@ func getTsConstructor(tsTableName string, cols []lyspg.Column, parentCols []lysp
 		prefixedColName := parCol.TableName + "_" + parCol.Name
 
 		// get Ts data type
-		tsDataType, err := GetTsDataTypeFromPg(parCol.DataType)
+		tsDataType, err := GetTsDataTypeFromPg(getColDataType(parCol))
 		if err != nil {
 			return nil, fmt.Errorf("GetTsDataTypeFromPg failed: %w", err)
 		}
@@ -11,7 +11,7 @@ import (
 
 // View generates the db view from the supplied db table
 // only handles 1 level of join, and does not coalesce nulls
-func View(ctx context.Context, db *pgxpool.Pool, schema, table string) (res string, err error) {
+func View(ctx context.Context, db *pgxpool.Pool, schema, table string, options ...GenOptions) (res string, err error) {
 
 	alias := table
 
@@ -95,9 +95,11 @@ func View(ctx context.Context, db *pgxpool.Pool, schema, table string) (res stri
 	res = strings.Join(resA, "\n") + ";"
 
 	// write to clipboard for convenience
-	err = WriteToClipboard(res)
-	if err != nil {
-		return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+	if len(options) == 0 || !options[0].SkipClipboard {
+		err = WriteToClipboard(res)
+		if err != nil {
+			return "", fmt.Errorf("WriteToClipboard failed: %w", err)
+		}
 	}
 
 	return "\n" + res + "\n", nil
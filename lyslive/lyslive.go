@@ -0,0 +1,569 @@
+// Package lyslive pushes row changes of a store's view to websocket clients which subscribed with the same filters they send to lys.Get.
+package lyslive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lyspgmon"
+	"github.com/loveyourstack/lys/lysset"
+	"github.com/loveyourstack/lys/lysws"
+)
+
+// TopicPrefix starts every live query topic. A topic is the prefix, the source name and optionally the GET filter query, e.g. "live:orders?status=open&amount=>100".
+const TopicPrefix string = "live:"
+
+// MsgTypeLiveDelta is the type of messages carrying a Delta
+const MsgTypeLiveDelta string = "live_delta"
+
+// delta operations
+const (
+	DeltaOpDelete string = "delete" // row was deleted or no longer matches the filters
+	DeltaOpInsert string = "insert" // row was inserted and matches the filters
+	DeltaOpUpdate string = "update" // row was updated and matches the filters. It might not have matched before, so clients should upsert
+)
+
+// Delta is a change to the result set of a live query.
+type Delta struct {
+	Op   string `json:"op"`
+	Id   string `json:"id"`
+	Item any    `json:"item,omitempty"` // the row as returned by the store's Select, if not deleted
+}
+
+// iLiveStore is a store that can be used as a live query source
+type iLiveStore[T any] interface {
+	GetPlan() lysmeta.Plan
+	Select(ctx context.Context, params lyspg.SelectParams) (items []T, unpagedCount lyspg.TotalCount, err error)
+}
+
+// iTopicHub is the part of lysws.NotificationHub used by Manager
+type iTopicHub interface {
+	PublishTopic(topic string, msg []byte)
+	TopicStatus() map[string]int
+}
+
+// SourceAuthFunc decides whether a user may subscribe to live queries of a source.
+type SourceAuthFunc func(ctx context.Context, userID int64) (allowed bool, err error)
+
+// defaultMaxTrackedRows is the default max number of row ids tracked per live query
+const defaultMaxTrackedRows int = 10000
+
+// SourceOpts describe the table underlying a source's view.
+type SourceOpts struct {
+	AuthFunc       SourceAuthFunc // required
+	MaxTrackedRows int            // max number of row ids tracked per live query, so that deletes are only sent for rows in the result set. Larger results always get deletes. Default 10000
+	PkColName      string         // db name of the primary key column in the view and table. Default "id"
+	SchemaName     string         // schema of the table which has the t_live_notify trigger
+	TableName      string         // table which has the t_live_notify trigger
+}
+
+type source struct {
+	authFunc         SourceAuthFunc
+	jsonKeyDbNameMap map[string]string
+	jsonKeyTypeMap   map[string]reflect.Type
+	maxTrackedRows   int
+	name             string
+	pkColName        string
+	schemaName       string
+	selectIds        func(ctx context.Context, conds []lyspg.Condition, limit int) (ids []string, err error)
+	selectOne        func(ctx context.Context, conds []lyspg.Condition) (item any, found bool, err error)
+	tableName        string
+}
+
+// resultIds are the ids of the rows in the result set of a live query
+type resultIds struct {
+	ids     lysset.Set[string]
+	mu      sync.Mutex // protects ids
+	tracked bool       // false if the result set had more than maxTrackedRows rows, in which case every id is assumed to be in it
+}
+
+func (r *resultIds) add(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tracked {
+		r.ids.Add(id)
+	}
+}
+
+func (r *resultIds) contains(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.tracked || r.ids.Contains(id)
+}
+
+func (r *resultIds) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids.Remove(id)
+}
+
+// rowChange is the payload sent by lyspgmon.live_notify_trigger
+type rowChange struct {
+	Schema string  `json:"schema"`
+	Table  string  `json:"table"`
+	Op     string  `json:"op"`
+	Id     *string `json:"id"`
+}
+
+// liveMessage has the same format as other lysws hub messages
+type liveMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	Body  string `json:"body"`
+}
+
+// Manager evaluates row change notifications against the live query topics subscribed in a hub.
+// Every instance evaluates the notifications for its own sockets, so no state is shared between instances.
+type Manager struct {
+	db         *pgxpool.Pool
+	getOptions lys.GetOptions
+	logger     *slog.Logger
+	mu         sync.RWMutex          // protects results and sources
+	results    map[string]*resultIds // queryKey → ids of the rows in the live query's result set
+	sources    map[string]source
+}
+
+// NewManager creates a new Manager. getOptions should be the same as those used by the app's lys.Env, so that filters are parsed in the same way.
+func NewManager(db *pgxpool.Pool, getOptions lys.GetOptions, logger *slog.Logger) (m *Manager, err error) {
+
+	if db == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	getOptions, err = lys.FillGetOptions(getOptions)
+	if err != nil {
+		return nil, fmt.Errorf("lys.FillGetOptions failed: %w", err)
+	}
+
+	return &Manager{
+		db:         db,
+		getOptions: getOptions,
+		logger:     logger.With("component", "lyslive"),
+		results:    make(map[string]*resultIds),
+		sources:    make(map[string]source),
+	}, nil
+}
+
+// AddSource makes the store's view available for live queries under name. The table must have the t_live_notify trigger (see lyspgmon.EnableLiveNotify).
+func AddSource[T any](m *Manager, name string, store iLiveStore[T], opts SourceOpts) (err error) {
+
+	if name == "" || strings.ContainsAny(name, "?:") {
+		return fmt.Errorf("name must not be empty or contain '?' or ':'")
+	}
+	if opts.AuthFunc == nil {
+		return fmt.Errorf("opts.AuthFunc is required")
+	}
+	if opts.SchemaName == "" || opts.TableName == "" {
+		return fmt.Errorf("opts.SchemaName and opts.TableName are required")
+	}
+	if opts.MaxTrackedRows < 0 {
+		return fmt.Errorf("opts.MaxTrackedRows must not be negative")
+	}
+	if opts.MaxTrackedRows == 0 {
+		opts.MaxTrackedRows = defaultMaxTrackedRows
+	}
+	if opts.PkColName == "" {
+		opts.PkColName = "id"
+	}
+
+	plan := store.GetPlan()
+	pkIdx := slices.IndexFunc(plan.Fields(), func(f lysmeta.Field) bool { return f.DbName == opts.PkColName })
+	if pkIdx == -1 {
+		return fmt.Errorf("pk col '%s' is not in store plan", opts.PkColName)
+	}
+	pkFieldName := plan.Fields()[pkIdx].Name
+
+	src := source{
+		authFunc:         opts.AuthFunc,
+		jsonKeyDbNameMap: plan.JsonKeyDbNameMap(),
+		jsonKeyTypeMap:   plan.JsonKeyTypeMap(),
+		maxTrackedRows:   opts.MaxTrackedRows,
+		name:             name,
+		pkColName:        opts.PkColName,
+		schemaName:       opts.SchemaName,
+		selectIds: func(ctx context.Context, conds []lyspg.Condition, limit int) (ids []string, err error) {
+			items, _, err := store.Select(ctx, lyspg.SelectParams{Fields: []string{opts.PkColName}, Conditions: conds, Limit: limit})
+			if err != nil {
+				return nil, fmt.Errorf("store.Select failed: %w", err)
+			}
+			for _, item := range items {
+				ids = append(ids, fmt.Sprint(reflect.Indirect(reflect.ValueOf(item)).FieldByName(pkFieldName).Interface()))
+			}
+			return ids, nil
+		},
+		selectOne: func(ctx context.Context, conds []lyspg.Condition) (item any, found bool, err error) {
+			items, _, err := store.Select(ctx, lyspg.SelectParams{Conditions: conds, Limit: 1})
+			if err != nil {
+				return nil, false, fmt.Errorf("store.Select failed: %w", err)
+			}
+			if len(items) == 0 {
+				return nil, false, nil
+			}
+			return items[0], true, nil
+		},
+		tableName: opts.TableName,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sources[name]; exists {
+		return fmt.Errorf("source '%s' already added", name)
+	}
+	m.sources[name] = src
+
+	return nil
+}
+
+// Topic returns the live query topic for the source and GET filter params.
+func Topic(sourceName string, filters url.Values) string {
+	if len(filters) == 0 {
+		return TopicPrefix + sourceName
+	}
+	return TopicPrefix + sourceName + "?" + filters.Encode()
+}
+
+// parseTopic returns the source and conditions of a live query topic.
+func (m *Manager) parseTopic(topic string) (src source, conds []lyspg.Condition, err error) {
+
+	if !strings.HasPrefix(topic, TopicPrefix) {
+		return source{}, nil, fmt.Errorf("not a live query topic")
+	}
+
+	name, query, _ := strings.Cut(strings.TrimPrefix(topic, TopicPrefix), "?")
+
+	m.mu.RLock()
+	src, ok := m.sources[name]
+	m.mu.RUnlock()
+	if !ok {
+		return source{}, nil, fmt.Errorf("unknown source: %s", name)
+	}
+
+	filters, err := url.ParseQuery(query)
+	if err != nil {
+		return source{}, nil, fmt.Errorf("url.ParseQuery failed: %w", err)
+	}
+
+	// the timezone of datetime filters can only be sent as param, since there is no request per topic
+	loc, err := lys.ExtractTimezone(m.getOptions.TzParamName, filters.Get(m.getOptions.TzParamName), "")
+	if err != nil {
+		return source{}, nil, fmt.Errorf("lys.ExtractTimezone failed: %w", err)
+	}
+
+	conds, err = lys.ExtractFiltersWithOptions(filters, src.jsonKeyDbNameMap, lysset.New[string](), nil, m.getOptions, lys.FilterOptions{JsonKeyTypeMap: src.jsonKeyTypeMap, Location: loc})
+	if err != nil {
+		return source{}, nil, fmt.Errorf("lys.ExtractFiltersWithOptions failed: %w", err)
+	}
+
+	return src, conds, nil
+}
+
+// SubscribeAuthFunc returns a lysws.SubscribeAuthFunc which authorizes live query topics using the source's AuthFunc, and passes other topics to next (which may be nil).
+func (m *Manager) SubscribeAuthFunc(next lysws.SubscribeAuthFunc) lysws.SubscribeAuthFunc {
+
+	return func(ctx context.Context, userID int64, topic string) (allowed bool, err error) {
+
+		if !strings.HasPrefix(topic, TopicPrefix) {
+			if next == nil {
+				return false, nil
+			}
+			return next(ctx, userID, topic)
+		}
+
+		// invalid topics are not allowed
+		src, conds, err := m.parseTopic(topic)
+		if err != nil {
+			m.logger.Debug("m.parseTopic failed", "topic", topic, "error", err)
+			return false, nil
+		}
+
+		allowed, err = src.authFunc(ctx, userID)
+		if err != nil || !allowed {
+			return allowed, err
+		}
+
+		// track the result set from now on, so that deletes of rows which the client received are sent
+		if _, err = m.trackResult(ctx, src, conds); err != nil {
+			m.logger.Error("m.trackResult failed", "topic", topic, "error", err)
+		}
+		return true, nil
+	}
+}
+
+// queryKey returns the key of a live query, which is the same for all topics of the source with the same conditions
+func queryKey(src source, conds []lyspg.Condition) (key string, err error) {
+	condsBytes, err := json.Marshal(conds)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return src.name + "\x00" + string(condsBytes), nil
+}
+
+// trackResult selects and stores the ids of the rows in the result set of the live query, if not already stored.
+func (m *Manager) trackResult(ctx context.Context, src source, conds []lyspg.Condition) (res *resultIds, err error) {
+
+	key, err := queryKey(src, conds)
+	if err != nil {
+		return nil, fmt.Errorf("queryKey failed: %w", err)
+	}
+
+	m.mu.RLock()
+	res, ok := m.results[key]
+	m.mu.RUnlock()
+	if ok {
+		return res, nil
+	}
+
+	ids, err := src.selectIds(ctx, conds, src.maxTrackedRows+1)
+	if err != nil {
+		return nil, fmt.Errorf("src.selectIds failed: %w", err)
+	}
+	res = &resultIds{}
+	if len(ids) <= src.maxTrackedRows {
+		res.ids = lysset.FromSlice(ids)
+		res.tracked = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// another subscription may have stored it in the meantime
+	if existing, ok := m.results[key]; ok {
+		return existing, nil
+	}
+	m.results[key] = res
+	return res, nil
+}
+
+// Listen listens for row change notifications and publishes deltas to the hub's live query topics. It reconnects with backoff on connection loss.
+// It blocks until ctx is canceled.
+func (m *Manager) Listen(ctx context.Context, hub iTopicHub) (err error) {
+
+	if hub == nil {
+		return fmt.Errorf("hub is required")
+	}
+
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		start := time.Now()
+		err = m.listenOnce(ctx, hub)
+		if ctx.Err() != nil {
+			return nil
+		}
+		m.logger.Info("listen connection lost, reconnecting", "error", err)
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) >= maxBackoff {
+			backoff = time.Second
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// listenOnce acquires a connection and handles notifications until the connection fails or ctx is canceled.
+func (m *Manager) listenOnce(ctx context.Context, hub iTopicHub) (err error) {
+
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("m.db.Acquire failed: %w", err)
+	}
+	defer conn.Release()
+
+	channel := pgx.Identifier{lyspgmon.LiveNotifyChannel}.Sanitize()
+	if _, err = conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("conn.Exec (LISTEN) failed: %w", err)
+	}
+	defer func() {
+		if _, unlistenErr := conn.Exec(context.Background(), "UNLISTEN "+channel); unlistenErr != nil {
+			m.logger.Error("conn.Exec (UNLISTEN) failed", "error", unlistenErr)
+		}
+	}()
+
+	for {
+		not, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("conn.WaitForNotification failed: %w", err)
+		}
+
+		m.handleNotification(ctx, hub, not.Payload)
+	}
+}
+
+// liveQuery is a source with conditions, and the topics which subscribe to it
+type liveQuery struct {
+	conds  []lyspg.Condition
+	src    source
+	topics []string
+}
+
+// handleNotification re-evaluates each live query of the changed table for the changed row, and publishes the resulting delta to the query's topics.
+// Topics with the same source and conditions share one query.
+func (m *Manager) handleNotification(ctx context.Context, hub iTopicHub, payload string) {
+
+	var change rowChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		m.logger.Error("json.Unmarshal failed", "payload", payload, "error", err)
+		return
+	}
+	if change.Id == nil {
+		m.logger.Error("row change has no id", "schema", change.Schema, "table", change.Table)
+		return
+	}
+
+	queries := make(map[string]*liveQuery)
+	for topic := range hub.TopicStatus() {
+
+		if !strings.HasPrefix(topic, TopicPrefix) {
+			continue
+		}
+
+		src, conds, err := m.parseTopic(topic)
+		if err != nil {
+			// topic was authorized when subscribed, so the source was removed or is unknown to this instance
+			m.logger.Debug("m.parseTopic failed", "topic", topic, "error", err)
+			continue
+		}
+		key, err := queryKey(src, conds)
+		if err != nil {
+			m.logger.Error("queryKey failed", "topic", topic, "error", err)
+			continue
+		}
+
+		if q, ok := queries[key]; ok {
+			q.topics = append(q.topics, topic)
+			continue
+		}
+		queries[key] = &liveQuery{conds: conds, src: src, topics: []string{topic}}
+	}
+
+	// stop tracking the results of queries without subscribers
+	m.mu.Lock()
+	for key := range m.results {
+		if _, ok := queries[key]; !ok {
+			delete(m.results, key)
+		}
+	}
+	m.mu.Unlock()
+
+	for key, q := range queries {
+
+		if q.src.schemaName != change.Schema || q.src.tableName != change.Table {
+			continue
+		}
+
+		m.mu.RLock()
+		res, tracked := m.results[key]
+		m.mu.RUnlock()
+		if !tracked {
+			// e.g. subscribed on another path: assume every id is in the result set for this change, and track it from now on
+			res = &resultIds{}
+		}
+
+		delta, send, err := evaluateChange(ctx, q.src, q.conds, change.Op, *change.Id, res)
+		if err != nil {
+			m.logger.Error("evaluateChange failed", "topics", q.topics, "id", *change.Id, "error", err)
+			continue
+		}
+		if !tracked {
+			if _, err = m.trackResult(ctx, q.src, q.conds); err != nil {
+				m.logger.Error("m.trackResult failed", "topics", q.topics, "error", err)
+			}
+		}
+		if !send {
+			continue
+		}
+
+		deltaBytes, err := json.Marshal(delta)
+		if err != nil {
+			m.logger.Error("json.Marshal failed (delta)", "topics", q.topics, "error", err)
+			continue
+		}
+
+		for _, topic := range q.topics {
+			msgBytes, err := json.Marshal(liveMessage{Type: MsgTypeLiveDelta, Topic: topic, Body: string(deltaBytes)})
+			if err != nil {
+				m.logger.Error("json.Marshal failed (message)", "topic", topic, "error", err)
+				continue
+			}
+			hub.PublishTopic(topic, msgBytes)
+		}
+	}
+}
+
+// evaluateChange returns the delta for a row change, checking whether the row matches the query's conditions, and updates the ids of the result set.
+// send is false if the change does not affect the result set, e.g. when a row which was not in it was deleted.
+func evaluateChange(ctx context.Context, src source, conds []lyspg.Condition, op, id string, res *resultIds) (delta Delta, send bool, err error) {
+
+	delta.Id = id
+
+	if op == "DELETE" {
+		if !res.contains(id) {
+			return Delta{}, false, nil
+		}
+		res.remove(id)
+		delta.Op = DeltaOpDelete
+		return delta, true, nil
+	}
+
+	// select the row only if it matches the conditions
+	conds = append(slices.Clone(conds), lyspg.Condition{Field: src.pkColName, Operator: lyspg.OpEquals, Value: id})
+	item, found, err := src.selectOne(ctx, conds)
+	if err != nil {
+		return Delta{}, false, fmt.Errorf("src.selectOne failed: %w", err)
+	}
+
+	switch {
+	case found && op == "INSERT":
+		res.add(id)
+		delta.Op = DeltaOpInsert
+	case found:
+		res.add(id)
+		delta.Op = DeltaOpUpdate
+	case op == "INSERT":
+		// new row doesn't match
+		return Delta{}, false, nil
+	case !res.contains(id):
+		// updated row didn't match before either
+		return Delta{}, false, nil
+	default:
+		// updated row no longer matches
+		res.remove(id)
+		delta.Op = DeltaOpDelete
+	}
+	delta.Item = item
+
+	return delta, true, nil
+}
@@ -0,0 +1,246 @@
+package lyslive
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOrder struct {
+	Id     int64  `db:"id" json:"id"`
+	Status string `db:"status" json:"status"`
+}
+
+// memOrderStore is an in-memory store which supports "=" conditions only.
+type memOrderStore struct {
+	orders  []testOrder
+	selects *int // if set, counts calls to Select
+}
+
+func (s memOrderStore) GetPlan() lysmeta.Plan {
+	plan, _ := lysmeta.Analyze(testOrder{})
+	return plan
+}
+
+func (s memOrderStore) Select(ctx context.Context, params lyspg.SelectParams) (items []testOrder, unpagedCount lyspg.TotalCount, err error) {
+	if s.selects != nil {
+		*s.selects++
+	}
+	for _, o := range s.orders {
+		match := true
+		for _, cond := range params.Conditions {
+			var val string
+			switch cond.Field {
+			case "id":
+				val = strconv.FormatInt(o.Id, 10)
+			case "status":
+				val = o.Status
+			}
+			if cond.Operator != lyspg.OpEquals || val != cond.Value {
+				match = false
+			}
+		}
+		if match {
+			items = append(items, o)
+		}
+	}
+	return items, lyspg.TotalCount{}, nil
+}
+
+// memHub records published messages.
+type memHub struct {
+	mu        sync.Mutex
+	published map[string][]liveMessage
+	topics    map[string]int
+}
+
+func (h *memHub) PublishTopic(topic string, msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var lm liveMessage
+	_ = json.Unmarshal(msg, &lm)
+	h.published[topic] = append(h.published[topic], lm)
+}
+
+func (h *memHub) TopicStatus() map[string]int {
+	return h.topics
+}
+
+func newTestManager(t *testing.T, store memOrderStore) *Manager {
+	t.Helper()
+
+	m, err := NewManager(&pgxpool.Pool{}, lys.GetOptions{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	err = AddSource(m, "orders", store, SourceOpts{
+		AuthFunc:   func(ctx context.Context, userID int64) (bool, error) { return userID == 1, nil },
+		SchemaName: "core",
+		TableName:  "order",
+	})
+	if err != nil {
+		t.Fatalf("AddSource failed: %v", err)
+	}
+	return m
+}
+
+func TestAddSource_Validation(t *testing.T) {
+	m := newTestManager(t, memOrderStore{})
+	authFunc := func(ctx context.Context, userID int64) (bool, error) { return true, nil }
+
+	assert.Error(t, AddSource(m, "orders", memOrderStore{}, SourceOpts{AuthFunc: authFunc, SchemaName: "core", TableName: "order"}), "duplicate")
+	assert.Error(t, AddSource(m, "a?b", memOrderStore{}, SourceOpts{AuthFunc: authFunc, SchemaName: "core", TableName: "order"}), "invalid name")
+	assert.Error(t, AddSource(m, "x", memOrderStore{}, SourceOpts{SchemaName: "core", TableName: "order"}), "no auth func")
+	assert.Error(t, AddSource(m, "x", memOrderStore{}, SourceOpts{AuthFunc: authFunc, TableName: "order"}), "no schema")
+	assert.Error(t, AddSource(m, "x", memOrderStore{}, SourceOpts{AuthFunc: authFunc, SchemaName: "core", TableName: "order", PkColName: "uuid"}), "pk not in plan")
+	assert.Error(t, AddSource(m, "x", memOrderStore{}, SourceOpts{AuthFunc: authFunc, SchemaName: "core", TableName: "order", MaxTrackedRows: -1}), "negative max tracked rows")
+}
+
+func TestSubscribeAuthFunc(t *testing.T) {
+	m := newTestManager(t, memOrderStore{})
+	ctx := context.Background()
+
+	nextCalled := false
+	authFunc := m.SubscribeAuthFunc(func(ctx context.Context, userID int64, topic string) (bool, error) {
+		nextCalled = true
+		return true, nil
+	})
+
+	tests := []struct {
+		userID int64
+		topic  string
+		want   bool
+	}{
+		{1, Topic("orders", url.Values{"status": {"open"}}), true},
+		{1, "live:orders", true},
+		{2, "live:orders", false},               // source AuthFunc denies
+		{1, "live:unknown", false},              // unknown source
+		{1, "live:orders?nosuchfield=1", false}, // invalid filter
+	}
+	for _, tt := range tests {
+		got, err := authFunc(ctx, tt.userID, tt.topic)
+		assert.NoError(t, err, tt.topic)
+		assert.Equal(t, tt.want, got, tt.topic)
+	}
+	assert.False(t, nextCalled)
+
+	// other topics are passed to next
+	got, err := authFunc(ctx, 2, "order:1")
+	assert.NoError(t, err)
+	assert.True(t, got)
+	assert.True(t, nextCalled)
+
+	// and denied without next
+	got, _ = m.SubscribeAuthFunc(nil)(ctx, 2, "order:1")
+	assert.False(t, got)
+}
+
+func TestHandleNotification(t *testing.T) {
+	store := memOrderStore{orders: []testOrder{{Id: 1, Status: "open"}, {Id: 2, Status: "closed"}}}
+	m := newTestManager(t, store)
+	ctx := context.Background()
+
+	openTopic := Topic("orders", url.Values{"status": {"open"}})
+	allTopic := Topic("orders", nil)
+	hub := &memHub{
+		published: make(map[string][]liveMessage),
+		topics:    map[string]int{openTopic: 1, allTopic: 1, "order:1": 1, "live:other": 1},
+	}
+
+	// the result sets are tracked from the first notification: {1} and {1, 2}
+	tests := []struct {
+		name     string
+		mutate   func() // changes the store before the notification
+		payload  string
+		wantOpen string // expected delta op for openTopic, "" if none
+		wantAll  string // expected delta op for allTopic
+	}{
+		{"insert matching", nil, `{"schema":"core","table":"order","op":"INSERT","id":"1"}`, DeltaOpInsert, DeltaOpInsert},
+		{"insert not matching", nil, `{"schema":"core","table":"order","op":"INSERT","id":"2"}`, "", DeltaOpInsert},
+		{"update not matching before or after", nil, `{"schema":"core","table":"order","op":"UPDATE","id":"2"}`, "", DeltaOpUpdate},
+		{"update no longer matching", func() { store.orders[0].Status = "closed" }, `{"schema":"core","table":"order","op":"UPDATE","id":"1"}`, DeltaOpDelete, DeltaOpUpdate},
+		{"update matching again", func() { store.orders[0].Status = "open" }, `{"schema":"core","table":"order","op":"UPDATE","id":"1"}`, DeltaOpUpdate, DeltaOpUpdate},
+		{"delete not in results", nil, `{"schema":"core","table":"order","op":"DELETE","id":"3"}`, "", ""},
+		{"delete not in open results", func() { store.orders = store.orders[:1] }, `{"schema":"core","table":"order","op":"DELETE","id":"2"}`, "", DeltaOpDelete},
+		{"delete in results", func() { store.orders = store.orders[:0] }, `{"schema":"core","table":"order","op":"DELETE","id":"1"}`, DeltaOpDelete, DeltaOpDelete},
+		{"deleted twice", nil, `{"schema":"core","table":"order","op":"DELETE","id":"1"}`, "", ""},
+		{"other table", nil, `{"schema":"core","table":"customer","op":"DELETE","id":"1"}`, "", ""},
+		{"no id", nil, `{"schema":"core","table":"order","op":"DELETE","id":null}`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mutate != nil {
+				tt.mutate()
+			}
+			hub.published = make(map[string][]liveMessage)
+			m.handleNotification(ctx, hub, tt.payload)
+
+			for topic, wantOp := range map[string]string{openTopic: tt.wantOpen, allTopic: tt.wantAll} {
+				msgs := hub.published[topic]
+				if wantOp == "" {
+					assert.Empty(t, msgs, topic)
+					continue
+				}
+				if len(msgs) != 1 {
+					t.Fatalf("%s: expected 1 message, got %d", topic, len(msgs))
+				}
+				assert.Equal(t, MsgTypeLiveDelta, msgs[0].Type)
+				assert.Equal(t, topic, msgs[0].Topic)
+
+				var delta Delta
+				if err := json.Unmarshal([]byte(msgs[0].Body), &delta); err != nil {
+					t.Fatalf("json.Unmarshal failed: %v", err)
+				}
+				assert.Equal(t, wantOp, delta.Op, topic)
+				assert.Equal(t, delta.Op != DeltaOpDelete, delta.Item != nil, topic)
+			}
+			assert.Empty(t, hub.published["order:1"])
+		})
+	}
+}
+
+func TestHandleNotification_SharedQuery(t *testing.T) {
+	selects := 0
+	store := memOrderStore{orders: []testOrder{{Id: 1, Status: "open"}}, selects: &selects}
+	m := newTestManager(t, store)
+	ctx := context.Background()
+
+	// both topics have the same conditions, since the timezone doesn't affect a text filter
+	topic1 := Topic("orders", url.Values{"status": {"open"}})
+	topic2 := Topic("orders", url.Values{"status": {"open"}, "xtz": {"UTC"}})
+	hub := &memHub{
+		published: make(map[string][]liveMessage),
+		topics:    map[string]int{topic1: 1, topic2: 1},
+	}
+
+	// subscription tracks the result set
+	allowed, err := m.SubscribeAuthFunc(nil)(ctx, 1, topic1)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 1, selects)
+
+	// one select for both topics
+	m.handleNotification(ctx, hub, `{"schema":"core","table":"order","op":"UPDATE","id":"1"}`)
+	assert.Equal(t, 2, selects)
+	assert.Len(t, hub.published[topic1], 1)
+	assert.Len(t, hub.published[topic2], 1)
+
+	// results of queries without subscribers are no longer tracked
+	hub.topics = map[string]int{}
+	m.handleNotification(ctx, hub, `{"schema":"core","table":"order","op":"UPDATE","id":"1"}`)
+	assert.Empty(t, m.results)
+}
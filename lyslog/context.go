@@ -0,0 +1,67 @@
+package lyslog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type ctxAttrsKey struct{}
+
+// AppendCtx returns a copy of ctx with attrs added to the attrs which ContextHandler adds to each record logged with that ctx.
+func AppendCtx(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(ctxAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, ctxAttrsKey{}, append(slices.Clip(existing), attrs...))
+}
+
+// CtxAttrs returns the attrs added to ctx by AppendCtx.
+func CtxAttrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// ContextHandler is a slog.Handler wrapper which adds the attrs stored in ctx by AppendCtx to each record, e.g. a request ID.
+// Use the logger's *Context methods (e.g. InfoContext) so that the ctx is passed. Attrs with keys already in the record are not added again.
+type ContextHandler struct {
+	next slog.Handler
+}
+
+// NewContextHandler creates a new ContextHandler which passes records to next, e.g. a SplitStreamHandler.
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+
+	attrs := CtxAttrs(ctx)
+	if len(attrs) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+
+	keys := make(map[string]struct{}, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		keys[a.Key] = struct{}{}
+		return true
+	})
+
+	r = r.Clone()
+	for _, a := range attrs {
+		if _, ok := keys[a.Key]; !ok {
+			r.AddAttrs(a)
+		}
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
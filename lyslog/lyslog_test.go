@@ -99,3 +99,37 @@ func TestSplitStreamHandler_WithGroupAppliesToBothStreams(t *testing.T) {
 		t.Fatalf("expected grouped key in stderr log, got: %q", stderr.String())
 	}
 }
+
+func TestContextHandler(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	logger := slog.New(NewContextHandler(NewSplitStreamHandler(&stdout, &stderr, testHandlerOptions(slog.LevelDebug))))
+
+	ctx := AppendCtx(context.Background(), slog.String("request_id", "abc"))
+	ctx2 := AppendCtx(ctx, slog.String("tenant", "t1"))
+
+	logger.InfoContext(ctx2, "info-message")
+	logger.ErrorContext(ctx, "error-message", "request_id", "explicit")
+	logger.Info("no-ctx-message")
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 stdout lines, got: %q", stdout.String())
+	}
+	if !strings.Contains(lines[0], "request_id=abc") || !strings.Contains(lines[0], "tenant=t1") {
+		t.Fatalf("expected ctx attrs in info log, got: %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Fatalf("did not expect ctx attrs without ctx, got: %q", lines[1])
+	}
+
+	// attr in record is not duplicated
+	errLog := stderr.String()
+	if strings.Count(errLog, "request_id=") != 1 || !strings.Contains(errLog, "request_id=explicit") {
+		t.Fatalf("expected single explicit request_id in error log, got: %q", errLog)
+	}
+
+	// parent ctx is unchanged
+	if len(CtxAttrs(ctx)) != 1 {
+		t.Fatalf("expected parent ctx to keep 1 attr, got %d", len(CtxAttrs(ctx)))
+	}
+}
@@ -2,6 +2,7 @@ package lysmeta
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/loveyourstack/lys/lystype"
 )
@@ -19,6 +20,14 @@ func init() {
 	gLystypeDatetimeType = reflect.TypeFor[lystype.Datetime]()
 	gLystypeDatetimeTypeP = reflect.TypeFor[*lystype.Datetime]()
 	gLystypeDatetimeTypeA = reflect.TypeFor[[]lystype.Datetime]()
+
+	gLystypeMoneyType = reflect.TypeFor[lystype.Money]()
+	gLystypeMoneyTypeP = reflect.TypeFor[*lystype.Money]()
+	gLystypeMoneyTypeA = reflect.TypeFor[[]lystype.Money]()
+
+	// for use in SetLocation
+	gLystypeDatetimeRangeType = reflect.TypeFor[lystype.DatetimeRange]()
+	gLystypeDatetimeRangeTypeP = reflect.TypeFor[*lystype.DatetimeRange]()
 }
 
 var (
@@ -33,9 +42,19 @@ var (
 	gLystypeDatetimeType  reflect.Type
 	gLystypeDatetimeTypeP reflect.Type
 	gLystypeDatetimeTypeA reflect.Type
+
+	gLystypeMoneyType  reflect.Type
+	gLystypeMoneyTypeP reflect.Type
+	gLystypeMoneyTypeA reflect.Type
+
+	gLystypeDatetimeRangeType  reflect.Type
+	gLystypeDatetimeRangeTypeP reflect.Type
 )
 
-// getInputValue returns val, but contains special handling for lystype date types
+// getInputValue returns val, but contains special handling for lystype date types and Money, which pgx has no codec for.
+// Money is sent as a string, which the codec registered by lystype.RegisterMoney encodes in binary format, e.g. for COPY
+// The other lystype types implement pgx interfaces and need no special handling
+// Datetimes are converted to UTC, whatever location they were received in: the storage contract is that timestamps are written as UTC
 func GetInputValue(val any, reflType reflect.Type) (inputVal any) {
 
 	switch reflType {
@@ -83,20 +102,40 @@ func GetInputValue(val any, reflType reflect.Type) (inputVal any) {
 	// lystype.Datetime
 	case gLystypeDatetimeType:
 		val := val.(lystype.Datetime)
-		return val.Format(lystype.DatetimeFormat)
+		return val.In(time.UTC).Format(lystype.DatetimeFormat)
 
 	case gLystypeDatetimeTypeP:
 		val := val.(*lystype.Datetime)
 		if val == nil {
 			return nil
 		}
-		return (*val).Format(lystype.DatetimeFormat)
+		return (*val).In(time.UTC).Format(lystype.DatetimeFormat)
 
 	case gLystypeDatetimeTypeA:
 		val := val.([]lystype.Datetime)
 		inputVal := make([]string, len(val))
 		for i, v := range val {
-			inputVal[i] = v.Format(lystype.DatetimeFormat)
+			inputVal[i] = v.In(time.UTC).Format(lystype.DatetimeFormat)
+		}
+		return inputVal
+
+	// lystype.Money
+	case gLystypeMoneyType:
+		val := val.(lystype.Money)
+		return val.String()
+
+	case gLystypeMoneyTypeP:
+		val := val.(*lystype.Money)
+		if val == nil {
+			return nil
+		}
+		return val.String()
+
+	case gLystypeMoneyTypeA:
+		val := val.([]lystype.Money)
+		inputVal := make([]string, len(val))
+		for i, v := range val {
+			inputVal[i] = v.String()
 		}
 		return inputVal
 
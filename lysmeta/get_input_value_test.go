@@ -70,13 +70,13 @@ func TestGetInputValueTimeTypes(t *testing.T) {
 func TestGetInputValueDatetimeTypes(t *testing.T) {
 
 	dt := lystype.Datetime(time.Date(2026, 4, 27, 10, 30, 40, 0, time.FixedZone("z2", 2*3600)))
-	assert.Equal(t, "2026-04-27 10:30:40+02", GetInputValue(dt, reflect.TypeFor[lystype.Datetime]()), "regular value")
+	assert.Equal(t, "2026-04-27 08:30:40+00", GetInputValue(dt, reflect.TypeFor[lystype.Datetime]()), "regular value: converted to UTC")
 
 	var dtNilPtr *lystype.Datetime
 	assert.Equal(t, nil, GetInputValue(dtNilPtr, reflect.TypeFor[*lystype.Datetime]()), "nil pointer")
 
 	dtPtr := &dt
-	assert.Equal(t, "2026-04-27 10:30:40+02", GetInputValue(dtPtr, reflect.TypeFor[*lystype.Datetime]()), "pointer value")
+	assert.Equal(t, "2026-04-27 08:30:40+00", GetInputValue(dtPtr, reflect.TypeFor[*lystype.Datetime]()), "pointer value: converted to UTC")
 
 	dtA := []lystype.Datetime{
 		lystype.Datetime(time.Date(2026, 4, 27, 10, 30, 40, 0, time.UTC)),
@@ -92,3 +92,29 @@ func TestGetInputValueDatetimeTypes(t *testing.T) {
 	dtAEmpty := []lystype.Datetime{}
 	assert.EqualValues(t, []string{}, GetInputValue(dtAEmpty, reflect.TypeFor[[]lystype.Datetime]()), "empty slice")
 }
+
+func TestGetInputValueMoneyTypes(t *testing.T) {
+
+	m := lystype.MoneyFromCents(-123450)
+	assert.Equal(t, "-1234.50", GetInputValue(m, reflect.TypeFor[lystype.Money]()), "regular value")
+
+	var mNilPtr *lystype.Money
+	assert.Equal(t, nil, GetInputValue(mNilPtr, reflect.TypeFor[*lystype.Money]()), "nil pointer")
+
+	mPtr := &m
+	assert.Equal(t, "-1234.50", GetInputValue(mPtr, reflect.TypeFor[*lystype.Money]()), "pointer value")
+
+	mA := []lystype.Money{lystype.MoneyFromCents(1), lystype.MoneyFromCents(200)}
+	assert.EqualValues(t, []string{"0.01", "2.00"}, GetInputValue(mA, reflect.TypeFor[[]lystype.Money]()), "slice")
+}
+
+func TestGetInputValueOtherLystypes(t *testing.T) {
+
+	// types implementing pgx interfaces are returned unchanged
+	d, err := lystype.ParseDecimal("1.50")
+	assert.NoError(t, err)
+	assert.Equal(t, d, GetInputValue(d, reflect.TypeFor[lystype.Decimal]()))
+
+	iv := lystype.Interval{Days: 1}
+	assert.Equal(t, iv, GetInputValue(iv, reflect.TypeFor[lystype.Interval]()))
+}
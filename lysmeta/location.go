@@ -0,0 +1,128 @@
+package lysmeta
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/loveyourstack/lys/lystype"
+)
+
+// SetLocation sets the location of the lystype.Datetime and lystype.DatetimeRange fields of v to loc, so that they are output in the user's timezone.
+// v must be a pointer to a struct, or a slice of structs or of pointers to structs. Fields of embedded structs are included, other nested structs are not.
+func SetLocation(v any, loc *time.Location) error {
+
+	if loc == nil {
+		return fmt.Errorf("loc is nil")
+	}
+
+	reflVal := reflect.ValueOf(v)
+
+	switch reflVal.Kind() {
+	case reflect.Slice:
+		for i := range reflVal.Len() {
+			elem := reflVal.Index(i)
+			if elem.Kind() == reflect.Pointer {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Struct {
+				return fmt.Errorf("v must be a slice of structs or of pointers to structs")
+			}
+			setStructLocation(elem, loc)
+		}
+
+	case reflect.Pointer:
+		// also allow a pointer to a pointer to a struct, e.g. the address of an item returned as *T
+		for reflVal.Kind() == reflect.Pointer && !reflVal.IsNil() {
+			reflVal = reflVal.Elem()
+		}
+		if reflVal.Kind() != reflect.Struct {
+			return fmt.Errorf("v must be a non-nil pointer to a struct")
+		}
+		setStructLocation(reflVal, loc)
+
+	default:
+		return fmt.Errorf("v must be a pointer to a struct, or a slice")
+	}
+
+	return nil
+}
+
+// setStructLocation sets the location of the datetime fields of the addressable struct reflVal
+func setStructLocation(reflVal reflect.Value, loc *time.Location) {
+
+	reflType := reflVal.Type()
+
+	for i := range reflVal.NumField() {
+
+		fieldType := reflType.Field(i)
+		fieldVal := reflVal.Field(i)
+
+		// skip unexported non-embedded fields, and fields promoted from unexported embedded structs
+		if (fieldType.PkgPath != "" || !fieldVal.CanSet()) && !fieldType.Anonymous {
+			continue
+		}
+
+		switch fieldType.Type {
+
+		case gLystypeDatetimeType:
+			dt := fieldVal.Interface().(lystype.Datetime)
+			fieldVal.Set(reflect.ValueOf(datetimeIn(dt, loc)))
+
+		case gLystypeDatetimeTypeP:
+			if !fieldVal.IsNil() {
+				dt := fieldVal.Elem().Interface().(lystype.Datetime)
+				fieldVal.Elem().Set(reflect.ValueOf(datetimeIn(dt, loc)))
+			}
+
+		case gLystypeDatetimeTypeA:
+			for j := range fieldVal.Len() {
+				dt := fieldVal.Index(j).Interface().(lystype.Datetime)
+				fieldVal.Index(j).Set(reflect.ValueOf(datetimeIn(dt, loc)))
+			}
+
+		case gLystypeDatetimeRangeType:
+			r := fieldVal.Interface().(lystype.DatetimeRange)
+			fieldVal.Set(reflect.ValueOf(datetimeRangeIn(r, loc)))
+
+		case gLystypeDatetimeRangeTypeP:
+			if !fieldVal.IsNil() {
+				r := fieldVal.Elem().Interface().(lystype.DatetimeRange)
+				fieldVal.Elem().Set(reflect.ValueOf(datetimeRangeIn(r, loc)))
+			}
+
+		default:
+			// flatten embedded structs
+			if fieldType.Anonymous {
+				embVal := fieldVal
+				if embVal.Kind() == reflect.Pointer {
+					if embVal.IsNil() {
+						continue
+					}
+					embVal = embVal.Elem()
+				}
+				if embVal.Kind() == reflect.Struct {
+					setStructLocation(embVal, loc)
+				}
+			}
+		}
+	}
+}
+
+// datetimeIn returns dt with its location set to loc. Zero values are left unchanged
+func datetimeIn(dt lystype.Datetime, loc *time.Location) lystype.Datetime {
+	if dt.IsZero() {
+		return dt
+	}
+	return dt.In(loc)
+}
+
+// datetimeRangeIn returns r with the location of its bounds set to loc
+func datetimeRangeIn(r lystype.DatetimeRange, loc *time.Location) lystype.DatetimeRange {
+	r.Lower = datetimeIn(r.Lower, loc)
+	r.Upper = datetimeIn(r.Upper, loc)
+	return r
+}
@@ -0,0 +1,90 @@
+package lysmeta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loveyourstack/lys/lystype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type setLocationEmbedded struct {
+	EmbDatetime lystype.Datetime `json:"emb_datetime"`
+}
+
+type setLocationRec struct {
+	setLocationEmbedded
+	Datetime    lystype.Datetime      `json:"datetime"`
+	DatetimeN   *lystype.Datetime     `json:"datetime_n"`
+	DatetimeNil *lystype.Datetime     `json:"datetime_nil"`
+	DatetimeA   []lystype.Datetime    `json:"datetime_a"`
+	DatetimeZ   lystype.Datetime      `json:"datetime_z"`
+	Range       lystype.DatetimeRange `json:"range"`
+	Date        lystype.Date          `json:"date"`
+	Name        string                `json:"name"`
+}
+
+func TestSetLocationSuccess(t *testing.T) {
+
+	utc := time.Date(2026, 4, 27, 10, 30, 0, 0, time.UTC)
+	loc := time.FixedZone("UTC+5:30", 5*3600+1800)
+
+	newRec := func() setLocationRec {
+		dtN := lystype.Datetime(utc)
+		return setLocationRec{
+			setLocationEmbedded: setLocationEmbedded{EmbDatetime: lystype.Datetime(utc)},
+			Datetime:            lystype.Datetime(utc),
+			DatetimeN:           &dtN,
+			DatetimeA:           []lystype.Datetime{lystype.Datetime(utc)},
+			Range:               lystype.DatetimeRange{Lower: lystype.Datetime(utc), LowerInc: true},
+			Date:                lystype.Date(utc),
+			Name:                "a",
+		}
+	}
+
+	assertRec := func(rec setLocationRec, msg string) {
+		expected := "2026-04-27 16:00:00+05:30"
+		assert.Equal(t, expected, rec.EmbDatetime.String(), msg)
+		assert.Equal(t, expected, rec.Datetime.String(), msg)
+		assert.Equal(t, expected, rec.DatetimeN.String(), msg)
+		assert.Nil(t, rec.DatetimeNil, msg)
+		assert.Equal(t, expected, rec.DatetimeA[0].String(), msg)
+		assert.True(t, rec.DatetimeZ.IsZero(), msg)
+		assert.Equal(t, time.UTC, rec.DatetimeZ.ToTime().Location(), msg)
+		assert.Equal(t, `["2026-04-27 16:00:00+05:30",)`, rec.Range.String(), msg)
+		assert.Equal(t, lystype.Date(utc), rec.Date, msg)
+	}
+
+	// slice of structs
+	recs := []setLocationRec{newRec(), newRec()}
+	require.NoError(t, SetLocation(recs, loc))
+	assertRec(recs[0], "slice of structs")
+	assertRec(recs[1], "slice of structs")
+
+	// slice of pointers
+	rec := newRec()
+	require.NoError(t, SetLocation([]*setLocationRec{&rec, nil}, loc))
+	assertRec(rec, "slice of pointers")
+
+	// pointer to struct
+	rec = newRec()
+	require.NoError(t, SetLocation(&rec, loc))
+	assertRec(rec, "pointer to struct")
+
+	// pointer to pointer to struct
+	rec = newRec()
+	recP := &rec
+	require.NoError(t, SetLocation(&recP, loc))
+	assertRec(rec, "pointer to pointer to struct")
+}
+
+func TestSetLocationFailure(t *testing.T) {
+
+	loc := time.UTC
+
+	assert.EqualError(t, SetLocation([]setLocationRec{}, nil), "loc is nil")
+	assert.EqualError(t, SetLocation(setLocationRec{}, loc), "v must be a pointer to a struct, or a slice")
+	assert.EqualError(t, SetLocation((*setLocationRec)(nil), loc), "v must be a non-nil pointer to a struct")
+	assert.EqualError(t, SetLocation([]int{1}, loc), "v must be a slice of structs or of pointers to structs")
+}
@@ -3,15 +3,23 @@ package lyspg
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lystrace"
 )
 
 // BulkInsert inserts multiple records using the postgres COPY protocol.
 // T must be a struct with "db" tags
 func BulkInsert[T any](ctx context.Context, db PoolOrTx, schemaName, tableName string, inputs []T) (rowsAffected int64, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.BulkInsert")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+tableName)
+	span.SetAttr("db.rows", strconv.Itoa(len(inputs)))
+
 	// check params
 	if len(inputs) == 0 {
 		return 0, fmt.Errorf("inputs has len 0")
@@ -30,6 +38,7 @@ func BulkInsert[T any](ctx context.Context, db PoolOrTx, schemaName, tableName s
 	}
 
 	// COPY to table using pgx
+	span.SetAttr(lystrace.AttrDbStatement, fmt.Sprintf("COPY %s (%s) FROM STDIN", pgx.Identifier{schemaName, tableName}.Sanitize(), strings.Join(plan.DbNames(), ", ")))
 	rowsAffected, err = db.CopyFrom(ctx, pgx.Identifier{schemaName, tableName}, plan.DbNames(), pgx.CopyFromRows(recs))
 	if err != nil {
 		return 0, fmt.Errorf("db.CopyFrom failed: %w", err)
@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/loveyourstack/lys/internal/stores/core/coretypetestm"
 	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lystype"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -227,6 +228,49 @@ func TestBulkInsertSuccess(t *testing.T) {
 	coretypetestm.TestFilledInput(t, item.Input)
 }
 
+func TestBulkInsertMoney(t *testing.T) {
+
+	ctx := context.Background()
+	db := mustGetDb(ctx, t)
+	defer db.Close()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("db.Begin failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "CREATE TABLE core.bulk_insert_money_test (id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY, c_money money NOT NULL, c_moneyn money, c_moneya money[]);")
+	if err != nil {
+		t.Fatalf("tx.Exec failed: %v", err)
+	}
+
+	type moneyInput struct {
+		CMoney  lystype.Money   `db:"c_money"`
+		CMoneyN *lystype.Money  `db:"c_moneyn"`
+		CMoneyA []lystype.Money `db:"c_moneya"`
+	}
+
+	// "-1234.50" has 8 bytes, the length of the binary format
+	m := lystype.MoneyFromCents(-123450)
+	inputs := []moneyInput{
+		{CMoney: m, CMoneyN: &m, CMoneyA: []lystype.Money{m, lystype.MoneyFromCents(1)}},
+		{CMoney: lystype.MoneyFromCents(99), CMoneyA: []lystype.Money{lystype.MoneyFromCents(0)}},
+	}
+	rowsAffected, err := BulkInsert(ctx, tx, "core", "bulk_insert_money_test", inputs)
+	if err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	assert.EqualValues(t, 2, rowsAffected)
+
+	rows, _ := tx.Query(ctx, "SELECT c_money, c_moneyn, c_moneya FROM core.bulk_insert_money_test ORDER BY id;")
+	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[moneyInput])
+	if err != nil {
+		t.Fatalf("pgx.CollectRows failed: %v", err)
+	}
+	assert.Equal(t, inputs, items)
+}
+
 func TestBulkInsertFailure(t *testing.T) {
 
 	schemaName := "core"
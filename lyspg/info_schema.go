@@ -20,6 +20,7 @@ type ForeignKey struct {
 	ParentSchema   string `db:"parent_schema"`
 	ParentTable    string `db:"parent_table"`
 	ParentColumn   string `db:"parent_column"`
+	Definition     string `db:"definition"` // pg_get_constraintdef, e.g. "FOREIGN KEY (product_id) REFERENCES core.product(id)"
 }
 
 // GetChildForeignKeys returns the child FKs of the supplied table
@@ -27,10 +28,11 @@ type ForeignKey struct {
 func GetChildForeignKeys(ctx context.Context, db PoolOrTx, schemaName, tableName string) (fks []ForeignKey, err error) {
 
 	stmt := `SELECT tc.constraint_name, tc.table_schema AS child_schema, tc.table_name AS child_table, kcu.column_name AS child_column, ccu.table_schema AS parent_schema, 
-		ccu.table_name AS parent_table, ccu.column_name AS parent_column
+		ccu.table_name AS parent_table, ccu.column_name AS parent_column, pg_get_constraintdef(con.oid) AS definition
 	FROM information_schema.table_constraints tc 
 	JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
 	JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name = tc.constraint_name
+	JOIN pg_constraint con ON con.conname = tc.constraint_name AND con.conrelid = format('%I.%I', tc.table_schema, tc.table_name)::regclass
 	WHERE tc.constraint_type = 'FOREIGN KEY' AND ccu.table_schema = $1 AND ccu.table_name = $2;`
 
 	rows, _ := db.Query(ctx, stmt, schemaName, tableName)
@@ -51,10 +53,11 @@ func GetChildForeignKeys(ctx context.Context, db PoolOrTx, schemaName, tableName
 func GetForeignKeys(ctx context.Context, db PoolOrTx, schemaName, tableName string) (fks []ForeignKey, err error) {
 
 	stmt := `SELECT tc.constraint_name, tc.table_schema AS child_schema, tc.table_name AS child_table, kcu.column_name AS child_column, ccu.table_schema AS parent_schema, 
-		ccu.table_name AS parent_table, ccu.column_name AS parent_column
+		ccu.table_name AS parent_table, ccu.column_name AS parent_column, pg_get_constraintdef(con.oid) AS definition
 	FROM information_schema.table_constraints tc 
 	JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
 	JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name = tc.constraint_name
+	JOIN pg_constraint con ON con.conname = tc.constraint_name AND con.conrelid = format('%I.%I', tc.table_schema, tc.table_name)::regclass
 	WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = $1 AND tc.table_name = $2;`
 
 	rows, _ := db.Query(ctx, stmt, schemaName, tableName)
@@ -92,19 +95,31 @@ type Column struct {
 	TableName   string
 	Name        string `db:"column_name"`
 	DataType    string `db:"data_type"`
+	UdtSchema   string `db:"udt_schema"` // schema of the underlying type, e.g. of an enum
+	UdtName     string `db:"udt_name"`   // name of the underlying type, e.g. "int8", or "_int8" for arrays
 	IsNullable  bool   `db:"is_nullable"`
 	IsIdentity  bool   `db:"is_identity"`
 	IsGenerated bool   `db:"is_generated"`
 	IsTracking  bool
+
+	FullDataType       string `db:"full_data_type"`        // including modifiers, e.g. "character varying(50)"
+	Default            string `db:"column_default"`        // default expression, or ""
+	GenerationExpr     string `db:"generation_expression"` // expression of a generated column, or ""
+	IdentityGeneration string `db:"identity_generation"`   // "ALWAYS", "BY DEFAULT" or ""
 }
 
 func GetTableColumns(ctx context.Context, db PoolOrTx, schemaName, tableName string) (cols []Column, err error) {
 
-	stmt := `SELECT column_name, data_type, 
+	stmt := `SELECT column_name, data_type, udt_schema, udt_name,
 		CASE WHEN is_nullable = 'YES' THEN true ELSE false END AS is_nullable, 
 		CASE WHEN is_identity = 'YES' THEN true ELSE false END AS is_identity,
-		CASE WHEN is_generated = 'ALWAYS' THEN true ELSE false END AS is_generated
-	FROM information_schema.columns 
+		CASE WHEN is_generated = 'ALWAYS' THEN true ELSE false END AS is_generated,
+		format_type(a.atttypid, a.atttypmod) AS full_data_type,
+		COALESCE(column_default, '') AS column_default,
+		COALESCE(generation_expression, '') AS generation_expression,
+		COALESCE(identity_generation, '') AS identity_generation
+	FROM information_schema.columns c
+	JOIN pg_attribute a ON a.attrelid = format('%I.%I', c.table_schema, c.table_name)::regclass AND a.attname = c.column_name
 	WHERE table_schema = $1 AND table_name = $2
 	ORDER BY is_identity DESC, column_name;`
 
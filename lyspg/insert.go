@@ -8,6 +8,7 @@ import (
 
 	"github.com/loveyourstack/lys/lyserr"
 	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lystrace"
 )
 
 // getInsertStmt returns an INSERT statement using the supplied params
@@ -53,10 +54,15 @@ func getInsertStmtAndValues[inputT any](schemaName, tableName, pkColName string,
 // inputT must be a struct with "db" tags
 func Insert[inputT any, pkT PrimaryKeyType](ctx context.Context, db PoolOrTx, schemaName, tableName, pkColName string, input inputT) (newPk pkT, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.Insert")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+tableName)
+
 	stmt, inputVals, err := getInsertStmtAndValues(schemaName, tableName, pkColName, input, nil, nil)
 	if err != nil {
 		return newPk, fmt.Errorf("getInsertStmtAndValues failed: %w", err)
 	}
+	span.SetAttr(lystrace.AttrDbStatement, stmt)
 
 	if err = db.QueryRow(ctx, stmt, inputVals...).Scan(&newPk); err != nil {
 		return newPk, lyserr.Db{Err: fmt.Errorf(ErrDescInsertScanFailed+": %w", err), Stmt: stmt}
@@ -69,10 +75,15 @@ func Insert[inputT any, pkT PrimaryKeyType](ctx context.Context, db PoolOrTx, sc
 // inputT must be a struct with "db" tags
 func InsertSelect[inputT any, itemT any](ctx context.Context, db PoolOrTx, schemaName, tableName, viewName, pkColName string, input inputT) (newItem itemT, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.Insert")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+tableName)
+
 	stmt, inputVals, err := getInsertStmtAndValues(schemaName, tableName, pkColName, input, nil, nil)
 	if err != nil {
 		return newItem, fmt.Errorf("getInsertStmtAndValues failed: %w", err)
 	}
+	span.SetAttr(lystrace.AttrDbStatement, stmt)
 
 	var newPk any
 	if err = db.QueryRow(ctx, stmt, inputVals...).Scan(&newPk); err != nil {
@@ -86,10 +97,15 @@ func InsertSelect[inputT any, itemT any](ctx context.Context, db PoolOrTx, schem
 func InsertWithExtras[inputT any, pkT PrimaryKeyType](ctx context.Context, db PoolOrTx, schemaName, tableName, pkColName string, input inputT,
 	extraDbCols []string, extraInputVals []any) (newPk pkT, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.Insert")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+tableName)
+
 	stmt, inputVals, err := getInsertStmtAndValues(schemaName, tableName, pkColName, input, extraDbCols, extraInputVals)
 	if err != nil {
 		return newPk, fmt.Errorf("getInsertStmtAndValues failed: %w", err)
 	}
+	span.SetAttr(lystrace.AttrDbStatement, stmt)
 
 	if err = db.QueryRow(ctx, stmt, inputVals...).Scan(&newPk); err != nil {
 		return newPk, lyserr.Db{Err: fmt.Errorf(ErrDescInsertScanFailed+": %w", err), Stmt: stmt}
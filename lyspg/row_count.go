@@ -7,6 +7,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lystrace"
 )
 
 // TotalCount contains the total number of table records. If IsEstimated is true, the Value was estimated using db statistics rather than calculated using a record count
@@ -25,6 +26,11 @@ var largeTableThreshold int64 = 10_000
 func fastRowCount(ctx context.Context, db PoolOrTx, schemaName, tableName string, setFuncParamValues []any, conds []Condition,
 	orCondSets [][]Condition, query string) (totalCount TotalCount, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.fastRowCount")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+tableName)
+	span.SetAttr(lystrace.AttrDbStatement, query)
+
 	// get rowcount from info schema stats
 	statsRowCount, err := GetStatsRowCount(ctx, db, schemaName, tableName)
 	if err != nil {
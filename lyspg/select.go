@@ -7,12 +7,17 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lystrace"
 )
 
 // Select returns multiple rows from the db according to the params supplied
 func Select[T any](ctx context.Context, db PoolOrTx, schemaName, tableName, viewName, defaultOrderBy string, allFields []string,
 	params SelectParams) (items []T, unpagedCount TotalCount, err error) {
 
+	ctx, span := lystrace.Start(ctx, "lyspg.Select")
+	defer func() { span.End(err) }()
+	span.SetAttr(lystrace.AttrDbTable, schemaName+"."+viewName)
+
 	// use allFields if the fields param was not sent
 	var fields []string
 	if params.Fields == nil {
@@ -42,6 +47,7 @@ func Select[T any](ctx context.Context, db PoolOrTx, schemaName, tableName, view
 
 	stmt += GetOrderBy(params.Sorts, defaultOrderBy)
 	stmt += GetLimitOffsetClause(numPlaceholders)
+	span.SetAttr(lystrace.AttrDbStatement, stmt)
 
 	// get params for stmt placeholders
 	paramValues := GetSelectParamValues(params.SetFuncParamValues, params.Conditions, params.OrConditionSets, true, GetLimit(params.Limit), params.Offset)
@@ -27,6 +27,7 @@ const (
 	OpNotEmpty          Operator = "NotEmpty"
 	OpNull              Operator = "Null"
 	OpNotNull           Operator = "NotNull"
+	OpRangeContains     Operator = "@>"
 )
 
 // Condition is a condition passed to a SELECT stmt
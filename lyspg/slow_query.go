@@ -0,0 +1,214 @@
+package lyspg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// max number of characters of a param to print in slow query logs
+const maxParamPrintChars int = 100
+
+// SlowQuery is a statement which exceeded the SlowQueryLog threshold
+type SlowQuery struct {
+	AppName  string          // application_name of the connection
+	Duration time.Duration   // from sending the statement until its result was read and closed
+	Err      string          // statement error, if any
+	Params   []string        // after redaction
+	Plan     json.RawMessage // output of EXPLAIN (FORMAT JSON), if captured
+	Stmt     string
+}
+
+// SlowQueryRecorder stores slow queries, e.g. lyspgslowquery.Store
+type SlowQueryRecorder interface {
+	RecordSlowQuery(ctx context.Context, q SlowQuery) error
+}
+
+// SlowQueryOptions configures a SlowQueryLog
+type SlowQueryOptions struct {
+	ExplainDb    PoolOrTx                                       // if set, slow SELECT, INSERT, UPDATE and DELETE statements are re-run as EXPLAIN (without ANALYZE) on this db to capture the plan
+	MaxPending   int                                            // max number of slow queries being explained or recorded at once: further ones are only logged. Default 10
+	Recorder     SlowQueryRecorder                              // if set, slow queries are stored using it
+	RedactParams func(stmt string, params []any) (res []string) // returns the params to log and record. Default replaces each param with "?". Use PrintParams to log them
+	Threshold    time.Duration                                  // default 500ms
+}
+
+func fillSlowQueryOptions(opts SlowQueryOptions) SlowQueryOptions {
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 10
+	}
+	if opts.RedactParams == nil {
+		opts.RedactParams = RedactAllParams
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = 500 * time.Millisecond
+	}
+	return opts
+}
+
+// PrintParams returns each param printed with fmt.Sprint, truncated to 100 chars. Use as SlowQueryOptions.RedactParams if params may be logged, e.g. in development
+func PrintParams(stmt string, params []any) (res []string) {
+	for _, p := range params {
+		s := fmt.Sprint(p)
+		if len(s) > maxParamPrintChars {
+			s = s[:maxParamPrintChars] + "..."
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// RedactAllParams replaces each param with "?", since params may contain personal data. It is the default SlowQueryOptions.RedactParams func
+func RedactAllParams(stmt string, params []any) (res []string) {
+	for range params {
+		res = append(res, "?")
+	}
+	return res
+}
+
+type slowQueryCtxKey struct{}
+type slowQuerySkipCtxKey struct{}
+
+// SlowQueryLog is a pgx.QueryTracer which logs statements taking longer than a threshold at Warn level, and optionally captures their plan and records them.
+// Add it to the pool config before creating the pool, e.g. cfg.ConnConfig.Tracer = slowQueryLog, so that all lyspg statements run on that pool are timed.
+type SlowQueryLog struct {
+	logger  *slog.Logger
+	opts    SlowQueryOptions
+	pending chan struct{} // semaphore limiting background work
+	wg      sync.WaitGroup
+}
+
+// NewSlowQueryLog returns a new SlowQueryLog
+func NewSlowQueryLog(logger *slog.Logger, options ...SlowQueryOptions) (sl *SlowQueryLog, err error) {
+
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	var opts SlowQueryOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	opts = fillSlowQueryOptions(opts)
+
+	return &SlowQueryLog{
+		logger:  logger,
+		opts:    opts,
+		pending: make(chan struct{}, opts.MaxPending),
+	}, nil
+}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (sl *SlowQueryLog) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if ctx.Value(slowQuerySkipCtxKey{}) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, slowQueryCtxKey{}, slowQueryStart{start: time.Now(), stmt: data.SQL, args: data.Args})
+}
+
+type slowQueryStart struct {
+	start time.Time
+	stmt  string
+	args  []any
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (sl *SlowQueryLog) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+
+	qs, ok := ctx.Value(slowQueryCtxKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+
+	dur := time.Since(qs.start)
+	if dur < sl.opts.Threshold {
+		return
+	}
+
+	q := SlowQuery{
+		Duration: dur,
+		Params:   sl.opts.RedactParams(qs.stmt, qs.args),
+		Stmt:     qs.stmt,
+	}
+	if conn != nil {
+		q.AppName = conn.Config().RuntimeParams["application_name"]
+	}
+	if data.Err != nil {
+		q.Err = data.Err.Error()
+	}
+
+	stmt := q.Stmt
+	if len(stmt) > MaxStmtPrintChars {
+		stmt = stmt[:MaxStmtPrintChars] + "..."
+	}
+	sl.logger.WarnContext(ctx, "slow query", slog.String("stmt", stmt), slog.Any("params", q.Params), slog.Duration("duration", dur), slog.String("err", q.Err))
+
+	if sl.opts.ExplainDb == nil && sl.opts.Recorder == nil {
+		return
+	}
+
+	// explain and record in the background, without delaying the caller
+	select {
+	case sl.pending <- struct{}{}:
+	default:
+		sl.logger.Warn("slow query not recorded: too many pending")
+		return
+	}
+
+	sl.wg.Add(1)
+	go func() {
+		defer sl.wg.Done()
+		defer func() { <-sl.pending }()
+
+		// the original ctx may already be cancelled. Skip tracing to avoid recording the explain or insert stmts themselves
+		bgCtx, cancel := context.WithTimeout(context.WithValue(context.Background(), slowQuerySkipCtxKey{}, true), 30*time.Second)
+		defer cancel()
+
+		sl.explainAndRecord(bgCtx, q, qs.args)
+	}()
+}
+
+func (sl *SlowQueryLog) explainAndRecord(ctx context.Context, q SlowQuery, args []any) {
+
+	if sl.opts.ExplainDb != nil && q.Err == "" && explainable(q.Stmt) {
+		stmt := "EXPLAIN (FORMAT JSON) " + q.Stmt
+		var plan string
+		err := sl.opts.ExplainDb.QueryRow(ctx, stmt, args...).Scan(&plan)
+		if err != nil {
+			sl.logger.Warn("slow query explain failed: " + err.Error())
+		} else {
+			q.Plan = json.RawMessage(plan)
+		}
+	}
+
+	if sl.opts.Recorder != nil {
+		err := sl.opts.Recorder.RecordSlowQuery(ctx, q)
+		if err != nil {
+			sl.logger.Error("slow query RecordSlowQuery failed: " + err.Error())
+		}
+	}
+}
+
+// Wait blocks until all slow queries being explained or recorded are done, e.g. before closing the pools on shutdown
+func (sl *SlowQueryLog) Wait() {
+	sl.wg.Wait()
+}
+
+// explainable returns true if stmt can be run as EXPLAIN without ANALYZE
+func explainable(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "INSERT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package lyspg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memSlowQueryRecorder struct {
+	mu      sync.Mutex
+	queries []SlowQuery
+}
+
+func (r *memSlowQueryRecorder) RecordSlowQuery(ctx context.Context, q SlowQuery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.queries = append(r.queries, q)
+	return nil
+}
+
+func TestSlowQueryLog(t *testing.T) {
+
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logBuf, nil))
+	rec := &memSlowQueryRecorder{}
+
+	// params are redacted by default
+	sl, err := NewSlowQueryLog(logger, SlowQueryOptions{Recorder: rec, Threshold: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	// fast: ignored
+	ctx := sl.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1;"})
+	sl.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	// slow
+	ctx = sl.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM a WHERE b = $1;", Args: []any{"secret"}})
+	time.Sleep(15 * time.Millisecond)
+	sl.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("failed")})
+	sl.Wait()
+
+	require.Len(t, rec.queries, 1)
+	q := rec.queries[0]
+	assert.Equal(t, "SELECT * FROM a WHERE b = $1;", q.Stmt)
+	assert.Equal(t, []string{"?"}, q.Params)
+	assert.Equal(t, "failed", q.Err)
+	assert.GreaterOrEqual(t, q.Duration, 10*time.Millisecond)
+
+	assert.Equal(t, 1, strings.Count(logBuf.String(), "slow query"))
+	assert.NotContains(t, logBuf.String(), "secret")
+
+	// skipped ctx is not traced
+	ctx = sl.TraceQueryStart(context.WithValue(context.Background(), slowQuerySkipCtxKey{}, true), nil, pgx.TraceQueryStartData{SQL: "SELECT 1;"})
+	time.Sleep(15 * time.Millisecond)
+	sl.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	sl.Wait()
+	assert.Len(t, rec.queries, 1)
+}
+
+func TestSlowQueryLog_PrintParams(t *testing.T) {
+
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logBuf, nil))
+
+	sl, err := NewSlowQueryLog(logger, SlowQueryOptions{RedactParams: PrintParams, Threshold: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	ctx := sl.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM a WHERE b = $1;", Args: []any{"visible"}})
+	time.Sleep(15 * time.Millisecond)
+	sl.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	assert.Contains(t, logBuf.String(), "visible")
+}
+
+func TestPrintParams(t *testing.T) {
+	res := PrintParams("", []any{1, "a", strings.Repeat("x", maxParamPrintChars+1)})
+	assert.Equal(t, []string{"1", "a", strings.Repeat("x", maxParamPrintChars) + "..."}, res)
+}
+
+func TestExplainable(t *testing.T) {
+	assert.True(t, explainable("  select 1;"))
+	assert.True(t, explainable("WITH a AS (SELECT 1) SELECT * FROM a;"))
+	assert.True(t, explainable("UPDATE a SET b = 1;"))
+	assert.False(t, explainable("SET search_path TO a;"))
+	assert.False(t, explainable(""))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lystype"
 )
 
 func getConnStr(dbConfig Database, userConfig User, appName string) string {
@@ -21,7 +22,7 @@ func getConnStr(dbConfig Database, userConfig User, appName string) string {
 	return u.String()
 }
 
-// GetConfig returns a Config struct matching the supplied params
+// GetConfig returns a Config struct matching the supplied params. Its AfterConnect hook registers the lystype codecs, e.g. for money
 func GetConfig(dbConfig Database, userConfig User, appName string) (cfg *pgxpool.Config, err error) {
 
 	cfg, err = pgxpool.ParseConfig(getConnStr(dbConfig, userConfig, appName))
@@ -29,15 +30,25 @@ func GetConfig(dbConfig Database, userConfig User, appName string) (cfg *pgxpool
 		return nil, fmt.Errorf("pgxpool.ParseConfig failed: %w", err)
 	}
 
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		lystype.RegisterMoney(conn.TypeMap())
+		return nil
+	}
+
 	return cfg, nil
 }
 
 // GetPool returns a connection pool to the postgres database matching the config params
 func GetPool(ctx context.Context, dbConfig Database, userConfig User, appName string) (db *pgxpool.Pool, err error) {
 
-	db, err = pgxpool.New(ctx, getConnStr(dbConfig, userConfig, appName))
+	cfg, err := GetConfig(dbConfig, userConfig, appName)
+	if err != nil {
+		return nil, fmt.Errorf("GetConfig failed: %w", err)
+	}
+
+	db, err = pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New failed: %w", err)
+		return nil, fmt.Errorf("pgxpool.NewWithConfig failed: %w", err)
 	}
 
 	err = db.Ping(ctx)
@@ -58,7 +69,11 @@ func GetPoolWithTypes(ctx context.Context, dbConfig Database, userConfig User, a
 	}
 
 	// register types in conn AfterConnect hook
+	registerLysTypes := cfg.AfterConnect
 	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if err := registerLysTypes(ctx, conn); err != nil {
+			return err
+		}
 		for _, typeName := range dataTypeNames {
 			dataType, err := conn.LoadType(ctx, typeName)
 			if err != nil {
@@ -15,7 +15,7 @@ import (
 
 // CreateLocalDb creates or recreates a test or dev db
 func CreateLocalDb(ctx context.Context, sqlAssets embed.FS, dbConf Database, dbSuperUser, dbOwnerConf User,
-	dropExisting, addSecurityPermissions bool, replacements []FileReplacement, logger *slog.Logger) (err error) {
+	dropExisting, addSecurityPermissions bool, replacements []FileReplacement, logger *slog.Logger, options ...PopulateOptions) (err error) {
 
 	pgDbConf := Database{
 		Host:     dbConf.Host,
@@ -89,7 +89,7 @@ func CreateLocalDb(ctx context.Context, sqlAssets embed.FS, dbConf Database, dbS
 	// populate and analyze db
 
 	logger.Info("Populating database")
-	if err = PopulateDb(ctx, dbOwnerUserDb, sqlAssets, dbConf.SchemaCreationOrder, replacements, logger); err != nil {
+	if err = PopulateDb(ctx, dbOwnerUserDb, sqlAssets, dbConf.SchemaCreationOrder, replacements, logger, options...); err != nil {
 		return fmt.Errorf("PopulateDb failed: %w", err)
 	}
 
@@ -146,9 +146,19 @@ func GrantAll(ctx context.Context, pgUserDb *pgxpool.Pool, userConf User, dbName
 	return nil
 }
 
+// PopulateOptions configures PopulateDb
+type PopulateOptions struct {
+	LoadFixtures func(ctx context.Context, db *pgxpool.Pool, sqlAssets embed.FS) error // optional: called after the data files are run, e.g. lysfixture.LoadFunc
+}
+
 // PopulateDb writes schema, tables, functions and views
 func PopulateDb(ctx context.Context, db *pgxpool.Pool, sqlAssets embed.FS, schemaCreationOrder []string, replacements []FileReplacement,
-	logger *slog.Logger) (err error) {
+	logger *slog.Logger, options ...PopulateOptions) (err error) {
+
+	var opts PopulateOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
 
 	// make sure db is empty (no user objects)
 	stmt := `SELECT count(*) FROM pg_class c
@@ -231,6 +241,14 @@ func PopulateDb(ctx context.Context, db *pgxpool.Pool, sqlAssets embed.FS, schem
 		}
 	}
 
+	// add fixtures, if any
+	if opts.LoadFixtures != nil {
+		logger.Info("Loading fixtures")
+		if err = opts.LoadFixtures(ctx, db, sqlAssets); err != nil {
+			return fmt.Errorf("opts.LoadFixtures failed: %w", err)
+		}
+	}
+
 	// add trigger func assignments to tables
 	assetTypes = []string{"tfa_"}
 	for _, assetType := range assetTypes {
@@ -0,0 +1,2 @@
+// Package lyspgdbtest contains helpers for tests which need a Postgres database.
+package lyspgdbtest
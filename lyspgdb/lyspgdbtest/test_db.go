@@ -0,0 +1,184 @@
+package lyspgdbtest
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyspgdb"
+)
+
+// max length of a postgres identifier
+const maxIdentifierLen int = 63
+
+// TestDbOptions configures a TestDbTemplate
+type TestDbOptions struct {
+	DataTypeNames   []string // registered in the pools of clones, as in lyspgdb.GetPoolWithTypes
+	PopulateOptions lyspgdb.PopulateOptions
+	Replacements    []lyspgdb.FileReplacement
+	TemplateName    string // default "<db>_template"
+}
+
+// TestDbTemplate creates a template db from embedded SQL files once, and clones it for each test or package using CREATE DATABASE ... TEMPLATE.
+// Since each clone is a separate db, tests using clones can run in parallel and modify data freely.
+// Use one TestDbTemplate per test binary, e.g. in a package var, so that the template is only created once.
+type TestDbTemplate struct {
+	dbConf      lyspgdb.Database
+	dbOwnerConf lyspgdb.User
+	dbSuperUser lyspgdb.User
+	logger      *slog.Logger
+	opts        TestDbOptions
+	sqlAssets   embed.FS
+
+	cloneMu    sync.Mutex // serializes CREATE DATABASE, which fails if the template is being accessed by another session
+	createErr  error
+	createOnce sync.Once
+}
+
+// NewTestDbTemplate returns a new TestDbTemplate. The template is named after dbConf.Database and is created on the first call to MustClone
+func NewTestDbTemplate(sqlAssets embed.FS, dbConf lyspgdb.Database, dbSuperUser, dbOwnerConf lyspgdb.User, logger *slog.Logger, options ...TestDbOptions) *TestDbTemplate {
+
+	var opts TestDbOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.TemplateName == "" {
+		opts.TemplateName = dbConf.Database + "_template"
+	}
+
+	return &TestDbTemplate{
+		dbConf:      dbConf,
+		dbOwnerConf: dbOwnerConf,
+		dbSuperUser: dbSuperUser,
+		logger:      logger,
+		opts:        opts,
+		sqlAssets:   sqlAssets,
+	}
+}
+
+// create (re-)creates the template db using lyspgdb.CreateLocalDb. It is not marked with IS_TEMPLATE, since such dbs cannot be dropped, and the superuser may clone any db
+func (tt *TestDbTemplate) create(ctx context.Context) (err error) {
+
+	templateConf := tt.dbConf
+	templateConf.Database = tt.opts.TemplateName
+
+	if err = lyspgdb.CreateLocalDb(ctx, tt.sqlAssets, templateConf, tt.dbSuperUser, tt.dbOwnerConf, true, false, tt.opts.Replacements, tt.logger, tt.opts.PopulateOptions); err != nil {
+		return fmt.Errorf("lyspgdb.CreateLocalDb failed: %w", err)
+	}
+
+	return nil
+}
+
+// withPgDb calls f with a superuser connection to the postgres db
+func (tt *TestDbTemplate) withPgDb(ctx context.Context, f func(pgDb *pgxpool.Pool) error) (err error) {
+
+	pgDbConf := lyspgdb.Database{Host: tt.dbConf.Host, Port: tt.dbConf.Port, Database: "postgres"}
+	pgDb, err := lyspgdb.GetPool(ctx, pgDbConf, tt.dbSuperUser, "TestDbTemplate")
+	if err != nil {
+		return fmt.Errorf("lyspgdb.GetPool failed (postgres db with %v user): %w", tt.dbSuperUser.Name, err)
+	}
+	defer pgDb.Close()
+
+	return f(pgDb)
+}
+
+// Clone creates the template if needed, and then a new db from it. It returns the clone's db config, which has a random name
+func (tt *TestDbTemplate) Clone(ctx context.Context) (cloneConf lyspgdb.Database, err error) {
+
+	tt.createOnce.Do(func() {
+		tt.createErr = tt.create(ctx)
+	})
+	if tt.createErr != nil {
+		return lyspgdb.Database{}, fmt.Errorf("tt.create failed: %w", tt.createErr)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err = rand.Read(suffix); err != nil {
+		return lyspgdb.Database{}, fmt.Errorf("rand.Read failed: %w", err)
+	}
+	cloneName := tt.opts.TemplateName
+	if len(cloneName) > maxIdentifierLen-9 {
+		cloneName = cloneName[:maxIdentifierLen-9]
+	}
+	cloneName += "_" + hex.EncodeToString(suffix)
+
+	tt.cloneMu.Lock()
+	defer tt.cloneMu.Unlock()
+
+	err = tt.withPgDb(ctx, func(pgDb *pgxpool.Pool) error {
+		stmt := fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s OWNER %s;", pgx.Identifier{cloneName}.Sanitize(), pgx.Identifier{tt.opts.TemplateName}.Sanitize(),
+			pgx.Identifier{tt.dbOwnerConf.Name}.Sanitize())
+		if _, err := pgDb.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("pgDb.Exec failed (create database): %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return lyspgdb.Database{}, err
+	}
+
+	cloneConf = tt.dbConf
+	cloneConf.Database = cloneName
+	return cloneConf, nil
+}
+
+// DropClone drops a db created by Clone
+func (tt *TestDbTemplate) DropClone(ctx context.Context, cloneConf lyspgdb.Database) (err error) {
+	return tt.withPgDb(ctx, func(pgDb *pgxpool.Pool) error {
+		return lyspgdb.DropDb(ctx, pgDb, cloneConf.Database)
+	})
+}
+
+// MustClone clones the template and returns a pool connected to the clone with the db owner user. The pool is closed and the clone dropped when t and its subtests complete.
+// Pass the pool to stores and routers as usual, and then use the lysclient Must... helpers against the router
+func (tt *TestDbTemplate) MustClone(ctx context.Context, t testing.TB) *pgxpool.Pool {
+
+	cloneConf, err := tt.Clone(ctx)
+	if err != nil {
+		t.Fatalf("tt.Clone failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tt.DropClone(context.Background(), cloneConf); err != nil {
+			t.Errorf("tt.DropClone failed: %v", err)
+		}
+	})
+
+	var db *pgxpool.Pool
+	if len(tt.opts.DataTypeNames) > 0 {
+		db, err = lyspgdb.GetPoolWithTypes(ctx, cloneConf, tt.dbOwnerConf, "test", tt.opts.DataTypeNames)
+	} else {
+		db, err = lyspgdb.GetPool(ctx, cloneConf, tt.dbOwnerConf, "test")
+	}
+	if err != nil {
+		t.Fatalf("lyspgdb.GetPool failed: %v", err)
+	}
+	// registered after the drop, so runs before it
+	t.Cleanup(db.Close)
+
+	return db
+}
+
+// MustBeginRollback begins a tx on db which is rolled back when t completes. It is a lighter alternative to MustClone for tests calling lyspg funcs directly, which accept a tx.
+// Tests using it may run in parallel with each other, but only see their own changes, and may block each other on row locks
+func MustBeginRollback(ctx context.Context, t testing.TB, db *pgxpool.Pool) pgx.Tx {
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("db.Begin failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			t.Errorf("tx.Rollback failed: %v", err)
+		}
+	})
+
+	return tx
+}
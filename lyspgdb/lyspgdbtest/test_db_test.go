@@ -0,0 +1,72 @@
+package lyspgdbtest_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/loveyourstack/lys/internal/myapp"
+	"github.com/loveyourstack/lys/internal/sql/ddl"
+	"github.com/loveyourstack/lys/lyspgdb"
+	"github.com/loveyourstack/lys/lyspgdb/lyspgdbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestDbTemplate(t *testing.T) {
+
+	ctx := context.Background()
+	conf := myapp.MustGetConfig(t)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	tt := lyspgdbtest.NewTestDbTemplate(ddl.SQLAssets, conf.Db, conf.DbSuperUser, conf.DbOwnerUser, logger)
+
+	// each clone only sees its own changes
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := tt.MustClone(ctx, t)
+
+			_, err := db.Exec(ctx, "TRUNCATE TABLE core.archive_test;")
+			require.NoError(t, err)
+			_, err = db.Exec(ctx, "INSERT INTO core.archive_test (c_int, c_text) VALUES (1, $1);", name)
+			require.NoError(t, err)
+
+			var texts []string
+			rows, err := db.Query(ctx, "SELECT c_text FROM core.archive_test;")
+			require.NoError(t, err)
+			for rows.Next() {
+				var s string
+				require.NoError(t, rows.Scan(&s))
+				texts = append(texts, s)
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, []string{name}, texts)
+		})
+	}
+}
+
+func TestMustBeginRollback(t *testing.T) {
+
+	ctx := context.Background()
+	conf := myapp.MustGetConfig(t)
+
+	db, err := lyspgdb.GetPool(ctx, conf.Db, conf.DbOwnerUser, "test")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var countBefore int
+	require.NoError(t, db.QueryRow(ctx, "SELECT count(*) FROM core.archive_test;").Scan(&countBefore))
+
+	t.Run("insert", func(t *testing.T) {
+		tx := lyspgdbtest.MustBeginRollback(ctx, t, db)
+		_, err := tx.Exec(ctx, "INSERT INTO core.archive_test (c_int, c_text) VALUES (1, 'rolled back');")
+		require.NoError(t, err)
+	})
+
+	var countAfter int
+	require.NoError(t, db.QueryRow(ctx, "SELECT count(*) FROM core.archive_test;").Scan(&countAfter))
+	assert.Equal(t, countBefore, countAfter)
+}
@@ -0,0 +1,422 @@
+package lyspgdb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migration file names are "<version>_<name>.up.sql" and "<version>_<name>.down.sql", e.g. "0001_add_order.up.sql"
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change read from a pair of up/down SQL files
+type Migration struct {
+	Version  int64
+	Name     string
+	UpFile   string
+	DownFile string // optional: without it, the migration cannot be reverted
+	Checksum string // sha256 of the up file, before replacements
+}
+
+// MigrationStatus is the state of a migration in the db
+type MigrationStatus struct {
+	Version          int64      `json:"version"`
+	Name             string     `json:"name"`
+	Applied          bool       `json:"applied"`
+	AppliedAt        *time.Time `json:"applied_at"`
+	ChecksumMismatch bool       `json:"checksum_mismatch"` // the up file was changed after the migration was applied
+	FileMissing      bool       `json:"file_missing"`      // applied, but no longer in the migration files
+}
+
+// MigrateOptions configures a Migrator
+type MigrateOptions struct {
+	Dir          string // directory of the migration files in sqlAssets. Default "migrations"
+	DryRun       bool   // if true, the migrations which would be run are logged and returned, but not run
+	Replacements []FileReplacement
+	SchemaName   string // schema of the migrations table. Default "public"
+	TableName    string // migrations table. Default "lys_migration"
+}
+
+// Migrator applies and reverts versioned migrations. The applied versions are stored in the migrations table, which is created if needed.
+// Each migration runs in its own transaction, and a session advisory lock ensures that only one Migrator runs at a time against the db
+type Migrator struct {
+	db         *pgxpool.Pool
+	logger     *slog.Logger
+	migrations []Migration // sorted by version
+	opts       MigrateOptions
+	sqlAssets  fs.FS
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// NewMigrator reads the migration files from sqlAssets and returns a new Migrator
+func NewMigrator(db *pgxpool.Pool, sqlAssets fs.FS, logger *slog.Logger, options ...MigrateOptions) (m *Migrator, err error) {
+
+	if db == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	var opts MigrateOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Dir == "" {
+		opts.Dir = "migrations"
+	}
+	if opts.SchemaName == "" {
+		opts.SchemaName = "public"
+	}
+	if opts.TableName == "" {
+		opts.TableName = "lys_migration"
+	}
+
+	migrations, err := ReadMigrations(sqlAssets, opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadMigrations failed: %w", err)
+	}
+
+	return &Migrator{db: db, logger: logger, migrations: migrations, opts: opts, sqlAssets: sqlAssets}, nil
+}
+
+// ReadMigrations returns the migrations in dir of sqlAssets, sorted by version. Files not matching the migration file name pattern are ignored
+func ReadMigrations(sqlAssets fs.FS, dir string) (migrations []Migration, err error) {
+
+	dirEntries, err := fs.ReadDir(sqlAssets, dir)
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadDir failed: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, dirEntry := range dirEntries {
+
+		matches := migrationFileRegex.FindStringSubmatch(dirEntry.Name())
+		if dirEntry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseInt failed for file %s: %w", dirEntry.Name(), err)
+		}
+		name, direction := matches[2], matches[3]
+		filePath := path.Join(dir, dirEntry.Name())
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if mig.Name != name {
+			return nil, fmt.Errorf("version %d is used by migrations %s and %s", version, mig.Name, name)
+		}
+
+		if direction == "up" {
+			mig.UpFile = filePath
+			content, err := fs.ReadFile(sqlAssets, filePath)
+			if err != nil {
+				return nil, fmt.Errorf("fs.ReadFile failed for file %s: %w", filePath, err)
+			}
+			sum := sha256.Sum256(content)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.DownFile = filePath
+		}
+	}
+
+	for _, mig := range byVersion {
+		if mig.UpFile == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations up to and including targetVersion (0: all), in version order. It fails if an applied migration's up file was changed
+func (m *Migrator) Up(ctx context.Context, targetVersion int64) (applied []Migration, err error) {
+
+	err = m.withLock(ctx, func(conn *pgxpool.Conn, appliedMap map[int64]appliedMigration) error {
+
+		for _, mig := range m.migrations {
+			if a, ok := appliedMap[mig.Version]; ok && a.Checksum != mig.Checksum {
+				return fmt.Errorf("migration %d_%s was changed after it was applied", mig.Version, mig.Name)
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if targetVersion > 0 && mig.Version > targetVersion {
+				break
+			}
+			if _, ok := appliedMap[mig.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, mig, true); err != nil {
+				return fmt.Errorf("m.run failed: %w", err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return applied, err
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first. steps must be at least 1
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []Migration, err error) {
+
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1")
+	}
+
+	err = m.withLock(ctx, func(conn *pgxpool.Conn, appliedMap map[int64]appliedMigration) error {
+		reverted, err = m.down(ctx, conn, appliedMap, steps)
+		return err
+	})
+	if err != nil {
+		return reverted, err
+	}
+
+	return reverted, nil
+}
+
+// Redo reverts and re-applies the last applied migration, e.g. while developing it
+func (m *Migrator) Redo(ctx context.Context) (redone Migration, err error) {
+
+	err = m.withLock(ctx, func(conn *pgxpool.Conn, appliedMap map[int64]appliedMigration) error {
+
+		reverted, err := m.down(ctx, conn, appliedMap, 1)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			return fmt.Errorf("no applied migration")
+		}
+
+		redone = reverted[0]
+		if err := m.run(ctx, conn, redone, true); err != nil {
+			return fmt.Errorf("m.run failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Migration{}, err
+	}
+
+	return redone, nil
+}
+
+// Status returns the state of each migration, including applied migrations whose files are missing, sorted by version
+func (m *Migrator) Status(ctx context.Context) (statuses []MigrationStatus, err error) {
+
+	appliedMap, err := m.selectApplied(ctx, m.db)
+	if err != nil {
+		return nil, fmt.Errorf("m.selectApplied failed: %w", err)
+	}
+
+	return migrationStatuses(m.migrations, appliedMap), nil
+}
+
+func migrationStatuses(migrations []Migration, appliedMap map[int64]appliedMigration) (statuses []MigrationStatus) {
+
+	known := make(map[int64]bool)
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := appliedMap[mig.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = &a.AppliedAt
+			st.ChecksumMismatch = a.Checksum != mig.Checksum
+		}
+		statuses = append(statuses, st)
+	}
+
+	for _, a := range appliedMap {
+		if !known[a.Version] {
+			statuses = append(statuses, MigrationStatus{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: &a.AppliedAt, FileMissing: true})
+		}
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+
+	return statuses
+}
+
+// down reverts the last steps applied migrations
+func (m *Migrator) down(ctx context.Context, conn *pgxpool.Conn, appliedMap map[int64]appliedMigration, steps int) (reverted []Migration, err error) {
+
+	versions := make([]int64, 0, len(appliedMap))
+	for v := range appliedMap {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	slices.Reverse(versions)
+
+	for _, v := range versions[:min(steps, len(versions))] {
+		i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Version == v })
+		if i == -1 {
+			return reverted, fmt.Errorf("applied migration %d_%s has no files", v, appliedMap[v].Name)
+		}
+		mig := m.migrations[i]
+		if mig.DownFile == "" {
+			return reverted, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+		if err = m.run(ctx, conn, mig, false); err != nil {
+			return reverted, fmt.Errorf("m.run failed: %w", err)
+		}
+		reverted = append(reverted, mig)
+	}
+
+	return reverted, nil
+}
+
+// run applies (up) or reverts mig in a tx, and records it in the migrations table
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, mig Migration, up bool) (err error) {
+
+	fileName := mig.UpFile
+	if !up {
+		fileName = mig.DownFile
+	}
+
+	if m.opts.DryRun {
+		m.logger.Info("Dry run: would execute " + fileName)
+		return nil
+	}
+
+	rawQry, err := fs.ReadFile(m.sqlAssets, fileName)
+	if err != nil {
+		return fmt.Errorf("fs.ReadFile failed for file: %v: %w", fileName, err)
+	}
+	for _, r := range m.opts.Replacements {
+		rawQry = bytes.ReplaceAll(rawQry, []byte(r.From), []byte(r.To))
+	}
+
+	m.logger.Info("Executing " + fileName)
+	start := time.Now()
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+
+		if _, err := tx.Exec(ctx, string(rawQry)); err != nil {
+			return fmt.Errorf("tx.Exec failed for file: %v: %w", fileName, err)
+		}
+
+		if up {
+			stmt := fmt.Sprintf("INSERT INTO %s (version, name, checksum, duration_ms) VALUES ($1, $2, $3, $4);", m.tableIdent())
+			if _, err := tx.Exec(ctx, stmt, mig.Version, mig.Name, mig.Checksum, time.Since(start).Milliseconds()); err != nil {
+				return fmt.Errorf("tx.Exec (insert version) failed: %w", err)
+			}
+		} else {
+			stmt := fmt.Sprintf("DELETE FROM %s WHERE version = $1;", m.tableIdent())
+			if _, err := tx.Exec(ctx, stmt, mig.Version); err != nil {
+				return fmt.Errorf("tx.Exec (delete version) failed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.BeginFunc failed: %w", err)
+	}
+
+	return nil
+}
+
+// withLock acquires a conn, takes the advisory lock, creates the migrations table if needed, and calls f with the applied migrations
+func (m *Migrator) withLock(ctx context.Context, f func(conn *pgxpool.Conn, appliedMap map[int64]appliedMigration) error) (err error) {
+
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("m.db.Acquire failed: %w", err)
+	}
+	defer conn.Release()
+
+	// lock key is derived from the table name, so that Migrators with different tables don't block each other
+	lockKey := m.opts.SchemaName + "." + m.opts.TableName
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock(hashtext($1));", lockKey); err != nil {
+		return fmt.Errorf("conn.Exec (lock) failed: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock(hashtext($1));", lockKey); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("conn.Exec (unlock) failed: %w", unlockErr))
+		}
+	}()
+
+	if !m.opts.DryRun {
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			checksum text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now(),
+			duration_ms bigint NOT NULL DEFAULT 0
+		);`, m.tableIdent())
+		if _, err = conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("conn.Exec (create table) failed: %w", err)
+		}
+	}
+
+	appliedMap, err := m.selectApplied(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("m.selectApplied failed: %w", err)
+	}
+
+	return f(conn, appliedMap)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// selectApplied returns the applied migrations by version. If the migrations table does not exist, none are applied
+func (m *Migrator) selectApplied(ctx context.Context, db querier) (appliedMap map[int64]appliedMigration, err error) {
+
+	appliedMap = make(map[int64]appliedMigration)
+
+	var exists bool
+	if err = db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL;", m.tableIdent()).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("db.QueryRow (exists) failed: %w", err)
+	}
+	if !exists {
+		return appliedMap, nil
+	}
+
+	stmt := fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s;", m.tableIdent())
+	rows, _ := db.Query(ctx, stmt)
+	applied, err := pgx.CollectRows(rows, pgx.RowToStructByName[appliedMigration])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows failed: %w", err)
+	}
+	for _, a := range applied {
+		appliedMap[a.Version] = a
+	}
+
+	return appliedMap, nil
+}
+
+func (m *Migrator) tableIdent() string {
+	return pgx.Identifier{m.opts.SchemaName, m.opts.TableName}.Sanitize()
+}
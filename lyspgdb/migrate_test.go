@@ -0,0 +1,75 @@
+package lyspgdb
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMigrations(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"migrations/0002_add_index.up.sql":     {Data: []byte("CREATE INDEX ...;")},
+		"migrations/0001_add_order.up.sql":     {Data: []byte("CREATE TABLE ...;")},
+		"migrations/0001_add_order.down.sql":   {Data: []byte("DROP TABLE ...;")},
+		"migrations/README.md":                 {Data: []byte("ignored")},
+		"migrations/10_later.up.sql":           {Data: []byte("SELECT 1;")},
+		"migrations/not_a_migration.up.sql.gz": {Data: []byte("ignored")},
+	}
+
+	migrations, err := ReadMigrations(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "add_order", migrations[0].Name)
+	assert.Equal(t, "migrations/0001_add_order.up.sql", migrations[0].UpFile)
+	assert.Equal(t, "migrations/0001_add_order.down.sql", migrations[0].DownFile)
+	assert.Len(t, migrations[0].Checksum, 64)
+
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Empty(t, migrations[1].DownFile)
+	assert.Equal(t, int64(10), migrations[2].Version)
+
+	// down without up
+	_, err = ReadMigrations(fstest.MapFS{"migrations/0001_a.down.sql": {}}, "migrations")
+	assert.Error(t, err)
+
+	// same version, different names
+	_, err = ReadMigrations(fstest.MapFS{"migrations/0001_a.up.sql": {}, "migrations/0001_b.up.sql": {}}, "migrations")
+	assert.Error(t, err)
+}
+
+func TestMigrationStatuses(t *testing.T) {
+
+	migrations := []Migration{{Version: 1, Name: "a", Checksum: "x"}, {Version: 2, Name: "b", Checksum: "y"}, {Version: 3, Name: "c"}}
+	appliedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	appliedMap := map[int64]appliedMigration{
+		1: {Version: 1, Name: "a", Checksum: "x", AppliedAt: appliedAt},
+		2: {Version: 2, Name: "b", Checksum: "changed", AppliedAt: appliedAt},
+		0: {Version: 0, Name: "removed", AppliedAt: appliedAt},
+	}
+
+	statuses := migrationStatuses(migrations, appliedMap)
+	require.Len(t, statuses, 4)
+
+	assert.Equal(t, MigrationStatus{Version: 0, Name: "removed", Applied: true, AppliedAt: &appliedAt, FileMissing: true}, statuses[0])
+	assert.Equal(t, MigrationStatus{Version: 1, Name: "a", Applied: true, AppliedAt: &appliedAt}, statuses[1])
+	assert.True(t, statuses[2].ChecksumMismatch)
+	assert.False(t, statuses[3].Applied)
+}
+
+func TestMigratorDownSteps(t *testing.T) {
+
+	// steps are checked before the db is used
+	m := &Migrator{}
+	for _, steps := range []int{0, -1} {
+		reverted, err := m.Down(context.Background(), steps)
+		assert.Error(t, err, steps)
+		assert.Empty(t, reverted, steps)
+	}
+}
@@ -0,0 +1,2 @@
+// Package schemadiff compares the schemas of a Postgres database with those created from embedded SQL files.
+package schemadiff
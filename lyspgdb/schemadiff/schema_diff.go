@@ -0,0 +1,439 @@
+package schemadiff
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lyspgdb"
+)
+
+// schema diff object types, in the order in which their fixes should be run
+const (
+	SchemaObjTable      string = "table"
+	SchemaObjColumn     string = "column"
+	SchemaObjFunction   string = "function"
+	SchemaObjView       string = "view"
+	SchemaObjMatView    string = "materialized view"
+	SchemaObjConstraint string = "constraint"
+	SchemaObjIndex      string = "index"
+	SchemaObjTrigger    string = "trigger"
+)
+
+var schemaObjOrder = []string{SchemaObjTable, SchemaObjColumn, SchemaObjFunction, SchemaObjView, SchemaObjMatView, SchemaObjConstraint, SchemaObjIndex, SchemaObjTrigger}
+
+// schema diff changes
+const (
+	SchemaChangeChanged string = "changed" // definitions differ
+	SchemaChangeExtra   string = "extra"   // only in the target db
+	SchemaChangeMissing string = "missing" // only in the expected schema
+)
+
+// SchemaColumn is a table column in a SchemaSnapshot
+type SchemaColumn struct {
+	DataType  string `db:"data_type"` // including modifiers, e.g. "character varying(50)"
+	Default   string `db:"col_default"`
+	Generated string `db:"generated"` // generation expression of a generated column
+	Identity  string `db:"identity"`  // "a" (always), "d" (by default) or ""
+	NotNull   bool   `db:"not_null"`
+}
+
+// SchemaSnapshot contains the definitions of the objects in some schemas of a db. Keys are qualified names, e.g. "core.order.id" for a column
+type SchemaSnapshot struct {
+	Columns     map[string]SchemaColumn
+	Constraints map[string]string // pg_get_constraintdef
+	Functions   map[string]string // pg_get_functiondef. Key includes the arg types, e.g. "core.add(integer, integer)"
+	Indexes     map[string]string // pg_get_indexdef, excluding indexes of constraints
+	MatViews    map[string]string // pg_get_viewdef
+	Tables      map[string]bool
+	Triggers    map[string]string // pg_get_triggerdef
+	Views       map[string]string // pg_get_viewdef
+}
+
+// SchemaDiffItem is a difference between an expected schema and a target db
+type SchemaDiffItem struct {
+	ObjectType string   `json:"object_type"`
+	Name       string   `json:"name"`
+	Change     string   `json:"change"`
+	Expected   string   `json:"expected"`
+	Actual     string   `json:"actual"`
+	Fix        []string `json:"fix"` // stmts which would make the target match the expected schema, if they can be generated. Review before running: fixes of extra objects drop them
+}
+
+// GetSchemaSnapshot returns the definitions of the tables, columns, constraints, indexes, views, materialized views, functions and triggers in schemaNames
+func GetSchemaSnapshot(ctx context.Context, db *pgxpool.Pool, schemaNames []string) (snap SchemaSnapshot, err error) {
+
+	snap = SchemaSnapshot{
+		Columns:     make(map[string]SchemaColumn),
+		Constraints: make(map[string]string),
+		Functions:   make(map[string]string),
+		Indexes:     make(map[string]string),
+		MatViews:    make(map[string]string),
+		Tables:      make(map[string]bool),
+		Triggers:    make(map[string]string),
+		Views:       make(map[string]string),
+	}
+
+	// tables
+	stmt := `SELECT n.nspname || '.' || c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = ANY($1);`
+	tables, err := collectStrings(ctx, db, stmt, schemaNames)
+	if err != nil {
+		return SchemaSnapshot{}, fmt.Errorf("collectStrings failed (tables): %w", err)
+	}
+	for _, t := range tables {
+		snap.Tables[t] = true
+	}
+
+	// columns and FK constraints, per table
+	for _, t := range tables {
+		schemaName, tableName := splitLast(t)
+
+		cols, err := lyspg.GetTableColumns(ctx, db, schemaName, tableName)
+		if err != nil {
+			return SchemaSnapshot{}, fmt.Errorf("lyspg.GetTableColumns failed for %s: %w", t, err)
+		}
+		for _, col := range cols {
+			snap.Columns[t+"."+col.Name] = schemaColumn(col)
+		}
+
+		// one row per FK column: the definition is the same for each
+		fks, err := lyspg.GetForeignKeys(ctx, db, schemaName, tableName)
+		if err != nil {
+			return SchemaSnapshot{}, fmt.Errorf("lyspg.GetForeignKeys failed for %s: %w", t, err)
+		}
+		for _, fk := range fks {
+			snap.Constraints[t+"."+fk.ConstraintName] = fk.Definition
+		}
+	}
+
+	// key/definition queries
+	defQueries := []struct {
+		name   string
+		target map[string]string
+		stmt   string
+	}{
+		{"constraints", snap.Constraints, `SELECT n.nspname || '.' || c.relname || '.' || con.conname, pg_get_constraintdef(con.oid)
+			FROM pg_constraint con
+			JOIN pg_class c ON c.oid = con.conrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE con.contype != 'f' AND n.nspname = ANY($1);`},
+		{"functions", snap.Functions, `SELECT n.nspname || '.' || p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')', pg_get_functiondef(p.oid)
+			FROM pg_proc p
+			JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE p.prokind IN ('f', 'p') AND n.nspname = ANY($1)
+				AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e');`},
+		{"indexes", snap.Indexes, `SELECT n.nspname || '.' || ic.relname, pg_get_indexdef(i.indexrelid)
+			FROM pg_index i
+			JOIN pg_class ic ON ic.oid = i.indexrelid
+			JOIN pg_namespace n ON n.oid = ic.relnamespace
+			WHERE n.nspname = ANY($1)
+				AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = i.indexrelid AND con.contype IN ('p', 'u', 'x'));`},
+		{"triggers", snap.Triggers, `SELECT n.nspname || '.' || c.relname || '.' || t.tgname, pg_get_triggerdef(t.oid)
+			FROM pg_trigger t
+			JOIN pg_class c ON c.oid = t.tgrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE NOT t.tgisinternal AND n.nspname = ANY($1);`},
+		{"views", snap.Views, `SELECT n.nspname || '.' || c.relname, pg_get_viewdef(c.oid)
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind = 'v' AND n.nspname = ANY($1);`},
+		{"materialized views", snap.MatViews, `SELECT n.nspname || '.' || c.relname, pg_get_viewdef(c.oid)
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind = 'm' AND n.nspname = ANY($1);`},
+	}
+	for _, q := range defQueries {
+		rows, _ := db.Query(ctx, q.stmt, schemaNames)
+		var key, def string
+		_, err = pgx.ForEachRow(rows, []any{&key, &def}, func() error {
+			q.target[key] = def
+			return nil
+		})
+		if err != nil {
+			return SchemaSnapshot{}, fmt.Errorf("pgx.ForEachRow failed (%s): %w", q.name, err)
+		}
+	}
+
+	return snap, nil
+}
+
+// schemaColumn converts col to a SchemaColumn
+func schemaColumn(col lyspg.Column) SchemaColumn {
+
+	sc := SchemaColumn{
+		DataType:  col.FullDataType,
+		Default:   col.Default,
+		Generated: col.GenerationExpr,
+		NotNull:   !col.IsNullable,
+	}
+	switch col.IdentityGeneration {
+	case "ALWAYS":
+		sc.Identity = "a"
+	case "BY DEFAULT":
+		sc.Identity = "d"
+	}
+
+	return sc
+}
+
+func collectStrings(ctx context.Context, db *pgxpool.Pool, stmt string, args ...any) (res []string, err error) {
+	rows, _ := db.Query(ctx, stmt, args...)
+	res, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows failed: %w", err)
+	}
+	return res, nil
+}
+
+// DiffSchemas returns the differences between the expected snapshot and the actual (target) snapshot, sorted by object type in fix order, then name
+func DiffSchemas(expected, actual SchemaSnapshot) (items []SchemaDiffItem) {
+
+	// tables
+	for name := range expected.Tables {
+		if !actual.Tables[name] {
+			items = append(items, SchemaDiffItem{ObjectType: SchemaObjTable, Name: name, Change: SchemaChangeMissing, Fix: []string{createTableStmt(name, expected.Columns)}})
+		}
+	}
+	for name := range actual.Tables {
+		if !expected.Tables[name] {
+			items = append(items, SchemaDiffItem{ObjectType: SchemaObjTable, Name: name, Change: SchemaChangeExtra, Fix: []string{"DROP TABLE " + quoteQualified(name) + ";"}})
+		}
+	}
+
+	// columns of tables which are in both
+	for name, exp := range expected.Columns {
+		tableName, colName := splitLast(name)
+		if !actual.Tables[tableName] {
+			continue
+		}
+		act, ok := actual.Columns[name]
+		if !ok {
+			items = append(items, SchemaDiffItem{ObjectType: SchemaObjColumn, Name: name, Change: SchemaChangeMissing, Expected: describeColumn(exp),
+				Fix: []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", quoteQualified(tableName), columnDef(colName, exp))}})
+			continue
+		}
+		if exp != act {
+			items = append(items, SchemaDiffItem{ObjectType: SchemaObjColumn, Name: name, Change: SchemaChangeChanged, Expected: describeColumn(exp), Actual: describeColumn(act),
+				Fix: alterColumnStmts(tableName, colName, exp, act)})
+		}
+	}
+	for name := range actual.Columns {
+		tableName, colName := splitLast(name)
+		if !expected.Tables[tableName] {
+			continue
+		}
+		if _, ok := expected.Columns[name]; !ok {
+			items = append(items, SchemaDiffItem{ObjectType: SchemaObjColumn, Name: name, Change: SchemaChangeExtra, Actual: describeColumn(actual.Columns[name]),
+				Fix: []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", quoteQualified(tableName), pgx.Identifier{colName}.Sanitize())}})
+		}
+	}
+
+	// objects with a definition
+	items = append(items, diffDefs(SchemaObjConstraint, expected.Constraints, actual.Constraints, func(name, def string) string {
+		tableName, conName := splitLast(name)
+		return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", quoteQualified(tableName), pgx.Identifier{conName}.Sanitize(), def)
+	}, func(name string) string {
+		tableName, conName := splitLast(name)
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", quoteQualified(tableName), pgx.Identifier{conName}.Sanitize())
+	})...)
+
+	items = append(items, diffDefs(SchemaObjFunction, expected.Functions, actual.Functions, func(name, def string) string {
+		return def + ";"
+	}, func(name string) string {
+		return "DROP FUNCTION " + name + ";"
+	})...)
+
+	items = append(items, diffDefs(SchemaObjIndex, expected.Indexes, actual.Indexes, func(name, def string) string {
+		return def + ";"
+	}, func(name string) string {
+		return "DROP INDEX " + quoteQualified(name) + ";"
+	})...)
+
+	items = append(items, diffDefs(SchemaObjTrigger, expected.Triggers, actual.Triggers, func(name, def string) string {
+		return def + ";"
+	}, func(name string) string {
+		tableName, trigName := splitLast(name)
+		return fmt.Sprintf("DROP TRIGGER %s ON %s;", pgx.Identifier{trigName}.Sanitize(), quoteQualified(tableName))
+	})...)
+
+	items = append(items, diffDefs(SchemaObjView, expected.Views, actual.Views, func(name, def string) string {
+		return fmt.Sprintf("CREATE VIEW %s AS\n%s", quoteQualified(name), def)
+	}, func(name string) string {
+		return "DROP VIEW " + quoteQualified(name) + ";"
+	})...)
+
+	items = append(items, diffDefs(SchemaObjMatView, expected.MatViews, actual.MatViews, func(name, def string) string {
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n%s", quoteQualified(name), def)
+	}, func(name string) string {
+		return "DROP MATERIALIZED VIEW " + quoteQualified(name) + ";"
+	})...)
+
+	slices.SortFunc(items, func(a, b SchemaDiffItem) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(schemaObjOrder, a.ObjectType), slices.Index(schemaObjOrder, b.ObjectType)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	return items
+}
+
+// diffDefs compares objects by definition. A changed object's fix drops and re-creates it
+func diffDefs(objType string, expected, actual map[string]string, createStmt func(name, def string) string, dropStmt func(name string) string) (items []SchemaDiffItem) {
+
+	for name, exp := range expected {
+		act, ok := actual[name]
+		switch {
+		case !ok:
+			items = append(items, SchemaDiffItem{ObjectType: objType, Name: name, Change: SchemaChangeMissing, Expected: exp, Fix: []string{createStmt(name, exp)}})
+		case exp != act:
+			items = append(items, SchemaDiffItem{ObjectType: objType, Name: name, Change: SchemaChangeChanged, Expected: exp, Actual: act,
+				Fix: []string{dropStmt(name), createStmt(name, exp)}})
+		}
+	}
+	for name, act := range actual {
+		if _, ok := expected[name]; !ok {
+			items = append(items, SchemaDiffItem{ObjectType: objType, Name: name, Change: SchemaChangeExtra, Actual: act, Fix: []string{dropStmt(name)}})
+		}
+	}
+
+	return items
+}
+
+// splitLast splits "a.b.c" into "a.b" and "c"
+func splitLast(name string) (prefix, last string) {
+	i := strings.LastIndex(name, ".")
+	if i == -1 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// quoteQualified quotes each part of a qualified name, e.g. "core.order" becomes "core"."order"
+func quoteQualified(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+func describeColumn(col SchemaColumn) string {
+	s := col.DataType
+	if col.NotNull {
+		s += " NOT NULL"
+	}
+	if col.Default != "" {
+		s += " DEFAULT " + col.Default
+	}
+	switch col.Identity {
+	case "a":
+		s += " GENERATED ALWAYS AS IDENTITY"
+	case "d":
+		s += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	if col.Generated != "" {
+		s += " GENERATED ALWAYS AS (" + col.Generated + ") STORED"
+	}
+	return s
+}
+
+func columnDef(colName string, col SchemaColumn) string {
+	return pgx.Identifier{colName}.Sanitize() + " " + describeColumn(col)
+}
+
+func createTableStmt(tableName string, columns map[string]SchemaColumn) string {
+
+	var colDefs []string
+	for name, col := range columns {
+		t, colName := splitLast(name)
+		if t == tableName {
+			colDefs = append(colDefs, "  "+columnDef(colName, col))
+		}
+	}
+	slices.Sort(colDefs)
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", quoteQualified(tableName), strings.Join(colDefs, ",\n"))
+}
+
+// alterColumnStmts returns the stmts needed to change act to exp. Identity and generation changes are not generated
+func alterColumnStmts(tableName, colName string, exp, act SchemaColumn) (stmts []string) {
+
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", quoteQualified(tableName), pgx.Identifier{colName}.Sanitize())
+
+	if exp.DataType != act.DataType {
+		stmts = append(stmts, fmt.Sprintf("%s TYPE %s USING %s::%s;", prefix, exp.DataType, pgx.Identifier{colName}.Sanitize(), exp.DataType))
+	}
+	if exp.NotNull != act.NotNull {
+		if exp.NotNull {
+			stmts = append(stmts, prefix+" SET NOT NULL;")
+		} else {
+			stmts = append(stmts, prefix+" DROP NOT NULL;")
+		}
+	}
+	if exp.Default != act.Default {
+		if exp.Default != "" {
+			stmts = append(stmts, fmt.Sprintf("%s SET DEFAULT %s;", prefix, exp.Default))
+		} else {
+			stmts = append(stmts, prefix+" DROP DEFAULT;")
+		}
+	}
+
+	return stmts
+}
+
+// DiffEmbeddedSchema creates a scratch db named "<target db>_schemadiff" from sqlAssets as lyspgdb.CreateLocalDb would, and returns the differences between it and the target db in
+// the schemas of dbConf.SchemaCreationOrder. The scratch db is dropped afterwards. dbSuperUser must be able to create databases, and dbOwnerConf must own the target db objects
+func DiffEmbeddedSchema(ctx context.Context, sqlAssets embed.FS, dbConf lyspgdb.Database, dbSuperUser, dbOwnerConf lyspgdb.User, replacements []lyspgdb.FileReplacement,
+	logger *slog.Logger) (items []SchemaDiffItem, err error) {
+
+	scratchConf := dbConf
+	scratchConf.Database = dbConf.Database + "_schemadiff"
+
+	defer func() {
+		pgDbConf := lyspgdb.Database{Host: dbConf.Host, Port: dbConf.Port, Database: "postgres"}
+		pgDb, dropErr := lyspgdb.GetPool(context.Background(), pgDbConf, dbSuperUser, "DiffEmbeddedSchema func")
+		if dropErr != nil {
+			logger.Error("lyspgdb.GetPool failed, scratch db not dropped: " + dropErr.Error())
+			return
+		}
+		defer pgDb.Close()
+		if dropErr = lyspgdb.DropDb(context.Background(), pgDb, scratchConf.Database); dropErr != nil {
+			logger.Error("lyspgdb.DropDb failed for scratch db: " + dropErr.Error())
+		}
+	}()
+
+	// build scratch db. Cleanup is registered first so that a partially created scratch db is also dropped
+	if err = lyspgdb.CreateLocalDb(ctx, sqlAssets, scratchConf, dbSuperUser, dbOwnerConf, true, false, replacements, logger); err != nil {
+		return nil, fmt.Errorf("lyspgdb.CreateLocalDb failed: %w", err)
+	}
+
+	// snapshot both
+	scratchDb, err := lyspgdb.GetPool(ctx, scratchConf, dbOwnerConf, "DiffEmbeddedSchema func")
+	if err != nil {
+		return nil, fmt.Errorf("lyspgdb.GetPool failed (scratch db): %w", err)
+	}
+	defer scratchDb.Close()
+
+	expected, err := GetSchemaSnapshot(ctx, scratchDb, dbConf.SchemaCreationOrder)
+	if err != nil {
+		return nil, fmt.Errorf("GetSchemaSnapshot failed (scratch db): %w", err)
+	}
+
+	targetDb, err := lyspgdb.GetPool(ctx, dbConf, dbOwnerConf, "DiffEmbeddedSchema func")
+	if err != nil {
+		return nil, fmt.Errorf("lyspgdb.GetPool failed (target db): %w", err)
+	}
+	defer targetDb.Close()
+
+	actual, err := GetSchemaSnapshot(ctx, targetDb, dbConf.SchemaCreationOrder)
+	if err != nil {
+		return nil, fmt.Errorf("GetSchemaSnapshot failed (target db): %w", err)
+	}
+
+	return DiffSchemas(expected, actual), nil
+}
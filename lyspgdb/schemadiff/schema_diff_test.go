@@ -0,0 +1,89 @@
+package schemadiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSnapshot() SchemaSnapshot {
+	return SchemaSnapshot{
+		Columns:     make(map[string]SchemaColumn),
+		Constraints: make(map[string]string),
+		Functions:   make(map[string]string),
+		Indexes:     make(map[string]string),
+		MatViews:    make(map[string]string),
+		Tables:      make(map[string]bool),
+		Triggers:    make(map[string]string),
+		Views:       make(map[string]string),
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+
+	exp := newSnapshot()
+	exp.Tables["core.order"] = true
+	exp.Tables["core.item"] = true
+	exp.Columns["core.order.id"] = SchemaColumn{DataType: "bigint", NotNull: true, Identity: "a"}
+	exp.Columns["core.order.name"] = SchemaColumn{DataType: "character varying(50)", NotNull: true, Default: "''::character varying"}
+	exp.Columns["core.order.note"] = SchemaColumn{DataType: "text"}
+	exp.Columns["core.item.id"] = SchemaColumn{DataType: "bigint", NotNull: true}
+	exp.Constraints["core.order.order_pkey"] = "PRIMARY KEY (id)"
+	exp.Indexes["core.order_name_idx"] = "CREATE INDEX order_name_idx ON core.\"order\" USING btree (name)"
+	exp.Views["core.v_order"] = " SELECT id FROM core.\"order\";"
+
+	act := newSnapshot()
+	act.Tables["core.order"] = true
+	act.Tables["core.old"] = true
+	act.Columns["core.order.id"] = SchemaColumn{DataType: "bigint", NotNull: true, Identity: "a"}
+	act.Columns["core.order.name"] = SchemaColumn{DataType: "text"}
+	act.Columns["core.order.extra"] = SchemaColumn{DataType: "integer"}
+	act.Columns["core.old.id"] = SchemaColumn{DataType: "integer"}
+	act.Constraints["core.order.order_pkey"] = "PRIMARY KEY (id)"
+	act.Indexes["core.order_name_idx"] = "CREATE INDEX order_name_idx ON core.\"order\" USING btree (name, id)"
+	act.MatViews["core.mv_order_count"] = " SELECT count(*) AS count FROM core.\"order\";"
+	act.Triggers["core.order.t_audit"] = "CREATE TRIGGER t_audit AFTER UPDATE ON core.\"order\" FOR EACH ROW EXECUTE FUNCTION core.audit()"
+
+	items := DiffSchemas(exp, act)
+
+	type change struct{ objType, name, change string }
+	var changes []change
+	for _, item := range items {
+		changes = append(changes, change{item.ObjectType, item.Name, item.Change})
+	}
+	assert.Equal(t, []change{
+		{SchemaObjTable, "core.item", SchemaChangeMissing},
+		{SchemaObjTable, "core.old", SchemaChangeExtra},
+		{SchemaObjColumn, "core.order.extra", SchemaChangeExtra},
+		{SchemaObjColumn, "core.order.name", SchemaChangeChanged},
+		{SchemaObjColumn, "core.order.note", SchemaChangeMissing},
+		{SchemaObjView, "core.v_order", SchemaChangeMissing},
+		{SchemaObjMatView, "core.mv_order_count", SchemaChangeExtra},
+		{SchemaObjIndex, "core.order_name_idx", SchemaChangeChanged},
+		{SchemaObjTrigger, "core.order.t_audit", SchemaChangeExtra},
+	}, changes)
+
+	byName := make(map[string]SchemaDiffItem)
+	for _, item := range items {
+		byName[item.Name] = item
+	}
+
+	assert.Equal(t, []string{"CREATE TABLE \"core\".\"item\" (\n  \"id\" bigint NOT NULL\n);"}, byName["core.item"].Fix)
+	assert.Equal(t, []string{"DROP TABLE \"core\".\"old\";"}, byName["core.old"].Fix)
+	assert.Equal(t, []string{
+		"ALTER TABLE \"core\".\"order\" ALTER COLUMN \"name\" TYPE character varying(50) USING \"name\"::character varying(50);",
+		"ALTER TABLE \"core\".\"order\" ALTER COLUMN \"name\" SET NOT NULL;",
+		"ALTER TABLE \"core\".\"order\" ALTER COLUMN \"name\" SET DEFAULT ''::character varying;",
+	}, byName["core.order.name"].Fix)
+	assert.Equal(t, []string{"ALTER TABLE \"core\".\"order\" ADD COLUMN \"note\" text;"}, byName["core.order.note"].Fix)
+	assert.Equal(t, []string{"ALTER TABLE \"core\".\"order\" DROP COLUMN \"extra\";"}, byName["core.order.extra"].Fix)
+	require.Len(t, byName["core.order_name_idx"].Fix, 2)
+	assert.Equal(t, "DROP INDEX \"core\".\"order_name_idx\";", byName["core.order_name_idx"].Fix[0])
+	assert.Equal(t, []string{"CREATE VIEW \"core\".\"v_order\" AS\n SELECT id FROM core.\"order\";"}, byName["core.v_order"].Fix)
+	assert.Equal(t, []string{"DROP MATERIALIZED VIEW \"core\".\"mv_order_count\";"}, byName["core.mv_order_count"].Fix)
+	assert.Equal(t, []string{"DROP TRIGGER \"t_audit\" ON \"core\".\"order\";"}, byName["core.order.t_audit"].Fix)
+
+	// identical
+	assert.Empty(t, DiffSchemas(exp, exp))
+}
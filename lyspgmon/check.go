@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -25,6 +26,12 @@ func CheckDb(ctx context.Context, ownerDb *pgxpool.Pool, logger *slog.Logger) (e
 		return fmt.Errorf("AddMissingUpdatedAtTriggers failed: %w", err)
 	}
 
+	// add any missing live notify triggers
+	err = AddMissingLiveNotifyTriggers(ctx, ownerDb, logger)
+	if err != nil {
+		return fmt.Errorf("AddMissingLiveNotifyTriggers failed: %w", err)
+	}
+
 	// check for tables that have the t_audit_update trigger but are missing the last_user_update_by col
 	err = CheckMissingLastUserUpdateByCols(ctx, ownerDb, logger)
 	if err != nil {
@@ -46,15 +53,17 @@ func CheckDb(ctx context.Context, ownerDb *pgxpool.Pool, logger *slog.Logger) (e
 	return nil
 }
 
+// addMissingTriggers creates (or replaces) the trigger on each table returned by viewName. If the view has a trigger_args text[] column, its values are passed to the trigger func.
 func addMissingTriggers(ctx context.Context, ownerDb *pgxpool.Pool, viewName, triggerName, when, triggerFunc string, logger *slog.Logger) (err error) {
 
 	type missingTrigger struct {
-		TableSchema string `db:"table_schema"`
-		TableName   string `db:"table_name"`
+		TableSchema string   `db:"table_schema"`
+		TableName   string   `db:"table_name"`
+		TriggerArgs []string `db:"trigger_args"`
 	}
 
 	// select tables that are missing the trigger
-	stmt := fmt.Sprintf("SELECT table_schema, table_name FROM %s.%s;", pgx.Identifier{gSchemaName}.Sanitize(), pgx.Identifier{viewName}.Sanitize())
+	stmt := fmt.Sprintf("SELECT * FROM %s.%s;", pgx.Identifier{gSchemaName}.Sanitize(), pgx.Identifier{viewName}.Sanitize())
 	rows, _ := ownerDb.Query(ctx, stmt)
 	items, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[missingTrigger])
 	if err != nil {
@@ -69,14 +78,31 @@ func addMissingTriggers(ctx context.Context, ownerDb *pgxpool.Pool, viewName, tr
 	// for each table
 	for _, item := range items {
 
-		// create the trigger
-		stmt = fmt.Sprintf("CREATE TRIGGER %s %s ON %s.%s FOR EACH ROW EXECUTE PROCEDURE %s.%s();",
+		// trigger args must be string literals
+		quotedArgs := make([]string, len(item.TriggerArgs))
+		for i, arg := range item.TriggerArgs {
+			quotedArgs[i] = "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+		}
+
+		// drop any outdated trigger with the same name and create the trigger in one tx (CREATE OR REPLACE TRIGGER needs PG 14)
+		dropStmt := fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s.%s;",
+			pgx.Identifier{triggerName}.Sanitize(), pgx.Identifier{item.TableSchema}.Sanitize(), pgx.Identifier{item.TableName}.Sanitize())
+		createStmt := fmt.Sprintf("CREATE TRIGGER %s %s ON %s.%s FOR EACH ROW EXECUTE PROCEDURE %s.%s(%s);",
 			pgx.Identifier{triggerName}.Sanitize(), when,
 			pgx.Identifier{item.TableSchema}.Sanitize(), pgx.Identifier{item.TableName}.Sanitize(),
-			pgx.Identifier{gSchemaName}.Sanitize(), pgx.Identifier{triggerFunc}.Sanitize())
-		_, err = ownerDb.Exec(ctx, stmt)
+			pgx.Identifier{gSchemaName}.Sanitize(), pgx.Identifier{triggerFunc}.Sanitize(), strings.Join(quotedArgs, ", "))
+
+		err = pgx.BeginFunc(ctx, ownerDb, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, dropStmt); err != nil {
+				return lyserr.Db{Err: fmt.Errorf("tx.Exec (drop) failed: %w", err), Stmt: dropStmt}
+			}
+			if _, err := tx.Exec(ctx, createStmt); err != nil {
+				return lyserr.Db{Err: fmt.Errorf("tx.Exec (create) failed: %w", err), Stmt: createStmt}
+			}
+			return nil
+		})
 		if err != nil {
-			return lyserr.Db{Err: fmt.Errorf("ownerDb.Exec failed on %s.%s: %w", item.TableSchema, item.TableName, err), Stmt: stmt}
+			return fmt.Errorf("pgx.BeginFunc failed on %s.%s: %w", item.TableSchema, item.TableName, err)
 		}
 
 		logger.Info("created trigger", slog.String("name", triggerName), slog.String("schema", item.TableSchema), slog.String("table", item.TableName))
@@ -85,9 +111,14 @@ func addMissingTriggers(ctx context.Context, ownerDb *pgxpool.Pool, viewName, tr
 	return nil
 }
 
-// AddMissingAuditUpdateTriggers adds missing audit update triggers for all tables returned by v_missing_audit_update_trigger
+// AddMissingAuditUpdateTriggers adds missing audit triggers for all tables returned by v_missing_audit_update_trigger. The table's primary key column is passed to the trigger func
 func AddMissingAuditUpdateTriggers(ctx context.Context, ownerDb *pgxpool.Pool, logger *slog.Logger) (err error) {
-	return addMissingTriggers(ctx, ownerDb, "v_missing_audit_update_trigger", "t_audit_update", "AFTER UPDATE", "audit_update_trigger", logger)
+	return addMissingTriggers(ctx, ownerDb, "v_missing_audit_update_trigger", "t_audit_update", "AFTER INSERT OR UPDATE OR DELETE", "audit_update_trigger", logger)
+}
+
+// AddMissingLiveNotifyTriggers adds missing live notify triggers for all tables returned by v_missing_live_notify_trigger
+func AddMissingLiveNotifyTriggers(ctx context.Context, ownerDb *pgxpool.Pool, logger *slog.Logger) (err error) {
+	return addMissingTriggers(ctx, ownerDb, "v_missing_live_notify_trigger", "t_live_notify", "AFTER INSERT OR UPDATE OR DELETE", "live_notify_trigger", logger)
 }
 
 // AddMissingUpdatedAtTriggers adds missing updated_at triggers for all tables returned by v_missing_updated_at_trigger
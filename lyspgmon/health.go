@@ -0,0 +1,314 @@
+package lyspgmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+)
+
+// alert severities
+const (
+	SeverityCritical string = "critical"
+	SeverityWarning  string = "warning"
+)
+
+// alert notification statuses
+const (
+	AlertFiring   string = "firing"
+	AlertResolved string = "resolved"
+)
+
+// Finding is a problem found by a HealthRule check. Key identifies its subject, e.g. "core.order", and is used to deduplicate alerts across runs
+type Finding struct {
+	Key     string  `db:"key"`
+	Message string  `db:"message"`
+	Value   float64 `db:"value"` // the measured value, e.g. the bloat %
+}
+
+// HealthRule is evaluated by HealthMonitor on each run
+type HealthRule struct {
+	Name     string        // unique
+	Severity string        // default SeverityWarning
+	For      time.Duration // optional: a finding must be present for this long before its alert fires
+	Check    func(ctx context.Context, db *pgxpool.Pool) (findings []Finding, err error)
+}
+
+// HealthAlert is a Finding of a HealthRule which is stored in lyspgmon.health_alert while open, i.e. until the finding is no longer returned
+type HealthAlert struct {
+	Id          int64      `db:"id" json:"id"`
+	RuleName    string     `db:"rule_name" json:"rule_name"`
+	FindingKey  string     `db:"finding_key" json:"finding_key"`
+	Severity    string     `db:"severity" json:"severity"`
+	Message     string     `db:"message" json:"message"`
+	Value       float64    `db:"value" json:"value"`
+	FirstSeenAt time.Time  `db:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  time.Time  `db:"last_seen_at" json:"last_seen_at"`
+	NotifiedAt  *time.Time `db:"notified_at" json:"notified_at"` // nil if the firing notification was not sent yet
+	ResolvedAt  *time.Time `db:"resolved_at" json:"resolved_at"`
+}
+
+// AlertNotification is sent to each AlertNotifier when an alert fires or is resolved
+type AlertNotification struct {
+	Status string      `json:"status"` // AlertFiring or AlertResolved
+	Alert  HealthAlert `json:"alert"`
+}
+
+// AlertNotifier sends alert notifications, e.g. EmailNotifier or WebhookNotifier
+type AlertNotifier interface {
+	Notify(ctx context.Context, n AlertNotification) error
+}
+
+// HealthMonitorOptions configures a HealthMonitor
+type HealthMonitorOptions struct {
+	Interval  time.Duration // time between runs. Default 5 minutes
+	Notifiers []AlertNotifier
+}
+
+// HealthMonitor periodically evaluates health rules, stores their results in lyspgmon.health_alert and lyspgmon.health_rule_status, and notifies when alerts fire and are resolved.
+// An alert's firing notification is sent once. If sending fails, it is retried on the next run. A resolved notification is only sent for alerts whose firing notification was sent
+type HealthMonitor struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+	opts   HealthMonitorOptions
+	rules  []HealthRule
+}
+
+// NewHealthMonitor returns a new HealthMonitor. lyspgmon must be installed (see Install)
+func NewHealthMonitor(ownerDb *pgxpool.Pool, rules []HealthRule, logger *slog.Logger, options ...HealthMonitorOptions) (hm *HealthMonitor, err error) {
+
+	if ownerDb == nil {
+		return nil, fmt.Errorf("ownerDb is required")
+	}
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+
+	rules = slices.Clone(rules)
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Check == nil {
+			return nil, fmt.Errorf("rule %s: check is required", rule.Name)
+		}
+		switch rule.Severity {
+		case "":
+			rules[i].Severity = SeverityWarning
+		case SeverityCritical, SeverityWarning:
+		default:
+			return nil, fmt.Errorf("rule %s: invalid severity: %s", rule.Name, rule.Severity)
+		}
+	}
+
+	var opts HealthMonitorOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Minute
+	}
+
+	return &HealthMonitor{db: ownerDb, logger: logger, opts: opts, rules: rules}, nil
+}
+
+// Run evaluates all rules immediately and then on each interval. It blocks until ctx is canceled
+func (hm *HealthMonitor) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(hm.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := hm.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			hm.logger.Error("hm.RunOnce failed: " + err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce evaluates all rules. A failing rule does not prevent the others from being evaluated, and does not resolve its open alerts
+func (hm *HealthMonitor) RunOnce(ctx context.Context) (err error) {
+
+	var errs []error
+	for _, rule := range hm.rules {
+		if err := hm.evaluateRule(ctx, rule); err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (hm *HealthMonitor) evaluateRule(ctx context.Context, rule HealthRule) (err error) {
+
+	findings, checkErr := rule.Check(ctx, hm.db)
+
+	// store rule status
+	lastErr := ""
+	if checkErr != nil {
+		lastErr = checkErr.Error()
+	}
+	stmt := fmt.Sprintf(`INSERT INTO %s.health_rule_status (rule_name, last_run_at, last_err, finding_count) VALUES ($1, now(), $2, $3)
+		ON CONFLICT (rule_name) DO UPDATE SET last_run_at = EXCLUDED.last_run_at, last_err = EXCLUDED.last_err, finding_count = EXCLUDED.finding_count;`,
+		pgx.Identifier{gSchemaName}.Sanitize())
+	if _, err = hm.db.Exec(ctx, stmt, rule.Name, lastErr, len(findings)); err != nil {
+		return lyserr.Db{Err: fmt.Errorf("hm.db.Exec (rule status) failed: %w", err), Stmt: stmt}
+	}
+
+	if checkErr != nil {
+		return fmt.Errorf("rule.Check failed: %w", checkErr)
+	}
+
+	// get open alerts
+	stmt = fmt.Sprintf("SELECT * FROM %s.health_alert WHERE rule_name = $1 AND resolved_at IS NULL;", pgx.Identifier{gSchemaName}.Sanitize())
+	rows, _ := hm.db.Query(ctx, stmt, rule.Name)
+	open, err := pgx.CollectRows(rows, pgx.RowToStructByName[HealthAlert])
+	if err != nil {
+		return lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+
+	ap := planAlerts(rule, open, findings, time.Now())
+
+	// store alert changes
+	err = pgx.BeginFunc(ctx, hm.db, func(tx pgx.Tx) error {
+		return storeAlertPlan(ctx, tx, &ap)
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.BeginFunc failed: %w", err)
+	}
+
+	// notify
+	for _, a := range ap.toFire(rule.For) {
+		if !hm.notify(ctx, AlertNotification{Status: AlertFiring, Alert: a}) {
+			continue
+		}
+		stmt = fmt.Sprintf("UPDATE %s.health_alert SET notified_at = now() WHERE id = $1;", pgx.Identifier{gSchemaName}.Sanitize())
+		if _, err = hm.db.Exec(ctx, stmt, a.Id); err != nil {
+			return lyserr.Db{Err: fmt.Errorf("hm.db.Exec (notified) failed: %w", err), Stmt: stmt}
+		}
+	}
+	for _, a := range ap.resolve {
+		if a.NotifiedAt != nil {
+			hm.notify(ctx, AlertNotification{Status: AlertResolved, Alert: a})
+		}
+	}
+
+	return nil
+}
+
+// notify sends n to all notifiers and returns true if all succeeded
+func (hm *HealthMonitor) notify(ctx context.Context, n AlertNotification) (ok bool) {
+
+	ok = true
+	for _, notifier := range hm.opts.Notifiers {
+		if err := notifier.Notify(ctx, n); err != nil {
+			hm.logger.Error("notifier.Notify failed: "+err.Error(), slog.String("rule", n.Alert.RuleName), slog.String("key", n.Alert.FindingKey))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// alertPlan contains the changes to open alerts resulting from a rule's findings
+type alertPlan struct {
+	insert  []HealthAlert // new alerts
+	update  []HealthAlert // open alerts whose finding is still present
+	resolve []HealthAlert // open alerts whose finding is no longer present
+}
+
+// toFire returns the new or updated alerts which need a firing notification: those not yet notified whose finding has been present for at least forDur
+func (ap alertPlan) toFire(forDur time.Duration) (alerts []HealthAlert) {
+	for _, a := range append(slices.Clone(ap.insert), ap.update...) {
+		if a.NotifiedAt == nil && a.LastSeenAt.Sub(a.FirstSeenAt) >= forDur {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// planAlerts compares findings with the open alerts of rule
+func planAlerts(rule HealthRule, open []HealthAlert, findings []Finding, now time.Time) (ap alertPlan) {
+
+	openByKey := make(map[string]HealthAlert, len(open))
+	for _, a := range open {
+		openByKey[a.FindingKey] = a
+	}
+
+	seen := make(map[string]bool, len(findings))
+	for _, f := range findings {
+		if seen[f.Key] {
+			continue
+		}
+		seen[f.Key] = true
+
+		a, exists := openByKey[f.Key]
+		if !exists {
+			a = HealthAlert{RuleName: rule.Name, FindingKey: f.Key, Severity: rule.Severity, FirstSeenAt: now}
+		}
+		a.Message = f.Message
+		a.Value = f.Value
+		a.LastSeenAt = now
+
+		if exists {
+			ap.update = append(ap.update, a)
+		} else {
+			ap.insert = append(ap.insert, a)
+		}
+	}
+
+	for _, a := range open {
+		if !seen[a.FindingKey] {
+			resolvedAt := now
+			a.ResolvedAt = &resolvedAt
+			ap.resolve = append(ap.resolve, a)
+		}
+	}
+
+	return ap
+}
+
+// storeAlertPlan stores ap in db and sets the ids of inserted alerts
+func storeAlertPlan(ctx context.Context, tx pgx.Tx, ap *alertPlan) (err error) {
+
+	schema := pgx.Identifier{gSchemaName}.Sanitize()
+
+	insertStmt := fmt.Sprintf(`INSERT INTO %s.health_alert (rule_name, finding_key, severity, message, value, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`, schema)
+	for i, a := range ap.insert {
+		if err = tx.QueryRow(ctx, insertStmt, a.RuleName, a.FindingKey, a.Severity, a.Message, a.Value, a.FirstSeenAt, a.LastSeenAt).Scan(&ap.insert[i].Id); err != nil {
+			return lyserr.Db{Err: fmt.Errorf("tx.QueryRow (insert) failed: %w", err), Stmt: insertStmt}
+		}
+	}
+
+	updateStmt := fmt.Sprintf("UPDATE %s.health_alert SET message = $2, value = $3, last_seen_at = $4 WHERE id = $1;", schema)
+	for _, a := range ap.update {
+		if _, err = tx.Exec(ctx, updateStmt, a.Id, a.Message, a.Value, a.LastSeenAt); err != nil {
+			return lyserr.Db{Err: fmt.Errorf("tx.Exec (update) failed: %w", err), Stmt: updateStmt}
+		}
+	}
+
+	resolveStmt := fmt.Sprintf("UPDATE %s.health_alert SET resolved_at = $2 WHERE id = $1;", schema)
+	for _, a := range ap.resolve {
+		if _, err = tx.Exec(ctx, resolveStmt, a.Id, a.ResolvedAt); err != nil {
+			return lyserr.Db{Err: fmt.Errorf("tx.Exec (resolve) failed: %w", err), Stmt: resolveStmt}
+		}
+	}
+
+	return nil
+}
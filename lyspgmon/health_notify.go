@@ -0,0 +1,85 @@
+package lyspgmon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/loveyourstack/lys/lysmail"
+)
+
+// alertSubject returns a one line summary of n, e.g. "[FIRING] bloat: core.order"
+func alertSubject(n AlertNotification) string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(n.Status), n.Alert.RuleName, n.Alert.FindingKey)
+}
+
+// EmailNotifier sends alert notifications by email
+type EmailNotifier struct {
+	Smtp *lysmail.SmtpConfig
+	To   []string
+}
+
+func (en EmailNotifier) Notify(ctx context.Context, n AlertNotification) error {
+
+	body := fmt.Sprintf("<p><b>%s</b></p><p>%s</p><p>Severity: %s<br>First seen: %s<br>Last seen: %s</p>",
+		html.EscapeString(alertSubject(n)), html.EscapeString(n.Alert.Message), html.EscapeString(n.Alert.Severity),
+		n.Alert.FirstSeenAt.Format(time.RFC3339), n.Alert.LastSeenAt.Format(time.RFC3339))
+
+	err := en.Smtp.Send(en.To, nil, alertSubject(n), body)
+	if err != nil {
+		return fmt.Errorf("en.Smtp.Send failed: %w", err)
+	}
+
+	return nil
+}
+
+// WebhookNotifier POSTs alert notifications as JSON to URL
+type WebhookNotifier struct {
+	Client *http.Client // optional: default http.DefaultClient
+	Header http.Header  // optional: e.g. Authorization
+	URL    string
+}
+
+func (wn WebhookNotifier) Notify(ctx context.Context, n AlertNotification) error {
+
+	type webhookBody struct {
+		Summary string `json:"summary"`
+		AlertNotification
+	}
+	body, err := json.Marshal(webhookBody{Summary: alertSubject(n), AlertNotification: n})
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext failed: %w", err)
+	}
+	for k, vals := range wn.Header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := wn.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package lyspgmon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+)
+
+// SQLRule returns a rule whose findings are returned by stmt, which must return the columns "key", "message" and "value" (double precision)
+func SQLRule(name, severity, stmt string, args ...any) HealthRule {
+	return HealthRule{
+		Name:     name,
+		Severity: severity,
+		Check: func(ctx context.Context, db *pgxpool.Pool) (findings []Finding, err error) {
+			return queryFindings(ctx, db, stmt, args...)
+		},
+	}
+}
+
+func queryFindings(ctx context.Context, db *pgxpool.Pool, stmt string, args ...any) (findings []Finding, err error) {
+
+	rows, _ := db.Query(ctx, stmt, args...)
+	findings, err = pgx.CollectRows(rows, pgx.RowToStructByName[Finding])
+	if err != nil {
+		return nil, lyserr.Db{Err: fmt.Errorf("pgx.CollectRows failed: %w", err), Stmt: stmt}
+	}
+
+	return findings, nil
+}
+
+// BloatRule finds tables whose bloat (see v_bloat) is above minBloatPct, e.g. 50 for 1.5 times the expected size, and which waste at least minWasteBytes
+func BloatRule(minBloatPct float64, minWasteBytes int64) HealthRule {
+	stmt := fmt.Sprintf(`SELECT DISTINCT table_schema || '.' || table_name AS key,
+			format('table bloat %%s%%%%, waste %%s', round((table_bloat - 1) * 100), table_waste_pretty) AS message,
+			((table_bloat - 1) * 100)::double precision AS value
+		FROM %s.v_bloat
+		WHERE (table_bloat - 1) * 100 > $1 AND table_waste >= $2;`, pgx.Identifier{gSchemaName}.Sanitize())
+	return SQLRule("bloat", SeverityWarning, stmt, minBloatPct, minWasteBytes)
+}
+
+// DuplicateShortnameRule finds tables with the same shortname comment (see CheckDuplicateShortnames)
+func DuplicateShortnameRule() HealthRule {
+	stmt := fmt.Sprintf(`SELECT com AS key,
+			'duplicate shortname on tables: ' || string_agg(table_schema || '.' || table_name, ', ' ORDER BY table_schema, table_name) AS message,
+			count(*)::double precision AS value
+		FROM %s.v_duplicate_shortnames
+		GROUP BY com;`, pgx.Identifier{gSchemaName}.Sanitize())
+	return SQLRule("duplicate_shortname", SeverityWarning, stmt)
+}
+
+// LongQueryRule finds queries which have been active for longer than maxDuration
+func LongQueryRule(maxDuration time.Duration) HealthRule {
+	stmt := `SELECT pid::text || '@' || query_start::text AS key,
+			format('query running for %s by %s: %s', date_trunc('second', now() - query_start), usename, left(query, 200)) AS message,
+			EXTRACT(EPOCH FROM now() - query_start)::double precision AS value
+		FROM pg_stat_activity
+		WHERE state = 'active' AND pid != pg_backend_pid() AND datname = current_database()
+			AND query_start < now() - make_interval(secs => $1);`
+	return SQLRule("long_query", SeverityWarning, stmt, maxDuration.Seconds())
+}
+
+// TableGrowthRule finds tables whose total size grew by more than maxGrowthPct within the period. On each run, it stores the current table sizes in lyspgmon.health_table_size
+// and compares them with the latest sizes stored at least period ago, so it needs one period of samples before it returns findings. Samples older than 2 periods are deleted
+func TableGrowthRule(maxGrowthPct float64, period time.Duration) HealthRule {
+
+	schema := pgx.Identifier{gSchemaName}.Sanitize()
+	findStmt := fmt.Sprintf(`SELECT cur.table_schema || '.' || cur.table_name AS key,
+			format('total size grew %%s%%%% from %%s to %%s', round(100.0 * (cur.total_bytes - base.total_bytes) / base.total_bytes), pg_size_pretty(base.total_bytes), cur.total_pretty) AS message,
+			(100.0 * (cur.total_bytes - base.total_bytes) / base.total_bytes)::double precision AS value
+		FROM %[1]s.v_table_size cur
+		JOIN LATERAL (
+			SELECT total_bytes FROM %[1]s.health_table_size h
+			WHERE h.table_schema = cur.table_schema AND h.table_name = cur.table_name AND h.sampled_at <= now() - make_interval(secs => $2)
+			ORDER BY sampled_at DESC LIMIT 1
+		) base ON true
+		WHERE base.total_bytes > 0 AND 100.0 * (cur.total_bytes - base.total_bytes) / base.total_bytes > $1;`, schema)
+	sampleStmt := fmt.Sprintf("INSERT INTO %[1]s.health_table_size (table_schema, table_name, total_bytes) SELECT table_schema, table_name, total_bytes FROM %[1]s.v_table_size;", schema)
+	pruneStmt := fmt.Sprintf("DELETE FROM %s.health_table_size WHERE sampled_at < now() - make_interval(secs => $1);", schema)
+
+	return HealthRule{
+		Name:     "table_growth",
+		Severity: SeverityWarning,
+		Check: func(ctx context.Context, db *pgxpool.Pool) (findings []Finding, err error) {
+
+			findings, err = queryFindings(ctx, db, findStmt, maxGrowthPct, period.Seconds())
+			if err != nil {
+				return nil, fmt.Errorf("queryFindings failed: %w", err)
+			}
+
+			if _, err = db.Exec(ctx, sampleStmt); err != nil {
+				return nil, lyserr.Db{Err: fmt.Errorf("db.Exec (sample) failed: %w", err), Stmt: sampleStmt}
+			}
+			if _, err = db.Exec(ctx, pruneStmt, 2*period.Seconds()); err != nil {
+				return nil, lyserr.Db{Err: fmt.Errorf("db.Exec (prune) failed: %w", err), Stmt: pruneStmt}
+			}
+
+			return findings, nil
+		},
+	}
+}
+
+// UnusedIndexRule finds indexes which have been returned by v_unused_indexes for at least minDays
+func UnusedIndexRule(minDays int) HealthRule {
+	stmt := fmt.Sprintf(`SELECT table_schema || '.' || table_name || '.' || index_name AS key,
+			format('index unused: %%s scans, size %%s', index_scans, index_size_pretty) AS message,
+			index_scans::double precision AS value
+		FROM %s.v_unused_indexes;`, pgx.Identifier{gSchemaName}.Sanitize())
+	rule := SQLRule("unused_index", SeverityWarning, stmt)
+	rule.For = time.Duration(minDays) * 24 * time.Hour
+	return rule
+}
@@ -0,0 +1,106 @@
+package lyspgmon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanAlerts(t *testing.T) {
+
+	rule := HealthRule{Name: "bloat", Severity: SeverityWarning, For: time.Hour}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	notified := t0
+
+	open := []HealthAlert{
+		{Id: 1, RuleName: "bloat", FindingKey: "core.a", FirstSeenAt: t0, LastSeenAt: t0},                        // still present, now due
+		{Id: 2, RuleName: "bloat", FindingKey: "core.b", FirstSeenAt: t0, LastSeenAt: t0, NotifiedAt: &notified}, // resolved
+	}
+	findings := []Finding{
+		{Key: "core.a", Message: "a", Value: 60},
+		{Key: "core.c", Message: "c", Value: 70},
+		{Key: "core.c", Message: "c dup", Value: 70}, // ignored
+	}
+
+	now := t0.Add(2 * time.Hour)
+	ap := planAlerts(rule, open, findings, now)
+
+	require.Len(t, ap.insert, 1)
+	assert.Equal(t, "core.c", ap.insert[0].FindingKey)
+	assert.Equal(t, "c", ap.insert[0].Message)
+	assert.Equal(t, now, ap.insert[0].FirstSeenAt)
+
+	require.Len(t, ap.update, 1)
+	assert.Equal(t, int64(1), ap.update[0].Id)
+	assert.Equal(t, float64(60), ap.update[0].Value)
+	assert.Equal(t, t0, ap.update[0].FirstSeenAt)
+	assert.Equal(t, now, ap.update[0].LastSeenAt)
+
+	require.Len(t, ap.resolve, 1)
+	assert.Equal(t, int64(2), ap.resolve[0].Id)
+	assert.Equal(t, now, *ap.resolve[0].ResolvedAt)
+
+	// core.c is new, so only core.a has been present for an hour
+	fire := ap.toFire(rule.For)
+	require.Len(t, fire, 1)
+	assert.Equal(t, "core.a", fire[0].FindingKey)
+
+	// without For, both fire
+	assert.Len(t, ap.toFire(0), 2)
+
+	// already notified alerts don't fire again
+	ap.update[0].NotifiedAt = &notified
+	assert.Len(t, ap.toFire(0), 1)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	var got map[string]any
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	wn := WebhookNotifier{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer x"}}}
+	err := wn.Notify(context.Background(), AlertNotification{Status: AlertFiring, Alert: HealthAlert{RuleName: "bloat", FindingKey: "core.a"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer x", gotAuth)
+	assert.Equal(t, "[FIRING] bloat: core.a", got["summary"])
+	assert.Equal(t, AlertFiring, got["status"])
+
+	// non-2xx is an error
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+	assert.Error(t, wn.Notify(context.Background(), AlertNotification{Status: AlertResolved}))
+}
+
+func TestNewHealthMonitor_Validation(t *testing.T) {
+	// validation happens before any db access, so a zero pool is enough
+	check := func(ctx context.Context, db *pgxpool.Pool) ([]Finding, error) { return nil, nil }
+	db := &pgxpool.Pool{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewHealthMonitor(db, []HealthRule{{Name: "a", Check: check}, {Name: "a", Check: check}}, logger)
+	assert.Error(t, err, "duplicate")
+	_, err = NewHealthMonitor(db, []HealthRule{{Name: "a"}}, logger)
+	assert.Error(t, err, "no check")
+	_, err = NewHealthMonitor(db, []HealthRule{{Name: "a", Check: check, Severity: "info"}}, logger)
+	assert.Error(t, err, "invalid severity")
+
+	rules := []HealthRule{{Name: "a", Check: check}}
+	hm, err := NewHealthMonitor(db, rules, logger)
+	require.NoError(t, err)
+	assert.Equal(t, SeverityWarning, hm.rules[0].Severity)
+	assert.Empty(t, rules[0].Severity, "caller's rules not modified")
+}
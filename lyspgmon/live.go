@@ -0,0 +1,41 @@
+package lyspgmon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+)
+
+// LiveNotifyChannel is the channel on which the live_notify_trigger sends row changes as JSON, e.g. {"schema":"core","table":"order","op":"UPDATE","id":"5"}
+const LiveNotifyChannel string = "lyspgmon_live"
+
+// EnableLiveNotify registers the table in lyspgmon.live_table, so that CheckDb adds the t_live_notify trigger to it. pkCol is the primary key column, e.g. "id".
+func EnableLiveNotify(ctx context.Context, ownerDb *pgxpool.Pool, tableSchema, tableName, pkCol string) (err error) {
+
+	stmt := fmt.Sprintf(`INSERT INTO %s.live_table (table_schema, table_name, pk_col) VALUES ($1, $2, $3)
+		ON CONFLICT (table_schema, table_name) DO UPDATE SET pk_col = EXCLUDED.pk_col;`, pgx.Identifier{gSchemaName}.Sanitize())
+	if _, err = ownerDb.Exec(ctx, stmt, tableSchema, tableName, pkCol); err != nil {
+		return lyserr.Db{Err: fmt.Errorf("ownerDb.Exec failed: %w", err), Stmt: stmt}
+	}
+
+	return nil
+}
+
+// DisableLiveNotify removes the table from lyspgmon.live_table and drops its t_live_notify trigger.
+func DisableLiveNotify(ctx context.Context, ownerDb *pgxpool.Pool, tableSchema, tableName string) (err error) {
+
+	stmt := fmt.Sprintf("DELETE FROM %s.live_table WHERE table_schema = $1 AND table_name = $2;", pgx.Identifier{gSchemaName}.Sanitize())
+	if _, err = ownerDb.Exec(ctx, stmt, tableSchema, tableName); err != nil {
+		return lyserr.Db{Err: fmt.Errorf("ownerDb.Exec failed (delete): %w", err), Stmt: stmt}
+	}
+
+	stmt = fmt.Sprintf("DROP TRIGGER IF EXISTS t_live_notify ON %s.%s;", pgx.Identifier{tableSchema}.Sanitize(), pgx.Identifier{tableName}.Sanitize())
+	if _, err = ownerDb.Exec(ctx, stmt); err != nil {
+		return lyserr.Db{Err: fmt.Errorf("ownerDb.Exec failed (drop trigger): %w", err), Stmt: stmt}
+	}
+
+	return nil
+}
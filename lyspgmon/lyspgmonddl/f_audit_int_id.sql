@@ -0,0 +1,16 @@
+-- returns the primary key value p_id as bigint for lyspgmon.audit_update.affected_id, or 0 if it is not an integer (e.g. uuid or text keys)
+CREATE OR REPLACE FUNCTION lyspgmon.audit_int_id(p_id text)
+  RETURNS bigint AS
+$BODY$
+BEGIN
+
+IF p_id ~ '^-?[0-9]{1,18}$' THEN
+  RETURN p_id::bigint;
+END IF;
+
+RETURN 0;
+
+END;
+$BODY$
+  LANGUAGE plpgsql IMMUTABLE
+  COST 100;
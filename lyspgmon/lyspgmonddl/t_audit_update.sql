@@ -2,12 +2,28 @@
 CREATE TABLE IF NOT EXISTS lyspgmon.audit_update
 (
   id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
-  affected_id bigint NOT NULL,
+  affected_id bigint NOT NULL DEFAULT 0,
+  affected_id_text text NOT NULL,
   affected_at tracking_at,
   affected_by tracking_last_user_update_by,
+  affected_op text NOT NULL DEFAULT 'UPDATE' CHECK (affected_op IN ('INSERT', 'UPDATE', 'DELETE')),
   affected_schema text NOT NULL,
   affected_table text NOT NULL,
   affected_old_values jsonb NOT NULL,
   affected_new_values jsonb NOT NULL
 );
 COMMENT ON TABLE lyspgmon.audit_update IS 'shortname: mon_au';
+
+-- upgrade tables created before INSERT and DELETE were audited, or before non-integer primary keys were supported
+DO $$
+BEGIN
+  ALTER TABLE lyspgmon.audit_update ADD COLUMN IF NOT EXISTS affected_id_text text;
+  UPDATE lyspgmon.audit_update SET affected_id_text = affected_id::text WHERE affected_id_text IS NULL;
+  ALTER TABLE lyspgmon.audit_update ALTER COLUMN affected_id SET DEFAULT 0;
+  ALTER TABLE lyspgmon.audit_update ALTER COLUMN affected_id_text SET NOT NULL;
+  ALTER TABLE lyspgmon.audit_update ADD COLUMN IF NOT EXISTS affected_op text NOT NULL DEFAULT 'UPDATE' CHECK (affected_op IN ('INSERT', 'UPDATE', 'DELETE'));
+END
+$$;
+
+DROP INDEX IF EXISTS lyspgmon.audit_update_affected_record_idx;
+CREATE INDEX IF NOT EXISTS audit_update_affected_record_text_idx ON lyspgmon.audit_update (affected_schema, affected_table, affected_id_text);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS lyspgmon.health_alert
+(
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  rule_name text NOT NULL,
+  finding_key text NOT NULL,
+  severity text NOT NULL,
+  message text NOT NULL,
+  value double precision NOT NULL DEFAULT 0,
+  first_seen_at timestamptz NOT NULL,
+  last_seen_at timestamptz NOT NULL,
+  notified_at timestamptz,
+  resolved_at timestamptz
+);
+COMMENT ON TABLE lyspgmon.health_alert IS 'shortname: mon_ha';
+
+-- at most one open alert per rule and finding
+CREATE UNIQUE INDEX IF NOT EXISTS health_alert_open_idx ON lyspgmon.health_alert (rule_name, finding_key) WHERE resolved_at IS NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS lyspgmon.health_rule_status
+(
+  rule_name text PRIMARY KEY,
+  last_run_at timestamptz NOT NULL,
+  last_err text NOT NULL DEFAULT '',
+  finding_count int NOT NULL DEFAULT 0
+);
+COMMENT ON TABLE lyspgmon.health_rule_status IS 'shortname: mon_hrs';
@@ -0,0 +1,12 @@
+-- table size samples used by the table growth health rule
+CREATE TABLE IF NOT EXISTS lyspgmon.health_table_size
+(
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  table_schema text NOT NULL,
+  table_name text NOT NULL,
+  total_bytes bigint NOT NULL,
+  sampled_at timestamptz NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE lyspgmon.health_table_size IS 'shortname: mon_hts';
+
+CREATE INDEX IF NOT EXISTS health_table_size_table_idx ON lyspgmon.health_table_size (table_schema, table_name, sampled_at);
@@ -0,0 +1,10 @@
+
+CREATE TABLE IF NOT EXISTS lyspgmon.live_table
+(
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  table_schema text NOT NULL,
+  table_name text NOT NULL,
+  pk_col text NOT NULL DEFAULT 'id',
+  UNIQUE (table_schema, table_name)
+);
+COMMENT ON TABLE lyspgmon.live_table IS 'shortname: mon_lt';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS lyspgmon.slow_query
+(
+  id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  recorded_at timestamptz NOT NULL DEFAULT now(),
+  application_name text NOT NULL DEFAULT '',
+  stmt text NOT NULL,
+  params text[] NOT NULL DEFAULT '{}',
+  duration_ms double precision NOT NULL,
+  err text NOT NULL DEFAULT '',
+  plan jsonb
+);
+COMMENT ON TABLE lyspgmon.slow_query IS 'shortname: mon_sq';
+
+CREATE INDEX IF NOT EXISTS slow_query_recorded_at_idx ON lyspgmon.slow_query (recorded_at);
@@ -1,24 +1,52 @@
 
+-- TG_ARGV[0] is the name of the table's primary key column. Default 'id'
 CREATE OR REPLACE FUNCTION lyspgmon.audit_update_trigger()
   RETURNS trigger AS
 $BODY$
 DECLARE
+  v_pk_col text;
 	v_old_row jsonb;
 	v_new_row jsonb;
 	v_old_values jsonb;
   v_new_values jsonb;
 	v_count int;
-  v_id bigint;
+  v_id text;
+  v_int_id bigint;
 	v_user text;
 BEGIN
 
-IF TG_OP != 'UPDATE' THEN
+v_pk_col := COALESCE(TG_ARGV[0], 'id');
+
+IF TG_OP = 'INSERT' THEN
+
+  -- record snapshot of new row
+  v_new_row := to_jsonb(NEW);
+  v_id := v_new_row->>v_pk_col;
+  v_int_id := lyspgmon.audit_int_id(v_id);
+  v_user := COALESCE(v_new_row->>'last_user_update_by', 'Unknown');
+
+  INSERT INTO lyspgmon.audit_update (affected_op, affected_schema, affected_table, affected_old_values, affected_new_values, affected_id, affected_id_text, affected_by)
+    VALUES (TG_OP, TG_TABLE_SCHEMA, TG_RELNAME, '{}', lyspgmon.remove_jsonb_fields(v_new_row), v_int_id, v_id, v_user);
   RETURN NEW;
+
+ELSIF TG_OP = 'DELETE' THEN
+
+  -- record snapshot of old row. The deleting user is unknown unless set by the app via set_config('lyspgmon.audit_user', ...)
+  v_old_row := to_jsonb(OLD);
+  v_id := v_old_row->>v_pk_col;
+  v_int_id := lyspgmon.audit_int_id(v_id);
+  v_user := COALESCE(NULLIF(current_setting('lyspgmon.audit_user', true), ''), 'Unknown');
+
+  INSERT INTO lyspgmon.audit_update (affected_op, affected_schema, affected_table, affected_old_values, affected_new_values, affected_id, affected_id_text, affected_by)
+    VALUES (TG_OP, TG_TABLE_SCHEMA, TG_RELNAME, lyspgmon.remove_jsonb_fields(v_old_row), '{}', v_int_id, v_id, v_user);
+  RETURN OLD;
+
 END IF;
 
 -- get old row details
 v_old_row := to_jsonb(OLD);
-v_id := v_old_row->'id';
+v_id := v_old_row->>v_pk_col;
+v_int_id := lyspgmon.audit_int_id(v_id);
 
 -- remove old row columns that shouldn't be saved
 v_old_row := lyspgmon.remove_jsonb_fields(v_old_row);
@@ -43,8 +71,8 @@ END IF;
 v_old_values := lyspgmon.jsonb_diff_val(v_old_row, v_new_row);
 
 -- record change
-INSERT INTO lyspgmon.audit_update (affected_schema, affected_table, affected_old_values, affected_new_values, affected_id, affected_by)
-  VALUES (TG_TABLE_SCHEMA, TG_RELNAME, v_old_values, v_new_values, v_id, v_user);
+INSERT INTO lyspgmon.audit_update (affected_op, affected_schema, affected_table, affected_old_values, affected_new_values, affected_id, affected_id_text, affected_by)
+  VALUES (TG_OP, TG_TABLE_SCHEMA, TG_RELNAME, v_old_values, v_new_values, v_int_id, v_id, v_user);
 RETURN NEW;
 
 END;
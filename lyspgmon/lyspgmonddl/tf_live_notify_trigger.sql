@@ -0,0 +1,23 @@
+CREATE OR REPLACE FUNCTION lyspgmon.live_notify_trigger()
+  RETURNS trigger AS
+$BODY$
+DECLARE
+  v_pk_col text := COALESCE(TG_ARGV[0], 'id');
+  v_id text;
+BEGIN
+
+-- get primary key value of the affected row
+IF TG_OP = 'DELETE' THEN
+  v_id := to_jsonb(OLD)->>v_pk_col;
+ELSE
+  v_id := to_jsonb(NEW)->>v_pk_col;
+END IF;
+
+-- notify listeners such as lyslive.Manager
+PERFORM pg_notify('lyspgmon_live', json_build_object('schema', TG_TABLE_SCHEMA, 'table', TG_TABLE_NAME, 'op', TG_OP, 'id', v_id)::text);
+RETURN NULL;
+
+END;
+$BODY$
+LANGUAGE plpgsql VOLATILE
+COST 100;
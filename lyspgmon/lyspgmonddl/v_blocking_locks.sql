@@ -0,0 +1,21 @@
+DROP VIEW IF EXISTS lyspgmon.v_blocking_locks;
+
+-- one row per blocked/blocking pair. Follow blocking_pid where blocking_is_blocked is true to find the head of a lock chain
+
+CREATE VIEW lyspgmon.v_blocking_locks AS
+  SELECT
+    blocked.pid AS blocked_pid,
+    COALESCE(blocked.usename::text, '') AS blocked_user,
+    blocked.query AS blocked_query,
+    COALESCE(EXTRACT(EPOCH FROM now() - blocked.query_start), 0)::bigint AS blocked_seconds,
+    COALESCE(blocked.wait_event_type, '') AS blocked_wait_event_type,
+    blocking.pid AS blocking_pid,
+    COALESCE(blocking.usename::text, '') AS blocking_user,
+    blocking.query AS blocking_query,
+    COALESCE(blocking.state, '') AS blocking_state,
+    COALESCE(EXTRACT(EPOCH FROM now() - blocking.xact_start), 0)::bigint AS blocking_xact_seconds,
+    cardinality(pg_blocking_pids(blocking.pid)) > 0 AS blocking_is_blocked
+  FROM pg_stat_activity blocked
+  CROSS JOIN LATERAL unnest(pg_blocking_pids(blocked.pid)) AS b(pid)
+  JOIN pg_stat_activity blocking ON blocking.pid = b.pid
+  WHERE blocked.datname = current_database();
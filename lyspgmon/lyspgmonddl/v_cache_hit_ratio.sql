@@ -0,0 +1,15 @@
+DROP VIEW IF EXISTS lyspgmon.v_cache_hit_ratio;
+
+-- share of table and index block reads served from shared buffers since stats were reset. Ratios are 0 if the table was never read
+
+CREATE VIEW lyspgmon.v_cache_hit_ratio AS
+  SELECT
+    schemaname AS table_schema,
+    relname AS table_name,
+    COALESCE(heap_blks_hit, 0) AS heap_blks_hit,
+    COALESCE(heap_blks_read, 0) AS heap_blks_read,
+    COALESCE(round(heap_blks_hit::numeric / NULLIF(heap_blks_hit + heap_blks_read, 0), 4), 0)::double precision AS heap_hit_ratio,
+    COALESCE(idx_blks_hit, 0) AS idx_blks_hit,
+    COALESCE(idx_blks_read, 0) AS idx_blks_read,
+    COALESCE(round(idx_blks_hit::numeric / NULLIF(idx_blks_hit + idx_blks_read, 0), 4), 0)::double precision AS idx_hit_ratio
+  FROM pg_statio_user_tables;
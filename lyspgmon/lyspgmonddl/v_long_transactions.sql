@@ -0,0 +1,20 @@
+DROP VIEW IF EXISTS lyspgmon.v_long_transactions;
+
+-- open transactions, oldest first. Filter on xact_seconds to find long-running ones
+
+CREATE VIEW lyspgmon.v_long_transactions AS
+  SELECT
+    pid,
+    COALESCE(usename::text, '') AS usename,
+    application_name,
+    COALESCE(client_addr::text, '') AS client_addr,
+    COALESCE(state, '') AS state,
+    COALESCE(wait_event_type, '') AS wait_event_type,
+    xact_start,
+    EXTRACT(EPOCH FROM now() - xact_start)::bigint AS xact_seconds,
+    COALESCE(EXTRACT(EPOCH FROM now() - state_change), 0)::bigint AS state_seconds,
+    query
+  FROM pg_stat_activity
+  WHERE xact_start IS NOT NULL
+    AND pid != pg_backend_pid()
+    AND datname = current_database();
@@ -11,10 +11,24 @@ CREATE VIEW lyspgmon.v_missing_audit_update_trigger AS
     AND c.table_name NOT LIKE '%\_archived'
     ORDER BY 1,2
   ), has AS (
+    -- triggers created before INSERT and DELETE were audited only fire on UPDATE, and are replaced
     SELECT event_object_schema, event_object_table
     FROM information_schema.triggers 
-    WHERE event_manipulation = 'UPDATE' AND trigger_name = 't_audit_update'
+    WHERE trigger_name = 't_audit_update'
+    GROUP BY 1,2
+    HAVING count(DISTINCT event_manipulation) = 3
+  ), pks AS (
+    -- single column primary keys
+    SELECT n.nspname AS table_schema, c.relname AS table_name, min(a.attname::text) AS pk_col
+    FROM pg_index i
+    JOIN pg_class c ON c.oid = i.indrelid
+    JOIN pg_namespace n ON n.oid = c.relnamespace
+    JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+    WHERE i.indisprimary
+    GROUP BY 1,2
+    HAVING count(*) = 1
   )
-  SELECT table_schema, table_name
+  SELECT needs.table_schema, needs.table_name, ARRAY[COALESCE(pks.pk_col, 'id')] AS trigger_args
   FROM needs
-  WHERE NOT EXISTS (SELECT 1 FROM has WHERE table_schema = has.event_object_schema AND table_name = has.event_object_table);
\ No newline at end of file
+  LEFT JOIN pks ON pks.table_schema = needs.table_schema AND pks.table_name = needs.table_name
+  WHERE NOT EXISTS (SELECT 1 FROM has WHERE needs.table_schema = has.event_object_schema AND needs.table_name = has.event_object_table);
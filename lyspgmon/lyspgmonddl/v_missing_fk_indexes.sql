@@ -0,0 +1,28 @@
+DROP VIEW IF EXISTS lyspgmon.v_missing_fk_indexes;
+
+-- foreign keys whose columns are not the leading columns of any index on the referencing table.
+-- Without such an index, deletes and key updates on the referenced table scan the referencing table
+
+CREATE VIEW lyspgmon.v_missing_fk_indexes AS
+  SELECT
+    n.nspname::text AS table_schema,
+    t.relname::text AS table_name,
+    c.conname::text AS constraint_name,
+    string_agg(a.attname::text, ', ' ORDER BY k.ord) AS fk_columns,
+    c.confrelid::regclass::text AS referenced_table,
+    pg_relation_size(t.oid) AS table_size,
+    pg_size_pretty(pg_relation_size(t.oid)) AS table_size_pretty,
+    format('CREATE INDEX ON %I.%I (%s);', n.nspname, t.relname, string_agg(quote_ident(a.attname), ', ' ORDER BY k.ord)) AS suggested_index
+  FROM pg_constraint c
+  JOIN pg_class t ON t.oid = c.conrelid
+  JOIN pg_namespace n ON n.oid = t.relnamespace
+  CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+  JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+  WHERE c.contype = 'f'
+    AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+    AND NOT EXISTS (
+      SELECT 1 FROM pg_index i
+      WHERE i.indrelid = c.conrelid
+        AND (string_to_array(i.indkey::text, ' ')::int2[])[1:cardinality(c.conkey)] @> c.conkey
+    )
+  GROUP BY n.nspname, t.relname, t.oid, c.conname, c.confrelid;
@@ -0,0 +1,16 @@
+
+DROP VIEW IF EXISTS lyspgmon.v_missing_live_notify_trigger;
+
+CREATE VIEW lyspgmon.v_missing_live_notify_trigger AS
+
+  WITH has AS (
+    SELECT DISTINCT event_object_schema, event_object_table
+    FROM information_schema.triggers 
+    WHERE trigger_name = 't_live_notify'
+  )
+  SELECT lt.table_schema, lt.table_name, ARRAY[lt.pk_col] AS trigger_args
+  FROM lyspgmon.live_table lt
+  JOIN information_schema.tables t ON t.table_schema = lt.table_schema AND t.table_name = lt.table_name
+  WHERE t.table_type = 'BASE TABLE'
+  AND NOT EXISTS (SELECT 1 FROM has WHERE lt.table_schema = has.event_object_schema AND lt.table_name = has.event_object_table)
+  ORDER BY 1,2;
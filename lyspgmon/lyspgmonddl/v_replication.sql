@@ -0,0 +1,18 @@
+DROP VIEW IF EXISTS lyspgmon.v_replication;
+
+-- standbys connected to this server. Empty on a server without standbys
+
+CREATE VIEW lyspgmon.v_replication AS
+  SELECT
+    pid,
+    COALESCE(usename::text, '') AS usename,
+    application_name,
+    COALESCE(client_addr::text, '') AS client_addr,
+    state,
+    sync_state,
+    COALESCE(pg_wal_lsn_diff(
+      CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, replay_lsn), 0)::bigint AS replay_lag_bytes,
+    COALESCE(EXTRACT(EPOCH FROM write_lag), 0)::double precision AS write_lag_seconds,
+    COALESCE(EXTRACT(EPOCH FROM flush_lag), 0)::double precision AS flush_lag_seconds,
+    COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::double precision AS replay_lag_seconds
+  FROM pg_stat_replication;
@@ -0,0 +1,16 @@
+DROP VIEW IF EXISTS lyspgmon.v_replication_slots;
+
+-- inactive slots retain WAL indefinitely and can fill the disk
+
+CREATE VIEW lyspgmon.v_replication_slots AS
+  SELECT
+    slot_name,
+    slot_type,
+    COALESCE(database, '') AS database,
+    active,
+    COALESCE(wal_status, '') AS wal_status,
+    COALESCE(pg_wal_lsn_diff(
+      CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, restart_lsn), 0)::bigint AS retained_bytes,
+    pg_size_pretty(COALESCE(pg_wal_lsn_diff(
+      CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, restart_lsn), 0)) AS retained_bytes_pretty
+  FROM pg_replication_slots;
@@ -0,0 +1,37 @@
+DROP VIEW IF EXISTS lyspgmon.v_sequence_exhaustion;
+
+-- ascending sequences and how much of their range is used. If the sequence is owned by a column of a smaller int type, the column's max value applies
+
+CREATE VIEW lyspgmon.v_sequence_exhaustion AS
+  WITH seq AS (
+    SELECT
+      s.schemaname AS sequence_schema,
+      s.sequencename AS sequence_name,
+      COALESCE(n.nspname::text, '') AS table_schema,
+      COALESCE(t.relname::text, '') AS table_name,
+      COALESCE(a.attname::text, '') AS column_name,
+      COALESCE(format_type(a.atttypid, a.atttypmod), s.data_type::text) AS data_type,
+      COALESCE(s.last_value, s.start_value - s.increment_by) AS last_value,
+      LEAST(s.max_value,
+        CASE format_type(a.atttypid, a.atttypmod) WHEN 'smallint' THEN 32767 WHEN 'integer' THEN 2147483647 ELSE s.max_value END) AS max_value
+    FROM pg_sequences s
+    JOIN pg_namespace sn ON sn.nspname = s.schemaname
+    JOIN pg_class sc ON sc.relnamespace = sn.oid AND sc.relname = s.sequencename
+    LEFT JOIN pg_depend d ON d.objid = sc.oid AND d.classid = 'pg_class'::regclass AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+    LEFT JOIN pg_class t ON t.oid = d.refobjid
+    LEFT JOIN pg_namespace n ON n.oid = t.relnamespace
+    LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+    WHERE s.increment_by > 0
+  )
+  SELECT
+    sequence_schema,
+    sequence_name,
+    table_schema,
+    table_name,
+    column_name,
+    data_type,
+    last_value,
+    max_value,
+    max_value - last_value AS remaining,
+    round(100.0 * last_value / max_value, 2)::double precision AS used_pct
+  FROM seq;
@@ -0,0 +1,15 @@
+DROP VIEW IF EXISTS lyspgmon.v_slow_queries;
+
+CREATE VIEW lyspgmon.v_slow_queries AS
+  SELECT
+    id,
+    recorded_at,
+    application_name,
+    stmt,
+    params,
+    duration_ms,
+    err,
+    plan,
+    COALESCE((plan->0->'Plan'->>'Total Cost')::double precision, 0) AS total_cost,
+    count(*) OVER (PARTITION BY stmt) AS stmt_count
+  FROM lyspgmon.slow_query;
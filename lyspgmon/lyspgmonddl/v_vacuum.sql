@@ -0,0 +1,17 @@
+DROP VIEW IF EXISTS lyspgmon.v_vacuum;
+
+CREATE VIEW lyspgmon.v_vacuum AS
+  SELECT
+    schemaname AS table_schema,
+    relname AS table_name,
+    n_live_tup AS live_rows,
+    n_dead_tup AS dead_rows,
+    COALESCE(round(100.0 * n_dead_tup / NULLIF(n_live_tup + n_dead_tup, 0), 2), 0)::double precision AS dead_pct,
+    n_mod_since_analyze AS mod_since_analyze,
+    COALESCE(GREATEST(last_vacuum, last_autovacuum), '0001-01-01 12:00:00') AS last_vacuum_any,
+    COALESCE(GREATEST(last_analyze, last_autoanalyze), '0001-01-01 12:00:00') AS last_analyze_any,
+    COALESCE(last_autovacuum, '0001-01-01 12:00:00') AS last_autovacuum,
+    COALESCE(last_autoanalyze, '0001-01-01 12:00:00') AS last_autoanalyze,
+    vacuum_count + autovacuum_count AS vacuum_count,
+    analyze_count + autoanalyze_count AS analyze_count
+  FROM pg_stat_user_tables;
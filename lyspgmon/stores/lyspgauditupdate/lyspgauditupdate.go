@@ -1,10 +1,23 @@
 package lyspgauditupdate
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
 	"github.com/loveyourstack/lys/lysmeta"
 	"github.com/loveyourstack/lys/lyspg"
 	"github.com/loveyourstack/lys/lystype"
@@ -19,19 +32,58 @@ const (
 	defaultOrderBy string = "affected_at DESC"
 )
 
+// affected ops
+const (
+	OpDelete string = "DELETE" // affected_old_values is a snapshot of the deleted row
+	OpInsert string = "INSERT" // affected_new_values is a snapshot of the inserted row
+	OpUpdate string = "UPDATE" // affected_old_values and affected_new_values contain the changed fields only
+)
+
+var (
+	ErrNotExistAsOf    = lyserr.User{Message: "record did not exist at the requested time", StatusCode: http.StatusNotFound}
+	ErrRecordDeleted   = lyserr.User{Message: "record is deleted and cannot be reverted", StatusCode: http.StatusNotFound}
+	ErrVersionNotFound = lyserr.User{Message: "version not found in record history", StatusCode: http.StatusNotFound}
+	ErrVersionOrder    = lyserr.User{Message: "from version must be before to version"}
+)
+
 // No input: records are created by t_audit_update trigger
 
 type Model struct {
 	Id                int64            `db:"id" json:"id"`
 	AffectedAt        lystype.Datetime `db:"affected_at" json:"affected_at"`
 	AffectedBy        string           `db:"affected_by" json:"affected_by"`
-	AffectedId        int64            `db:"affected_id" json:"affected_id"`
-	AffectedNewValues map[string]any   `db:"affected_new_values" json:"affected_new_values"`
-	AffectedOldValues map[string]any   `db:"affected_old_values" json:"affected_old_values"`
+	AffectedId        int64            `db:"affected_id" json:"affected_id"`           // pk value of the affected record. 0 if the pk is not an integer
+	AffectedIdText    string           `db:"affected_id_text" json:"affected_id_text"` // pk value of the affected record as text, for any pk type
+	AffectedNewValues Values           `db:"affected_new_values" json:"affected_new_values"`
+	AffectedOldValues Values           `db:"affected_old_values" json:"affected_old_values"`
+	AffectedOp        string           `db:"affected_op" json:"affected_op"`
 	AffectedSchema    string           `db:"affected_schema" json:"affected_schema"`
 	AffectedTable     string           `db:"affected_table" json:"affected_table"`
 }
 
+// Values are the values of an audited row, keyed by db name. Numbers are decoded as json.Number, so that bigint and numeric values keep their precision
+type Values map[string]any
+
+func (v *Values) UnmarshalJSON(b []byte) error {
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return fmt.Errorf("dec.Decode failed: %w", err)
+	}
+	*v = m
+	return nil
+}
+
+// FieldDiff is the change of a single field between two versions of a record
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
 var (
 	plan lysmeta.Plan
 )
@@ -62,3 +114,275 @@ func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []M
 func (s Store) SelectById(ctx context.Context, id int64) (item Model, err error) {
 	return lyspg.SelectUnique[Model](ctx, s.Db, schemaName, viewName, pkColName, id)
 }
+
+// SelectHistory returns the audit entries of a single record, oldest first
+func (s Store) SelectHistory(ctx context.Context, affectedSchema, affectedTable, affectedId string) (items []Model, err error) {
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s.%s WHERE affected_schema = $1 AND affected_table = $2 AND affected_id_text = $3 ORDER BY affected_at, id;",
+		strings.Join(plan.DbNames(), ", "), pgx.Identifier{schemaName}.Sanitize(), pgx.Identifier{viewName}.Sanitize())
+
+	return lyspg.SelectT[Model](ctx, s.Db, stmt, affectedSchema, affectedTable, affectedId)
+}
+
+// SelectDiff returns the field changes of a single record between the versions created by audit entries fromId and toId.
+// fromId may be 0 to diff from before the first entry
+func (s Store) SelectDiff(ctx context.Context, affectedSchema, affectedTable, affectedId string, fromId, toId int64) (diffs []FieldDiff, err error) {
+
+	history, err := s.SelectHistory(ctx, affectedSchema, affectedTable, affectedId)
+	if err != nil {
+		return nil, fmt.Errorf("s.SelectHistory failed: %w", err)
+	}
+
+	return DiffVersions(history, fromId, toId)
+}
+
+// DiffVersions returns the field changes in history (oldest first) after entry fromId, up to and including entry toId.
+// fromId may be 0 to diff from before the first entry
+func DiffVersions(history []Model, fromId, toId int64) (diffs []FieldDiff, err error) {
+
+	fromIdx := -1
+	if fromId != 0 {
+		fromIdx = slices.IndexFunc(history, func(m Model) bool { return m.Id == fromId })
+		if fromIdx < 0 {
+			return nil, ErrVersionNotFound
+		}
+	}
+	toIdx := slices.IndexFunc(history, func(m Model) bool { return m.Id == toId })
+	if toIdx < 0 {
+		return nil, ErrVersionNotFound
+	}
+	if toIdx <= fromIdx {
+		return nil, ErrVersionOrder
+	}
+
+	return Diff(history[fromIdx+1 : toIdx+1]), nil
+}
+
+// Diff combines consecutive audit entries (oldest first) into one change per field, sorted by field.
+// Fields which were changed and then changed back are omitted
+func Diff(entries []Model) (diffs []FieldDiff) {
+
+	diffMap := make(map[string]*FieldDiff)
+
+	for _, e := range entries {
+
+		// keys of both maps: inserts only have new values, deletes only have old values
+		fields := make([]string, 0, len(e.AffectedOldValues)+len(e.AffectedNewValues))
+		for k := range e.AffectedOldValues {
+			fields = append(fields, k)
+		}
+		for k := range e.AffectedNewValues {
+			if _, ok := e.AffectedOldValues[k]; !ok {
+				fields = append(fields, k)
+			}
+		}
+
+		for _, field := range fields {
+
+			// the old value is taken from the first entry which changed the field
+			fd, ok := diffMap[field]
+			if !ok {
+				fd = &FieldDiff{Field: field, Old: e.AffectedOldValues[field]}
+				diffMap[field] = fd
+			}
+			fd.New = e.AffectedNewValues[field]
+		}
+	}
+
+	for _, fd := range diffMap {
+		if reflect.DeepEqual(fd.Old, fd.New) {
+			continue
+		}
+		diffs = append(diffs, *fd)
+	}
+	slices.SortFunc(diffs, func(a, b FieldDiff) int { return strings.Compare(a.Field, b.Field) })
+
+	return diffs
+}
+
+// SelectCurrent returns the current row of a record as stored by the audit trigger, i.e. with db names as keys and jsonb-encoded values (see Values)
+func (s Store) SelectCurrent(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any) (row map[string]any, found bool, err error) {
+
+	stmt := fmt.Sprintf("SELECT to_jsonb(t) FROM %s.%s t WHERE %s = $1;",
+		pgx.Identifier{affectedSchema}.Sanitize(), pgx.Identifier{affectedTable}.Sanitize(), pgx.Identifier{pkColName}.Sanitize())
+
+	var values Values
+	err = s.Db.QueryRow(ctx, stmt, pkVal).Scan(&values)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, lyserr.Db{Err: fmt.Errorf("s.Db.QueryRow failed: %w", err), Stmt: stmt}
+	}
+
+	return values, true, nil
+}
+
+// SelectStateAsOf returns the state of a record at asOf, reconstructed from its current row and audit history. exists is false if the record did not exist at asOf
+func (s Store) SelectStateAsOf(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time) (state map[string]any, exists bool, err error) {
+
+	current, found, err := s.SelectCurrent(ctx, affectedSchema, affectedTable, pkColName, pkVal)
+	if err != nil {
+		return nil, false, fmt.Errorf("s.SelectCurrent failed: %w", err)
+	}
+
+	history, err := s.SelectHistory(ctx, affectedSchema, affectedTable, fmt.Sprint(pkVal))
+	if err != nil {
+		return nil, false, fmt.Errorf("s.SelectHistory failed: %w", err)
+	}
+
+	state, exists = Reconstruct(current, found, history, asOf)
+	return state, exists, nil
+}
+
+// Reconstruct returns the state of a record at asOf by undoing the entries of history (oldest first) made after asOf, starting from the current row.
+// Starting from the current row means records created before auditing was enabled can also be reconstructed.
+// Audited fields only are restored after a delete is undone, since tracking cols such as id and created_at are not stored
+func Reconstruct(current map[string]any, currentExists bool, history []Model, asOf time.Time) (state map[string]any, exists bool) {
+
+	state = maps.Clone(current)
+	exists = currentExists
+
+	for _, e := range slices.Backward(history) {
+
+		if !time.Time(e.AffectedAt).After(asOf) {
+			break
+		}
+
+		switch e.AffectedOp {
+		case OpInsert:
+			state, exists = nil, false
+		case OpDelete:
+			state, exists = maps.Clone(e.AffectedOldValues), true
+		default:
+			if state == nil {
+				state = make(map[string]any)
+			}
+			maps.Copy(state, e.AffectedOldValues)
+		}
+	}
+
+	return state, exists
+}
+
+// Revert sets the fields of a record which have changed since asOf back to their values at asOf. If field is not empty, only that field is reverted.
+// The update is made with lyspg.UpdatePartialWithExtras after converting the values to the column types, setting last_user_update_by to userName,
+// so the revert is itself audited. reverted contains the assigned values
+func (s Store) Revert(ctx context.Context, affectedSchema, affectedTable, pkColName string, pkVal any, asOf time.Time, field, userName string) (reverted map[string]any, err error) {
+
+	current, found, err := s.SelectCurrent(ctx, affectedSchema, affectedTable, pkColName, pkVal)
+	if err != nil {
+		return nil, fmt.Errorf("s.SelectCurrent failed: %w", err)
+	}
+	if !found {
+		return nil, ErrRecordDeleted
+	}
+
+	history, err := s.SelectHistory(ctx, affectedSchema, affectedTable, fmt.Sprint(pkVal))
+	if err != nil {
+		return nil, fmt.Errorf("s.SelectHistory failed: %w", err)
+	}
+
+	state, exists := Reconstruct(current, found, history, asOf)
+	if !exists {
+		return nil, ErrNotExistAsOf
+	}
+
+	reverted = RevertAssignments(current, state, pkColName)
+	if field != "" {
+		if _, ok := current[field]; !ok {
+			return nil, lyserr.User{Message: fmt.Sprintf("invalid field: %s", field)}
+		}
+		v, changed := reverted[field]
+		reverted = make(map[string]any)
+		if changed {
+			reverted[field] = v
+		}
+	}
+
+	// nothing to do
+	if len(reverted) == 0 {
+		return reverted, nil
+	}
+
+	// type the values as the table's columns, so that e.g. bigint and numeric values are assigned without loss of precision
+	assignments, err := s.typeValues(ctx, affectedSchema, affectedTable, reverted)
+	if err != nil {
+		return nil, fmt.Errorf("s.typeValues failed: %w", err)
+	}
+
+	dbNameMap := make(map[string]string, len(assignments))
+	for k := range assignments {
+		dbNameMap[k] = k
+	}
+
+	err = updatePartialWithExtras(ctx, s.Db, affectedSchema, affectedTable, pkColName, dbNameMap, assignments, pkVal, []string{"last_user_update_by"}, []any{userName})
+	if err != nil {
+		return nil, fmt.Errorf("updatePartialWithExtras failed: %w", err)
+	}
+
+	return reverted, nil
+}
+
+// typeValues returns values converted to the types of the table's columns by jsonb_populate_record
+func (s Store) typeValues(ctx context.Context, affectedSchema, affectedTable string, values map[string]any) (typed map[string]any, err error) {
+
+	valuesBytes, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal failed: %w", err)
+	}
+
+	table := pgx.Identifier{affectedSchema}.Sanitize() + "." + pgx.Identifier{affectedTable}.Sanitize()
+	cols := make([]string, 0, len(values))
+	for _, dbName := range slices.Sorted(maps.Keys(values)) {
+		cols = append(cols, "r."+pgx.Identifier{dbName}.Sanitize())
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM jsonb_populate_record(NULL::%s, $1::jsonb) r;", strings.Join(cols, ", "), table)
+	rows, _ := s.Db.Query(ctx, stmt, string(valuesBytes))
+	typed, err = pgx.CollectExactlyOneRow(rows, pgx.RowToMap)
+	if err != nil {
+		return nil, lyserr.Db{Err: fmt.Errorf("pgx.CollectExactlyOneRow failed: %w", err), Stmt: stmt}
+	}
+
+	return typed, nil
+}
+
+// updatePartialWithExtras calls lyspg.UpdatePartialWithExtras with pkVal converted to a lyspg.PrimaryKeyType
+func updatePartialWithExtras(ctx context.Context, db lyspg.PoolOrTx, schemaName, tableName, pkColName string, jsonKeyDbNameMap map[string]string, assignmentsMap map[string]any,
+	pkVal any, extraDbCols []string, extraInputVals []any) error {
+
+	if id, ok := pkVal.(uuid.UUID); ok {
+		return lyspg.UpdatePartialWithExtras(ctx, db, schemaName, tableName, pkColName, jsonKeyDbNameMap, assignmentsMap, id, extraDbCols, extraInputVals)
+	}
+
+	v := reflect.ValueOf(pkVal)
+	switch {
+	case v.CanInt():
+		return lyspg.UpdatePartialWithExtras(ctx, db, schemaName, tableName, pkColName, jsonKeyDbNameMap, assignmentsMap, v.Int(), extraDbCols, extraInputVals)
+	case v.CanUint():
+		return lyspg.UpdatePartialWithExtras(ctx, db, schemaName, tableName, pkColName, jsonKeyDbNameMap, assignmentsMap, v.Uint(), extraDbCols, extraInputVals)
+	case v.Kind() == reflect.String:
+		return lyspg.UpdatePartialWithExtras(ctx, db, schemaName, tableName, pkColName, jsonKeyDbNameMap, assignmentsMap, v.String(), extraDbCols, extraInputVals)
+	default:
+		return fmt.Errorf("unsupported pk type: %T", pkVal)
+	}
+}
+
+// RevertAssignments returns the fields of state whose values differ from current, excluding the pk, tracking cols and cols which no longer exist
+func RevertAssignments(current, state map[string]any, pkColName string) (assignments map[string]any) {
+
+	assignments = make(map[string]any)
+	for k, v := range state {
+		if k == pkColName || lyspg.TrackingColNames.Contains(k) {
+			continue
+		}
+		cur, ok := current[k]
+		if !ok || reflect.DeepEqual(cur, v) {
+			continue
+		}
+		assignments[k] = v
+	}
+
+	return assignments
+}
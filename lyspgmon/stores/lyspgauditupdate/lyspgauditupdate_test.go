@@ -0,0 +1,119 @@
+package lyspgauditupdate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loveyourstack/lys/lystype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	entries := []Model{
+		{Id: 1, AffectedOp: OpInsert, AffectedOldValues: map[string]any{}, AffectedNewValues: map[string]any{"name": "a", "qty": 1.0, "note": nil}},
+		{Id: 2, AffectedOp: OpUpdate, AffectedOldValues: map[string]any{"qty": 1.0}, AffectedNewValues: map[string]any{"qty": 2.0}},
+		{Id: 3, AffectedOp: OpUpdate, AffectedOldValues: map[string]any{"name": "a", "qty": 2.0}, AffectedNewValues: map[string]any{"name": "b", "qty": 3.0}},
+		{Id: 4, AffectedOp: OpUpdate, AffectedOldValues: map[string]any{"name": "b"}, AffectedNewValues: map[string]any{"name": "a"}},
+		{Id: 5, AffectedOp: OpDelete, AffectedOldValues: map[string]any{"name": "a", "qty": 3.0, "note": nil}, AffectedNewValues: map[string]any{}},
+	}
+
+	// insert to latest update: note is unchanged (nil to nil)
+	assert.Equal(t, []FieldDiff{{Field: "name", Old: nil, New: "a"}, {Field: "qty", Old: nil, New: 3.0}}, Diff(entries[:4]))
+
+	// updates only: name was changed back
+	assert.Equal(t, []FieldDiff{{Field: "qty", Old: 1.0, New: 3.0}}, Diff(entries[1:4]))
+
+	// delete
+	assert.Equal(t, []FieldDiff{{Field: "name", Old: "a", New: nil}, {Field: "qty", Old: 3.0, New: nil}}, Diff(entries[4:]))
+
+	assert.Empty(t, Diff(nil))
+}
+
+func TestDiffVersions(t *testing.T) {
+	history := []Model{
+		{Id: 3, AffectedOldValues: map[string]any{}, AffectedNewValues: map[string]any{"name": "a"}},
+		{Id: 7, AffectedOldValues: map[string]any{"name": "a"}, AffectedNewValues: map[string]any{"name": "b"}},
+	}
+
+	diffs, err := DiffVersions(history, 3, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []FieldDiff{{Field: "name", Old: "a", New: "b"}}, diffs)
+
+	diffs, err = DiffVersions(history, 0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []FieldDiff{{Field: "name", Old: nil, New: "a"}}, diffs)
+
+	_, err = DiffVersions(history, 7, 3)
+	assert.ErrorIs(t, err, ErrVersionOrder)
+	_, err = DiffVersions(history, 7, 7)
+	assert.ErrorIs(t, err, ErrVersionOrder)
+	_, err = DiffVersions(history, 4, 7)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	_, err = DiffVersions(history, 0, 8)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func TestReconstruct(t *testing.T) {
+	day := func(d int) lystype.Datetime { return lystype.Datetime(time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC)) }
+	asOf := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	history := []Model{
+		{AffectedAt: day(1), AffectedOp: OpInsert, AffectedOldValues: map[string]any{}, AffectedNewValues: map[string]any{"name": "a", "qty": 1.0}},
+		{AffectedAt: day(3), AffectedOp: OpUpdate, AffectedOldValues: map[string]any{"qty": 1.0}, AffectedNewValues: map[string]any{"qty": 2.0}},
+		{AffectedAt: day(5), AffectedOp: OpDelete, AffectedOldValues: map[string]any{"name": "a", "qty": 2.0}, AffectedNewValues: map[string]any{}},
+	}
+
+	// currently deleted
+	state, exists := Reconstruct(nil, false, history, asOf(6))
+	assert.False(t, exists)
+	assert.Nil(t, state)
+
+	state, exists = Reconstruct(nil, false, history, asOf(5))
+	assert.True(t, exists)
+	assert.Equal(t, map[string]any{"name": "a", "qty": 2.0}, state)
+
+	state, exists = Reconstruct(nil, false, history, asOf(2))
+	assert.True(t, exists)
+	assert.Equal(t, map[string]any{"name": "a", "qty": 1.0}, state)
+
+	_, exists = Reconstruct(nil, false, history, asOf(1))
+	assert.False(t, exists)
+
+	// current row is not modified, and fields not in history are kept
+	current := map[string]any{"id": 1.0, "name": "a", "qty": 2.0}
+	state, exists = Reconstruct(current, true, history[:2], asOf(2))
+	assert.True(t, exists)
+	assert.Equal(t, map[string]any{"id": 1.0, "name": "a", "qty": 1.0}, state)
+	assert.Equal(t, 2.0, current["qty"])
+
+	// record created before auditing was enabled
+	state, exists = Reconstruct(current, true, history[1:2], asOf(1))
+	assert.True(t, exists)
+	assert.Equal(t, 1.0, state["qty"])
+}
+
+func TestRevertAssignments(t *testing.T) {
+	current := map[string]any{"id": 1.0, "name": "b", "qty": 2.0, "updated_at": "2026-01-02"}
+	state := map[string]any{"id": 1.0, "name": "a", "qty": 2.0, "updated_at": "2026-01-01", "dropped_col": "x"}
+
+	assert.Equal(t, map[string]any{"name": "a"}, RevertAssignments(current, state, "id"))
+}
+
+func TestValuesUnmarshalJSON(t *testing.T) {
+	var v Values
+	err := json.Unmarshal([]byte(`{"big":9007199254740993,"amount":12.345,"tags":[1,2],"name":"a"}`), &v)
+	assert.NoError(t, err)
+
+	// numbers beyond 2^53 keep their precision
+	assert.Equal(t, json.Number("9007199254740993"), v["big"])
+	assert.Equal(t, json.Number("12.345"), v["amount"])
+	assert.Equal(t, []any{json.Number("1"), json.Number("2")}, v["tags"])
+	assert.Equal(t, "a", v["name"])
+
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"big":9007199254740993,"amount":12.345,"tags":[1,2],"name":"a"}`, string(b))
+
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &v))
+}
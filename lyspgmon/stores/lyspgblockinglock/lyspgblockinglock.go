@@ -0,0 +1,59 @@
+package lyspgblockinglock
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg blocking locks"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_blocking_locks"
+	viewName       string = "v_blocking_locks"
+	defaultOrderBy string = "blocked_seconds DESC"
+)
+
+type Model struct {
+	BlockedPid           int    `db:"blocked_pid" json:"blocked_pid"`
+	BlockedQuery         string `db:"blocked_query" json:"blocked_query"`
+	BlockedSeconds       int64  `db:"blocked_seconds" json:"blocked_seconds"`
+	BlockedUser          string `db:"blocked_user" json:"blocked_user"`
+	BlockedWaitEventType string `db:"blocked_wait_event_type" json:"blocked_wait_event_type"`
+	BlockingIsBlocked    bool   `db:"blocking_is_blocked" json:"blocking_is_blocked"` // if true, the blocking session is itself blocked: follow BlockingPid to find the head of the chain
+	BlockingPid          int    `db:"blocking_pid" json:"blocking_pid"`
+	BlockingQuery        string `db:"blocking_query" json:"blocking_query"` // last query of the blocking session, which may not be the one holding the lock
+	BlockingState        string `db:"blocking_state" json:"blocking_state"`
+	BlockingUser         string `db:"blocking_user" json:"blocking_user"`
+	BlockingXactSeconds  int64  `db:"blocking_xact_seconds" json:"blocking_xact_seconds"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
@@ -0,0 +1,56 @@
+package lyspgcachehit
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg cache hit ratio"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_cache_hit_ratio"
+	viewName       string = "v_cache_hit_ratio"
+	defaultOrderBy string = "heap_blks_read DESC"
+)
+
+type Model struct {
+	HeapBlksHit  int64   `db:"heap_blks_hit" json:"heap_blks_hit"`
+	HeapBlksRead int64   `db:"heap_blks_read" json:"heap_blks_read"`
+	HeapHitRatio float64 `db:"heap_hit_ratio" json:"heap_hit_ratio"`
+	IdxBlksHit   int64   `db:"idx_blks_hit" json:"idx_blks_hit"`
+	IdxBlksRead  int64   `db:"idx_blks_read" json:"idx_blks_read"`
+	IdxHitRatio  float64 `db:"idx_hit_ratio" json:"idx_hit_ratio"`
+	TableName    string  `db:"table_name" json:"table_name"`
+	TableSchema  string  `db:"table_schema" json:"table_schema"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
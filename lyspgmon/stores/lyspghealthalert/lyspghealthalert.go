@@ -0,0 +1,66 @@
+package lyspghealthalert
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+const (
+	name           string = "Pg health alerts"
+	schemaName     string = "lyspgmon"
+	tableName      string = "health_alert"
+	viewName       string = "health_alert"
+	pkColName      string = "id"
+	defaultOrderBy string = "last_seen_at DESC"
+)
+
+// No input: records are created by lyspgmon.HealthMonitor
+
+type Model struct {
+	Id          int64             `db:"id" json:"id"`
+	FindingKey  string            `db:"finding_key" json:"finding_key"`
+	FirstSeenAt lystype.Datetime  `db:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  lystype.Datetime  `db:"last_seen_at" json:"last_seen_at"`
+	Message     string            `db:"message" json:"message"`
+	NotifiedAt  *lystype.Datetime `db:"notified_at" json:"notified_at"`
+	ResolvedAt  *lystype.Datetime `db:"resolved_at" json:"resolved_at"` // nil while open
+	RuleName    string            `db:"rule_name" json:"rule_name"`
+	Severity    string            `db:"severity" json:"severity"`
+	Value       float64           `db:"value" json:"value"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
+
+func (s Store) SelectById(ctx context.Context, id int64) (item Model, err error) {
+	return lyspg.SelectUnique[Model](ctx, s.Db, schemaName, viewName, pkColName, id)
+}
@@ -0,0 +1,59 @@
+package lyspglongtx
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+const (
+	name           string = "Pg long-running transactions"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_long_transactions"
+	viewName       string = "v_long_transactions"
+	defaultOrderBy string = "xact_seconds DESC"
+)
+
+type Model struct {
+	ApplicationName string           `db:"application_name" json:"application_name"`
+	ClientAddr      string           `db:"client_addr" json:"client_addr"`
+	Pid             int              `db:"pid" json:"pid"`
+	Query           string           `db:"query" json:"query"`
+	State           string           `db:"state" json:"state"`                 // e.g. "idle in transaction"
+	StateSeconds    int64            `db:"state_seconds" json:"state_seconds"` // time in the current state
+	UseName         string           `db:"usename" json:"usename"`
+	WaitEventType   string           `db:"wait_event_type" json:"wait_event_type"`
+	XactSeconds     int64            `db:"xact_seconds" json:"xact_seconds"`
+	XactStart       lystype.Datetime `db:"xact_start" json:"xact_start"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
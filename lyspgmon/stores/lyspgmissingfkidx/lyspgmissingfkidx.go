@@ -0,0 +1,56 @@
+package lyspgmissingfkidx
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg missing foreign key indexes"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_missing_fk_indexes"
+	viewName       string = "v_missing_fk_indexes"
+	defaultOrderBy string = "table_size DESC"
+)
+
+type Model struct {
+	ConstraintName  string `db:"constraint_name" json:"constraint_name"`
+	FkColumns       string `db:"fk_columns" json:"fk_columns"`
+	ReferencedTable string `db:"referenced_table" json:"referenced_table"`
+	SuggestedIndex  string `db:"suggested_index" json:"suggested_index"` // CREATE INDEX stmt
+	TableName       string `db:"table_name" json:"table_name"`
+	TableSchema     string `db:"table_schema" json:"table_schema"`
+	TableSize       int64  `db:"table_size" json:"table_size"`
+	TableSizePretty string `db:"table_size_pretty" json:"table_size_pretty"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
@@ -0,0 +1,58 @@
+package lyspgreplication
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg replication"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_replication"
+	viewName       string = "v_replication"
+	defaultOrderBy string = "replay_lag_bytes DESC"
+)
+
+type Model struct {
+	ApplicationName  string  `db:"application_name" json:"application_name"`
+	ClientAddr       string  `db:"client_addr" json:"client_addr"`
+	FlushLagSeconds  float64 `db:"flush_lag_seconds" json:"flush_lag_seconds"`
+	Pid              int     `db:"pid" json:"pid"`
+	ReplayLagBytes   int64   `db:"replay_lag_bytes" json:"replay_lag_bytes"`
+	ReplayLagSeconds float64 `db:"replay_lag_seconds" json:"replay_lag_seconds"`
+	State            string  `db:"state" json:"state"`
+	SyncState        string  `db:"sync_state" json:"sync_state"`
+	UseName          string  `db:"usename" json:"usename"`
+	WriteLagSeconds  float64 `db:"write_lag_seconds" json:"write_lag_seconds"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
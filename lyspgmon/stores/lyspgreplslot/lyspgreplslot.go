@@ -0,0 +1,55 @@
+package lyspgreplslot
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg replication slots"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_replication_slots"
+	viewName       string = "v_replication_slots"
+	defaultOrderBy string = "retained_bytes DESC"
+)
+
+type Model struct {
+	Active              bool   `db:"active" json:"active"`
+	Database            string `db:"database" json:"database"`             // empty for physical slots
+	RetainedBytes       int64  `db:"retained_bytes" json:"retained_bytes"` // WAL retained for the slot
+	RetainedBytesPretty string `db:"retained_bytes_pretty" json:"retained_bytes_pretty"`
+	SlotName            string `db:"slot_name" json:"slot_name"`
+	SlotType            string `db:"slot_type" json:"slot_type"`
+	WalStatus           string `db:"wal_status" json:"wal_status"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
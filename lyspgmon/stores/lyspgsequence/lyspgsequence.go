@@ -0,0 +1,58 @@
+package lyspgsequence
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+)
+
+const (
+	name           string = "Pg sequence exhaustion"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_sequence_exhaustion"
+	viewName       string = "v_sequence_exhaustion"
+	defaultOrderBy string = "used_pct DESC"
+)
+
+type Model struct {
+	ColumnName     string  `db:"column_name" json:"column_name"` // owning column, if any
+	DataType       string  `db:"data_type" json:"data_type"`     // of the owning column if any, otherwise of the sequence
+	LastValue      int64   `db:"last_value" json:"last_value"`
+	MaxValue       int64   `db:"max_value" json:"max_value"`
+	Remaining      int64   `db:"remaining" json:"remaining"`
+	SequenceName   string  `db:"sequence_name" json:"sequence_name"`
+	SequenceSchema string  `db:"sequence_schema" json:"sequence_schema"`
+	TableName      string  `db:"table_name" json:"table_name"`
+	TableSchema    string  `db:"table_schema" json:"table_schema"`
+	UsedPct        float64 `db:"used_pct" json:"used_pct"`
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
@@ -0,0 +1,109 @@
+package lyspgslowquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+const (
+	name           string = "Pg slow queries"
+	schemaName     string = "lyspgmon"
+	tableName      string = "slow_query"
+	viewName       string = "v_slow_queries"
+	pkColName      string = "id"
+	defaultOrderBy string = "recorded_at DESC"
+)
+
+// Input is created from lyspg.SlowQuery by RecordSlowQuery
+type Input struct {
+	ApplicationName string          `db:"application_name" json:"application_name"`
+	DurationMs      float64         `db:"duration_ms" json:"duration_ms"`
+	Err             string          `db:"err" json:"err"`
+	Params          []string        `db:"params" json:"params"`
+	Plan            json.RawMessage `db:"plan" json:"plan"` // EXPLAIN (FORMAT JSON) output, or null
+	Stmt            string          `db:"stmt" json:"stmt"`
+}
+
+type Model struct {
+	Id         int64            `db:"id" json:"id"`
+	RecordedAt lystype.Datetime `db:"recorded_at" json:"recorded_at"`
+	StmtCount  int64            `db:"stmt_count" json:"stmt_count"` // number of recorded slow queries with the same stmt
+	TotalCost  float64          `db:"total_cost" json:"total_cost"` // planner's total cost estimate, or 0 if no plan
+	Input
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+// DeleteBefore deletes slow queries recorded before t, e.g. to limit the table size
+func (s Store) DeleteBefore(ctx context.Context, t time.Time) (rowsAffected int64, err error) {
+
+	stmt := fmt.Sprintf("DELETE FROM %s.%s WHERE recorded_at < $1;", pgx.Identifier{schemaName}.Sanitize(), pgx.Identifier{tableName}.Sanitize())
+	cmdTag, err := s.Db.Exec(ctx, stmt, t)
+	if err != nil {
+		return 0, lyserr.Db{Err: fmt.Errorf("s.Db.Exec failed: %w", err), Stmt: stmt}
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+// RecordSlowQuery implements lyspg.SlowQueryRecorder
+func (s Store) RecordSlowQuery(ctx context.Context, q lyspg.SlowQuery) error {
+
+	input := Input{
+		ApplicationName: q.AppName,
+		DurationMs:      float64(q.Duration) / float64(time.Millisecond),
+		Err:             q.Err,
+		Params:          q.Params,
+		Plan:            q.Plan,
+		Stmt:            q.Stmt,
+	}
+	if input.Params == nil {
+		input.Params = []string{}
+	}
+
+	_, err := lyspg.Insert[Input, int64](ctx, s.Db, schemaName, tableName, pkColName, input)
+	if err != nil {
+		return fmt.Errorf("lyspg.Insert failed: %w", err)
+	}
+
+	return nil
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
+
+func (s Store) SelectById(ctx context.Context, id int64) (item Model, err error) {
+	return lyspg.SelectUnique[Model](ctx, s.Db, schemaName, viewName, pkColName, id)
+}
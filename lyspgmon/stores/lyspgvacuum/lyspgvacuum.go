@@ -0,0 +1,61 @@
+package lyspgvacuum
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lysmeta"
+	"github.com/loveyourstack/lys/lyspg"
+	"github.com/loveyourstack/lys/lystype"
+)
+
+const (
+	name           string = "Pg vacuum and analyze status"
+	schemaName     string = "lyspgmon"
+	tableName      string = "v_vacuum"
+	viewName       string = "v_vacuum"
+	defaultOrderBy string = "dead_rows DESC"
+)
+
+type Model struct {
+	AnalyzeCount    int64            `db:"analyze_count" json:"analyze_count"` // manual and auto
+	DeadPct         float64          `db:"dead_pct" json:"dead_pct"`
+	DeadRows        int64            `db:"dead_rows" json:"dead_rows"`
+	LastAnalyzeAny  lystype.Datetime `db:"last_analyze_any" json:"last_analyze_any"` // latest manual or auto analyze
+	LastAutoanalyze lystype.Datetime `db:"last_autoanalyze" json:"last_autoanalyze"`
+	LastAutovacuum  lystype.Datetime `db:"last_autovacuum" json:"last_autovacuum"`
+	LastVacuumAny   lystype.Datetime `db:"last_vacuum_any" json:"last_vacuum_any"` // latest manual or auto vacuum
+	LiveRows        int64            `db:"live_rows" json:"live_rows"`
+	ModSinceAnalyze int64            `db:"mod_since_analyze" json:"mod_since_analyze"`
+	TableName       string           `db:"table_name" json:"table_name"`
+	TableSchema     string           `db:"table_schema" json:"table_schema"`
+	VacuumCount     int64            `db:"vacuum_count" json:"vacuum_count"` // manual and auto
+}
+
+var (
+	plan lysmeta.Plan
+)
+
+func init() {
+	var err error
+	plan, err = lysmeta.Analyze(Model{})
+	if err != nil {
+		log.Fatalf("lysmeta.Analyze failed for %s.%s: %s", schemaName, tableName, err.Error())
+	}
+}
+
+type Store struct {
+	Db *pgxpool.Pool
+}
+
+func (s Store) GetName() string {
+	return name
+}
+func (s Store) GetPlan() lysmeta.Plan {
+	return plan
+}
+
+func (s Store) Select(ctx context.Context, params lyspg.SelectParams) (items []Model, unpagedCount lyspg.TotalCount, err error) {
+	return lyspg.Select[Model](ctx, s.Db, schemaName, tableName, viewName, defaultOrderBy, plan.DbNames(), params)
+}
@@ -0,0 +1,296 @@
+// Package lystrace records spans of work within a request, such as handlers and db queries, and passes them to a pluggable Exporter.
+// Trace context is propagated using the W3C traceparent header. It has no dependency on OpenTelemetry or an external collector.
+package lystrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C trace context header
+const TraceparentHeader string = "traceparent"
+
+// common span attribute keys
+const (
+	AttrDbStatement    string = "db.statement"
+	AttrDbTable        string = "db.table"
+	AttrHttpMethod     string = "http.method"
+	AttrHttpRoute      string = "http.route"
+	AttrHttpStatusCode string = "http.status_code"
+)
+
+// SpanData is a finished span, as passed to an Exporter.
+type SpanData struct {
+	TraceId      string            `json:"trace_id"`
+	SpanId       string            `json:"span_id"`
+	ParentSpanId string            `json:"parent_span_id"` // empty for a root span
+	Name         string            `json:"name"`
+	StartTime    time.Time         `json:"start_time"`
+	EndTime      time.Time         `json:"end_time"`
+	Attrs        map[string]string `json:"attrs"`
+	Err          string            `json:"err"`
+}
+
+// Duration returns the duration of the span.
+func (sd SpanData) Duration() time.Duration {
+	return sd.EndTime.Sub(sd.StartTime)
+}
+
+// Exporter receives finished spans. Export is called synchronously when a span ends, so implementations should not block.
+type Exporter interface {
+	Export(span SpanData)
+}
+
+// Tracer starts root spans. Child spans are started with Start and use the exporter of their root span.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a new Tracer which exports spans to exporter.
+func NewTracer(exporter Exporter) (t *Tracer, err error) {
+	if exporter == nil {
+		return nil, fmt.Errorf("exporter is required")
+	}
+	return &Tracer{exporter: exporter}, nil
+}
+
+// Span is an unfinished unit of work. All methods may be called on a nil Span, so callers need not check whether tracing is enabled.
+type Span struct {
+	exporter Exporter
+	mu       sync.Mutex // protects fields below
+	data     SpanData
+	ended    bool
+	sampled  bool
+}
+
+type spanCtxKey struct{}
+
+// SpanFromCtx returns the current span of ctx, or nil if none.
+func SpanFromCtx(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return s
+}
+
+// StartRoot starts a span which is the root of this process's part of the trace. If traceparent is a valid W3C traceparent header value, the span continues that trace.
+// Otherwise, a new trace is started. If no random ids can be generated, ctx is returned unchanged with a nil span.
+func (t *Tracer) StartRoot(ctx context.Context, name, traceparent string) (context.Context, *Span) {
+
+	spanId, err := randHex(8)
+	if err != nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		exporter: t.exporter,
+		data: SpanData{
+			SpanId:    spanId,
+			Name:      name,
+			StartTime: time.Now(),
+			Attrs:     make(map[string]string),
+		},
+		sampled: true,
+	}
+
+	if traceId, parentId, sampled, err := ParseTraceparent(traceparent); err == nil {
+		s.data.TraceId = traceId
+		s.data.ParentSpanId = parentId
+		s.sampled = sampled
+	} else {
+		if s.data.TraceId, err = randHex(16); err != nil {
+			return ctx, nil
+		}
+	}
+
+	return context.WithValue(ctx, spanCtxKey{}, s), s
+}
+
+// Start starts a child span of the current span of ctx. If ctx has no span, tracing is not enabled for this request: ctx is returned unchanged with a nil span.
+// The same applies if no random span id can be generated.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+
+	parent := SpanFromCtx(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	spanId, err := randHex(8)
+	if err != nil {
+		return ctx, nil
+	}
+
+	parent.mu.Lock()
+	traceId, parentId := parent.data.TraceId, parent.data.SpanId
+	parent.mu.Unlock()
+
+	s := &Span{
+		exporter: parent.exporter,
+		data: SpanData{
+			TraceId:      traceId,
+			SpanId:       spanId,
+			ParentSpanId: parentId,
+			Name:         name,
+			StartTime:    time.Now(),
+			Attrs:        make(map[string]string),
+		},
+		sampled: parent.sampled,
+	}
+
+	return context.WithValue(ctx, spanCtxKey{}, s), s
+}
+
+// SetAttr sets an attribute of the span.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Attrs[key] = value
+}
+
+// SetError records err on the span. A nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Err = err.Error()
+}
+
+// End finishes the span, recording err if not nil and no error was already set, and exports it if sampled. Subsequent calls have no effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	if err != nil && s.data.Err == "" {
+		s.data.Err = err.Error()
+	}
+	s.data.EndTime = time.Now()
+	data := s.data
+	data.Attrs = maps.Clone(s.data.Attrs)
+	s.mu.Unlock()
+
+	if s.sampled {
+		s.exporter.Export(data)
+	}
+}
+
+// Traceparent returns the W3C traceparent header value identifying the current span of ctx, or "" if none.
+func Traceparent(ctx context.Context) string {
+
+	s := SpanFromCtx(ctx)
+	if s == nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + s.data.TraceId + "-" + s.data.SpanId + "-" + flags
+}
+
+// Inject sets the traceparent header of an outgoing request to the current span of ctx, if any.
+func Inject(ctx context.Context, header http.Header) {
+	if tp := Traceparent(ctx); tp != "" {
+		header.Set(TraceparentHeader, tp)
+	}
+}
+
+// ParseTraceparent parses a W3C traceparent header value of the form "00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>".
+func ParseTraceparent(tp string) (traceId, parentId string, sampled bool, err error) {
+
+	parts := strings.Split(tp, "-")
+	if len(parts) < 4 {
+		return "", "", false, fmt.Errorf("invalid traceparent: expected 4 parts")
+	}
+	version, traceId, parentId, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// version ff is invalid, and version 00 must have exactly 4 parts. Later versions may append parts
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false, fmt.Errorf("invalid traceparent version")
+	}
+	if !isLowerHex(traceId, 32) || traceId == strings.Repeat("0", 32) {
+		return "", "", false, fmt.Errorf("invalid traceparent trace id")
+	}
+	if !isLowerHex(parentId, 16) || parentId == strings.Repeat("0", 16) {
+		return "", "", false, fmt.Errorf("invalid traceparent parent id")
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", false, fmt.Errorf("invalid traceparent flags")
+	}
+
+	flagBytes, _ := hex.DecodeString(flags)
+	return traceId, parentId, flagBytes[0]&1 == 1, nil
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MemoryExporter keeps finished spans in memory, e.g. for tests or a debug endpoint.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *MemoryExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Reset removes all spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// Spans returns the finished spans in the order they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	spans := make([]SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
@@ -0,0 +1,104 @@
+package lystrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+
+	traceId, parentId, sampled, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceId)
+	assert.Equal(t, "00f067aa0ba902b7", parentId)
+	assert.True(t, sampled)
+
+	_, _, sampled, err = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	assert.False(t, sampled)
+
+	// later versions may have more parts
+	_, _, _, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err)
+
+	for _, tp := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+	} {
+		_, _, _, err = ParseTraceparent(tp)
+		assert.Error(t, err, tp)
+	}
+}
+
+func TestSpans(t *testing.T) {
+
+	exp := &MemoryExporter{}
+	tracer, err := NewTracer(exp)
+	require.NoError(t, err)
+
+	ctx, root := tracer.StartRoot(context.Background(), "root", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	root.SetAttr("a", "1")
+
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("first"))
+	child.End(errors.New("second"))
+	child.End(nil) // no effect
+	root.End(nil)
+
+	spans := exp.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, "first", spans[0].Err)
+	assert.Equal(t, "root", spans[1].Name)
+	assert.Equal(t, "1", spans[1].Attrs["a"])
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].ParentSpanId)
+	assert.Equal(t, spans[1].TraceId, spans[0].TraceId)
+	assert.Equal(t, spans[1].SpanId, spans[0].ParentSpanId)
+
+	// propagation
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+spans[1].SpanId+"-01", header.Get(TraceparentHeader))
+
+	// not sampled
+	exp.Reset()
+	_, root = tracer.StartRoot(context.Background(), "root", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	root.End(nil)
+	assert.Empty(t, exp.Spans())
+
+	// new trace
+	_, root = tracer.StartRoot(context.Background(), "root", "invalid")
+	root.End(nil)
+	spans = exp.Spans()
+	require.Len(t, spans, 1)
+	assert.Len(t, spans[0].TraceId, 32)
+	assert.Empty(t, spans[0].ParentSpanId)
+}
+
+func TestNoSpan(t *testing.T) {
+
+	ctx := context.Background()
+	ctx2, span := Start(ctx, "child")
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, ctx2)
+
+	// nil span methods are no-ops
+	span.SetAttr("a", "1")
+	span.SetError(errors.New("err"))
+	span.End(nil)
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Empty(t, header.Get(TraceparentHeader))
+}
@@ -10,8 +10,51 @@ import (
 const (
 	// DatetimeFormat is the format into which Datetime values are marshalled
 	DatetimeFormat string = "2006-01-02 15:04:05-07"
+
+	// DatetimeFormatMinutes is the format into which Datetime values are marshalled if their offset is not a whole hour, e.g. "+05:30", as in Postgres output
+	DatetimeFormatMinutes string = "2006-01-02 15:04:05-07:00"
 )
 
+// datetimeParseLayouts are the layouts accepted by ParseDatetime. Layouts without offset are interpreted in the supplied location
+var datetimeParseLayouts = []struct {
+	layout    string
+	hasOffset bool
+}{
+	{"2006-01-02 15:04:05.999999999-07", true},
+	{"2006-01-02 15:04:05.999999999-07:00", true},
+	{time.RFC3339Nano, true},
+	{"2006-01-02 15:04:05.999999999", false},
+	{"2006-01-02T15:04:05.999999999", false},
+	{"2006-01-02 15:04", false},
+	{"2006-01-02T15:04", false},
+	{DateFormat, false},
+}
+
+// ParseDatetime parses s, which may be in DatetimeFormat, DatetimeFormatMinutes, RFC3339 or a format without offset such as "2006-01-02 15:04" or "2006-01-02".
+// Values without offset are interpreted in loc, or in UTC if loc is nil
+func ParseDatetime(s string, loc *time.Location) (Datetime, error) {
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	s = strings.TrimSpace(s)
+	for _, l := range datetimeParseLayouts {
+		var ti time.Time
+		var err error
+		if l.hasOffset {
+			ti, err = time.Parse(l.layout, s)
+		} else {
+			ti, err = time.ParseInLocation(l.layout, s, loc)
+		}
+		if err == nil {
+			return Datetime(ti), nil
+		}
+	}
+
+	return Datetime{}, fmt.Errorf("invalid datetime: %s", s)
+}
+
 // Datetime is an implementation of time.Time which represents datetimes exchanged via json
 type Datetime time.Time
 
@@ -24,7 +67,12 @@ func (t *Datetime) UnmarshalJSON(b []byte) error {
 	}
 	ti, err := time.Parse(DatetimeFormat, s)
 	if err != nil {
-		return fmt.Errorf("time.Parse failed: %w", err)
+		// offsets which are not a whole hour include the minutes
+		var errMinutes error
+		ti, errMinutes = time.Parse(DatetimeFormatMinutes, s)
+		if errMinutes != nil {
+			return fmt.Errorf("time.Parse failed: %w", err)
+		}
 	}
 	*t = Datetime(ti)
 	return nil
@@ -48,7 +96,7 @@ func (t *Datetime) Scan(src any) error {
 
 // MarshalJSON converts the receiver to json
 func (t Datetime) MarshalJSON() ([]byte, error) {
-	return strconv.AppendQuote(nil, t.Format(DatetimeFormat)), nil
+	return strconv.AppendQuote(nil, t.String()), nil
 }
 
 // Format is a wrapper for the same function on the underlying time.Time variable
@@ -56,13 +104,21 @@ func (t Datetime) Format(layout string) string {
 	return time.Time(t).Format(layout)
 }
 
+// In returns the Datetime with its location set to loc, for display in the user's timezone
+func (t Datetime) In(loc *time.Location) Datetime {
+	return Datetime(time.Time(t).In(loc))
+}
+
 // IsZero is a wrapper for the same function on the underlying time.Time variable
 func (t Datetime) IsZero() bool {
 	return time.Time(t).IsZero()
 }
 
-// String returns the Datetime as a string in the DatetimeFormat layout.
+// String returns the Datetime as a string in the DatetimeFormat layout, or DatetimeFormatMinutes if its offset is not a whole hour.
 func (t Datetime) String() string {
+	if _, offset := time.Time(t).Zone(); offset%3600 != 0 {
+		return t.Format(DatetimeFormatMinutes)
+	}
 	return t.Format(DatetimeFormat)
 }
 
@@ -61,3 +61,59 @@ func TestDatetimeStringAndToTime(t *testing.T) {
 	assert.Equal(t, "2024-06-15 14:30:00+01", dt.String())
 	assert.Equal(t, "2024-06-15 14:30:00+01", dt.ToTime().Format(DatetimeFormat))
 }
+
+func TestDatetimeMinutesOffset(t *testing.T) {
+
+	datetimeStr := "\"2024-06-15 14:30:00+05:30\""
+	var dt Datetime
+
+	err := dt.UnmarshalJSON([]byte(datetimeStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+
+	marshalled, err := dt.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, datetimeStr, string(marshalled), "marshalled datetime")
+}
+
+func TestDatetimeIn(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tz database not available")
+	}
+
+	dt := Datetime(time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC))
+	assert.Equal(t, "2024-06-15 10:30:00-04", dt.In(loc).String())
+	assert.Equal(t, "2024-06-15 20:00:00+05:30", dt.In(time.FixedZone("IST", 5*3600+1800)).String())
+	assert.True(t, dt.In(loc).ToTime().Equal(dt.ToTime()))
+}
+
+func TestParseDatetime(t *testing.T) {
+
+	loc := time.FixedZone("UTC-3", -3*3600)
+
+	tests := map[string]time.Time{
+		"2024-06-15 14:30:00+01":       time.Date(2024, 6, 15, 13, 30, 0, 0, time.UTC),
+		"2024-06-15 14:30:00.5+05:30":  time.Date(2024, 6, 15, 9, 0, 0, 500000000, time.UTC),
+		"2024-06-15T14:30:00Z":         time.Date(2024, 6, 15, 14, 30, 0, 0, time.UTC),
+		"2024-06-15 14:30:00":          time.Date(2024, 6, 15, 17, 30, 0, 0, time.UTC),
+		"2024-06-15T14:30":             time.Date(2024, 6, 15, 17, 30, 0, 0, time.UTC),
+		"2024-06-15":                   time.Date(2024, 6, 15, 3, 0, 0, 0, time.UTC),
+		" 2024-06-15 14:30:00.123456 ": time.Date(2024, 6, 15, 17, 30, 0, 123456000, time.UTC),
+	}
+	for s, expected := range tests {
+		dt, err := ParseDatetime(s, loc)
+		if assert.NoError(t, err, s) {
+			assert.True(t, expected.Equal(dt.ToTime()), s)
+		}
+	}
+
+	// nil location means UTC
+	dt, err := ParseDatetime("2024-06-15", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-06-15 00:00:00+00", dt.String())
+
+	for _, s := range []string{"", "2024-06", "15.06.2024", "2024-06-15 25:00"} {
+		_, err := ParseDatetime(s, loc)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,158 @@
+package lystype
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// decimalMaxExp limits the exponent of parsed decimals, so that e.g. "1e10000000" cannot allocate a huge value. It is the max scale of a Postgres numeric
+const decimalMaxExp int64 = 16383
+
+// Decimal is an arbitrary precision decimal number which represents numeric values exchanged via json without float loss.
+// It is marshalled as a json string, e.g. "1234.50", and keeps the scale of the value it was created from
+type Decimal struct {
+	s string // canonical representation, e.g. "-1234.50". Empty means zero
+}
+
+// ParseDecimal parses s, e.g. "-1234.50" or "1.5e3", into a Decimal
+func ParseDecimal(s string) (Decimal, error) {
+	coef, exp, err := parseDecimalParts(s)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return decimalFromParts(coef, exp), nil
+}
+
+// DecimalFromFloat64 returns the shortest Decimal which represents f
+func DecimalFromFloat64(f float64) Decimal {
+	d, _ := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// parseDecimalParts returns the coefficient and exponent of s, so that s = coef * 10^exp
+func parseDecimalParts(s string) (coef *big.Int, exp int32, err error) {
+
+	s = strings.TrimSpace(s)
+	mantissa, expStr, hasExp := strings.Cut(strings.ToLower(s), "e")
+
+	var exp64 int64
+	if hasExp {
+		exp64, err = strconv.ParseInt(expStr, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid decimal exponent: %s", s)
+		}
+	}
+
+	sign := ""
+	if len(mantissa) > 0 && (mantissa[0] == '-' || mantissa[0] == '+') {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := intPart + fracPart
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return nil, 0, fmt.Errorf("invalid decimal: %s", s)
+	}
+
+	coef, ok := new(big.Int).SetString(sign+digits, 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid decimal: %s", s)
+	}
+
+	exp64 -= int64(len(fracPart))
+	if exp64 > decimalMaxExp || exp64 < -decimalMaxExp {
+		return nil, 0, fmt.Errorf("decimal exponent out of range (-%d to %d): %s", decimalMaxExp, decimalMaxExp, s)
+	}
+
+	return coef, int32(exp64), nil
+}
+
+// decimalFromParts returns the Decimal coef * 10^exp
+func decimalFromParts(coef *big.Int, exp int32) Decimal {
+
+	if coef == nil {
+		coef = new(big.Int)
+	}
+
+	if exp >= 0 {
+		val := new(big.Int).Mul(coef, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+		return Decimal{s: val.String()}
+	}
+
+	digits := new(big.Int).Abs(coef).String()
+	scale := int(-exp)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if coef.Sign() < 0 {
+		sign = "-"
+	}
+
+	return Decimal{s: sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]}
+}
+
+// UnmarshalJSON converts the supplied json string or number to a Decimal and writes the result to the receiver
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	dec, err := ParseDecimal(s)
+	if err != nil {
+		return fmt.Errorf("ParseDecimal failed: %w", err)
+	}
+	*d = dec
+	return nil
+}
+
+// MarshalJSON converts the receiver to a json string
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, d.String()), nil
+}
+
+// ScanNumeric implements the pgtype.NumericScanner interface.
+func (d *Decimal) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		return fmt.Errorf("unsupported Scan source: NULL")
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("unsupported Scan source: NaN or infinity")
+	}
+	*d = decimalFromParts(v.Int, v.Exp)
+	return nil
+}
+
+// NumericValue implements the pgtype.NumericValuer interface.
+func (d Decimal) NumericValue() (pgtype.Numeric, error) {
+	coef, exp, err := parseDecimalParts(d.String())
+	if err != nil {
+		return pgtype.Numeric{}, err
+	}
+	return pgtype.Numeric{Int: coef, Exp: exp, Valid: true}, nil
+}
+
+// Float64 returns the nearest float64 value of the Decimal
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IsZero returns true if the Decimal's value is zero
+func (d Decimal) IsZero() bool {
+	return strings.Trim(d.s, "-0.") == ""
+}
+
+// String returns the Decimal without exponent, e.g. "-1234.50".
+func (d Decimal) String() string {
+	if d.s == "" {
+		return "0"
+	}
+	return d.s
+}
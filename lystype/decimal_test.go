@@ -0,0 +1,99 @@
+package lystype
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimalSuccess(t *testing.T) {
+
+	decStr := "\"12345678901234567890.123456789\""
+	var d Decimal
+
+	// test unmarshal
+	err := d.UnmarshalJSON([]byte(decStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+
+	// test marshal: no float loss
+	marshalled, err := d.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, decStr, string(marshalled), "marshalled decimal")
+
+	// test NumericValue and ScanNumeric
+	n, err := d.NumericValue()
+	require.NoError(t, err)
+	assert.Equal(t, int32(-9), n.Exp)
+
+	var d2 Decimal
+	err = d2.ScanNumeric(n)
+	assert.NoError(t, err, "ScanNumeric should not error")
+	assert.Equal(t, d, d2)
+}
+
+func TestDecimalParse(t *testing.T) {
+
+	tests := map[string]string{
+		"0":       "0",
+		"-1.50":   "-1.50",
+		"+2":      "2",
+		".5":      "0.5",
+		"1.5e3":   "1500",
+		"15E-3":   "0.015",
+		" 42.0 ":  "42.0",
+		"-0.0001": "-0.0001",
+	}
+	for s, expected := range tests {
+		d, err := ParseDecimal(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, d.String(), s)
+	}
+
+	for _, s := range []string{"", "-", "1.2.3", "1,5", "abc", "1e", "NaN"} {
+		_, err := ParseDecimal(s)
+		assert.Error(t, err, s)
+	}
+
+	// exponent limits
+	_, err := ParseDecimal("1e16383")
+	assert.NoError(t, err)
+	_, err = ParseDecimal("1e-16383")
+	assert.NoError(t, err)
+	for _, s := range []string{"1e100000", "1e-100000", "1e10000000", "0.1e-16383"} {
+		_, err := ParseDecimal(s)
+		assert.ErrorContains(t, err, "out of range", s)
+	}
+}
+
+func TestDecimalUnmarshalNumberAndNull(t *testing.T) {
+	var d Decimal
+	err := d.UnmarshalJSON([]byte("1234.5"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.5", d.String())
+	assert.Equal(t, 1234.5, d.Float64())
+
+	err = d.UnmarshalJSON([]byte("null"))
+	assert.NoError(t, err)
+	assert.True(t, d.IsZero())
+	assert.Equal(t, "0", d.String())
+}
+
+func TestDecimalScanNumericUnsupported(t *testing.T) {
+	var d Decimal
+	assert.Error(t, d.ScanNumeric(pgtype.Numeric{}))
+	assert.Error(t, d.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}))
+
+	err := d.ScanNumeric(pgtype.Numeric{Int: big.NewInt(-5), Exp: 2, Valid: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "-500", d.String())
+}
+
+func TestDecimalFromFloat64(t *testing.T) {
+	assert.Equal(t, "0.1", DecimalFromFloat64(0.1).String())
+	assert.True(t, Decimal{}.IsZero())
+	assert.True(t, DecimalFromFloat64(0).IsZero())
+	assert.False(t, DecimalFromFloat64(0.01).IsZero())
+}
@@ -1,2 +1,2 @@
-// Package lystype contains date/time types used in lys and lyspg.
+// Package lystype contains date/time, numeric, interval, range and json types used in lys and lyspg.
 package lystype
@@ -0,0 +1,194 @@
+package lystype
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Interval represents Postgres interval values exchanged via json.
+// It is marshalled as an ISO 8601 duration, e.g. "P1Y2M3DT4H5M6.5S", which Postgres also accepts as input
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+// IntervalFromDuration returns the Interval of d, which only has Microseconds
+func IntervalFromDuration(d time.Duration) Interval {
+	return Interval{Microseconds: d.Microseconds()}
+}
+
+// ParseInterval parses s, which is either an ISO 8601 duration such as "P3DT4H" or a Go duration such as "1h30m", into an Interval
+func ParseInterval(s string) (Interval, error) {
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToUpper(s), "P") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return Interval{}, fmt.Errorf("invalid interval: %s", s)
+		}
+		return IntervalFromDuration(d), nil
+	}
+
+	var iv Interval
+	inTime := false
+	rest := strings.ToUpper(s[1:])
+	if rest == "" || rest == "T" {
+		return Interval{}, fmt.Errorf("invalid interval: %s", s)
+	}
+
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+
+		// number, possibly signed and with fraction, followed by a designator
+		end := strings.IndexAny(rest, "YMWDHS")
+		if end < 1 {
+			return Interval{}, fmt.Errorf("invalid interval: %s", s)
+		}
+		num, designator := rest[:end], rest[end]
+		rest = rest[end+1:]
+
+		if inTime {
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return Interval{}, fmt.Errorf("invalid interval: %s", s)
+			}
+			switch designator {
+			case 'H':
+				iv.Microseconds += int64(math.Round(f * float64(time.Hour/time.Microsecond)))
+			case 'M':
+				iv.Microseconds += int64(math.Round(f * float64(time.Minute/time.Microsecond)))
+			case 'S':
+				iv.Microseconds += int64(math.Round(f * float64(time.Second/time.Microsecond)))
+			default:
+				return Interval{}, fmt.Errorf("invalid interval: %s", s)
+			}
+			continue
+		}
+
+		n, err := strconv.ParseInt(num, 10, 32)
+		if err != nil {
+			return Interval{}, fmt.Errorf("invalid interval: %s", s)
+		}
+		switch designator {
+		case 'Y':
+			iv.Months += int32(n) * 12
+		case 'M':
+			iv.Months += int32(n)
+		case 'W':
+			iv.Days += int32(n) * 7
+		case 'D':
+			iv.Days += int32(n)
+		default:
+			return Interval{}, fmt.Errorf("invalid interval: %s", s)
+		}
+	}
+
+	return iv, nil
+}
+
+// UnmarshalJSON converts the supplied json to an Interval and writes the result to the receiver
+func (iv *Interval) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "null" {
+		*iv = Interval{}
+		return nil
+	}
+	parsed, err := ParseInterval(s)
+	if err != nil {
+		return fmt.Errorf("ParseInterval failed: %w", err)
+	}
+	*iv = parsed
+	return nil
+}
+
+// MarshalJSON converts the receiver to json
+func (iv Interval) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, iv.String()), nil
+}
+
+// ScanInterval implements the pgtype.IntervalScanner interface.
+func (iv *Interval) ScanInterval(v pgtype.Interval) error {
+	if !v.Valid {
+		return fmt.Errorf("unsupported Scan source: NULL")
+	}
+	*iv = Interval{Months: v.Months, Days: v.Days, Microseconds: v.Microseconds}
+	return nil
+}
+
+// IntervalValue implements the pgtype.IntervalValuer interface.
+func (iv Interval) IntervalValue() (pgtype.Interval, error) {
+	return pgtype.Interval{Months: iv.Months, Days: iv.Days, Microseconds: iv.Microseconds, Valid: true}, nil
+}
+
+// Duration returns the Interval as time.Duration, counting a day as 24 hours and a month as 30 days, as Postgres does when justifying intervals
+func (iv Interval) Duration() time.Duration {
+	days := int64(iv.Months)*30 + int64(iv.Days)
+	return time.Duration(days)*24*time.Hour + time.Duration(iv.Microseconds)*time.Microsecond
+}
+
+// IsZero returns true if all parts of the Interval are zero
+func (iv Interval) IsZero() bool {
+	return iv == Interval{}
+}
+
+// String returns the Interval as an ISO 8601 duration with a sign per part, as in the Postgres iso_8601 interval style, e.g. "P-1Y-2MT3H".
+func (iv Interval) String() string {
+
+	if iv.IsZero() {
+		return "PT0S"
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("P")
+
+	if years := iv.Months / 12; years != 0 {
+		fmt.Fprintf(&sb, "%dY", years)
+	}
+	if months := iv.Months % 12; months != 0 {
+		fmt.Fprintf(&sb, "%dM", months)
+	}
+	if iv.Days != 0 {
+		fmt.Fprintf(&sb, "%dD", iv.Days)
+	}
+
+	if iv.Microseconds != 0 {
+		sb.WriteString("T")
+
+		sign := ""
+		us := iv.Microseconds
+		if us < 0 {
+			sign = "-"
+			us = -us
+		}
+		hours := us / int64(time.Hour/time.Microsecond)
+		us -= hours * int64(time.Hour/time.Microsecond)
+		minutes := us / int64(time.Minute/time.Microsecond)
+		us -= minutes * int64(time.Minute/time.Microsecond)
+
+		if hours != 0 {
+			fmt.Fprintf(&sb, "%s%dH", sign, hours)
+		}
+		if minutes != 0 {
+			fmt.Fprintf(&sb, "%s%dM", sign, minutes)
+		}
+		if us != 0 {
+			secs := strconv.FormatInt(us/1e6, 10)
+			if frac := us % 1e6; frac != 0 {
+				secs += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+			}
+			fmt.Fprintf(&sb, "%s%sS", sign, secs)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package lystype
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalSuccess(t *testing.T) {
+
+	ivStr := "\"P1Y2M3DT4H5M6.5S\""
+	var iv Interval
+
+	// test unmarshal
+	err := iv.UnmarshalJSON([]byte(ivStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+	assert.Equal(t, Interval{Months: 14, Days: 3, Microseconds: 14706500000}, iv)
+
+	// test marshal
+	marshalled, err := iv.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, ivStr, string(marshalled), "marshalled interval")
+
+	// test IntervalValue and ScanInterval
+	v, err := iv.IntervalValue()
+	require.NoError(t, err)
+	var iv2 Interval
+	err = iv2.ScanInterval(v)
+	assert.NoError(t, err, "ScanInterval should not error")
+	assert.Equal(t, iv, iv2)
+}
+
+func TestIntervalParse(t *testing.T) {
+
+	tests := map[string]Interval{
+		"PT0S":       {},
+		"P2W":        {Days: 14},
+		"pt1.5h":     {Microseconds: 5400000000},
+		"P-1Y-2M":    {Months: -14},
+		"PT-4H-5M":   {Microseconds: -14700000000},
+		"1h30m":      {Microseconds: 5400000000},
+		"-250ms":     {Microseconds: -250000},
+		"P1DT0.1S":   {Days: 1, Microseconds: 100000},
+		"P1M1DT1M1S": {Months: 1, Days: 1, Microseconds: 61000000},
+	}
+	for s, expected := range tests {
+		iv, err := ParseInterval(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, iv, s)
+	}
+
+	for _, s := range []string{"", "P", "PT", "P1H", "PT1D", "P1.5D", "P1X", "1 day"} {
+		_, err := ParseInterval(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestIntervalString(t *testing.T) {
+	assert.Equal(t, "PT0S", Interval{}.String())
+	assert.Equal(t, "P-1Y-2M", Interval{Months: -14}.String())
+	assert.Equal(t, "PT-4H-5M", Interval{Microseconds: -14700000000}.String())
+	assert.Equal(t, "P1DT0.000001S", Interval{Days: 1, Microseconds: 1}.String())
+}
+
+func TestIntervalDuration(t *testing.T) {
+	assert.Equal(t, 90*time.Minute, IntervalFromDuration(90*time.Minute).Duration())
+	assert.Equal(t, 31*24*time.Hour+time.Second, Interval{Months: 1, Days: 1, Microseconds: 1000000}.Duration())
+}
+
+func TestIntervalUnmarshalNullAndScanNull(t *testing.T) {
+	var iv Interval
+	err := iv.UnmarshalJSON([]byte("null"))
+	assert.NoError(t, err)
+	assert.True(t, iv.IsZero())
+
+	assert.Error(t, iv.ScanInterval(pgtype.Interval{}))
+}
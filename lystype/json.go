@@ -0,0 +1,38 @@
+package lystype
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON holds a value of type T, e.g. a struct or map, which is stored as Postgres json or jsonb.
+// pgx encodes and scans it using MarshalJSON and UnmarshalJSON
+type JSON[T any] struct {
+	V T
+}
+
+// UnmarshalJSON converts the supplied json to T and writes the result to the receiver
+func (j *JSON[T]) UnmarshalJSON(b []byte) error {
+	var v T
+	if string(b) != "null" {
+		if err := json.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("json.Unmarshal failed: %w", err)
+		}
+	}
+	j.V = v
+	return nil
+}
+
+// MarshalJSON converts the receiver's value to json
+func (j JSON[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.V)
+}
+
+// String returns the receiver's value as compact json, or an empty string if it cannot be marshalled.
+func (j JSON[T]) String() string {
+	b, err := json.Marshal(j.V)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
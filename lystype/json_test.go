@@ -0,0 +1,39 @@
+package lystype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSuccess(t *testing.T) {
+
+	type attrs struct {
+		Color string `json:"color"`
+		Size  int    `json:"size"`
+	}
+
+	jsonStr := `{"color":"red","size":3}`
+	var j JSON[attrs]
+
+	// test unmarshal
+	err := j.UnmarshalJSON([]byte(jsonStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+	assert.Equal(t, attrs{Color: "red", Size: 3}, j.V)
+
+	// test marshal
+	marshalled, err := j.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, jsonStr, string(marshalled), "marshalled json")
+	assert.Equal(t, jsonStr, j.String())
+}
+
+func TestJSONUnmarshalNullAndError(t *testing.T) {
+	j := JSON[map[string]int]{V: map[string]int{"a": 1}}
+	err := j.UnmarshalJSON([]byte("null"))
+	assert.NoError(t, err)
+	assert.Nil(t, j.V)
+
+	err = j.UnmarshalJSON([]byte(`{"a":"b"}`))
+	assert.Error(t, err)
+}
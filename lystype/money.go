@@ -0,0 +1,292 @@
+package lystype
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// OIDs of the Postgres money types, which pgx has no codec for
+const (
+	MoneyOID      uint32 = 790
+	MoneyArrayOID uint32 = 791
+)
+
+// Money is an amount with 2 fraction digits which represents Postgres money values exchanged via json.
+// It is marshalled as a json string, e.g. "-1234.50". Postgres formats money using lc_monetary, which must also use 2 fraction digits.
+// Register MoneyCodec in each connection using RegisterMoney, so that Money can be sent in binary format, e.g. by lyspg.BulkInsert
+type Money struct {
+	cents int64
+}
+
+// MoneyFromCents returns the Money of the supplied number of cents (minor units)
+func MoneyFromCents(cents int64) Money {
+	return Money{cents: cents}
+}
+
+// ParseMoney parses s, e.g. "-1234.5", into Money. It only accepts an optional sign, digits and an optional decimal point followed by 1 or 2 digits
+func ParseMoney(s string) (Money, error) {
+
+	num := strings.TrimSpace(s)
+	neg := strings.HasPrefix(num, "-")
+	if neg || strings.HasPrefix(num, "+") {
+		num = num[1:]
+	}
+
+	intPart, fracPart, hasPoint := strings.Cut(num, ".")
+	if !isDigits(intPart) || (hasPoint && (!isDigits(fracPart) || len(fracPart) > 2)) {
+		return Money{}, fmt.Errorf("invalid money: %s", s)
+	}
+
+	cents, err := strconv.ParseInt(intPart+(fracPart + "00")[:2], 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid money: %s", s)
+	}
+	if neg {
+		cents = -cents
+	}
+
+	return Money{cents: cents}, nil
+}
+
+// isDigits returns true if s is not empty and only contains ASCII digits
+func isDigits(s string) bool {
+	return s != "" && strings.TrimLeft(s, "0123456789") == ""
+}
+
+// parsePgMoney parses Postgres money output such as "-$1,234.50", "1.234,50 €" or "($3.10)" into Money:
+// a final "." or "," followed by 1 or 2 digits is the decimal separator, other separators and currency symbols are ignored
+func parsePgMoney(s string) (Money, error) {
+
+	s = strings.TrimSpace(s)
+	neg := strings.Contains(s, "-") || (strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")"))
+
+	// keep only digits and separators
+	kept := strings.Builder{}
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			kept.WriteRune(r)
+		case strings.ContainsRune("-+()$", r), unicode.IsLetter(r), unicode.IsSpace(r), unicode.Is(unicode.Sc, r):
+			// sign, grouping space or currency symbol
+		default:
+			return Money{}, fmt.Errorf("invalid money: %s", s)
+		}
+	}
+	num := kept.String()
+
+	intPart, fracPart := num, ""
+	if i := strings.LastIndexAny(num, ".,"); i != -1 && len(num)-i-1 <= 2 {
+		intPart, fracPart = num[:i], num[i+1:]
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+	if intPart == "" && fracPart == "" {
+		return Money{}, fmt.Errorf("invalid money: %s", s)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	plain := intPart
+	if fracPart != "" {
+		plain += "." + fracPart
+	}
+	if neg {
+		plain = "-" + plain
+	}
+
+	return ParseMoney(plain)
+}
+
+// UnmarshalJSON converts the supplied json string or number to Money and writes the result to the receiver
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "null" {
+		*m = Money{}
+		return nil
+	}
+	money, err := ParseMoney(s)
+	if err != nil {
+		return fmt.Errorf("ParseMoney failed: %w", err)
+	}
+	*m = money
+	return nil
+}
+
+// MarshalJSON converts the receiver to a json string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, m.String()), nil
+}
+
+// Scan implements the database/sql Scanner interface.
+func (m *Money) Scan(src any) error {
+
+	if src == nil {
+		return fmt.Errorf("unsupported Scan source type: %T", src)
+	}
+
+	switch src := src.(type) {
+	case string:
+		money, err := parsePgMoney(src)
+		if err != nil {
+			return fmt.Errorf("parsePgMoney failed: %w", err)
+		}
+		*m = money
+		return nil
+	case []byte:
+		return m.Scan(string(src))
+	default:
+		return fmt.Errorf("unsupported Scan source type: %T", src)
+	}
+}
+
+// Value implements the database/sql/driver Valuer interface.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Cents returns the amount in cents (minor units)
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+// Float64 returns the amount as float64
+func (m Money) Float64() float64 {
+	return float64(m.cents) / 100
+}
+
+// IsZero returns true if the amount is zero
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+// String returns the amount without currency symbol or grouping, e.g. "-1234.50".
+func (m Money) String() string {
+	abs := m.cents
+	sign := ""
+	if abs < 0 {
+		abs = -abs
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// RegisterMoney registers MoneyCodec for the money and money[] types in tm, e.g. in pgxpool.Config.AfterConnect: conn.TypeMap()
+func RegisterMoney(tm *pgtype.Map) {
+	moneyType := &pgtype.Type{Name: "money", OID: MoneyOID, Codec: MoneyCodec{}}
+	tm.RegisterType(moneyType)
+	tm.RegisterType(&pgtype.Type{Name: "_money", OID: MoneyArrayOID, Codec: &pgtype.ArrayCodec{ElementType: moneyType}})
+}
+
+// MoneyCodec is the pgtype.Codec of the money type. Its binary format is the amount in cents as int64, its text format depends on lc_monetary.
+// It encodes Money and strings such as "-1234.50", and scans into Money and string
+type MoneyCodec struct{}
+
+// FormatSupported implements the pgtype.Codec interface.
+func (MoneyCodec) FormatSupported(format int16) bool {
+	return format == pgtype.BinaryFormatCode || format == pgtype.TextFormatCode
+}
+
+// PreferredFormat implements the pgtype.Codec interface.
+func (MoneyCodec) PreferredFormat() int16 {
+	return pgtype.BinaryFormatCode
+}
+
+// PlanEncode implements the pgtype.Codec interface.
+func (MoneyCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	switch value.(type) {
+	case Money, string:
+		return encodePlanMoney{format: format}
+	}
+	return nil
+}
+
+type encodePlanMoney struct {
+	format int16
+}
+
+func (plan encodePlanMoney) Encode(value any, buf []byte) (newBuf []byte, err error) {
+
+	var money Money
+	switch value := value.(type) {
+	case Money:
+		money = value
+	case string:
+		if money, err = parsePgMoney(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if plan.format == pgtype.BinaryFormatCode {
+		return binary.BigEndian.AppendUint64(buf, uint64(money.cents)), nil
+	}
+	return append(buf, money.String()...), nil
+}
+
+// PlanScan implements the pgtype.Codec interface.
+func (MoneyCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	switch target.(type) {
+	case *Money, *string:
+		return scanPlanMoney{format: format}
+	}
+	return nil
+}
+
+type scanPlanMoney struct {
+	format int16
+}
+
+func (plan scanPlanMoney) Scan(src []byte, target any) error {
+	if src == nil {
+		return fmt.Errorf("cannot scan NULL into %T", target)
+	}
+
+	money, err := decodeMoney(plan.format, src)
+	if err != nil {
+		return err
+	}
+
+	switch target := target.(type) {
+	case *Money:
+		*target = money
+	case *string:
+		*target = money.String()
+	}
+	return nil
+}
+
+// DecodeDatabaseSQLValue implements the pgtype.Codec interface.
+func (MoneyCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+	money, err := decodeMoney(format, src)
+	if err != nil {
+		return nil, err
+	}
+	return money.String(), nil
+}
+
+// DecodeValue implements the pgtype.Codec interface.
+func (MoneyCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return decodeMoney(format, src)
+}
+
+// decodeMoney decodes src in binary or text format
+func decodeMoney(format int16, src []byte) (Money, error) {
+	if format == pgtype.BinaryFormatCode {
+		if len(src) != 8 {
+			return Money{}, fmt.Errorf("invalid length for money: %d", len(src))
+		}
+		return Money{cents: int64(binary.BigEndian.Uint64(src))}, nil
+	}
+	return parsePgMoney(string(src))
+}
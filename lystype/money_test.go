@@ -0,0 +1,146 @@
+package lystype
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneySuccess(t *testing.T) {
+
+	moneyStr := "\"-1234.50\""
+	var m Money
+
+	// test unmarshal
+	err := m.UnmarshalJSON([]byte(moneyStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+	assert.Equal(t, int64(-123450), m.Cents())
+
+	// test marshal
+	marshalled, err := m.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, moneyStr, string(marshalled), "marshalled money")
+
+	// test Scan of Postgres output and Value
+	var m2 Money
+	err = m2.Scan("-$1,234.50")
+	assert.NoError(t, err, "Scan should not error")
+	assert.Equal(t, m, m2)
+
+	val, err := m2.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "-1234.50", val)
+}
+
+func TestMoneyParse(t *testing.T) {
+
+	tests := map[string]int64{
+		"0":     0,
+		"12":    1200,
+		"12.5":  1250,
+		"+12.5": 1250,
+		" 7 ":   700,
+		"-0.01": -1,
+		"1234":  123400,
+	}
+	for s, expected := range tests {
+		m, err := ParseMoney(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, m.Cents(), s)
+	}
+
+	for _, s := range []string{"", "-", "$", ".5", "12.", "12abc", "1e5", "1.2.3", "1.234", "--1", "1,234", "$1.00", "1 234", "99999999999999999999"} {
+		_, err := ParseMoney(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+
+	tests := map[string]int64{
+		"$0.50":       50,
+		"$1,234.56":   123456,
+		"1.234,56 €":  123456,
+		"1,234":       123400,
+		"(£3.10)":     -310,
+		"-$0.01":      -1,
+		"1 234 567.8": 123456780,
+	}
+	for s, expected := range tests {
+		var m Money
+		require.NoError(t, m.Scan(s), s)
+		assert.Equal(t, expected, m.Cents(), s)
+	}
+
+	for _, s := range []string{"", "$", "1;5", "99999999999999999999"} {
+		var m Money
+		assert.Error(t, m.Scan(s), s)
+	}
+}
+
+func TestMoneyUnmarshalNumberAndNull(t *testing.T) {
+	var m Money
+	err := m.UnmarshalJSON([]byte("12.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "12.30", m.String())
+	assert.Equal(t, 12.3, m.Float64())
+
+	err = m.UnmarshalJSON([]byte("null"))
+	assert.NoError(t, err)
+	assert.True(t, m.IsZero())
+}
+
+func TestMoneyScanNilAndUnsupported(t *testing.T) {
+	var m Money
+	assert.Error(t, m.Scan(nil))
+	assert.Error(t, m.Scan(12))
+	assert.NoError(t, m.Scan([]byte("$5.00")))
+	assert.Equal(t, MoneyFromCents(500), m)
+}
+
+func TestMoneyCodec(t *testing.T) {
+
+	tm := pgtype.NewMap()
+	RegisterMoney(tm)
+
+	// binary format is int64 cents, including for 8 byte strings such as "-1234.50"
+	for _, v := range []any{MoneyFromCents(-123450), "-1234.50"} {
+		buf, err := tm.Encode(MoneyOID, pgtype.BinaryFormatCode, v, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0x1d, 0xc6}, buf)
+
+		var m Money
+		require.NoError(t, tm.Scan(MoneyOID, pgtype.BinaryFormatCode, buf, &m))
+		assert.Equal(t, MoneyFromCents(-123450), m)
+	}
+
+	// text format
+	buf, err := tm.Encode(MoneyOID, pgtype.TextFormatCode, MoneyFromCents(500), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "5.00", string(buf))
+
+	var m Money
+	require.NoError(t, tm.Scan(MoneyOID, pgtype.TextFormatCode, []byte("-$1,234.50"), &m))
+	assert.Equal(t, MoneyFromCents(-123450), m)
+
+	// binary into string
+	var s string
+	require.NoError(t, tm.Scan(MoneyOID, pgtype.BinaryFormatCode, []byte{0, 0, 0, 0, 0, 0, 0x01, 0xf4}, &s))
+	assert.Equal(t, "5.00", s)
+
+	// arrays
+	buf, err = tm.Encode(MoneyArrayOID, pgtype.BinaryFormatCode, []Money{MoneyFromCents(1), MoneyFromCents(2)}, nil)
+	require.NoError(t, err)
+	var ms []Money
+	require.NoError(t, tm.Scan(MoneyArrayOID, pgtype.BinaryFormatCode, buf, &ms))
+	assert.Equal(t, []Money{MoneyFromCents(1), MoneyFromCents(2)}, ms)
+
+	// errors
+	_, err = tm.Encode(MoneyOID, pgtype.BinaryFormatCode, "abc", nil)
+	assert.Error(t, err)
+	assert.Error(t, tm.Scan(MoneyOID, pgtype.BinaryFormatCode, []byte{1, 2}, &m))
+	assert.Error(t, tm.Scan(MoneyOID, pgtype.BinaryFormatCode, nil, &m))
+}
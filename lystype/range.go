@@ -0,0 +1,274 @@
+package lystype
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RangeBound is the constraint of the bound types of Range
+type RangeBound interface {
+	Date | Datetime
+	IsZero() bool
+	String() string
+	ToTime() time.Time
+}
+
+// Range represents Postgres range values exchanged via json, e.g. daterange or tstzrange. A zero bound is unbounded.
+// It is marshalled as a json object, e.g. {"lower":"2024-01-01","upper":null,"lower_inc":true,"upper_inc":false}, or {"empty":true}
+type Range[T RangeBound] struct {
+	Lower    T
+	Upper    T
+	LowerInc bool // true if Lower is included in the range: "[" rather than "("
+	UpperInc bool // true if Upper is included in the range: "]" rather than ")"
+	Empty    bool
+}
+
+// DateRange represents Postgres daterange values
+type DateRange = Range[Date]
+
+// DatetimeRange represents Postgres tstzrange values
+type DatetimeRange = Range[Datetime]
+
+// rangeJson is the json representation of Range. Unbounded bounds are null
+type rangeJson[T RangeBound] struct {
+	Lower    *T    `json:"lower"`
+	Upper    *T    `json:"upper"`
+	LowerInc *bool `json:"lower_inc"`
+	UpperInc *bool `json:"upper_inc"`
+	Empty    bool  `json:"empty,omitempty"`
+}
+
+// ParseRange parses s, which is a Postgres range literal such as "[2024-01-01,2024-02-01)", "(,2024-02-01]" or "empty", into a Range
+// Datetime bounds without offset are interpreted in loc, or in UTC if loc is nil
+func ParseRange[T RangeBound](s string, loc *time.Location) (r Range[T], err error) {
+
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "empty") {
+		return Range[T]{Empty: true}, nil
+	}
+
+	if len(s) < 3 || !strings.ContainsRune("[(", rune(s[0])) || !strings.ContainsRune("])", rune(s[len(s)-1])) {
+		return Range[T]{}, fmt.Errorf("invalid range: %s", s)
+	}
+	r.LowerInc = s[0] == '['
+	r.UpperInc = s[len(s)-1] == ']'
+
+	lowerStr, upperStr, ok := strings.Cut(s[1:len(s)-1], ",")
+	if !ok {
+		return Range[T]{}, fmt.Errorf("invalid range: %s", s)
+	}
+
+	if r.Lower, err = ParseRangeBound[T](lowerStr, loc); err != nil {
+		return Range[T]{}, fmt.Errorf("invalid range lower bound: %w", err)
+	}
+	if r.Upper, err = ParseRangeBound[T](upperStr, loc); err != nil {
+		return Range[T]{}, fmt.Errorf("invalid range upper bound: %w", err)
+	}
+
+	return r, nil
+}
+
+// ParseRangeBound parses a single bound value of Range, e.g. "2024-01-01" for DateRange. An empty string is the zero value (unbounded)
+// Datetime bounds are parsed using ParseDatetime with loc
+func ParseRangeBound[T RangeBound](s string, loc *time.Location) (bound T, err error) {
+
+	s = strings.Trim(strings.TrimSpace(s), "\"")
+	if s == "" {
+		return bound, nil
+	}
+
+	switch p := any(&bound).(type) {
+	case *Date:
+		ti, err := time.Parse(DateFormat, s)
+		if err != nil {
+			return bound, fmt.Errorf("time.Parse failed: %w", err)
+		}
+		*p = Date(ti)
+	case *Datetime:
+		dt, err := ParseDatetime(s, loc)
+		if err != nil {
+			return bound, fmt.Errorf("ParseDatetime failed: %w", err)
+		}
+		*p = dt
+	}
+
+	return bound, nil
+}
+
+// UnmarshalJSON converts the supplied json object to a Range and writes the result to the receiver.
+// If lower_inc or upper_inc are missing, they default to the canonical Postgres bounds "[)"
+func (r *Range[T]) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*r = Range[T]{}
+		return nil
+	}
+
+	var rj rangeJson[T]
+	if err := json.Unmarshal(b, &rj); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	if rj.Empty {
+		*r = Range[T]{Empty: true}
+		return nil
+	}
+
+	*r = Range[T]{LowerInc: true}
+	if rj.Lower != nil {
+		r.Lower = *rj.Lower
+	}
+	if rj.Upper != nil {
+		r.Upper = *rj.Upper
+	}
+	if rj.LowerInc != nil {
+		r.LowerInc = *rj.LowerInc
+	}
+	if rj.UpperInc != nil {
+		r.UpperInc = *rj.UpperInc
+	}
+	return nil
+}
+
+// MarshalJSON converts the receiver to a json object
+func (r Range[T]) MarshalJSON() ([]byte, error) {
+
+	if r.Empty {
+		return []byte(`{"empty":true}`), nil
+	}
+
+	rj := rangeJson[T]{LowerInc: &r.LowerInc, UpperInc: &r.UpperInc}
+	if !r.Lower.IsZero() {
+		rj.Lower = &r.Lower
+	}
+	if !r.Upper.IsZero() {
+		rj.Upper = &r.Upper
+	}
+	return json.Marshal(rj)
+}
+
+// ScanNull implements the pgtype.RangeScanner interface.
+func (r *Range[T]) ScanNull() error {
+	return fmt.Errorf("unsupported Scan source: NULL")
+}
+
+// ScanBounds implements the pgtype.RangeScanner interface.
+func (r *Range[T]) ScanBounds() (lowerTarget, upperTarget any) {
+	return &r.Lower, &r.Upper
+}
+
+// SetBoundTypes implements the pgtype.RangeScanner interface.
+func (r *Range[T]) SetBoundTypes(lower, upper pgtype.BoundType) error {
+
+	var zero T
+	r.Empty = lower == pgtype.Empty
+	if r.Empty || lower == pgtype.Unbounded {
+		r.Lower = zero
+	}
+	if r.Empty || upper == pgtype.Unbounded {
+		r.Upper = zero
+	}
+	r.LowerInc = lower == pgtype.Inclusive
+	r.UpperInc = upper == pgtype.Inclusive
+
+	return nil
+}
+
+// IsNull implements the pgtype.RangeValuer interface.
+func (r Range[T]) IsNull() bool {
+	return false
+}
+
+// BoundTypes implements the pgtype.RangeValuer interface.
+func (r Range[T]) BoundTypes() (lower, upper pgtype.BoundType) {
+
+	if r.Empty {
+		return pgtype.Empty, pgtype.Empty
+	}
+
+	boundType := func(bound T, inc bool) pgtype.BoundType {
+		switch {
+		case bound.IsZero():
+			return pgtype.Unbounded
+		case inc:
+			return pgtype.Inclusive
+		default:
+			return pgtype.Exclusive
+		}
+	}
+
+	return boundType(r.Lower, r.LowerInc), boundType(r.Upper, r.UpperInc)
+}
+
+// Bounds implements the pgtype.RangeValuer interface. Datetime bounds are written as UTC. Date bounds keep their location, so that their date does not change
+func (r Range[T]) Bounds() (lower, upper any) {
+	return rangeBoundValue(r.Lower), rangeBoundValue(r.Upper)
+}
+
+// rangeBoundValue returns the time.Time which is written for bound
+func rangeBoundValue[T RangeBound](bound T) time.Time {
+	switch b := any(bound).(type) {
+	case Datetime:
+		return b.ToTime().UTC()
+	default:
+		return bound.ToTime()
+	}
+}
+
+// Contains returns true if v is in the range
+func (r Range[T]) Contains(v T) bool {
+
+	if r.Empty {
+		return false
+	}
+
+	t := v.ToTime()
+	if !r.Lower.IsZero() && (t.Before(r.Lower.ToTime()) || (!r.LowerInc && t.Equal(r.Lower.ToTime()))) {
+		return false
+	}
+	if !r.Upper.IsZero() && (t.After(r.Upper.ToTime()) || (!r.UpperInc && t.Equal(r.Upper.ToTime()))) {
+		return false
+	}
+	return true
+}
+
+// IsZero returns true if the Range is the zero value, i.e. unbounded and not empty
+func (r Range[T]) IsZero() bool {
+	return !r.Empty && r.Lower.IsZero() && r.Upper.IsZero() && !r.LowerInc && !r.UpperInc
+}
+
+// String returns the Range as a Postgres range literal, e.g. "[2024-01-01,2024-02-01)". Bounds containing spaces are quoted.
+func (r Range[T]) String() string {
+
+	if r.Empty {
+		return "empty"
+	}
+
+	boundStr := func(bound T) string {
+		if bound.IsZero() {
+			return ""
+		}
+		s := bound.String()
+		if strings.Contains(s, " ") {
+			return "\"" + s + "\""
+		}
+		return s
+	}
+
+	sb := strings.Builder{}
+	if r.LowerInc && !r.Lower.IsZero() {
+		sb.WriteString("[")
+	} else {
+		sb.WriteString("(")
+	}
+	sb.WriteString(boundStr(r.Lower) + "," + boundStr(r.Upper))
+	if r.UpperInc && !r.Upper.IsZero() {
+		sb.WriteString("]")
+	} else {
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package lystype
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateRangeSuccess(t *testing.T) {
+
+	rangeStr := `{"lower":"2024-01-01","upper":"2024-02-01","lower_inc":true,"upper_inc":false}`
+	var r DateRange
+
+	// test unmarshal
+	err := r.UnmarshalJSON([]byte(rangeStr))
+	assert.NoError(t, err, "UnmarshalJSON should not error")
+	assert.Equal(t, "[2024-01-01,2024-02-01)", r.String())
+
+	// test marshal
+	marshalled, err := r.MarshalJSON()
+	assert.NoError(t, err, "MarshalJSON should not error")
+	assert.Equal(t, rangeStr, string(marshalled), "marshalled range")
+
+	// test RangeValuer and RangeScanner
+	lowerType, upperType := r.BoundTypes()
+	assert.Equal(t, pgtype.Inclusive, lowerType)
+	assert.Equal(t, pgtype.Exclusive, upperType)
+
+	var r2 DateRange
+	lowerTarget, upperTarget := r2.ScanBounds()
+	*lowerTarget.(*Date) = r.Lower
+	*upperTarget.(*Date) = r.Upper
+	err = r2.SetBoundTypes(lowerType, upperType)
+	assert.NoError(t, err, "SetBoundTypes should not error")
+	assert.Equal(t, r, r2)
+}
+
+func TestDateRangeUnbounded(t *testing.T) {
+
+	var r DateRange
+	err := r.UnmarshalJSON([]byte(`{"lower":null,"upper":"2024-02-01","upper_inc":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, "(,2024-02-01]", r.String())
+
+	marshalled, err := r.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"lower":null,"upper":"2024-02-01","lower_inc":true,"upper_inc":true}`, string(marshalled))
+
+	lowerType, _ := r.BoundTypes()
+	assert.Equal(t, pgtype.Unbounded, lowerType)
+}
+
+func TestDateRangeEmpty(t *testing.T) {
+
+	var r DateRange
+	err := r.UnmarshalJSON([]byte(`{"empty":true}`))
+	require.NoError(t, err)
+	assert.True(t, r.Empty)
+	assert.Equal(t, "empty", r.String())
+
+	marshalled, err := r.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"empty":true}`, string(marshalled))
+
+	err = r.SetBoundTypes(pgtype.Empty, pgtype.Empty)
+	assert.NoError(t, err)
+	assert.True(t, r.Empty)
+	assert.False(t, r.Contains(Date(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))))
+}
+
+func TestRangeBoundsLocation(t *testing.T) {
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	// date bounds keep their date
+	dr := DateRange{Lower: Date(time.Date(2024, 1, 1, 0, 0, 0, 0, loc)), Upper: Date(time.Date(2024, 2, 1, 0, 0, 0, 0, loc)), LowerInc: true}
+	lower, upper := dr.Bounds()
+	assert.Equal(t, "2024-01-01", lower.(time.Time).Format(DateFormat))
+	assert.Equal(t, "2024-02-01", upper.(time.Time).Format(DateFormat))
+
+	// datetime bounds are converted to UTC
+	dtr := DatetimeRange{Lower: Datetime(time.Date(2024, 1, 1, 1, 0, 0, 0, loc)), LowerInc: true}
+	lower, _ = dtr.Bounds()
+	assert.Equal(t, time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), lower)
+}
+
+func TestParseRange(t *testing.T) {
+
+	r, err := ParseRange[Date]("[2024-01-01,2024-02-01)", nil)
+	require.NoError(t, err)
+	assert.True(t, r.Contains(Date(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))))
+	assert.True(t, r.Contains(Date(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))))
+	assert.False(t, r.Contains(Date(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))))
+
+	dtr, err := ParseRange[Datetime](`["2024-01-01 10:00:00+00",)`, nil)
+	require.NoError(t, err)
+	assert.True(t, dtr.Upper.IsZero())
+	assert.Equal(t, `["2024-01-01 10:00:00+00",)`, dtr.String())
+
+	r, err = ParseRange[Date]("EMPTY", nil)
+	require.NoError(t, err)
+	assert.True(t, r.Empty)
+
+	for _, s := range []string{"", "2024-01-01", "[2024-01-01]", "[x,)", "[2024-01-01,2024-02-01"} {
+		_, err := ParseRange[Date](s, nil)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRangeScanNull(t *testing.T) {
+	var r DatetimeRange
+	assert.Error(t, r.ScanNull())
+	assert.True(t, r.IsZero())
+}
@@ -0,0 +1,254 @@
+package lysws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxNotifyPayloadLen is the max payload length accepted by Postgres NOTIFY in a default configuration
+const maxNotifyPayloadLen = 7999
+
+var ErrFanoutPayloadTooLarge = fmt.Errorf("fan-out payload exceeds %d bytes", maxNotifyPayloadLen)
+
+// fanoutPayload is the NOTIFY payload used to send a message to other hub instances.
+type fanoutPayload struct {
+	Origin string         `json:"o"` // instanceId of the sender, which has already delivered the message locally
+	UserId int64          `json:"u,omitempty"`
+	Msg    messagePayload `json:"m"`
+	Raw    []byte         `json:"r,omitempty"` // message sent unchanged via Broadcast/BroadcastE. If set, Msg is ignored
+}
+
+// replayBuffer keeps the most recent user messages so that reconnecting clients can receive the ones they missed.
+// The zero value is usable and keeps no messages.
+type replayBuffer struct {
+	lastPrune time.Time
+	maxAge    time.Duration // 0: no age limit
+	mu        sync.Mutex
+	seq       atomic.Uint64 // last assigned sequence number. Shared by all users, so that no per-user state is kept for users without messages
+	size      int           // max messages per user. 0: replay disabled
+	users     map[int64][]replayEntry
+}
+
+type replayEntry struct {
+	at  time.Time
+	id  string
+	msg []byte
+}
+
+// add stores msg for the user, dropping messages beyond the buffer size or max age.
+func (rb *replayBuffer) add(userID int64, id string, msg []byte) {
+	if rb.size == 0 {
+		return
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.users == nil {
+		rb.users = make(map[int64][]replayEntry)
+	}
+
+	now := time.Now()
+	entries := append(rb.users[userID], replayEntry{at: now, id: id, msg: msg})
+	if len(entries) > rb.size {
+		entries = entries[len(entries)-rb.size:]
+	}
+	rb.users[userID] = entries
+
+	// occasionally remove expired messages of all users, so that buffers of users who don't reconnect are freed
+	if rb.maxAge > 0 && now.Sub(rb.lastPrune) > rb.maxAge {
+		for uID := range rb.users {
+			rb.pruneUser(uID, now)
+		}
+		rb.lastPrune = now
+	}
+}
+
+// after returns the user's messages following the one with lastId. found is false if lastId is no longer (or was never) in the buffer.
+func (rb *replayBuffer) after(userID int64, lastId string) (msgs [][]byte, found bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.pruneUser(userID, time.Now())
+
+	entries := rb.users[userID]
+	for i, entry := range entries {
+		if entry.id != lastId {
+			continue
+		}
+		for _, e := range entries[i+1:] {
+			msgs = append(msgs, e.msg)
+		}
+		return msgs, true
+	}
+	return nil, false
+}
+
+// nextSeq returns the next message sequence number.
+func (rb *replayBuffer) nextSeq() uint64 {
+	return rb.seq.Add(1)
+}
+
+// pruneUser removes the user's expired messages. The caller must hold rb.mu.
+func (rb *replayBuffer) pruneUser(userID int64, now time.Time) {
+	if rb.maxAge == 0 {
+		return
+	}
+
+	entries := rb.users[userID]
+	i := 0
+	for i < len(entries) && now.Sub(entries[i].at) > rb.maxAge {
+		i++
+	}
+	if i == len(entries) {
+		delete(rb.users, userID)
+		return
+	}
+	rb.users[userID] = entries[i:]
+}
+
+// deliver sends the message to the local sockets of the topic's subscribers or of the user, and stores user messages for replay.
+func (h *NotificationHub) deliver(userID int64, payload messagePayload) {
+
+	msgBytes, err := json.Marshal(payload)
+	if err != nil {
+		h.logger.Error("json.Marshal failed", "payload", payload, "error", err)
+		return
+	}
+
+	if payload.Topic != "" {
+		if err := h.publishTopic(payload.Topic, userID, msgBytes, false); err != nil {
+			h.logger.Error("h.publishTopic failed", "topic", payload.Topic, "user_id", userID, "error", err)
+		}
+		return
+	}
+
+	if payload.Id != "" {
+		h.replay.add(userID, payload.Id, msgBytes)
+	}
+	if err := h.broadcast(userID, msgBytes, false); err != nil {
+		h.logger.Error("h.broadcast failed", "user_id", userID, "error", err)
+	}
+}
+
+// fanout sends the message to the other hub instances via the fan-out channel, if configured.
+func (h *NotificationHub) fanout(ctx context.Context, fp fanoutPayload) (err error) {
+
+	if h.dbFanoutChannel == "" {
+		return nil
+	}
+
+	fp.Origin = h.instanceId
+	b, err := json.Marshal(fp)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if len(b) > maxNotifyPayloadLen {
+		return ErrFanoutPayloadTooLarge
+	}
+
+	if _, err = h.db.Exec(ctx, "SELECT pg_notify($1, $2)", h.dbFanoutChannel, string(b)); err != nil {
+		return fmt.Errorf("h.db.Exec (pg_notify) failed: %w", err)
+	}
+	return nil
+}
+
+// receiveFanout delivers a message sent by another hub instance to local sockets.
+func (h *NotificationHub) receiveFanout(payload string) {
+
+	var fp fanoutPayload
+	if err := json.Unmarshal([]byte(payload), &fp); err != nil {
+		h.logger.Error("json.Unmarshal failed for fan-out payload", "payload", payload, "error", err)
+		return
+	}
+
+	// already delivered locally by SendUser, SendTopic or Broadcast
+	if fp.Origin == h.instanceId {
+		return
+	}
+
+	if fp.Raw != nil {
+		if err := h.broadcast(fp.UserId, fp.Raw, false); err != nil {
+			h.logger.Error("h.broadcast failed", "user_id", fp.UserId, "error", err)
+		}
+		return
+	}
+
+	h.deliver(fp.UserId, fp.Msg)
+}
+
+// randHex returns n random bytes, hex encoded.
+func randHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// replayTo writes the user's messages following lastId using write. If some of those messages are no longer available,
+// only a message of type MsgTypeReplayGap is written, and the client should reload its state instead.
+func (h *NotificationHub) replayTo(userID int64, lastId string, write func(msg []byte) error) (err error) {
+
+	if lastId == "" {
+		return nil
+	}
+
+	msgs, found := h.replay.after(userID, lastId)
+	if !found {
+		var gapBytes []byte
+		gapBytes, err = json.Marshal(messagePayload{Type: MsgTypeReplayGap})
+		if err != nil {
+			return fmt.Errorf("json.Marshal failed: %w", err)
+		}
+		return write(gapBytes)
+	}
+
+	for _, msg := range msgs {
+		if err = write(msg); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// SendTopic sends a message to the subscribers of topic on this and, if a fan-out channel is configured, all other hub instances.
+// The message is delivered locally even if fan-out fails.
+func (h *NotificationHub) SendTopic(ctx context.Context, topic, msgType, body string) (err error) {
+
+	if topic == "" || len(topic) > maxTopicLen {
+		return ErrInvalidTopic
+	}
+
+	payload := messagePayload{Type: msgType, Topic: topic, Body: body}
+	h.deliver(0, payload)
+
+	if err = h.fanout(ctx, fanoutPayload{Msg: payload}); err != nil {
+		return fmt.Errorf("h.fanout failed: %w", err)
+	}
+	return nil
+}
+
+// SendUser sends a message to the user's sockets on this and, if a fan-out channel is configured, all other hub instances.
+// The message gets an id made of this instance's id and a sequence number, and is kept for replay if enabled.
+// The message is delivered locally even if fan-out fails.
+func (h *NotificationHub) SendUser(ctx context.Context, userID int64, msgType, body string) (err error) {
+
+	payload := messagePayload{
+		Id:   fmt.Sprintf("%s-%d", h.instanceId, h.replay.nextSeq()),
+		Type: msgType,
+		Body: body,
+	}
+	h.deliver(userID, payload)
+
+	if err = h.fanout(ctx, fanoutPayload{UserId: userID, Msg: payload}); err != nil {
+		return fmt.Errorf("h.fanout failed: %w", err)
+	}
+	return nil
+}
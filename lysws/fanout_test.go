@@ -0,0 +1,180 @@
+package lysws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	rb := replayBuffer{size: 3}
+
+	// disabled buffer keeps nothing
+	disabled := replayBuffer{}
+	disabled.add(1, "a", []byte("a"))
+	if _, found := disabled.after(1, "a"); found {
+		t.Fatal("expected disabled buffer to keep no messages")
+	}
+
+	for i := 1; i <= 4; i++ {
+		id := fmt.Sprintf("m%d", i)
+		rb.add(1, id, []byte(id))
+	}
+	rb.add(2, "other", []byte("other"))
+
+	// m1 was dropped due to size
+	if _, found := rb.after(1, "m1"); found {
+		t.Fatal("expected m1 to be dropped")
+	}
+
+	msgs, found := rb.after(1, "m2")
+	if !found || len(msgs) != 2 || string(msgs[0]) != "m3" || string(msgs[1]) != "m4" {
+		t.Fatalf("unexpected replay after m2: found=%v msgs=%q", found, msgs)
+	}
+
+	msgs, found = rb.after(1, "m4")
+	if !found || len(msgs) != 0 {
+		t.Fatalf("unexpected replay after m4: found=%v msgs=%q", found, msgs)
+	}
+
+	// sequences are shared by all users
+	if rb.nextSeq() != 1 || rb.nextSeq() != 2 || rb.nextSeq() != 3 {
+		t.Fatal("unexpected sequence numbers")
+	}
+}
+
+func TestReplayBufferMaxAge(t *testing.T) {
+	rb := replayBuffer{size: 10, maxAge: time.Minute}
+
+	rb.add(1, "old", []byte("old"))
+	rb.users[1][0].at = time.Now().Add(-2 * time.Minute)
+	rb.add(1, "new", []byte("new"))
+
+	if _, found := rb.after(1, "old"); found {
+		t.Fatal("expected expired message to be pruned")
+	}
+	if _, found := rb.after(1, "new"); !found {
+		t.Fatal("expected recent message to be kept")
+	}
+}
+
+func TestSendUserAndReplay(t *testing.T) {
+	hub := newTestHub()
+	hub.instanceId = "i1"
+	hub.replay = replayBuffer{size: 10}
+	userID := int64(3)
+
+	serverConn, clientConn, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(userID, serverConn)
+
+	// first message is received live
+	if err := hub.SendUser(context.Background(), userID, "note", "one"); err != nil {
+		t.Fatalf("SendUser failed: %v", err)
+	}
+	first := readTestMessage(t, clientConn)
+	if first.Id != "i1-1" || first.Body != "one" {
+		t.Fatalf("unexpected message: %+v", first)
+	}
+
+	// client disconnects and misses the next messages
+	hub.Unregister(userID, serverConn)
+	for _, body := range []string{"two", "three"} {
+		if err := hub.SendUser(context.Background(), userID, "note", body); err != nil {
+			t.Fatalf("SendUser failed: %v", err)
+		}
+	}
+
+	// client reconnects and asks for replay
+	serverConn2, clientConn2, cleanup2 := newWebsocketPair(t)
+	t.Cleanup(cleanup2)
+	hub.Register(userID, serverConn2)
+
+	req, _ := json.Marshal(clientMessage{Action: ClientActionReplay, LastId: first.Id})
+	hub.handleClientMessage(context.Background(), userID, serverConn2, req)
+
+	for _, want := range []string{"two", "three"} {
+		if got := readTestMessage(t, clientConn2); got.Body != want {
+			t.Fatalf("unexpected replayed message: got %+v, want body %s", got, want)
+		}
+	}
+
+	// unknown last id results in a gap message
+	req, _ = json.Marshal(clientMessage{Action: ClientActionReplay, LastId: "i9-1"})
+	hub.handleClientMessage(context.Background(), userID, serverConn2, req)
+	if got := readTestMessage(t, clientConn2); got.Type != MsgTypeReplayGap {
+		t.Fatalf("expected replay gap message, got %+v", got)
+	}
+}
+
+func TestReceiveFanout(t *testing.T) {
+	hub := newTestHub()
+	hub.instanceId = "i1"
+	hub.replay = replayBuffer{size: 10}
+	userID := int64(4)
+
+	serverConn, clientConn, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(userID, serverConn)
+
+	// own messages are ignored since they were already delivered locally
+	own, _ := json.Marshal(fanoutPayload{Origin: "i1", UserId: userID, Msg: messagePayload{Id: "i1-1", Type: "note", Body: "own"}})
+	hub.receiveFanout(string(own))
+
+	other, _ := json.Marshal(fanoutPayload{Origin: "i2", UserId: userID, Msg: messagePayload{Id: "i2-1", Type: "note", Body: "other"}})
+	hub.receiveFanout(string(other))
+
+	if got := readTestMessage(t, clientConn); got.Id != "i2-1" || got.Body != "other" {
+		t.Fatalf("unexpected message: %+v", got)
+	}
+
+	// message from another instance is kept for replay on this instance too
+	if _, found := hub.replay.after(userID, "i2-1"); !found {
+		t.Fatal("expected fan-out message to be kept for replay")
+	}
+}
+
+func TestReceiveFanoutRaw(t *testing.T) {
+	hub := newTestHub()
+	hub.instanceId = "i1"
+	userID := int64(5)
+
+	serverConn, clientConn, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(userID, serverConn)
+
+	// message sent by Broadcast on another instance is delivered unchanged
+	other, _ := json.Marshal(fanoutPayload{Origin: "i2", UserId: userID, Raw: []byte(`{"type":"raw"}`)})
+	hub.receiveFanout(string(other))
+
+	if err := clientConn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline failed: %v", err)
+	}
+	_, msg, err := clientConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if string(msg) != `{"type":"raw"}` {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestWriteMessageUnregistered(t *testing.T) {
+	hub := newTestHub()
+	userID := int64(6)
+
+	serverConn, _, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(userID, serverConn)
+	hub.Unregister(userID, serverConn)
+
+	// a write racing with Unregister must not leave a write lock behind
+	if err := hub.writeMessage(serverConn, []byte("x")); err != ErrConnNotRegistered {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hub.writeLocks) != 0 {
+		t.Fatalf("expected no write locks, got %d", len(hub.writeLocks))
+	}
+}
@@ -2,7 +2,6 @@ package lysws
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -19,34 +18,50 @@ import (
 )
 
 // NotificationHub manages WebSocket connections for user notifications.
-// It listens for database notifications and broadcasts messages to connected clients based on user ID.
+// It listens for database notifications and broadcasts messages to connected clients based on user ID or subscribed topic.
 type NotificationHub struct {
 	closed             atomic.Bool
-	conns              map[int64][]*websocket.Conn // user_id → active sockets
+	conns              map[int64][]*websocket.Conn             // user_id → active sockets
+	connTopics         map[*websocket.Conn]map[string]struct{} // socket → subscribed topics
 	heartbeatPingIntvl time.Duration
 	heartbeatPongWait  time.Duration
 	heartbeatWriteWait time.Duration
 	listenCancel       context.CancelFunc // cancels the active ListenAndBroadcast loop
 	logger             *slog.Logger
+	maxConnTopics      int
 	maxUserConnections int
-	mu                 sync.RWMutex // protects conns
+	mu                 sync.RWMutex           // protects conns, connTopics, sseClients, topics and writeLocks
+	sseClients         map[int64][]*sseClient // user_id → active Server-Sent Events streams
+	subscribeAuthFunc  SubscribeAuthFunc
+	topics             map[string]map[*websocket.Conn]int64 // topic → subscribed sockets → user_id
 	upgrader           websocket.Upgrader
+	writeLocks         map[*websocket.Conn]*sync.Mutex // registered socket → lock which serializes writes, since sockets do not support concurrent writers
 
 	// database fields for LISTEN/UNLISTEN
 	db              *pgxpool.Pool
 	dbListenChannel string // database channel to LISTEN on for notifications
+	dbFanoutChannel string // optional database channel used to send messages to all hub instances
+	instanceId      string // identifies this hub instance in fan-out messages
+	replay          replayBuffer
 }
 
 type NotificationHubOptions struct {
 	HeartbeatPingInterval time.Duration
 	HeartbeatPongWait     time.Duration
 	HeartbeatWriteWait    time.Duration
+	FanoutChannel         string            // if set, SendUser, SendTopic, Broadcast and BroadcastE also deliver to sockets connected to other hub instances via this database channel
+	MaxConnTopics         int               // max topics a single socket may subscribe to
+	ReplayBufferSize      int               // number of SendUser/ListenAndRoute user messages kept per user for replay to reconnecting clients. 0 disables replay
+	ReplayMaxAge          time.Duration     // max age of messages kept for replay
+	SubscribeAuthFunc     SubscribeAuthFunc // authorizes client topic subscriptions. If nil, all client subscriptions are rejected
 }
 
 const (
 	defaultHeartbeatPingIntvl = 54 * time.Second
 	defaultHeartbeatPongWait  = 60 * time.Second
 	defaultHeartbeatWriteWait = 10 * time.Second
+	defaultMaxConnTopics      = 50
+	defaultReplayMaxAge       = 5 * time.Minute
 )
 
 // NewNotificationHub creates a new NotificationHub instance.
@@ -74,6 +89,8 @@ func NewNotificationHub(ctx context.Context, db *pgxpool.Pool, dbListenChannel s
 		HeartbeatPingInterval: defaultHeartbeatPingIntvl,
 		HeartbeatPongWait:     defaultHeartbeatPongWait,
 		HeartbeatWriteWait:    defaultHeartbeatWriteWait,
+		MaxConnTopics:         defaultMaxConnTopics,
+		ReplayMaxAge:          defaultReplayMaxAge,
 	}
 	if len(options) > 0 {
 		opts = options[0]
@@ -86,6 +103,12 @@ func NewNotificationHub(ctx context.Context, db *pgxpool.Pool, dbListenChannel s
 		if opts.HeartbeatWriteWait == 0 {
 			opts.HeartbeatWriteWait = defaultHeartbeatWriteWait
 		}
+		if opts.MaxConnTopics == 0 {
+			opts.MaxConnTopics = defaultMaxConnTopics
+		}
+		if opts.ReplayMaxAge == 0 {
+			opts.ReplayMaxAge = defaultReplayMaxAge
+		}
 	}
 	if opts.HeartbeatPingInterval <= 0 {
 		return nil, fmt.Errorf("heartbeatPingInterval must be greater than 0")
@@ -99,6 +122,23 @@ func NewNotificationHub(ctx context.Context, db *pgxpool.Pool, dbListenChannel s
 	if opts.HeartbeatPingInterval >= opts.HeartbeatPongWait {
 		return nil, fmt.Errorf("heartbeatPingInterval must be less than heartbeatPongWait")
 	}
+	if opts.MaxConnTopics < 0 {
+		return nil, fmt.Errorf("maxConnTopics must not be negative")
+	}
+	if opts.ReplayBufferSize < 0 {
+		return nil, fmt.Errorf("replayBufferSize must not be negative")
+	}
+	if opts.ReplayMaxAge < 0 {
+		return nil, fmt.Errorf("replayMaxAge must not be negative")
+	}
+	if opts.FanoutChannel != "" && opts.FanoutChannel == dbListenChannel {
+		return nil, fmt.Errorf("fanoutChannel must differ from dbListenChannel")
+	}
+
+	instanceId, err := randHex(4)
+	if err != nil {
+		return nil, fmt.Errorf("randHex failed: %w", err)
+	}
 
 	// initialize the WebSocket upgrader with CORS check based on allowedOrigin
 	upgrader := websocket.Upgrader{
@@ -109,15 +149,23 @@ func NewNotificationHub(ctx context.Context, db *pgxpool.Pool, dbListenChannel s
 
 	return &NotificationHub{
 		conns:              make(map[int64][]*websocket.Conn),
+		connTopics:         make(map[*websocket.Conn]map[string]struct{}),
 		heartbeatPingIntvl: opts.HeartbeatPingInterval,
 		heartbeatPongWait:  opts.HeartbeatPongWait,
 		heartbeatWriteWait: opts.HeartbeatWriteWait,
 		logger:             logger.With("component", "notification hub"),
+		maxConnTopics:      opts.MaxConnTopics,
 		maxUserConnections: maxUserConnections,
+		subscribeAuthFunc:  opts.SubscribeAuthFunc,
+		topics:             make(map[string]map[*websocket.Conn]int64),
 		upgrader:           upgrader,
+		writeLocks:         make(map[*websocket.Conn]*sync.Mutex),
 
 		db:              db,
+		dbFanoutChannel: opts.FanoutChannel,
 		dbListenChannel: dbListenChannel,
+		instanceId:      instanceId,
+		replay:          replayBuffer{maxAge: opts.ReplayMaxAge, size: opts.ReplayBufferSize},
 	}, nil
 }
 
@@ -132,7 +180,7 @@ func (h *NotificationHub) broadcast(userID int64, msg []byte, logFailures bool)
 
 	for _, conn := range connsCopy {
 		h.logger.Debug("broadcasting message", "user_id", userID, "message", string(msg))
-		if connErr := conn.WriteMessage(websocket.TextMessage, msg); connErr != nil {
+		if connErr := h.writeMessage(conn, msg); connErr != nil {
 			if logFailures {
 				h.logger.Error("conn.WriteMessage failed", "user_id", userID, "error", connErr)
 			}
@@ -140,18 +188,59 @@ func (h *NotificationHub) broadcast(userID int64, msg []byte, logFailures bool)
 			err = errors.Join(err, connErr)
 		}
 	}
+
+	// Server-Sent Events streams
+	h.mu.RLock()
+	sseCopy := slices.Clone(h.sseClients[userID])
+	h.mu.RUnlock()
+
+	for _, client := range sseCopy {
+		h.logger.Debug("broadcasting message (sse)", "user_id", userID, "message", string(msg))
+		if sendErr := client.send(msg); sendErr != nil {
+			if logFailures {
+				h.logger.Error("client.send failed", "user_id", userID, "error", sendErr)
+			}
+			h.unregisterSSE(userID, client)
+			err = errors.Join(err, sendErr)
+		}
+	}
+
 	return err
 }
 
-// Broadcast sends a message to all active WebSocket connections for a given user ID.
+// Broadcast sends a message to all active WebSocket connections and Server-Sent Events streams for a given user ID.
+// If a fan-out channel is configured, the message is also sent to the user's connections on all other hub instances.
 func (h *NotificationHub) Broadcast(userID int64, msg []byte) {
 	_ = h.broadcast(userID, msg, true)
+
+	if err := h.fanoutRaw(userID, msg); err != nil {
+		h.logger.Error("h.fanoutRaw failed", "user_id", userID, "error", err)
+	}
 }
 
-// BroadcastE sends a message to all active WebSocket connections for a given user ID.
-// It returns an error if any connection fails to send the message, but continues to attempt sending to all connections.
+// BroadcastE sends a message to all active WebSocket connections and Server-Sent Events streams for a given user ID.
+// If a fan-out channel is configured, the message is also sent to the user's connections on all other hub instances.
+// It returns an error if any connection or the fan-out fails to send the message, but continues to attempt sending to all connections.
 func (h *NotificationHub) BroadcastE(userID int64, msg []byte) (err error) {
-	return h.broadcast(userID, msg, false)
+	err = h.broadcast(userID, msg, false)
+
+	if fanoutErr := h.fanoutRaw(userID, msg); fanoutErr != nil {
+		err = errors.Join(err, fmt.Errorf("h.fanoutRaw failed: %w", fanoutErr))
+	}
+	return err
+}
+
+// fanoutRaw sends the unchanged message for the user to the other hub instances via the fan-out channel, if configured.
+func (h *NotificationHub) fanoutRaw(userID int64, msg []byte) (err error) {
+
+	if h.dbFanoutChannel == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.heartbeatWriteWait)
+	defer cancel()
+
+	return h.fanout(ctx, fanoutPayload{UserId: userID, Raw: msg})
 }
 
 // Close closes all active WebSocket connections and clears the connection map.
@@ -172,9 +261,22 @@ func (h *NotificationHub) Close() (err error) {
 	for _, conns := range h.conns {
 		all = append(all, conns...)
 	}
+	allSse := make([]*sseClient, 0)
+	for _, clients := range h.sseClients {
+		allSse = append(allSse, clients...)
+	}
 	h.conns = make(map[int64][]*websocket.Conn)
+	h.sseClients = make(map[int64][]*sseClient)
+	h.connTopics = make(map[*websocket.Conn]map[string]struct{})
+	h.topics = make(map[string]map[*websocket.Conn]int64)
+	h.writeLocks = make(map[*websocket.Conn]*sync.Mutex)
 	h.mu.Unlock()
 
+	// end SSE streams
+	for _, client := range allSse {
+		client.close()
+	}
+
 	// close sockets outside the lock so slow network closes do not block remaining ops
 	for _, conn := range all {
 		if closeErr := conn.Close(); closeErr != nil {
@@ -196,14 +298,39 @@ func (h *NotificationHub) GetUserConns(userID int64) []*websocket.Conn {
 // NotificationSelectFunc defines a function type for selecting notification details from the database.
 type NotificationSelectFunc func(ctx context.Context, db *pgxpool.Pool, notId int64) (userId int64, notType, message string, err error)
 
+// Notification is a message to be routed to a user, to the subscribers of a topic, or to the subscribers of a topic who are a given user.
+type Notification struct {
+	Body   string
+	Topic  string // if set, message is sent to the topic's subscribers
+	Type   string
+	UserId int64 // if set without Topic, message is sent to all of the user's sockets. If set with Topic, only to that user's subscribed sockets
+}
+
+// NotificationRouteFunc defines a function type for selecting notification details, including the route, from the database.
+type NotificationRouteFunc func(ctx context.Context, db *pgxpool.Pool, notId int64) (n Notification, err error)
+
 // ListenAndBroadcast listens for database notifications and broadcasts messages to users based on the notification payload.
-// Only call this once per hub.
+// Only call this once per hub. Use ListenAndRoute to also route messages to topics.
 func (h *NotificationHub) ListenAndBroadcast(ctx context.Context, selectFunc NotificationSelectFunc) (err error) {
 
 	if selectFunc == nil {
 		return fmt.Errorf("selectFunc is required")
 	}
 
+	return h.ListenAndRoute(ctx, func(ctx context.Context, db *pgxpool.Pool, notId int64) (n Notification, err error) {
+		n.UserId, n.Type, n.Body, err = selectFunc(ctx, db, notId)
+		return n, err
+	})
+}
+
+// ListenAndRoute listens for database notifications and sends messages to users and/or topic subscribers based on the notification returned by routeFunc.
+// Only call this once per hub.
+func (h *NotificationHub) ListenAndRoute(ctx context.Context, routeFunc NotificationRouteFunc) (err error) {
+
+	if routeFunc == nil {
+		return fmt.Errorf("routeFunc is required")
+	}
+
 	// create a cancellable context for the listen loop
 	listenCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -238,7 +365,7 @@ func (h *NotificationHub) ListenAndBroadcast(ctx context.Context, selectFunc Not
 		start := time.Now()
 
 		// listen for notifications on the acquired connection
-		err = h.listenOnce(listenCtx, conn, selectFunc)
+		err = h.listenOnce(listenCtx, conn, routeFunc)
 
 		// release the connection back to the pool
 		conn.Release()
@@ -263,26 +390,31 @@ func (h *NotificationHub) ListenAndBroadcast(ctx context.Context, selectFunc Not
 	}
 }
 
-// listenOnce listens for database notifications on the specified connection and broadcasts messages to users.
-func (h *NotificationHub) listenOnce(ctx context.Context, conn *pgxpool.Conn, selectFunc NotificationSelectFunc) (err error) {
+// listenOnce listens for database notifications on the specified connection and sends messages to users and topics.
+// If a fan-out channel is configured, it also receives messages sent by other hub instances.
+func (h *NotificationHub) listenOnce(ctx context.Context, conn *pgxpool.Conn, routeFunc NotificationRouteFunc) (err error) {
 
-	// LISTEN to receive notifications on the dbListenChannel
-	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{h.dbListenChannel}.Sanitize())
-	if err != nil {
-		return fmt.Errorf("conn.Exec (LISTEN) failed on channel %s: %w", h.dbListenChannel, err)
+	channels := []string{h.dbListenChannel}
+	if h.dbFanoutChannel != "" {
+		channels = append(channels, h.dbFanoutChannel)
+	}
+
+	// LISTEN to receive notifications on the channels
+	for _, channel := range channels {
+		_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+		if err != nil {
+			return fmt.Errorf("conn.Exec (LISTEN) failed on channel %s: %w", channel, err)
+		}
 	}
 	defer func() {
-		_, unlistenErr := conn.Exec(context.Background(), "UNLISTEN "+pgx.Identifier{h.dbListenChannel}.Sanitize())
-		if unlistenErr != nil {
-			h.logger.Error("conn.Exec (UNLISTEN) failed", "channel", h.dbListenChannel, "error", unlistenErr)
+		for _, channel := range channels {
+			_, unlistenErr := conn.Exec(context.Background(), "UNLISTEN "+pgx.Identifier{channel}.Sanitize())
+			if unlistenErr != nil {
+				h.logger.Error("conn.Exec (UNLISTEN) failed", "channel", channel, "error", unlistenErr)
+			}
 		}
 	}()
 
-	type messagePayload struct {
-		Type string `json:"type"`
-		Body string `json:"body"`
-	}
-
 	// wait for notifications or context cancellation
 	for {
 		not, err := conn.Conn().WaitForNotification(ctx)
@@ -293,35 +425,34 @@ func (h *NotificationHub) listenOnce(ctx context.Context, conn *pgxpool.Conn, se
 			return fmt.Errorf("conn.WaitForNotification failed: %w", err)
 		}
 
-		// payload needs to be the notification ID int64 to be looked up by selectFunc
-		notId, err := strconv.ParseInt(not.Payload, 10, 64)
-		if err != nil {
-			h.logger.Error("strconv.ParseInt failed", "payload", not.Payload, "error", err)
+		// message sent by a hub instance
+		if h.dbFanoutChannel != "" && not.Channel == h.dbFanoutChannel {
+			h.receiveFanout(not.Payload)
 			continue
 		}
 
-		// select the notification details
-		userId, notType, message, err := selectFunc(ctx, h.db, notId)
+		// payload needs to be the notification ID int64 to be looked up by routeFunc
+		notId, err := strconv.ParseInt(not.Payload, 10, 64)
 		if err != nil {
-			h.logger.Error("selectFunc failed", "notification_id", notId, "error", err)
+			h.logger.Error("strconv.ParseInt failed", "payload", not.Payload, "error", err)
 			continue
 		}
 
-		// prepare JSON payload
-		payload := messagePayload{
-			Type: notType,
-			Body: message,
-		}
-		msgBytes, err := json.Marshal(payload)
+		// select the notification details
+		n, err := routeFunc(ctx, h.db, notId)
 		if err != nil {
-			h.logger.Error("json.Marshal failed", "payload", payload, "error", err)
+			h.logger.Error("routeFunc failed", "notification_id", notId, "error", err)
 			continue
 		}
 
-		// broadcast the message to the user's active connections
-		if err := h.BroadcastE(userId, msgBytes); err != nil {
-			h.logger.Error("h.BroadcastE failed", "user_id", userId, "error", err)
-		}
+		// send the message to the topic's subscribers, or to the user's active connections
+		// every instance receives the same notification, so the id derived from it is the same on all instances
+		h.deliver(n.UserId, messagePayload{
+			Id:    "n-" + strconv.FormatInt(notId, 10),
+			Type:  n.Type,
+			Topic: n.Topic,
+			Body:  n.Body,
+		})
 
 	} // end for
 }
@@ -352,7 +483,7 @@ func (h *NotificationHub) Register(userID int64, c *websocket.Conn) (accepted bo
 		h.logger.Error("connection already registered for user", "user_id", userID)
 
 	// reject if userID already has maximum active connections
-	case len(h.conns[userID]) >= h.maxUserConnections:
+	case len(h.conns[userID])+len(h.sseClients[userID]) >= h.maxUserConnections:
 		shouldClose = true
 
 		// client should listen for this code to prevent endless reconnect loops
@@ -363,6 +494,7 @@ func (h *NotificationHub) Register(userID int64, c *websocket.Conn) (accepted bo
 	// register new connection
 	default:
 		h.conns[userID] = append(h.conns[userID], c)
+		h.writeLocks[c] = &sync.Mutex{}
 		h.logger.Debug("registered connection", "user_id", userID)
 		accepted = true
 	}
@@ -441,11 +573,15 @@ func (h *NotificationHub) ServeUserSocket(ctx context.Context, w http.ResponseWr
 		}
 	}()
 
-	// block until connection is closed (e.g. by client, network issues, or user refreshing page)
+	// block until connection is closed (e.g. by client, network issues, or user refreshing page), handling client messages such as topic subscriptions
 	for {
-		if _, _, err := wsConn.ReadMessage(); err != nil {
+		msgType, data, err := wsConn.ReadMessage()
+		if err != nil {
 			return nil
 		}
+		if msgType == websocket.TextMessage {
+			h.handleClientMessage(ctx, userID, wsConn, data)
+		}
 	}
 }
 
@@ -462,7 +598,7 @@ func sleepOrDone(ctx context.Context, d time.Duration) bool {
 	}
 }
 
-// Status returns a snapshot of the number of active connections for each user ID.
+// Status returns a snapshot of the number of active connections (WebSocket and Server-Sent Events) for each user ID.
 func (h *NotificationHub) Status() map[int64]int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -471,6 +607,9 @@ func (h *NotificationHub) Status() map[int64]int {
 	for userID, conns := range h.conns {
 		status[userID] = len(conns)
 	}
+	for userID, clients := range h.sseClients {
+		status[userID] += len(clients)
+	}
 	return status
 }
 
@@ -491,8 +630,10 @@ func (h *NotificationHub) Unregister(userID int64, c *websocket.Conn) {
 			h.logger.Error("conn.Close failed", "user_id", userID, "error", err)
 		}
 
-		// remove conn from slice
+		// remove conn from slice and from its topics
 		h.conns[userID] = append(conns[:i], conns[i+1:]...)
+		h.removeConnTopics(conn)
+		delete(h.writeLocks, conn)
 
 		// if no more connections for user, remove user from map
 		if len(h.conns[userID]) == 0 {
@@ -513,13 +654,10 @@ func (h *NotificationHub) UpgradeHttpRequest(w http.ResponseWriter, r *http.Requ
 	return c, nil
 }
 
-// UserConnCount returns the number of active connections for a given user ID.
+// UserConnCount returns the number of active connections (WebSocket and Server-Sent Events) for a given user ID.
 func (h *NotificationHub) UserConnCount(userID int64) int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if conns, exists := h.conns[userID]; exists {
-		return len(conns)
-	}
-	return 0
+	return len(h.conns[userID]) + len(h.sseClients[userID])
 }
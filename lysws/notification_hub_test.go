@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,6 +32,7 @@ func newTestHub() *NotificationHub {
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(_ *http.Request) bool { return true },
 		},
+		writeLocks: make(map[*websocket.Conn]*sync.Mutex),
 	}
 }
 
@@ -263,6 +265,14 @@ func TestListenAndBroadcastValidation(t *testing.T) {
 		}
 	})
 
+	t.Run("nil route func", func(t *testing.T) {
+		hub := newTestHub()
+		err := hub.ListenAndRoute(context.Background(), nil)
+		if err == nil || !strings.Contains(err.Error(), "routeFunc is required") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
 	t.Run("closed hub", func(t *testing.T) {
 		hub := newTestHub()
 		hub.closed.Store(true)
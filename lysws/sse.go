@@ -0,0 +1,200 @@
+package lysws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseQueueLen is the number of messages buffered per SSE stream before the client is considered too slow and is disconnected
+const sseQueueLen = 64
+
+var (
+	ErrSseClientClosed    = errors.New("sse client closed")
+	ErrSseClientQueueFull = errors.New("sse client queue full")
+)
+
+// sseClient is a Server-Sent Events stream. Messages are queued and written by the goroutine serving the stream.
+type sseClient struct {
+	closeOnce sync.Once
+	done      chan struct{}
+	queue     chan []byte
+}
+
+func newSseClient() *sseClient {
+	return &sseClient{
+		done:  make(chan struct{}),
+		queue: make(chan []byte, sseQueueLen),
+	}
+}
+
+// close ends the stream. It is safe to call more than once.
+func (c *sseClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// send queues msg without blocking.
+func (c *sseClient) send(msg []byte) error {
+	select {
+	case <-c.done:
+		return ErrSseClientClosed
+	default:
+	}
+
+	select {
+	case c.queue <- msg:
+		return nil
+	default:
+		return ErrSseClientQueueFull
+	}
+}
+
+// registerSSE adds an SSE stream for a given user ID, subject to the same limits as Register.
+func (h *NotificationHub) registerSSE(userID int64, c *sseClient) (err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed.Load() {
+		return fmt.Errorf("notification hub is closed")
+	}
+	if len(h.conns[userID])+len(h.sseClients[userID]) >= h.maxUserConnections {
+		h.logger.Error("maximum active connections reached for user", "user_id", userID)
+		return fmt.Errorf("max connections reached")
+	}
+
+	if h.sseClients == nil {
+		h.sseClients = make(map[int64][]*sseClient)
+	}
+	h.sseClients[userID] = append(h.sseClients[userID], c)
+	h.logger.Debug("registered sse stream", "user_id", userID)
+
+	return nil
+}
+
+// unregisterSSE removes and ends an SSE stream for a given user ID.
+func (h *NotificationHub) unregisterSSE(userID int64, c *sseClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c.close()
+
+	i := slices.Index(h.sseClients[userID], c)
+	if i < 0 {
+		return
+	}
+	h.sseClients[userID] = slices.Delete(h.sseClients[userID], i, i+1)
+	if len(h.sseClients[userID]) == 0 {
+		delete(h.sseClients, userID)
+	}
+	h.logger.Debug("unregistered sse stream", "user_id", userID)
+}
+
+// ServeUserSSE streams the user's messages as Server-Sent Events, as an alternative to ServeUserSocket for clients behind proxies which break websocket upgrades.
+// Streams share the user's connection limit with websockets and receive the same messages via Broadcast, SendUser and ListenAndBroadcast.
+// If the request has a Last-Event-ID header (sent by EventSource on reconnect) or last_event_id query param, missed messages are replayed first.
+// It blocks until the client disconnects, the ctx is canceled or the hub is closed. Topic subscriptions are only available via websocket.
+func (h *NotificationHub) ServeUserSSE(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64) (err error) {
+
+	// exit if hub is closed
+	if h.closed.Load() {
+		return fmt.Errorf("notification hub is closed")
+	}
+
+	client := newSseClient()
+	if err = h.registerSSE(userID, client); err != nil {
+		// client should stop reconnecting on this status
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return nil
+	}
+	defer h.unregisterSSE(userID, client)
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering, e.g. nginx
+	w.WriteHeader(http.StatusOK)
+
+	// write sends a single event, bounded by the write wait
+	write := func(b []byte) error {
+		if deadlineErr := rc.SetWriteDeadline(time.Now().Add(h.heartbeatWriteWait)); deadlineErr != nil && !errors.Is(deadlineErr, http.ErrNotSupported) {
+			return fmt.Errorf("rc.SetWriteDeadline failed: %w", deadlineErr)
+		}
+		if _, err := w.Write(b); err != nil {
+			return fmt.Errorf("w.Write failed: %w", err)
+		}
+		if err := rc.Flush(); err != nil {
+			return fmt.Errorf("rc.Flush failed: %w", err)
+		}
+		return nil
+	}
+
+	// send initial comment so that clients and proxies see the stream is open
+	if err = write([]byte(": connected\n\n")); err != nil {
+		return nil
+	}
+
+	// replay missed messages
+	lastId := r.Header.Get("Last-Event-ID")
+	if lastId == "" {
+		lastId = r.URL.Query().Get("last_event_id")
+	}
+	if err = h.replayTo(userID, lastId, func(msg []byte) error { return write(formatSseEvent(msg)) }); err != nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(h.heartbeatPingIntvl)
+	defer ticker.Stop()
+
+	// block until stream is closed (e.g. by client, hub or network issues)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.Context().Done():
+			return nil
+		case <-client.done:
+			return nil
+		case msg := <-client.queue:
+			if err = write(formatSseEvent(msg)); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			// heartbeat comment keeps proxies from closing idle streams and detects dead peers
+			if err = write([]byte(": hb\n\n")); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// formatSseEvent formats msg as an SSE event. The id field is set from the message's id, if any, so that EventSource sends it as Last-Event-ID on reconnect.
+func formatSseEvent(msg []byte) []byte {
+
+	var buf bytes.Buffer
+
+	var payload struct {
+		Id string `json:"id"`
+	}
+	if json.Unmarshal(msg, &payload) == nil && payload.Id != "" && !strings.ContainsAny(payload.Id, "\r\n") {
+		buf.WriteString("id: " + payload.Id + "\n")
+	}
+
+	// each line of the message needs its own data field
+	for line := range bytes.SplitSeq(msg, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,178 @@
+package lysws
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSseEvent reads lines from the stream until a complete event with data is received, skipping comments.
+func readSseEvent(t *testing.T, reader *bufio.Reader) (id, data string) {
+	t.Helper()
+
+	type result struct {
+		id, data string
+		err      error
+	}
+	ch := make(chan result, 1)
+
+	go func() {
+		var r result
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				r.err = err
+				ch <- r
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && r.data != "":
+				ch <- r
+				return
+			case strings.HasPrefix(line, "id: "):
+				r.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				r.data += strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("read sse stream: %v", r.err)
+		}
+		return r.id, r.data
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for sse event")
+	}
+	return "", ""
+}
+
+func openSseStream(t *testing.T, url, lastEventId string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest failed: %v", err)
+	}
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.DefaultClient.Do failed: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func waitForUserConnCount(t *testing.T, hub *NotificationHub, userID int64, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.UserConnCount(userID) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d connections, got %d", want, hub.UserConnCount(userID))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeUserSSE(t *testing.T) {
+	hub := newTestHub()
+	hub.instanceId = "i1"
+	hub.replay = replayBuffer{size: 10}
+	userID := int64(600)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = hub.ServeUserSSE(r.Context(), w, r, userID)
+	}))
+	t.Cleanup(ts.Close)
+
+	resp, reader := openSseStream(t, ts.URL, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response: status %d, content type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitForUserConnCount(t, hub, userID, 1)
+
+	// raw broadcast without id
+	hub.Broadcast(userID, []byte("line1\nline2"))
+	id, data := readSseEvent(t, reader)
+	if id != "" || data != "line1line2" {
+		t.Fatalf("unexpected event: id %q, data %q", id, data)
+	}
+
+	// SendUser message has id
+	if err := hub.SendUser(context.Background(), userID, "note", "one"); err != nil {
+		t.Fatalf("SendUser failed: %v", err)
+	}
+	id, data = readSseEvent(t, reader)
+	if id != "i1-1" || !strings.Contains(data, `"body":"one"`) {
+		t.Fatalf("unexpected event: id %q, data %q", id, data)
+	}
+
+	// disconnect, miss a message, then reconnect with Last-Event-ID
+	_ = resp.Body.Close()
+	waitForUserConnCount(t, hub, userID, 0)
+
+	if err := hub.SendUser(context.Background(), userID, "note", "two"); err != nil {
+		t.Fatalf("SendUser failed: %v", err)
+	}
+
+	_, reader = openSseStream(t, ts.URL, "i1-1")
+	id, data = readSseEvent(t, reader)
+	if id != "i1-2" || !strings.Contains(data, `"body":"two"`) {
+		t.Fatalf("unexpected replayed event: id %q, data %q", id, data)
+	}
+}
+
+func TestServeUserSSESharesMaxUserConnections(t *testing.T) {
+	hub := newTestHub()
+	hub.maxUserConnections = 2
+	userID := int64(601)
+
+	// one websocket
+	serverConn, _, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(userID, serverConn)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = hub.ServeUserSSE(r.Context(), w, r, userID)
+	}))
+	t.Cleanup(ts.Close)
+
+	// one SSE stream is accepted
+	resp, _ := openSseStream(t, ts.URL, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected first stream to be accepted, got status %d", resp.StatusCode)
+	}
+	waitForUserConnCount(t, hub, userID, 2)
+	if got := hub.Status()[userID]; got != 2 {
+		t.Fatalf("expected Status to count both transports, got %d", got)
+	}
+
+	// next is rejected
+	resp2, _ := openSseStream(t, ts.URL, "")
+	if resp2.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got status %d", resp2.StatusCode)
+	}
+
+	// closing the hub ends the stream
+	_ = hub.Close()
+	waitForUserConnCount(t, hub, userID, 0)
+}
+
+func TestFormatSseEvent(t *testing.T) {
+	got := string(formatSseEvent([]byte(`{"id":"i1-5","type":"x","body":"y"}`)))
+	want := "id: i1-5\ndata: {\"id\":\"i1-5\",\"type\":\"x\",\"body\":\"y\"}\n\n"
+	if got != want {
+		t.Fatalf("unexpected event: got %q, want %q", got, want)
+	}
+}
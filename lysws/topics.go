@@ -0,0 +1,261 @@
+package lysws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gorilla/websocket"
+)
+
+// client to server actions
+const (
+	ClientActionReplay      string = "replay"
+	ClientActionSubscribe   string = "subscribe"
+	ClientActionUnsubscribe string = "unsubscribe"
+)
+
+// server to client message types sent in reply to client actions
+const (
+	MsgTypeReplayGap       string = "replay_gap" // some messages after last_id are no longer available, so client should reload its state
+	MsgTypeSubscribed      string = "subscribed"
+	MsgTypeSubscribeFailed string = "subscribe_failed"
+	MsgTypeUnsubscribed    string = "unsubscribed"
+)
+
+const maxTopicLen = 200
+
+var (
+	ErrConnNotRegistered    = errors.New("connection is not registered for user")
+	ErrInvalidTopic         = fmt.Errorf("topic must have between 1 and %d characters", maxTopicLen)
+	ErrMaxTopicsReached     = errors.New("max topics reached")
+	ErrSubscribeNotAllowed  = errors.New("subscription not allowed")
+	ErrSubscribeAuthFailure = errors.New("subscription authorization failed")
+)
+
+// SubscribeAuthFunc decides whether a user may subscribe to a topic, e.g. by checking that the user may read the order in "order:123".
+type SubscribeAuthFunc func(ctx context.Context, userID int64, topic string) (allowed bool, err error)
+
+// clientMessage is a message sent by the client, e.g. {"action":"subscribe","topic":"order:123"} or {"action":"replay","last_id":"n-42"}.
+type clientMessage struct {
+	Action string `json:"action"`
+	LastId string `json:"last_id,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+}
+
+// messagePayload is the JSON message sent to clients.
+type messagePayload struct {
+	Id    string `json:"id,omitempty"` // set on user messages which can be replayed
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Body  string `json:"body"`
+}
+
+// handleClientMessage processes a text message received from the client. Invalid messages are ignored.
+func (h *NotificationHub) handleClientMessage(ctx context.Context, userID int64, c *websocket.Conn, data []byte) {
+
+	var cm clientMessage
+	if err := json.Unmarshal(data, &cm); err != nil {
+		h.logger.Debug("json.Unmarshal failed for client message", "user_id", userID, "error", err)
+		return
+	}
+
+	reply := messagePayload{Topic: cm.Topic}
+
+	switch cm.Action {
+
+	case ClientActionSubscribe:
+		reply.Type = MsgTypeSubscribed
+		if err := h.authorizeAndSubscribe(ctx, userID, c, cm.Topic); err != nil {
+			reply.Type = MsgTypeSubscribeFailed
+			reply.Body = err.Error()
+		}
+
+	case ClientActionUnsubscribe:
+		h.Unsubscribe(c, cm.Topic)
+		reply.Type = MsgTypeUnsubscribed
+
+	case ClientActionReplay:
+		if err := h.replayTo(userID, cm.LastId, func(msg []byte) error { return h.writeMessage(c, msg) }); err != nil {
+			h.logger.Debug("h.replayTo failed", "user_id", userID, "error", err)
+		}
+		return
+
+	default:
+		h.logger.Debug("unknown client action", "user_id", userID, "action", cm.Action)
+		return
+	}
+
+	msgBytes, err := json.Marshal(reply)
+	if err != nil {
+		h.logger.Error("json.Marshal failed", "payload", reply, "error", err)
+		return
+	}
+	if err := h.writeMessage(c, msgBytes); err != nil {
+		h.logger.Debug("h.writeMessage failed", "user_id", userID, "error", err)
+	}
+}
+
+// authorizeAndSubscribe checks the subscription using the hub's SubscribeAuthFunc before subscribing.
+func (h *NotificationHub) authorizeAndSubscribe(ctx context.Context, userID int64, c *websocket.Conn, topic string) (err error) {
+
+	if h.subscribeAuthFunc == nil {
+		return ErrSubscribeNotAllowed
+	}
+	if topic == "" || len(topic) > maxTopicLen {
+		return ErrInvalidTopic
+	}
+
+	allowed, err := h.subscribeAuthFunc(ctx, userID, topic)
+	if err != nil {
+		// don't expose internal error to client
+		h.logger.Error("h.subscribeAuthFunc failed", "user_id", userID, "topic", topic, "error", err)
+		return ErrSubscribeAuthFailure
+	}
+	if !allowed {
+		return ErrSubscribeNotAllowed
+	}
+
+	return h.Subscribe(userID, c, topic)
+}
+
+// PublishTopic sends a message to all connections subscribed to topic.
+func (h *NotificationHub) PublishTopic(topic string, msg []byte) {
+	_ = h.publishTopic(topic, 0, msg, true)
+}
+
+// PublishTopicE sends a message to all connections subscribed to topic.
+// It returns an error if any connection fails to send the message, but continues to attempt sending to all connections.
+func (h *NotificationHub) PublishTopicE(topic string, msg []byte) (err error) {
+	return h.publishTopic(topic, 0, msg, false)
+}
+
+// publishTopic sends msg to the topic's subscribers. If userID is not 0, only that user's subscribed connections receive it.
+func (h *NotificationHub) publishTopic(topic string, userID int64, msg []byte, logFailures bool) (err error) {
+
+	// copy subscribers to avoid iteration issues due to Unregister calls
+	h.mu.RLock()
+	subs := make(map[*websocket.Conn]int64, len(h.topics[topic]))
+	for conn, subUserID := range h.topics[topic] {
+		if userID == 0 || subUserID == userID {
+			subs[conn] = subUserID
+		}
+	}
+	h.mu.RUnlock()
+
+	for conn, subUserID := range subs {
+		h.logger.Debug("publishing message", "topic", topic, "user_id", subUserID, "message", string(msg))
+		if connErr := h.writeMessage(conn, msg); connErr != nil {
+			if logFailures {
+				h.logger.Error("h.writeMessage failed", "topic", topic, "user_id", subUserID, "error", connErr)
+			}
+			h.Unregister(subUserID, conn)
+			err = errors.Join(err, connErr)
+		}
+	}
+	return err
+}
+
+// removeConnTopics removes the connection from all of its topics. The caller must hold h.mu.
+func (h *NotificationHub) removeConnTopics(c *websocket.Conn) {
+	for topic := range h.connTopics[c] {
+		delete(h.topics[topic], c)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	delete(h.connTopics, c)
+}
+
+// Subscribe adds a registered connection to topic without authorization checks. Use it for server-initiated subscriptions.
+func (h *NotificationHub) Subscribe(userID int64, c *websocket.Conn, topic string) (err error) {
+
+	if topic == "" || len(topic) > maxTopicLen {
+		return ErrInvalidTopic
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !slices.Contains(h.conns[userID], c) {
+		return ErrConnNotRegistered
+	}
+
+	if h.connTopics == nil {
+		h.connTopics = make(map[*websocket.Conn]map[string]struct{})
+	}
+	if h.topics == nil {
+		h.topics = make(map[string]map[*websocket.Conn]int64)
+	}
+
+	// already subscribed
+	if _, exists := h.connTopics[c][topic]; exists {
+		return nil
+	}
+
+	if h.maxConnTopics > 0 && len(h.connTopics[c]) >= h.maxConnTopics {
+		return ErrMaxTopicsReached
+	}
+
+	if h.connTopics[c] == nil {
+		h.connTopics[c] = make(map[string]struct{})
+	}
+	h.connTopics[c][topic] = struct{}{}
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*websocket.Conn]int64)
+	}
+	h.topics[topic][c] = userID
+
+	h.logger.Debug("subscribed connection", "user_id", userID, "topic", topic)
+	return nil
+}
+
+// TopicStatus returns a snapshot of the number of subscribed connections for each topic.
+func (h *NotificationHub) TopicStatus() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status := make(map[string]int, len(h.topics))
+	for topic, subs := range h.topics {
+		status[topic] = len(subs)
+	}
+	return status
+}
+
+// Unsubscribe removes the connection from topic. It is a no-op if the connection is not subscribed.
+func (h *NotificationHub) Unsubscribe(c *websocket.Conn, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.connTopics[c][topic]; !exists {
+		return
+	}
+
+	delete(h.connTopics[c], topic)
+	if len(h.connTopics[c]) == 0 {
+		delete(h.connTopics, c)
+	}
+	delete(h.topics[topic], c)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// writeMessage writes a text message to the registered connection, serializing concurrent writers.
+// The write lock is only looked up, never created, so that a write racing with Unregister cannot leave a lock behind.
+func (h *NotificationHub) writeMessage(c *websocket.Conn, msg []byte) error {
+	h.mu.RLock()
+	lock, ok := h.writeLocks[c]
+	h.mu.RUnlock()
+	if !ok {
+		return ErrConnNotRegistered
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	return c.WriteMessage(websocket.TextMessage, msg)
+}
@@ -0,0 +1,182 @@
+package lysws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func readTestMessage(t *testing.T, conn *websocket.Conn) messagePayload {
+	t.Helper()
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("set read deadline: %v", err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	var payload messagePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal message %s: %v", data, err)
+	}
+	return payload
+}
+
+func TestSubscribeAndPublishTopic(t *testing.T) {
+	hub := newTestHub()
+
+	serverConn1, clientConn1, cleanup1 := newWebsocketPair(t)
+	t.Cleanup(cleanup1)
+	serverConn2, clientConn2, cleanup2 := newWebsocketPair(t)
+	t.Cleanup(cleanup2)
+
+	hub.Register(1, serverConn1)
+	hub.Register(2, serverConn2)
+
+	// conn must be registered for the user
+	if err := hub.Subscribe(2, serverConn1, "order:1"); !errors.Is(err, ErrConnNotRegistered) {
+		t.Fatalf("expected ErrConnNotRegistered, got: %v", err)
+	}
+	if err := hub.Subscribe(1, serverConn1, ""); !errors.Is(err, ErrInvalidTopic) {
+		t.Fatalf("expected ErrInvalidTopic, got: %v", err)
+	}
+
+	for _, userConn := range []struct {
+		userID int64
+		conn   *websocket.Conn
+	}{{1, serverConn1}, {2, serverConn2}} {
+		if err := hub.Subscribe(userConn.userID, userConn.conn, "order:1"); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+	if got := hub.TopicStatus()["order:1"]; got != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", got)
+	}
+
+	hub.PublishTopic("order:1", []byte(`{"type":"changed","topic":"order:1","body":"a"}`))
+	for _, clientConn := range []*websocket.Conn{clientConn1, clientConn2} {
+		if got := readTestMessage(t, clientConn); got.Body != "a" {
+			t.Fatalf("unexpected message body: %s", got.Body)
+		}
+	}
+
+	// unsubscribed conn no longer receives messages
+	hub.Unsubscribe(serverConn2, "order:1")
+	if got := hub.TopicStatus()["order:1"]; got != 1 {
+		t.Fatalf("expected 1 subscriber after Unsubscribe, got %d", got)
+	}
+
+	// unregistering removes conn from its topics
+	hub.Unregister(1, serverConn1)
+	if got := len(hub.TopicStatus()); got != 0 {
+		t.Fatalf("expected no topics after Unregister, got %d", got)
+	}
+}
+
+func TestSubscribeEnforcesMaxConnTopics(t *testing.T) {
+	hub := newTestHub()
+	hub.maxConnTopics = 1
+
+	serverConn, _, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(1, serverConn)
+
+	if err := hub.Subscribe(1, serverConn, "a"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	// resubscribing is a no-op
+	if err := hub.Subscribe(1, serverConn, "a"); err != nil {
+		t.Fatalf("Subscribe (repeat) failed: %v", err)
+	}
+	if err := hub.Subscribe(1, serverConn, "b"); !errors.Is(err, ErrMaxTopicsReached) {
+		t.Fatalf("expected ErrMaxTopicsReached, got: %v", err)
+	}
+}
+
+func TestServeUserSocketSubscribeProtocol(t *testing.T) {
+	hub := newTestHub()
+	userID := int64(7)
+
+	hub.subscribeAuthFunc = func(ctx context.Context, uID int64, topic string) (bool, error) {
+		if topic == "broken" {
+			return false, errors.New("db down")
+		}
+		return uID == userID && strings.HasPrefix(topic, "order:"), nil
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = hub.ServeUserSocket(r.Context(), w, r, userID)
+	}))
+	t.Cleanup(ts.Close)
+
+	clientConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial websocket: %v", err)
+	}
+	t.Cleanup(func() { _ = clientConn.Close() })
+
+	tests := []struct {
+		action   string
+		topic    string
+		wantType string
+		wantBody string
+	}{
+		{ClientActionSubscribe, "order:1", MsgTypeSubscribed, ""},
+		{ClientActionSubscribe, "dashboard:sales", MsgTypeSubscribeFailed, ErrSubscribeNotAllowed.Error()},
+		{ClientActionSubscribe, "broken", MsgTypeSubscribeFailed, ErrSubscribeAuthFailure.Error()},
+		{ClientActionSubscribe, "order:2", MsgTypeSubscribed, ""},
+		{ClientActionUnsubscribe, "order:2", MsgTypeUnsubscribed, ""},
+	}
+	for _, tt := range tests {
+		if err := clientConn.WriteJSON(clientMessage{Action: tt.action, Topic: tt.topic}); err != nil {
+			t.Fatalf("write client message: %v", err)
+		}
+		got := readTestMessage(t, clientConn)
+		if got.Type != tt.wantType || got.Topic != tt.topic || got.Body != tt.wantBody {
+			t.Fatalf("%s %s: unexpected reply: %+v", tt.action, tt.topic, got)
+		}
+	}
+
+	status := hub.TopicStatus()
+	if len(status) != 1 || status["order:1"] != 1 {
+		t.Fatalf("unexpected topic status: %v", status)
+	}
+
+	// publish to the subscribed topic, and to the topic filtered by another user
+	if err := hub.publishTopic("order:1", 99, []byte(`{"type":"x","body":"other user"}`), false); err != nil {
+		t.Fatalf("publishTopic failed: %v", err)
+	}
+	if err := hub.PublishTopicE("order:1", []byte(`{"type":"x","body":"hello"}`)); err != nil {
+		t.Fatalf("PublishTopicE failed: %v", err)
+	}
+	if got := readTestMessage(t, clientConn); got.Body != "hello" {
+		t.Fatalf("unexpected message body: %s", got.Body)
+	}
+}
+
+func TestClientSubscribeRejectedWithoutAuthFunc(t *testing.T) {
+	hub := newTestHub()
+
+	serverConn, clientConn, cleanup := newWebsocketPair(t)
+	t.Cleanup(cleanup)
+	hub.Register(1, serverConn)
+
+	hub.handleClientMessage(context.Background(), 1, serverConn, []byte(`{"action":"subscribe","topic":"order:1"}`))
+
+	got := readTestMessage(t, clientConn)
+	if got.Type != MsgTypeSubscribeFailed || got.Body != ErrSubscribeNotAllowed.Error() {
+		t.Fatalf("unexpected reply: %+v", got)
+	}
+	if len(hub.TopicStatus()) != 0 {
+		t.Fatal("expected no subscriptions")
+	}
+}
@@ -0,0 +1,343 @@
+package lys
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// error categories, as split by HandleError. Db errors caused by bad input are user errors
+const (
+	ErrCategoryDb       string = "db"
+	ErrCategoryExt      string = "ext"
+	ErrCategoryInternal string = "internal"
+	ErrCategoryNone     string = "none"
+	ErrCategoryUser     string = "user"
+)
+
+const (
+	defaultMetricsNamespace string = "lys"
+	metricsContentType      string = "text/plain; version=0.0.4; charset=utf-8"
+	unmatchedRoute          string = "unmatched"
+)
+
+// defaultMetricsBuckets are the upper bounds in seconds of the request duration histogram buckets, as used by Prometheus clients
+var defaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsOptions contains the options used by Metrics.
+type MetricsOptions struct {
+	Buckets   []float64 // upper bounds in seconds of the request duration histogram buckets. Default: Prometheus client defaults
+	Namespace string    // prefix of all metric names. Default "lys"
+}
+
+type requestKey struct {
+	method      string
+	route       string
+	status      int
+	errCategory string
+}
+
+type durationKey struct {
+	method string
+	route  string
+}
+
+type durationHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// gaugeFunc returns the current values of a gauge or counter, one per label set
+type gaugeFunc struct {
+	name       string
+	help       string
+	metricType string // "gauge" or "counter"
+	valuesFunc func() []labeledValue
+}
+
+type labeledValue struct {
+	labels [][2]string
+	value  float64
+}
+
+// Metrics records per-route request metrics and exports them, together with registered pool, hub and session gauges, in Prometheus text format.
+// It has no dependency on a Prometheus client.
+type Metrics struct {
+	buckets   []float64
+	durations map[durationKey]*durationHistogram
+	gauges    []gaugeFunc
+	mu        sync.Mutex
+	namespace string
+	requests  map[requestKey]uint64
+}
+
+// NewMetrics returns a new Metrics.
+func NewMetrics(options ...MetricsOptions) (m *Metrics, err error) {
+
+	opts := MetricsOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	if opts.Namespace == "" {
+		opts.Namespace = defaultMetricsNamespace
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = defaultMetricsBuckets
+	}
+	if !slices.IsSorted(opts.Buckets) {
+		return nil, fmt.Errorf("buckets must be sorted")
+	}
+
+	return &Metrics{
+		buckets:   slices.Clone(opts.Buckets),
+		durations: make(map[durationKey]*durationHistogram),
+		namespace: opts.Namespace,
+		requests:  make(map[requestKey]uint64),
+	}, nil
+}
+
+// Middleware records the duration, status code and error category of each request. The route label is the mux path template, so it should be added with router.Use.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		sw := &StatusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		m.observe(r.Method, routeTemplate(r), sw.Status, sw.ErrCategory, time.Since(start))
+	})
+}
+
+// observe records a single request
+func (m *Metrics) observe(method, route string, status int, errCategory string, duration time.Duration) {
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if errCategory == "" {
+		errCategory = ErrCategoryNone
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method: method, route: route, status: status, errCategory: errCategory}]++
+
+	dk := durationKey{method: method, route: route}
+	h, ok := m.durations[dk]
+	if !ok {
+		h = &durationHistogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[dk] = h
+	}
+	secs := duration.Seconds()
+	if i, _ := slices.BinarySearch(m.buckets, secs); i < len(m.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += secs
+}
+
+// AddGaugeFunc exports the value returned by valueFunc as a gauge. name is prefixed with the namespace.
+func (m *Metrics) AddGaugeFunc(name, help string, valueFunc func() float64) {
+	m.addGauge(name, help, "gauge", func() []labeledValue { return []labeledValue{{value: valueFunc()}} })
+}
+
+func (m *Metrics) addGauge(name, help, metricType string, valuesFunc func() []labeledValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gauges = append(m.gauges, gaugeFunc{name: m.namespace + "_" + name, help: help, metricType: metricType, valuesFunc: valuesFunc})
+}
+
+// AddHub exports the user and connection counts of a hub such as lysws.NotificationHub, labeled with hubName.
+func (m *Metrics) AddHub(hubName string, hub interface{ Status() map[int64]int }) {
+
+	labels := [][2]string{{"hub", hubName}}
+
+	m.addGauge("hub_users", "Number of users with open connections.", "gauge", func() []labeledValue {
+		return []labeledValue{{labels: labels, value: float64(len(hub.Status()))}}
+	})
+	m.addGauge("hub_connections", "Number of open connections.", "gauge", func() []labeledValue {
+		total := 0
+		for _, n := range hub.Status() {
+			total += n
+		}
+		return []labeledValue{{labels: labels, value: float64(total)}}
+	})
+}
+
+// AddPool exports the stats of db, labeled with poolName.
+func (m *Metrics) AddPool(poolName string, db *pgxpool.Pool) {
+
+	labels := [][2]string{{"pool", poolName}}
+
+	stat := func(f func(s *pgxpool.Stat) float64) func() []labeledValue {
+		return func() []labeledValue {
+			return []labeledValue{{labels: labels, value: f(db.Stat())}}
+		}
+	}
+
+	m.addGauge("pgxpool_acquired_conns", "Number of currently acquired connections.", "gauge", stat(func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }))
+	m.addGauge("pgxpool_constructing_conns", "Number of connections being constructed.", "gauge", stat(func(s *pgxpool.Stat) float64 { return float64(s.ConstructingConns()) }))
+	m.addGauge("pgxpool_idle_conns", "Number of idle connections.", "gauge", stat(func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }))
+	m.addGauge("pgxpool_max_conns", "Maximum size of the pool.", "gauge", stat(func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }))
+	m.addGauge("pgxpool_total_conns", "Total number of connections.", "gauge", stat(func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }))
+	m.addGauge("pgxpool_acquire_count_total", "Number of successful acquires.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }))
+	m.addGauge("pgxpool_acquire_duration_seconds_total", "Total duration of successful acquires.", "counter", stat(func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }))
+	m.addGauge("pgxpool_canceled_acquire_count_total", "Number of acquires canceled by a context.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) }))
+	m.addGauge("pgxpool_empty_acquire_count_total", "Number of acquires which waited for a connection.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }))
+	m.addGauge("pgxpool_new_conns_count_total", "Number of new connections opened.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.NewConnsCount()) }))
+	m.addGauge("pgxpool_max_idle_destroy_count_total", "Number of connections closed due to MaxConnIdleTime.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.MaxIdleDestroyCount()) }))
+	m.addGauge("pgxpool_max_lifetime_destroy_count_total", "Number of connections closed due to MaxConnLifetime.", "counter", stat(func(s *pgxpool.Stat) float64 { return float64(s.MaxLifetimeDestroyCount()) }))
+}
+
+// AddSessions exports the session count of a store such as lysauth.AppSessions, labeled with sessionsName.
+func (m *Metrics) AddSessions(sessionsName string, sessions interface{ Count() int }) {
+
+	m.addGauge("sessions", "Number of sessions.", "gauge", func() []labeledValue {
+		return []labeledValue{{labels: [][2]string{{"sessions", sessionsName}}, value: float64(sessions.Count())}}
+	})
+}
+
+// Handler serves the metrics in Prometheus text format.
+func (m *Metrics) Handler() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		if err := m.Write(w); err != nil {
+			// headers are already sent
+			return
+		}
+	}
+}
+
+// Write writes the metrics in Prometheus text format to w.
+func (m *Metrics) Write(w io.Writer) (err error) {
+
+	bw := bufio.NewWriter(w)
+
+	m.mu.Lock()
+	m.writeRequests(bw)
+	m.writeDurations(bw)
+	gauges := slices.Clone(m.gauges)
+	m.mu.Unlock()
+
+	// gauge funcs are called without lock since they may be slow. Metrics of the same name are written together
+	slices.SortStableFunc(gauges, func(a, b gaugeFunc) int { return strings.Compare(a.name, b.name) })
+	for i, g := range gauges {
+		if i == 0 || gauges[i-1].name != g.name {
+			writeMetricHeader(bw, g.name, g.help, g.metricType)
+		}
+		for _, lv := range g.valuesFunc() {
+			writeSample(bw, g.name, lv.labels, lv.value)
+		}
+	}
+
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("bw.Flush failed: %w", err)
+	}
+	return nil
+}
+
+// writeRequests writes the request counter. m.mu must be held
+func (m *Metrics) writeRequests(w *bufio.Writer) {
+
+	name := m.namespace + "_http_requests_total"
+	writeMetricHeader(w, name, "Number of HTTP requests by route, status code and error category.", "counter")
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		if c := strings.Compare(a.route, b.route); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.method, b.method); c != 0 {
+			return c
+		}
+		if a.status != b.status {
+			return a.status - b.status
+		}
+		return strings.Compare(a.errCategory, b.errCategory)
+	})
+
+	for _, k := range keys {
+		labels := [][2]string{{"method", k.method}, {"route", k.route}, {"status", strconv.Itoa(k.status)}, {"error_category", k.errCategory}}
+		writeSample(w, name, labels, float64(m.requests[k]))
+	}
+}
+
+// writeDurations writes the request duration histogram. m.mu must be held
+func (m *Metrics) writeDurations(w *bufio.Writer) {
+
+	name := m.namespace + "_http_request_duration_seconds"
+	writeMetricHeader(w, name, "Duration of HTTP requests by route.", "histogram")
+
+	keys := make([]durationKey, 0, len(m.durations))
+	for k := range m.durations {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b durationKey) int {
+		if c := strings.Compare(a.route, b.route); c != 0 {
+			return c
+		}
+		return strings.Compare(a.method, b.method)
+	})
+
+	for _, k := range keys {
+		h := m.durations[k]
+		labels := [][2]string{{"method", k.method}, {"route", k.route}}
+
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			writeSample(w, name+"_bucket", append(slices.Clone(labels), [2]string{"le", formatFloat(le)}), float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", append(slices.Clone(labels), [2]string{"le", "+Inf"}), float64(h.count))
+		writeSample(w, name+"_sum", labels, h.sum)
+		writeSample(w, name+"_count", labels, float64(h.count))
+	}
+}
+
+func writeMetricHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeSample(w *bufio.Writer, name string, labels [][2]string, value float64) {
+
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l[0] + `="` + escapeLabelValue(l[1]) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
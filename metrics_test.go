@@ -0,0 +1,107 @@
+package lys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loveyourstack/lys/lyserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHub struct{}
+
+func (testHub) Status() map[int64]int { return map[int64]int{1: 2, 2: 1} }
+
+type testSessions struct{}
+
+func (testSessions) Count() int { return 4 }
+
+func TestMetrics(t *testing.T) {
+	m, err := NewMetrics(MetricsOptions{Buckets: []float64{0.1, 1}})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch mux.Vars(r)["id"] {
+		case "bad":
+			HandleError(r.Context(), lyserr.User{Message: "bad id"}, discardLog, w)
+		case "broken":
+			HandleError(r.Context(), errors.New("broken"), discardLog, w)
+		default:
+			JsonResponse(StdResponse{Status: ReqSucceeded}, http.StatusOK, w)
+		}
+	}).Methods("GET")
+
+	for _, id := range []string{"1", "2", "bad", "broken"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/"+id, nil))
+	}
+
+	m.AddHub("main", testHub{})
+	m.AddSessions("app", testSessions{})
+	m.AddGaugeFunc("custom_value", "A custom value.", func() float64 { return 1.5 })
+
+	pool, err := pgxpool.New(context.Background(), "postgres://user@localhost:1/db")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	m.AddPool("main", pool)
+
+	w := httptest.NewRecorder()
+	m.Handler()(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, metricsContentType, w.Header().Get("Content-Type"))
+	out := w.Body.String()
+
+	for _, want := range []string{
+		"# TYPE lys_http_requests_total counter\n",
+		`lys_http_requests_total{method="GET",route="/items/{id}",status="200",error_category="none"} 2` + "\n",
+		`lys_http_requests_total{method="GET",route="/items/{id}",status="400",error_category="user"} 1` + "\n",
+		`lys_http_requests_total{method="GET",route="/items/{id}",status="500",error_category="internal"} 1` + "\n",
+		"# TYPE lys_http_request_duration_seconds histogram\n",
+		`lys_http_request_duration_seconds_bucket{method="GET",route="/items/{id}",le="+Inf"} 4` + "\n",
+		`lys_http_request_duration_seconds_count{method="GET",route="/items/{id}"} 4` + "\n",
+		`lys_hub_users{hub="main"} 2` + "\n",
+		`lys_hub_connections{hub="main"} 3` + "\n",
+		`lys_sessions{sessions="app"} 4` + "\n",
+		"lys_custom_value 1.5\n",
+		`lys_pgxpool_max_conns{pool="main"} `,
+		"# TYPE lys_pgxpool_acquire_count_total counter\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+
+	// each metric has a single header
+	assert.Equal(t, 1, strings.Count(out, "# TYPE lys_hub_users gauge"))
+}
+
+func TestMetricsObserveBuckets(t *testing.T) {
+	m, err := NewMetrics(MetricsOptions{Buckets: []float64{0.1, 1}, Namespace: "app"})
+	require.NoError(t, err)
+
+	m.observe("GET", unmatchedRoute, 0, "", 50*time.Millisecond)
+	m.observe("GET", unmatchedRoute, 0, "", 100*time.Millisecond)
+	m.observe("GET", unmatchedRoute, 0, "", 2*time.Second)
+
+	var sb strings.Builder
+	require.NoError(t, m.Write(&sb))
+	out := sb.String()
+
+	assert.Contains(t, out, `app_http_request_duration_seconds_bucket{method="GET",route="unmatched",le="0.1"} 2`+"\n")
+	assert.Contains(t, out, `app_http_request_duration_seconds_bucket{method="GET",route="unmatched",le="1"} 2`+"\n")
+	assert.Contains(t, out, `app_http_request_duration_seconds_bucket{method="GET",route="unmatched",le="+Inf"} 3`+"\n")
+	assert.Contains(t, out, `app_http_requests_total{method="GET",route="unmatched",status="200",error_category="none"} 3`+"\n")
+
+	_, err = NewMetrics(MetricsOptions{Buckets: []float64{1, 0.1}})
+	assert.Error(t, err)
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabelValue("a\\b\"c\nd"))
+}
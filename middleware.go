@@ -0,0 +1,152 @@
+package lys
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loveyourstack/lys/lyslog"
+	"github.com/loveyourstack/lys/lyspgdb"
+	"github.com/loveyourstack/lys/lysstring"
+	"github.com/loveyourstack/lys/lystrace"
+)
+
+// RequestIdHeader is the header used by RequestId to receive and return the request ID
+const RequestIdHeader string = "X-Request-ID"
+
+// RequestIdCtxKey is the key used by RequestId to bind the request ID to a request via context
+const RequestIdCtxKey lyspgdb.ContextKey = "RequestIdKey"
+
+const maxRequestIdLen int = 128
+
+// GetRequestIdFromCtx returns the request ID bound to ctx by RequestId, or "" if there is none.
+func GetRequestIdFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIdCtxKey).(string)
+	return id
+}
+
+// RequestId is middleware which propagates the X-Request-ID header sent by the client or proxy, or assigns a new ID if missing or invalid.
+// The ID is returned in the response header, bound to the request ctx, and added to the ctx attrs logged by lyslog.ContextHandler.
+// It should be the outermost middleware so that all other middleware can use the ID.
+func RequestId(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.Header.Get(RequestIdHeader)
+		if !validRequestId(id) {
+			id = lysstring.Rand(16)
+		}
+
+		w.Header().Set(RequestIdHeader, id)
+
+		ctx := context.WithValue(r.Context(), RequestIdCtxKey, id)
+		ctx = lyslog.AppendCtx(ctx, slog.String("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestId returns true if id is not empty, not too long, and only contains safe characters, since it is logged and returned
+func validRequestId(id string) bool {
+
+	if id == "" || len(id) > maxRequestIdLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog returns middleware which logs one line per request at Info level with the method, route template, status, bytes, duration, user and request ID.
+// The route template and user are only available if it is added with router.Use and runs after the middleware which binds the user info to the request.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			start := time.Now()
+			sw := &StatusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r)
+
+			status := sw.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			ctx := r.Context()
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", routeTemplate(r)),
+				slog.Int("status", status),
+				slog.Int("bytes", sw.Bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("user", GetUserNameFromCtx(ctx)),
+			}
+			if id := GetRequestIdFromCtx(ctx); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			if sw.ErrCategory != "" {
+				attrs = append(attrs, slog.String("error_category", sw.ErrCategory))
+			}
+
+			logger.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+		})
+	}
+}
+
+// routeTemplate returns the path template of the mux route matched by r, or "unmatched"
+func routeTemplate(r *http.Request) string {
+
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
+
+// Tracing returns middleware which starts a root span named "<method> <route template>" for each request, continuing the trace of the traceparent header if sent.
+// Child spans, such as those of lyspg queries, are started from the request ctx. Errors logged by the error handlers are recorded on the span.
+// Like AccessLog, the route template is only available if it is added with router.Use.
+func Tracing(tracer *lystrace.Tracer) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			route := routeTemplate(r)
+			ctx, span := tracer.StartRoot(r.Context(), r.Method+" "+route, r.Header.Get(lystrace.TraceparentHeader))
+			span.SetAttr(lystrace.AttrHttpMethod, r.Method)
+			span.SetAttr(lystrace.AttrHttpRoute, route)
+			if id := GetRequestIdFromCtx(ctx); id != "" {
+				span.SetAttr("request_id", id)
+			}
+
+			sw := &StatusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			status := sw.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttr(lystrace.AttrHttpStatusCode, strconv.Itoa(status))
+
+			var err error
+			if status >= http.StatusInternalServerError {
+				err = fmt.Errorf("status %d", status)
+			}
+			span.End(err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package lys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/loveyourstack/lys/lyslog"
+	"github.com/loveyourstack/lys/lystrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testNamedUserInfo struct{}
+
+func (testNamedUserInfo) GetUserName() string { return "alice" }
+
+func TestRequestId(t *testing.T) {
+
+	var gotId string
+	handler := RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotId = GetRequestIdFromCtx(r.Context())
+	}))
+
+	// propagated
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIdHeader, "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", gotId)
+	assert.Equal(t, "abc-123", w.Header().Get(RequestIdHeader))
+
+	// assigned if missing or invalid
+	for _, sent := range []string{"", "bad id\n", strings.Repeat("a", maxRequestIdLen+1)} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIdHeader, sent)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Len(t, gotId, 16, sent)
+		assert.Equal(t, gotId, w.Header().Get(RequestIdHeader), sent)
+	}
+}
+
+func TestAccessLogAndErrorLog(t *testing.T) {
+
+	var stdout, stderr bytes.Buffer
+	logger := slog.New(lyslog.NewContextHandler(lyslog.NewSplitStreamHandler(&stdout, &stderr, nil)))
+
+	r := mux.NewRouter()
+	r.Use(RequestId, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserInfoCtxKey, testNamedUserInfo{})))
+		})
+	}, AccessLog(logger))
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "in handler")
+		HandleError(r.Context(), errors.New("broken"), logger, w)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(RequestIdHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 info lines, got: %q", stdout.String())
+	}
+
+	// ctx attrs are added by ContextHandler
+	assert.Contains(t, lines[0], "request_id=req-1")
+
+	// access log
+	for _, want := range []string{"msg=request", "method=GET", "route=/items/{id}", "status=500", "bytes=", "duration=", "user=alice", "request_id=req-1", "error_category=internal"} {
+		assert.Contains(t, lines[1], want)
+	}
+	assert.Equal(t, 1, strings.Count(lines[1], "request_id="))
+
+	// error log includes request ID once
+	assert.Contains(t, stderr.String(), "request_id=req-1")
+	assert.Equal(t, 1, strings.Count(stderr.String(), "request_id="))
+}
+
+func TestTracing(t *testing.T) {
+
+	exp := &lystrace.MemoryExporter{}
+	tracer, err := lystrace.NewTracer(exp)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.Use(Tracing(tracer))
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := lystrace.Start(r.Context(), "child")
+		span.End(nil)
+		HandleError(r.Context(), errors.New("broken"), discardLog, w)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(lystrace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exp.Spans()
+	require.Len(t, spans, 2)
+	child, root := spans[0], spans[1]
+
+	assert.Equal(t, "GET /items/{id}", root.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.TraceId)
+	assert.Equal(t, "00f067aa0ba902b7", root.ParentSpanId)
+	assert.Equal(t, "/items/{id}", root.Attrs[lystrace.AttrHttpRoute])
+	assert.Equal(t, "500", root.Attrs[lystrace.AttrHttpStatusCode])
+	assert.Equal(t, "broken", root.Err)
+
+	assert.Equal(t, root.TraceId, child.TraceId)
+	assert.Equal(t, root.SpanId, child.ParentSpanId)
+}
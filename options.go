@@ -12,6 +12,7 @@ const (
 	defaultPageParamName    string = "xpage"
 	defaultPerPageParamName string = "xper_page"
 	defaultSortParamName    string = "xsort"
+	defaultTzParamName      string = "xtz"
 
 	defaultMultipleValueSeparator string = "|"
 	defaultMetadataSeparator      string = "^"
@@ -37,6 +38,7 @@ type GetOptions struct {
 	PageParamName    string // name of the param which defines the page offset returned by a paged GET request, e.g. "xpage=1"
 	PerPageParamName string // name of the param which defines the number of records returned by a paged GET request, e.g. "xper_page=20"
 	SortParamName    string // name of the param which sorts the records returned by a GET request, e.g. "xsort=name,-age"
+	TzParamName      string // name of the param which sets the timezone of datetimes in a GET request, e.g. "xtz=Europe/Berlin". Overrides the TimezoneHeader
 
 	// separators
 
@@ -84,6 +86,9 @@ func FillGetOptions(input GetOptions) (ret GetOptions, err error) {
 	if ret.SortParamName == "" {
 		ret.SortParamName = defaultSortParamName
 	}
+	if ret.TzParamName == "" {
+		ret.TzParamName = defaultTzParamName
+	}
 	if ret.MultipleValueSeparator == "" {
 		ret.MultipleValueSeparator = defaultMultipleValueSeparator
 	}
@@ -110,6 +115,7 @@ func FillGetOptions(input GetOptions) (ret GetOptions, err error) {
 		ret.PageParamName,
 		ret.PerPageParamName,
 		ret.SortParamName,
+		ret.TzParamName,
 		ret.MultipleValueSeparator,
 		ret.MetadataSeparator,
 	})
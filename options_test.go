@@ -15,6 +15,7 @@ func TestFillGetOptionsDefaults(t *testing.T) {
 	assert.Equal(t, defaultPageParamName, opts.PageParamName)
 	assert.Equal(t, defaultPerPageParamName, opts.PerPageParamName)
 	assert.Equal(t, defaultSortParamName, opts.SortParamName)
+	assert.Equal(t, defaultTzParamName, opts.TzParamName)
 	assert.Equal(t, defaultMultipleValueSeparator, opts.MultipleValueSeparator)
 	assert.Equal(t, defaultMetadataSeparator, opts.MetadataSeparator)
 	assert.Equal(t, defaultPerPage, opts.DefaultPerPage)
@@ -30,6 +31,7 @@ func TestFillGetOptionsCustomValues(t *testing.T) {
 		PageParamName:          "pg",
 		PerPageParamName:       "pp",
 		SortParamName:          "srt",
+		TzParamName:            "tz",
 		MultipleValueSeparator: ",",
 		MetadataSeparator:      "~",
 		DefaultPerPage:         10,
@@ -47,6 +49,7 @@ func TestFillGetOptionsCustomValues(t *testing.T) {
 	assert.Equal(t, input.PageParamName, opts.PageParamName)
 	assert.Equal(t, input.PerPageParamName, opts.PerPageParamName)
 	assert.Equal(t, input.SortParamName, opts.SortParamName)
+	assert.Equal(t, input.TzParamName, opts.TzParamName)
 	assert.Equal(t, input.MultipleValueSeparator, opts.MultipleValueSeparator)
 	assert.Equal(t, input.MetadataSeparator, opts.MetadataSeparator)
 	assert.Equal(t, input.DefaultPerPage, opts.DefaultPerPage)
@@ -90,10 +90,26 @@ func JsonResponse(resp StdResponse, httpStatus int, w http.ResponseWriter) {
 
 // StatusWriter is a wrapper around http.ResponseWriter that captures the status code and number of bytes written in the response.
 // It implements http.Flusher and http.Hijacker so that it can also write websocket responses.
+// ErrCategory is set by the error handlers, e.g. to ErrCategoryUser.
 type StatusWriter struct {
 	http.ResponseWriter
-	Status int
-	Bytes  int
+	Status      int
+	Bytes       int
+	ErrCategory string
+}
+
+// setErrCategory sets the error category on each StatusWriter wrapped by w
+func setErrCategory(w http.ResponseWriter, category string) {
+	for w != nil {
+		if sw, ok := w.(*StatusWriter); ok {
+			sw.ErrCategory = category
+		}
+		uw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = uw.Unwrap()
+	}
 }
 
 func (sw *StatusWriter) Flush() {